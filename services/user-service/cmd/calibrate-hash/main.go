@@ -0,0 +1,44 @@
+// Command calibrate-hash measures password hashing on the current machine and
+// prints the settings that reach the target hashing time, ready to paste into
+// the service environment. Run it on hardware matching production.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"user-service/internal/password"
+)
+
+func main() {
+	algorithm := flag.String("algorithm", password.AlgorithmArgon2id, "hash algorithm to calibrate: argon2id or bcrypt")
+	target := flag.Duration("target", 250*time.Millisecond, "minimum time a single hash should take")
+	memory := flag.Uint("memory", 19456, "argon2id memory in KiB")
+	parallelism := flag.Uint("parallelism", 1, "argon2id parallelism")
+	flag.Parse()
+
+	switch *algorithm {
+	case password.AlgorithmArgon2id:
+		if *parallelism < 1 || *parallelism > 255 {
+			log.Fatal("parallelism must be between 1 and 255")
+		}
+
+		params, took := password.CalibrateArgon2id(*target, uint32(*memory), uint8(*parallelism))
+		fmt.Printf("# argon2id: %s per hash\n", took.Round(time.Millisecond))
+		fmt.Printf("PASSWORD_HASH_ALGORITHM=%s\n", password.AlgorithmArgon2id)
+		fmt.Printf("PASSWORD_ARGON2_MEMORY=%d\n", params.Memory)
+		fmt.Printf("PASSWORD_ARGON2_ITERATIONS=%d\n", params.Iterations)
+		fmt.Printf("PASSWORD_ARGON2_PARALLELISM=%d\n", params.Parallelism)
+
+	case password.AlgorithmBcrypt:
+		cost, took := password.CalibrateBcrypt(*target)
+		fmt.Printf("# bcrypt: %s per hash\n", took.Round(time.Millisecond))
+		fmt.Printf("PASSWORD_HASH_ALGORITHM=%s\n", password.AlgorithmBcrypt)
+		fmt.Printf("PASSWORD_BCRYPT_COST=%d\n", cost)
+
+	default:
+		log.Fatalf("unsupported algorithm %q", *algorithm)
+	}
+}
@@ -0,0 +1,119 @@
+// Command migrate applies and inspects the user service schema migrations
+// embedded from the migrations directory. It reads the same DB_* environment
+// and config file as the server.
+//
+//	migrate up            apply all pending migrations
+//	migrate down [N]      roll back the last N migrations (default 1)
+//	migrate status        list migrations and whether they are applied
+//	migrate force VERSION record the schema as being at VERSION without running scripts
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/database"
+	"user-service/migrations"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: migrate up | down [N] | status | force VERSION")
+		flag.PrintDefaults()
+	}
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file; environment variables override it")
+	timeout := flag.Duration("timeout", 10*time.Minute, "give up after this long, including waiting for the migration lock")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := database.Connect(cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to connect to database: ", err)
+	}
+	defer db.Close()
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		log.Fatal("Failed to load migrations: ", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch command := flag.Arg(0); command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("applied %d migration(s)\n", applied)
+
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid step count %q", flag.Arg(1))
+			}
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("rolled back %d migration(s)\n", rolledBack)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "-"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", status.Version, status.Name, status.State, appliedAt)
+		}
+		w.Flush()
+
+	case "force":
+		if flag.NArg() < 2 {
+			log.Fatal("force needs a version")
+		}
+		version, err := strconv.ParseInt(flag.Arg(1), 10, 64)
+		if err != nil || version < 0 {
+			log.Fatalf("invalid version %q", flag.Arg(1))
+		}
+		if err := migrator.Force(ctx, version); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("schema recorded at version %d\n", version)
+
+	default:
+		log.Printf("unknown command %q", command)
+		flag.Usage()
+		os.Exit(2)
+	}
+}
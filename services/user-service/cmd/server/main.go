@@ -1,13 +1,36 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"net"
+	"net/http"
 	"os"
+	"time"
 
+	"user-service/internal/buildinfo"
+	"user-service/internal/config"
+	"user-service/internal/database"
+	"user-service/internal/events"
+	"user-service/internal/grpcserver"
 	"user-service/internal/handlers"
+	"user-service/internal/health"
+	"user-service/internal/keystore"
+	"user-service/internal/lifecycle"
+	"user-service/internal/lockout"
+	"user-service/internal/mailer"
+	"user-service/internal/metrics"
 	"user-service/internal/middleware"
+	"user-service/internal/outbox"
+	"user-service/internal/password"
 	"user-service/internal/repository"
+	"user-service/internal/revocation"
+	"user-service/internal/routes"
 	"user-service/internal/services"
+	"user-service/internal/tokens"
+	"user-service/internal/webhooks"
+	"user-service/migrations"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -20,61 +43,226 @@ func main() {
 		logrus.Warn("No .env file found")
 	}
 
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML config file; environment variables override it")
+	flag.Parse()
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	gin.SetMode(cfg.Server.Mode)
+
+	app := lifecycle.New(
+		time.Duration(cfg.Server.ShutdownTimeout)*time.Second,
+		time.Duration(cfg.Server.DrainDelay)*time.Second,
+	)
+
 	// Initialize database connection
-	db, err := repository.NewPostgresConnection()
+	db, err := database.Connect(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
-	defer db.Close()
+	app.OnShutdown("postgres", db.Close)
+	metrics.RegisterDBStats(db, cfg.Database.DBName)
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		log.Fatal("Failed to load migrations:", err)
+	}
+	if cfg.Database.AutoMigrate {
+		if _, err := migrator.Up(context.Background()); err != nil {
+			log.Fatal("Failed to apply migrations:", err)
+		}
+	}
+
+	// Redis is optional at startup: revocations fall back to process memory
+	redisClient, err := repository.NewRedisConnection(cfg.Redis)
+	if err != nil {
+		logrus.WithError(err).Warn("Redis unavailable, using in-memory token revocation until it recovers")
+	}
+	app.OnShutdown("redis", redisClient.Close)
+
+	// Postgres and its schema are required to serve traffic; Redis is not,
+	// since revocations fall back to process memory without it
+	healthChecks := health.NewRegistry(
+		time.Duration(cfg.Health.CheckTimeout)*time.Second,
+		time.Duration(cfg.Health.CacheTTL)*time.Second,
+	)
+	healthChecks.Add("database", true, health.Postgres(db))
+	healthChecks.Add("migrations", true, health.Migrations(migrator))
+	healthChecks.Add("cache", false, health.Redis(redisClient))
+
+	revocations := revocation.NewFallbackStore(revocation.NewRedisStore(redisClient), revocation.NewMemoryStore())
 
 	// Initialize repositories
 	userRepo := repository.NewUserRepository(db)
+	verificationRepo := repository.NewVerificationRepository(db)
+	passwordResetRepo := repository.NewPasswordResetRepository(db)
+	mfaRepo := repository.NewMFARepository(db)
+	signingKeyRepo := repository.NewSigningKeyRepository(db)
+	sessionRepo := repository.NewSessionRepository(db)
+	passwordRepo := repository.NewPasswordRepository(db)
+	outboxRepo := repository.NewOutboxRepository(db)
+	customerStatsRepo := repository.NewCustomerStatsRepository(db)
+	webhookRepo := repository.NewWebhookRepository(db)
+	roleRepo := repository.NewRoleRepository(db)
+
+	// Load (or create) the JWT signing keys and keep them rotated
+	keyStore := keystore.NewStore(signingKeyRepo, cfg.JWT)
+	if err := keyStore.Init(); err != nil {
+		log.Fatal("Failed to initialize JWT signing keys:", err)
+	}
+	app.Go("jwt-key-rotation", keyStore.Run)
+
+	mail := mailer.New(cfg.Mail)
+
+	passwordPolicy, err := password.NewPolicy(cfg.Password)
+	if err != nil {
+		log.Fatal("Failed to initialize password policy:", err)
+	}
+	passwordHasher, err := password.NewHasher(cfg.Hash)
+	if err != nil {
+		log.Fatal("Failed to initialize password hasher:", err)
+	}
+	passwordHasher = metrics.InstrumentHasher(passwordHasher, cfg.Hash.Algorithm)
 
 	// Initialize services
-	userService := services.NewUserService(userRepo)
+	accessTTL := time.Duration(cfg.JWT.AccessTokenDuration) * time.Minute
+	refreshTTL := time.Duration(cfg.JWT.RefreshTokenDuration) * time.Hour
+	accountPolicy, ipPolicy := lockout.PoliciesFromConfig(cfg.Lockout)
+	loginGuard := lockout.NewGuard(
+		lockout.NewRedisTracker(redisClient, accountPolicy),
+		lockout.NewRedisTracker(redisClient, ipPolicy),
+	)
+	verificationService := services.NewVerificationService(userRepo, verificationRepo, mail, cfg.Auth, cfg.Mail)
+	mfaService := services.NewMFAService(userRepo, mfaRepo, cfg.MFA, cfg.Auth)
+	if encrypted, err := mfaService.EncryptStoredSecrets(); err != nil {
+		log.Fatal("Failed to encrypt stored MFA secrets:", err)
+	} else if encrypted > 0 {
+		logrus.WithField("count", encrypted).Info("Encrypted stored MFA secrets")
+	}
+	passwordService := services.NewPasswordService(userRepo, passwordRepo, revocations, loginGuard, passwordPolicy, passwordHasher, accessTTL)
+	passwordResetService := services.NewPasswordResetService(userRepo, passwordResetRepo, passwordRepo, passwordService, mail, passwordPolicy, passwordHasher, cfg.Auth, cfg.Mail)
+	sessionService := services.NewSessionService(sessionRepo, revocations, accessTTL)
+	roleService := services.NewRoleService(roleRepo, userRepo, revocations, accessTTL)
+	userService := services.NewInstrumentedUserService(services.NewUserService(userRepo, sessionRepo, verificationService, mfaService, keyStore, revocations, loginGuard, passwordPolicy, passwordHasher, services.NewLogSecurityEventEmitter(), roleService, cfg.Auth, accessTTL, refreshTTL))
 
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(userService)
+	verificationHandler := handlers.NewVerificationHandler(verificationService)
+	passwordResetHandler := handlers.NewPasswordResetHandler(passwordResetService)
+	passwordHandler := handlers.NewPasswordHandler(passwordService)
+	mfaHandler := handlers.NewMFAHandler(mfaService, userService)
+	keysHandler := handlers.NewKeysHandler(keyStore)
+	sessionHandler := handlers.NewSessionHandler(sessionService)
+	customerStatsService := services.NewCustomerStatsService(customerStatsRepo, time.Duration(cfg.Consumer.DedupeRetention)*time.Hour)
+	adminHandler := handlers.NewAdminHandler(userService, customerStatsService)
+	outboxHandler := handlers.NewOutboxHandler(services.NewOutboxService(outboxRepo))
+	webhookHandler := handlers.NewWebhookHandler(services.NewWebhookService(webhookRepo, cfg.Webhooks))
+	roleHandler := handlers.NewRoleHandler(roleService)
+
+	// Publish user events written to the outbox. Events wait in Postgres
+	// while Redis is down and go out once it is back.
+	if cfg.Outbox.Enabled {
+		publisher := events.NewRedisPublisher(redisClient, cfg.Outbox.StreamPrefix, int64(cfg.Outbox.StreamMaxLen))
+		app.Go("outbox-relay", outbox.NewRelay(outboxRepo, publisher, cfg.Outbox).Run)
+	}
+
+	// Send user events to partner webhooks. Deliveries are queued with the
+	// user change and wait in Postgres until a dispatcher sends them.
+	if cfg.Webhooks.Enabled {
+		app.Go("webhook-dispatcher", webhooks.NewDispatcher(webhookRepo, cfg.Webhooks).Run)
+	}
+
+	// Mail password reset links outside the request
+	app.Go("password-reset-mailer", passwordResetService.SendResetEmails)
+
+	// Keep customer statistics from order and payment events
+	if cfg.Consumer.Enabled {
+		streams := make([]string, 0, len(services.CustomerStatsEvents))
+		for _, eventType := range services.CustomerStatsEvents {
+			streams = append(streams, cfg.Consumer.StreamPrefix+eventType)
+		}
+		consumerName := cfg.Consumer.Name
+		if consumerName == "" {
+			consumerName, _ = os.Hostname()
+		}
+		consumer := events.NewRedisConsumer(redisClient, events.RedisConsumerOptions{
+			Group:     cfg.Consumer.Group,
+			Consumer:  consumerName,
+			Streams:   streams,
+			BatchSize: int64(cfg.Consumer.BatchSize),
+			Block:     time.Duration(cfg.Consumer.Block) * time.Millisecond,
+			ClaimIdle: time.Duration(cfg.Consumer.ClaimIdle) * time.Second,
+		}, customerStatsService.HandleEvent)
+		app.Go("event-consumer", consumer.Run)
+		app.Go("processed-events-purge", customerStatsService.PurgeProcessedEvents)
+	}
 
 	// Setup Gin router
 	router := gin.Default()
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatal("Invalid trusted proxies:", err)
+	}
 
 	// Add middleware
-	router.Use(middleware.CORSMiddleware())
+	router.Use(middleware.CORSMiddleware(cfg.Server.AllowedOrigins))
 	router.Use(middleware.LoggingMiddleware())
+	router.Use(middleware.MetricsMiddleware())
 	router.Use(middleware.RecoveryMiddleware())
 
-	// Health check endpoint
-	router.GET("/health", handlers.HealthCheck)
-	router.GET("/metrics", handlers.MetricsHandler)
-
-	// API routes
-	v1 := router.Group("/api/v1")
-	{
-		// Public routes
-		v1.POST("/auth/register", userHandler.Register)
-		v1.POST("/auth/login", userHandler.Login)
-		v1.POST("/auth/refresh", userHandler.RefreshToken)
-
-		// Protected routes
-		protected := v1.Group("/")
-		protected.Use(middleware.JWTAuthMiddleware())
-		{
-			protected.GET("/users/profile", userHandler.GetProfile)
-			protected.PUT("/users/profile", userHandler.UpdateProfile)
-			protected.DELETE("/users/profile", userHandler.DeleteAccount)
-			protected.GET("/users/:id", userHandler.GetUserByID)
+	tokenValidator := tokens.NewValidator(keyStore, revocations, passwordService)
+	authMiddleware := middleware.NewAuthMiddleware(tokenValidator, roleService)
+	serviceKeys := middleware.NewServiceKeys(cfg.Auth.ServiceKeys)
+	introspectionService := services.NewIntrospectionService(tokenValidator, userRepo, time.Duration(cfg.Auth.IntrospectionCacheTTL)*time.Second)
+
+	routes.SetupRoutes(router, routes.Handlers{
+		Health:        handlers.NewHealthHandler(healthChecks, app.Ready),
+		User:          userHandler,
+		Verification:  verificationHandler,
+		PasswordReset: passwordResetHandler,
+		Password:      passwordHandler,
+		MFA:           mfaHandler,
+		Keys:          keysHandler,
+		Sessions:      sessionHandler,
+		Admin:         adminHandler,
+		Introspection: handlers.NewIntrospectionHandler(introspectionService),
+		Outbox:        outboxHandler,
+		Webhooks:      webhookHandler,
+		Roles:         roleHandler,
+	}, authMiddleware, serviceKeys)
+
+	if cfg.Metrics.Enabled {
+		if cfg.Metrics.Port == "" {
+			router.GET(cfg.Metrics.Path, gin.WrapH(metrics.Handler()))
+		} else {
+			mux := http.NewServeMux()
+			mux.Handle(cfg.Metrics.Path, metrics.Handler())
+			app.AddHTTPServer("metrics", &http.Server{
+				Addr:              net.JoinHostPort(cfg.Server.Host, cfg.Metrics.Port),
+				Handler:           mux,
+				ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout) * time.Second,
+			})
 		}
 	}
 
-	// Start server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8001"
+	if cfg.GRPC.Enabled {
+		grpcServer := grpcserver.New(cfg.GRPC, cfg.Server.Host, serviceKeys, userService, tokenValidator)
+		app.AddServer("grpc", grpcServer.ListenAndServe, grpcServer.Shutdown)
 	}
 
-	logrus.Infof("User Service starting on port %s", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Failed to start server:", err)
+	app.AddHTTPServer("http", &http.Server{
+		Addr:              net.JoinHostPort(cfg.Server.Host, cfg.Server.Port),
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout) * time.Second,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout) * time.Second,
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout) * time.Second,
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout) * time.Second,
+	})
+
+	// Run until SIGINT/SIGTERM, then drain and close everything in order
+	logrus.Infof("User Service %s (%s) starting on port %s", buildinfo.Version, buildinfo.Commit, cfg.Server.Port)
+	if err := app.Run(context.Background()); err != nil {
+		log.Fatal("Server stopped with error:", err)
 	}
 }
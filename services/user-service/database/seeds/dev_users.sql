@@ -0,0 +1,7 @@
+-- Sample users for local development. Run after `go run ./cmd/migrate up`:
+--   psql -f database/seeds/dev_users.sql
+-- Password is 'password123' hashed with bcrypt
+INSERT INTO users (email, username, password_hash, first_name, last_name, phone, is_verified) VALUES
+('john.doe@example.com', 'johndoe', '$2a$10$N9qo8uLOickgx2ZMRZoMye1Jrq/zAG6Q/DKOJcGdFGWBDJpE1Y2.2', 'John', 'Doe', '+1234567890', true),
+('jane.smith@example.com', 'janesmith', '$2a$10$N9qo8uLOickgx2ZMRZoMye1Jrq/zAG6Q/DKOJcGdFGWBDJpE1Y2.2', 'Jane', 'Smith', '+1234567891', true)
+ON CONFLICT (email) DO NOTHING;
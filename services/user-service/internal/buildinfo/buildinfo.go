@@ -0,0 +1,32 @@
+// Package buildinfo describes the running binary. The variables are set at
+// link time:
+//
+//	go build -ldflags "-X user-service/internal/buildinfo.Version=1.4.0 \
+//	  -X user-service/internal/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//	  -X user-service/internal/buildinfo.BuildTime=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import "runtime"
+
+var (
+	Version   = "dev"
+	Commit    = "unknown"
+	BuildTime = "unknown"
+)
+
+// Info is the build description reported by health endpoints.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildTime: BuildTime,
+		GoVersion: runtime.Version(),
+	}
+}
@@ -1,107 +1,381 @@
+// Package config holds the service settings. They are layered: Default
+// values, then an optional YAML or TOML file, then environment variables.
+// Every setting has a file key (its key tag, nested under its section's key)
+// and one or more environment variables (its env tag; the first one set
+// wins). A variable can also be given as NAME_FILE, naming a file whose
+// contents are the value, so secrets can be mounted rather than exported.
 package config
 
-import (
-	"log"
-	"os"
-	"strconv"
-)
-
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Redis    RedisConfig
+	Server   ServerConfig         `key:"server"`
+	Database DatabaseConfig       `key:"database"`
+	JWT      JWTConfig            `key:"jwt"`
+	Redis    RedisConfig          `key:"redis"`
+	Auth     AuthConfig           `key:"auth"`
+	Mail     MailConfig           `key:"mail"`
+	MFA      MFAConfig            `key:"mfa"`
+	Lockout  LockoutConfig        `key:"lockout"`
+	Password PasswordPolicyConfig `key:"password"`
+	Hash     PasswordHashConfig   `key:"hash"`
+	Health   HealthConfig         `key:"health"`
+	Metrics  MetricsConfig        `key:"metrics"`
+	GRPC     GRPCConfig           `key:"grpc"`
+	Outbox   OutboxConfig         `key:"outbox"`
+	Consumer ConsumerConfig       `key:"consumer"`
+	Webhooks WebhooksConfig       `key:"webhooks"`
 }
 
 type ServerConfig struct {
-	Port string
-	Host string
-	Mode string
+	Port string `key:"port" env:"SERVER_PORT,PORT"`
+	Host string `key:"host" env:"SERVER_HOST"`
+	Mode string `key:"mode" env:"GIN_MODE"`
+	// AllowedOrigins lists the origins CORS responses allow; "*" allows any.
+	AllowedOrigins []string `key:"allowed_origins" env:"CORS_ALLOWED_ORIGINS"`
+	// TrustedProxies lists the proxy IPs or CIDRs whose X-Forwarded-For and
+	// X-Real-IP headers are believed. Empty trusts none, so the client IP is
+	// the address of the TCP peer.
+	TrustedProxies    []string `key:"trusted_proxies" env:"SERVER_TRUSTED_PROXIES"`
+	ReadHeaderTimeout int      `key:"read_header_timeout" env:"SERVER_READ_HEADER_TIMEOUT"` // seconds
+	ReadTimeout       int      `key:"read_timeout" env:"SERVER_READ_TIMEOUT"`               // seconds
+	WriteTimeout      int      `key:"write_timeout" env:"SERVER_WRITE_TIMEOUT"`             // seconds
+	IdleTimeout       int      `key:"idle_timeout" env:"SERVER_IDLE_TIMEOUT"`               // seconds
+	// DrainDelay is how long (seconds) /health fails before shutdown starts,
+	// giving load balancers time to stop routing new requests here.
+	DrainDelay int `key:"drain_delay" env:"SERVER_DRAIN_DELAY"`
+	// ShutdownTimeout bounds (seconds) finishing in-flight requests and
+	// closing connections once shutdown starts.
+	ShutdownTimeout int `key:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT"`
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     int
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Host     string `key:"host" env:"DB_HOST"`
+	Port     int    `key:"port" env:"DB_PORT"`
+	User     string `key:"user" env:"DB_USER"`
+	Password string `key:"password" env:"DB_PASSWORD"`
+	DBName   string `key:"name" env:"DB_NAME"`
+	SSLMode  string `key:"ssl_mode" env:"DB_SSL_MODE,DB_SSLMODE"`
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate  bool `key:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	MaxOpenConns int  `key:"max_open_conns" env:"DB_MAX_OPEN_CONNS"`
+	MaxIdleConns int  `key:"max_idle_conns" env:"DB_MAX_IDLE_CONNS"`
 }
 
 type JWTConfig struct {
-	SecretKey            string
-	AccessTokenDuration  int // minutes
-	RefreshTokenDuration int // hours
+	SecretKey            string `key:"secret" env:"JWT_SECRET"`
+	AccessTokenDuration  int    `key:"access_duration" env:"JWT_ACCESS_DURATION"`   // minutes
+	RefreshTokenDuration int    `key:"refresh_duration" env:"JWT_REFRESH_DURATION"` // hours
+	// Algorithm is the asymmetric algorithm new signing keys use: RS256 or EdDSA.
+	Algorithm           string `key:"algorithm" env:"JWT_ALGORITHM"`
+	KeyRotationInterval int    `key:"key_rotation_interval" env:"JWT_KEY_ROTATION_INTERVAL"` // hours
+	// KeyVerificationGrace is how long a retired key keeps verifying; it must
+	// outlive the longest access token signed with it.
+	KeyVerificationGrace int `key:"key_verification_grace" env:"JWT_KEY_VERIFICATION_GRACE"` // hours
+	KeyRefreshInterval   int `key:"key_refresh_interval" env:"JWT_KEY_REFRESH_INTERVAL"`     // minutes
 }
 
 type RedisConfig struct {
-	Host     string
-	Port     string
-	Password string
-	DB       int
-}
-
-func LoadConfig() *Config {
-    return &Config{
-        Database: DatabaseConfig{
-            Host:     getEnv("DB_HOST", "postgres"),        // Đổi từ "localhost" thành "postgres"
-            Port:     getEnvAsInt("DB_PORT", 5432),         // Đổi từ 5432 thành 5432
-            User:     getEnv("DB_USER", "postgres"),
-            Password: getEnv("DB_PASSWORD", "password"),     // Đổi từ "postgres" thành "password"
-            DBName:   getEnv("DB_NAME", "user_db"),         // Đổi từ "user_service_db" thành "user_db"
-            SSLMode:  getEnv("DB_SSL_MODE", "disable"),
-        },
-        Redis: RedisConfig{
-            Host:     getEnv("REDIS_HOST", "redis"),        // Đổi từ "localhost" thành "redis"
-            Port:     getEnv("REDIS_PORT", "6379"),
-            Password: getEnv("REDIS_PASSWORD", "password"), // Thêm password
-            DB:       getEnvAsInt("REDIS_DB", 0),
-        },
-    }
-}
-
-// func LoadConfig() *Config {
-// 	return &Config{
-// 		Server: ServerConfig{
-// 			Port: getEnv("SERVER_PORT", "8001"),
-// 			Host: getEnv("SERVER_HOST", "0.0.0.0"),
-// 			Mode: getEnv("GIN_MODE", "debug"),
-// 		},
-// 		Database: DatabaseConfig{
-// 			Host:     getEnv("DB_HOST", "localhost"),
-// 			Port:     getEnvAsInt("DB_PORT", 5432),
-// 			User:     getEnv("DB_USER", "postgres"),
-// 			Password: getEnv("DB_PASSWORD", "postgres"),
-// 			DBName:   getEnv("DB_NAME", "user_service_db"),
-// 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
-// 		},
-// 		JWT: JWTConfig{
-// 			SecretKey:            getEnv("JWT_SECRET", "your-super-secret-key-change-in-production"),
-// 			AccessTokenDuration:  getEnvAsInt("JWT_ACCESS_DURATION", 15),  // 15 minutes
-// 			RefreshTokenDuration: getEnvAsInt("JWT_REFRESH_DURATION", 24), // 24 hours
-// 		},
-// 		Redis: RedisConfig{
-// 			Host:     getEnv("REDIS_HOST", "localhost"),
-// 			Port:     getEnv("REDIS_PORT", "6379"),
-// 			Password: getEnv("REDIS_PASSWORD", ""),
-// 			DB:       getEnvAsInt("REDIS_DB", 0),
-// 		},
-// 	}
-// }
-
-func getEnv(key, defaultValue string) string {
-	if value := os.Getenv(key); value != "" {
-		return value
-	}
-	return defaultValue
+	Host     string `key:"host" env:"REDIS_HOST"`
+	Port     string `key:"port" env:"REDIS_PORT"`
+	Password string `key:"password" env:"REDIS_PASSWORD"`
+	DB       int    `key:"db" env:"REDIS_DB"`
+}
+
+type AuthConfig struct {
+	// RequireEmailVerification makes Login refuse accounts whose email has not been confirmed.
+	RequireEmailVerification bool `key:"require_email_verification" env:"AUTH_REQUIRE_EMAIL_VERIFICATION"`
+	VerificationTokenTTL     int  `key:"verification_token_ttl" env:"AUTH_VERIFICATION_TOKEN_TTL"`       // hours
+	VerificationResendDelay  int  `key:"verification_resend_delay" env:"AUTH_VERIFICATION_RESEND_DELAY"` // seconds
+	PasswordResetTokenTTL    int  `key:"password_reset_token_ttl" env:"AUTH_PASSWORD_RESET_TOKEN_TTL"`   // minutes
+	// PasswordResetResendDelay is how long (seconds) after a reset link
+	// further requests for the same address are ignored.
+	PasswordResetResendDelay int `key:"password_reset_resend_delay" env:"AUTH_PASSWORD_RESET_RESEND_DELAY"`
+	// TokenSigningSecret signs the opaque tokens mailed to users (verification,
+	// reset, ...). It falls back to the JWT secret when unset.
+	TokenSigningSecret string `key:"token_signing_secret" env:"AUTH_TOKEN_SIGNING_SECRET"`
+	// ServiceKeys authenticate other services: they send one as the
+	// X-Service-Key header (HTTP) or x-service-key metadata (gRPC).
+	ServiceKeys []string `key:"service_keys" env:"SERVICE_KEYS,GRPC_SERVICE_KEYS"`
+	// IntrospectionCacheTTL is how long (seconds) token introspection results
+	// are reused; a revocation can take this long to show.
+	IntrospectionCacheTTL int `key:"introspection_cache_ttl" env:"AUTH_INTROSPECTION_CACHE_TTL"`
+}
+
+type MFAConfig struct {
+	Issuer string `key:"issuer" env:"MFA_ISSUER"`
+	// RequiredRoles lists the roles that cannot sign in without a second factor.
+	RequiredRoles     []string `key:"required_roles" env:"MFA_REQUIRED_ROLES"`
+	ChallengeTTL      int      `key:"challenge_ttl" env:"MFA_CHALLENGE_TTL"` // minutes
+	RecoveryCodeCount int      `key:"recovery_code_count" env:"MFA_RECOVERY_CODE_COUNT"`
+	// EncryptionKey encrypts TOTP secrets at rest. Secrets stored under a
+	// previous key can no longer be read, so it is never rotated in place.
+	EncryptionKey string `key:"encryption_key" env:"MFA_ENCRYPTION_KEY"`
+}
+
+// LockoutConfig throttles failed logins per account and per client IP.
+type LockoutConfig struct {
+	// FreeAttempts failures are allowed before back-off delays kick in.
+	FreeAttempts int `key:"free_attempts" env:"LOGIN_BACKOFF_FREE_ATTEMPTS"`
+	BaseDelay    int `key:"base_delay" env:"LOGIN_BACKOFF_BASE_DELAY"`     // seconds, doubled for every further failure
+	MaxDelay     int `key:"max_delay" env:"LOGIN_BACKOFF_MAX_DELAY"`       // seconds
+	Threshold    int `key:"threshold" env:"LOGIN_LOCKOUT_THRESHOLD"`       // failures per account before a lockout
+	IPThreshold  int `key:"ip_threshold" env:"LOGIN_LOCKOUT_IP_THRESHOLD"` // failures per client IP before a lockout
+	Duration     int `key:"duration" env:"LOGIN_LOCKOUT_DURATION"`         // minutes
+	// Window is how long (minutes) failures are remembered after the last one.
+	Window int `key:"window" env:"LOGIN_ATTEMPT_WINDOW"`
+}
+
+// PasswordPolicyConfig sets the rules for new passwords.
+type PasswordPolicyConfig struct {
+	MinLength      int  `key:"min_length" env:"PASSWORD_MIN_LENGTH"`
+	MaxLength      int  `key:"max_length" env:"PASSWORD_MAX_LENGTH"` // bytes; bcrypt ignores anything past 72
+	RequireUpper   bool `key:"require_uppercase" env:"PASSWORD_REQUIRE_UPPERCASE"`
+	RequireLower   bool `key:"require_lowercase" env:"PASSWORD_REQUIRE_LOWERCASE"`
+	RequireDigit   bool `key:"require_digit" env:"PASSWORD_REQUIRE_DIGIT"`
+	RequireSymbol  bool `key:"require_symbol" env:"PASSWORD_REQUIRE_SYMBOL"`
+	MinCharClasses int  `key:"min_char_classes" env:"PASSWORD_MIN_CHAR_CLASSES"`
+	CheckBreached  bool `key:"check_breached" env:"PASSWORD_CHECK_BREACHED"`
+	// BreachedListFile overrides the bundled list of SHA-1 hashes of breached passwords.
+	BreachedListFile string `key:"breached_list_file" env:"PASSWORD_BREACHED_LIST_FILE"`
+	// HistorySize is how many previous passwords a user may not reuse.
+	HistorySize int `key:"history_size" env:"PASSWORD_HISTORY_SIZE"`
+}
+
+// PasswordHashConfig selects how new password hashes are made. Stored hashes
+// made with another algorithm or weaker parameters are upgraded on login.
+type PasswordHashConfig struct {
+	Algorithm         string `key:"algorithm" env:"PASSWORD_HASH_ALGORITHM"` // argon2id or bcrypt
+	BcryptCost        int    `key:"bcrypt_cost" env:"PASSWORD_BCRYPT_COST"`
+	Argon2Memory      int    `key:"argon2_memory" env:"PASSWORD_ARGON2_MEMORY"` // KiB
+	Argon2Iterations  int    `key:"argon2_iterations" env:"PASSWORD_ARGON2_ITERATIONS"`
+	Argon2Parallelism int    `key:"argon2_parallelism" env:"PASSWORD_ARGON2_PARALLELISM"`
+}
+
+type MailConfig struct {
+	SMTPHost     string `key:"smtp_host" env:"SMTP_HOST"`
+	SMTPPort     int    `key:"smtp_port" env:"SMTP_PORT"`
+	SMTPUsername string `key:"smtp_username" env:"SMTP_USERNAME"`
+	SMTPPassword string `key:"smtp_password" env:"SMTP_PASSWORD"`
+	From         string `key:"from" env:"MAIL_FROM"`
+	// AppBaseURL is the storefront URL that links in emails point to.
+	AppBaseURL string `key:"app_base_url" env:"APP_BASE_URL"`
+}
+
+type HealthConfig struct {
+	// CheckTimeout bounds (seconds) each dependency check.
+	CheckTimeout int `key:"check_timeout" env:"HEALTH_CHECK_TIMEOUT"`
+	// CacheTTL is how long (seconds) check results are reused, so frequent
+	// probes don't each hit the database.
+	CacheTTL int `key:"cache_ttl" env:"HEALTH_CACHE_TTL"`
+}
+
+type MetricsConfig struct {
+	Enabled bool   `key:"enabled" env:"METRICS_ENABLED"`
+	Path    string `key:"path" env:"METRICS_PATH"`
+	// Port, when set, serves metrics on a separate admin listener instead of
+	// the public router, so they can be kept off the internet.
+	Port string `key:"port" env:"METRICS_PORT"`
+}
+
+type GRPCConfig struct {
+	Enabled bool   `key:"enabled" env:"GRPC_ENABLED"`
+	Port    string `key:"port" env:"GRPC_PORT"`
+	// Reflection lets tools such as grpcurl discover the services.
+	Reflection bool `key:"reflection" env:"GRPC_REFLECTION"`
+}
+
+// OutboxConfig drives the relay that publishes user events from the outbox
+// table to Redis Streams.
+type OutboxConfig struct {
+	// Enabled runs the relay in this process. Events are written to the
+	// outbox either way and wait there until a relay publishes them.
+	Enabled bool `key:"enabled" env:"OUTBOX_ENABLED"`
+	// StreamPrefix is prepended to the event type to name the stream, e.g.
+	// "events:user.created".
+	StreamPrefix string `key:"stream_prefix" env:"OUTBOX_STREAM_PREFIX"`
+	// StreamMaxLen caps each stream (approximately); 0 leaves it unbounded.
+	StreamMaxLen int `key:"stream_max_len" env:"OUTBOX_STREAM_MAX_LEN"`
+	PollInterval int `key:"poll_interval" env:"OUTBOX_POLL_INTERVAL"` // milliseconds
+	BatchSize    int `key:"batch_size" env:"OUTBOX_BATCH_SIZE"`
+	// MaxAttempts is how many times an event is tried before it moves to the
+	// dead letters.
+	MaxAttempts    int `key:"max_attempts" env:"OUTBOX_MAX_ATTEMPTS"`
+	RetryBaseDelay int `key:"retry_base_delay" env:"OUTBOX_RETRY_BASE_DELAY"` // seconds, doubled per attempt
+	RetryMaxDelay  int `key:"retry_max_delay" env:"OUTBOX_RETRY_MAX_DELAY"`   // seconds
+	// Retention is how long (hours) published events are kept before they
+	// are deleted.
+	Retention int `key:"retention" env:"OUTBOX_RETENTION"`
+}
+
+// ConsumerConfig drives the consumer of order and payment events that keeps
+// customer statistics.
+type ConsumerConfig struct {
+	Enabled bool `key:"enabled" env:"CONSUMER_ENABLED"`
+	// Group is the Redis consumer group; replicas sharing it split the events.
+	Group string `key:"group" env:"CONSUMER_GROUP"`
+	// Name identifies this replica in the group. It defaults to the host name,
+	// which must then be stable across restarts for pending events to resume.
+	Name         string `key:"name" env:"CONSUMER_NAME"`
+	StreamPrefix string `key:"stream_prefix" env:"CONSUMER_STREAM_PREFIX"`
+	BatchSize    int    `key:"batch_size" env:"CONSUMER_BATCH_SIZE"`
+	// Block is how long (milliseconds) a read waits for events; it also
+	// bounds how long the consumer takes to stop.
+	Block int `key:"block" env:"CONSUMER_BLOCK"`
+	// ClaimIdle is how long (seconds) an unacknowledged event waits before it
+	// is delivered again.
+	ClaimIdle int `key:"claim_idle" env:"CONSUMER_CLAIM_IDLE"`
+	// DedupeRetention is how long (hours) processed event IDs are kept to
+	// ignore redeliveries.
+	DedupeRetention int `key:"dedupe_retention" env:"CONSUMER_DEDUPE_RETENTION"`
+}
+
+// WebhooksConfig drives the dispatcher that sends user events to partner
+// webhook subscriptions.
+type WebhooksConfig struct {
+	// Enabled runs the dispatcher in this process. Deliveries are queued
+	// either way and wait until a dispatcher sends them.
+	Enabled      bool `key:"enabled" env:"WEBHOOKS_ENABLED"`
+	PollInterval int  `key:"poll_interval" env:"WEBHOOKS_POLL_INTERVAL"` // milliseconds
+	BatchSize    int  `key:"batch_size" env:"WEBHOOKS_BATCH_SIZE"`
+	Timeout      int  `key:"timeout" env:"WEBHOOKS_TIMEOUT"` // seconds per request
+	// MaxAttempts is how many times a delivery is tried before it is marked
+	// failed; it can still be replayed.
+	MaxAttempts    int `key:"max_attempts" env:"WEBHOOKS_MAX_ATTEMPTS"`
+	RetryBaseDelay int `key:"retry_base_delay" env:"WEBHOOKS_RETRY_BASE_DELAY"` // seconds, doubled per attempt
+	RetryMaxDelay  int `key:"retry_max_delay" env:"WEBHOOKS_RETRY_MAX_DELAY"`   // seconds
+	// DisableAfter is how many failed attempts in a row, across deliveries,
+	// disable a subscription until an administrator re-enables it.
+	DisableAfter int `key:"disable_after" env:"WEBHOOKS_DISABLE_AFTER"`
+	// Retention is how long (hours) finished deliveries and their attempt
+	// logs are kept.
+	Retention int `key:"retention" env:"WEBHOOKS_RETENTION"`
+	// AllowPrivateTargets accepts plain http endpoints on loopback, private
+	// and link-local addresses. It is meant for local development only.
+	AllowPrivateTargets bool `key:"allow_private_targets" env:"WEBHOOKS_ALLOW_PRIVATE_TARGETS"`
 }
 
-func getEnvAsInt(key string, defaultValue int) int {
-	if value := os.Getenv(key); value != "" {
-		if intValue, err := strconv.Atoi(value); err == nil {
-			return intValue
-		}
-		log.Printf("Warning: Invalid integer value for %s, using default %d", key, defaultValue)
+// Default returns the settings used when neither the config file nor the
+// environment says otherwise.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:              "8001",
+			Host:              "0.0.0.0",
+			Mode:              "debug",
+			AllowedOrigins:    []string{"*"},
+			ReadHeaderTimeout: 5,
+			ReadTimeout:       15,
+			WriteTimeout:      30,
+			IdleTimeout:       120,
+			DrainDelay:        5,
+			ShutdownTimeout:   30,
+		},
+		JWT: JWTConfig{
+			AccessTokenDuration:  15,
+			RefreshTokenDuration: 24,
+			Algorithm:            "RS256",
+			KeyRotationInterval:  720,
+			KeyVerificationGrace: 48,
+			KeyRefreshInterval:   5,
+		},
+		Database: DatabaseConfig{
+			Host:         "postgres",
+			Port:         5432,
+			User:         "postgres",
+			Password:     "password",
+			DBName:       "user_db",
+			SSLMode:      "disable",
+			MaxOpenConns: 25,
+			MaxIdleConns: 10,
+		},
+		Redis: RedisConfig{
+			Host:     "redis",
+			Port:     "6379",
+			Password: "password",
+		},
+		Auth: AuthConfig{
+			VerificationTokenTTL:     48,
+			VerificationResendDelay:  60,
+			PasswordResetTokenTTL:    30,
+			PasswordResetResendDelay: 60,
+			IntrospectionCacheTTL:    10,
+		},
+		Mail: MailConfig{
+			SMTPPort:   587,
+			From:       "no-reply@stationery.local",
+			AppBaseURL: "http://localhost:3000",
+		},
+		MFA: MFAConfig{
+			Issuer:            "Stationery",
+			RequiredRoles:     []string{"admin", "moderator"},
+			ChallengeTTL:      5,
+			RecoveryCodeCount: 10,
+		},
+		Lockout: LockoutConfig{
+			FreeAttempts: 3,
+			BaseDelay:    1,
+			MaxDelay:     30,
+			Threshold:    10,
+			IPThreshold:  50,
+			Duration:     15,
+			Window:       60,
+		},
+		Password: PasswordPolicyConfig{
+			MinLength:      8,
+			MaxLength:      72,
+			MinCharClasses: 2,
+			CheckBreached:  true,
+			HistorySize:    5,
+		},
+		Hash: PasswordHashConfig{
+			Algorithm:         "argon2id",
+			BcryptCost:        10,
+			Argon2Memory:      19456,
+			Argon2Iterations:  2,
+			Argon2Parallelism: 1,
+		},
+		Health: HealthConfig{
+			CheckTimeout: 2,
+			CacheTTL:     5,
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+			Path:    "/metrics",
+		},
+		GRPC: GRPCConfig{
+			Enabled:    true,
+			Port:       "9001",
+			Reflection: true,
+		},
+		Outbox: OutboxConfig{
+			Enabled:        true,
+			StreamPrefix:   "events:",
+			StreamMaxLen:   100000,
+			PollInterval:   1000,
+			BatchSize:      100,
+			MaxAttempts:    10,
+			RetryBaseDelay: 5,
+			RetryMaxDelay:  900,
+			Retention:      168,
+		},
+		Consumer: ConsumerConfig{
+			Enabled:         true,
+			Group:           "user-service",
+			StreamPrefix:    "events:",
+			BatchSize:       50,
+			Block:           2000,
+			ClaimIdle:       60,
+			DedupeRetention: 720,
+		},
+		Webhooks: WebhooksConfig{
+			Enabled:        true,
+			PollInterval:   1000,
+			BatchSize:      20,
+			Timeout:        10,
+			MaxAttempts:    8,
+			RetryBaseDelay: 30,
+			RetryMaxDelay:  3600,
+			DisableAfter:   20,
+			Retention:      720,
+		},
 	}
-	return defaultValue
 }
@@ -0,0 +1,212 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// setting is one leaf field of Config together with where it can be set from.
+type setting struct {
+	key   string // dotted file key, e.g. "database.host"
+	env   []string
+	value reflect.Value
+}
+
+// Load builds the configuration from Default, the file at path (skipped when
+// path is empty) and the environment, then validates it. All problems found
+// along the way are reported together in a single *ValidationError.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	settings := settingsOf(reflect.ValueOf(cfg).Elem(), "")
+
+	var problems []string
+	if path != "" {
+		problems = append(problems, loadFile(settings, path)...)
+	}
+	problems = append(problems, loadEnv(settings)...)
+
+	if cfg.Auth.TokenSigningSecret == "" {
+		cfg.Auth.TokenSigningSecret = cfg.JWT.SecretKey
+	}
+
+	problems = append(problems, cfg.problems()...)
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+func settingsOf(v reflect.Value, prefix string) []setting {
+	var settings []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		key := field.Tag.Get("key")
+		if key == "" {
+			continue
+		}
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			settings = append(settings, settingsOf(v.Field(i), key)...)
+			continue
+		}
+
+		var env []string
+		if tag := field.Tag.Get("env"); tag != "" {
+			env = strings.Split(tag, ",")
+		}
+		settings = append(settings, setting{key: key, env: env, value: v.Field(i)})
+	}
+	return settings
+}
+
+func loadFile(settings []setting, path string) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{fmt.Sprintf("config file: %v", err)}
+	}
+
+	tree := make(map[string]interface{})
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return []string{fmt.Sprintf("config file %s: unsupported format %q (use .yaml, .yml or .toml)", path, ext)}
+	}
+	if err != nil {
+		return []string{fmt.Sprintf("config file %s: %v", path, err)}
+	}
+
+	values := make(map[string]interface{})
+	flatten(tree, "", values)
+
+	var problems []string
+	for _, s := range settings {
+		raw, ok := values[s.key]
+		if !ok {
+			continue
+		}
+		delete(values, s.key)
+		if err := setFromFile(s.value, raw); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s: %v", path, s.key, err))
+		}
+	}
+
+	unknown := make([]string, 0, len(values))
+	for key := range values {
+		unknown = append(unknown, key)
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("%s: %s: unknown setting", path, key))
+	}
+
+	return problems
+}
+
+// flatten turns nested sections into dotted keys.
+func flatten(tree map[string]interface{}, prefix string, out map[string]interface{}) {
+	for key, value := range tree {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if section, ok := value.(map[string]interface{}); ok {
+			flatten(section, key, out)
+			continue
+		}
+		out[key] = value
+	}
+}
+
+func setFromFile(v reflect.Value, raw interface{}) error {
+	if items, ok := raw.([]interface{}); ok {
+		if v.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			list = append(list, fmt.Sprint(item))
+		}
+		v.Set(reflect.ValueOf(list))
+		return nil
+	}
+	return set(v, fmt.Sprint(raw))
+}
+
+// loadEnv applies NAME or NAME_FILE for every setting. An empty variable
+// counts as unset, except for lists where it means an empty list.
+func loadEnv(settings []setting) []string {
+	var problems []string
+	for _, s := range settings {
+		for _, name := range s.env {
+			value, ok := os.LookupEnv(name)
+			if ok && value == "" && s.value.Kind() != reflect.Slice {
+				ok = false
+			}
+
+			if file, fromFile := os.LookupEnv(name + "_FILE"); fromFile && file != "" {
+				if ok {
+					problems = append(problems, fmt.Sprintf("%s and %s_FILE are both set", name, name))
+					break
+				}
+				data, err := os.ReadFile(file)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s_FILE: %v", name, err))
+					break
+				}
+				value, ok = strings.TrimRight(string(data), "\r\n"), true
+			}
+
+			if !ok {
+				continue
+			}
+			if err := set(s.value, value); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+			}
+			break
+		}
+	}
+	return problems
+}
+
+func set(v reflect.Value, raw string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
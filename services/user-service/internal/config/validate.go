@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// minSecretLength is the shortest accepted HMAC secret, in bytes.
+const minSecretLength = 32
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Validate checks the settings for values the service cannot run with.
+func (c *Config) Validate() error {
+	if problems := c.problems(); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func (c *Config) problems() []string {
+	var p problems
+
+	p.check(validPort(c.Server.Port), "server.port must be a port number, got %q", c.Server.Port)
+	p.check(c.Server.ReadHeaderTimeout > 0 && c.Server.ReadTimeout > 0 && c.Server.WriteTimeout > 0 && c.Server.IdleTimeout > 0,
+		"server read/write/idle timeouts must be positive")
+	p.check(c.Server.DrainDelay >= 0, "server.drain_delay must not be negative")
+	p.check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	p.check(oneOf(c.Server.Mode, "debug", "release", "test"), "server.mode must be debug, release or test, got %q", c.Server.Mode)
+	for _, proxy := range c.Server.TrustedProxies {
+		p.check(validIPOrCIDR(proxy), "server.trusted_proxies: %q is not an IP address or CIDR", proxy)
+	}
+
+	p.check(c.Database.Host != "", "database.host is required")
+	p.check(c.Database.Port > 0 && c.Database.Port <= 65535, "database.port must be a port number, got %d", c.Database.Port)
+	p.check(c.Database.User != "", "database.user is required")
+	p.check(c.Database.DBName != "", "database.name is required")
+	p.check(oneOf(c.Database.SSLMode, "disable", "allow", "prefer", "require", "verify-ca", "verify-full"),
+		"database.ssl_mode %q is not a libpq sslmode", c.Database.SSLMode)
+	p.check(c.Database.MaxOpenConns >= 0 && c.Database.MaxIdleConns >= 0, "database connection limits must not be negative")
+
+	p.check(oneOf(c.JWT.Algorithm, "RS256", "EdDSA"), "jwt.algorithm must be RS256 or EdDSA, got %q", c.JWT.Algorithm)
+	p.check(c.JWT.AccessTokenDuration > 0, "jwt.access_duration must be positive")
+	p.check(c.JWT.RefreshTokenDuration > 0, "jwt.refresh_duration must be positive")
+	p.check(c.JWT.KeyRotationInterval > 0, "jwt.key_rotation_interval must be positive")
+	p.check(c.JWT.KeyRefreshInterval > 0, "jwt.key_refresh_interval must be positive")
+	p.check(c.JWT.KeyVerificationGrace*60 >= c.JWT.AccessTokenDuration,
+		"jwt.key_verification_grace (%dh) must outlive jwt.access_duration (%dm)", c.JWT.KeyVerificationGrace, c.JWT.AccessTokenDuration)
+
+	p.check(c.Redis.Host != "", "redis.host is required")
+	p.check(validPort(c.Redis.Port), "redis.port must be a port number, got %q", c.Redis.Port)
+	p.check(c.Redis.DB >= 0, "redis.db must not be negative")
+
+	p.check(len(c.Auth.TokenSigningSecret) >= minSecretLength,
+		"auth.token_signing_secret (or jwt.secret) must be at least %d bytes", minSecretLength)
+	p.check(c.Auth.VerificationTokenTTL > 0, "auth.verification_token_ttl must be positive")
+	p.check(c.Auth.VerificationResendDelay >= 0, "auth.verification_resend_delay must not be negative")
+	p.check(c.Auth.PasswordResetTokenTTL > 0, "auth.password_reset_token_ttl must be positive")
+	p.check(c.Auth.PasswordResetResendDelay >= 0, "auth.password_reset_resend_delay must not be negative")
+	for _, key := range c.Auth.ServiceKeys {
+		p.check(len(key) >= minSecretLength, "auth.service_keys entries must be at least %d bytes", minSecretLength)
+	}
+	p.check(c.Auth.IntrospectionCacheTTL >= 0, "auth.introspection_cache_ttl must not be negative")
+
+	p.check(c.Mail.SMTPPort > 0 && c.Mail.SMTPPort <= 65535, "mail.smtp_port must be a port number, got %d", c.Mail.SMTPPort)
+	p.check(c.Mail.From != "", "mail.from is required")
+	if u, err := url.Parse(c.Mail.AppBaseURL); err != nil || u.Scheme == "" || u.Host == "" {
+		p.add("mail.app_base_url must be an absolute URL, got %q", c.Mail.AppBaseURL)
+	}
+
+	p.check(c.MFA.Issuer != "", "mfa.issuer is required")
+	p.check(c.MFA.ChallengeTTL > 0, "mfa.challenge_ttl must be positive")
+	p.check(c.MFA.RecoveryCodeCount > 0, "mfa.recovery_code_count must be positive")
+	p.check(len(c.MFA.EncryptionKey) >= minSecretLength, "mfa.encryption_key must be at least %d bytes", minSecretLength)
+
+	p.check(c.Lockout.FreeAttempts >= 0, "lockout.free_attempts must not be negative")
+	p.check(c.Lockout.BaseDelay >= 0 && c.Lockout.MaxDelay >= c.Lockout.BaseDelay,
+		"lockout.max_delay must be at least lockout.base_delay")
+	p.check(c.Lockout.Threshold > 0 && c.Lockout.IPThreshold > 0, "lockout thresholds must be positive")
+	p.check(c.Lockout.Duration > 0 && c.Lockout.Window > 0, "lockout.duration and lockout.window must be positive")
+
+	p.check(c.Password.MinLength > 0 && c.Password.MaxLength >= c.Password.MinLength,
+		"password.max_length must be at least password.min_length (%d)", c.Password.MinLength)
+	p.check(c.Password.MinCharClasses >= 0 && c.Password.MinCharClasses <= 4, "password.min_char_classes must be between 0 and 4")
+	p.check(c.Password.HistorySize >= 0, "password.history_size must not be negative")
+
+	p.check(oneOf(c.Hash.Algorithm, "argon2id", "bcrypt"), "hash.algorithm must be argon2id or bcrypt, got %q", c.Hash.Algorithm)
+
+	p.check(c.Health.CheckTimeout > 0, "health.check_timeout must be positive")
+	p.check(c.Health.CacheTTL >= 0, "health.cache_ttl must not be negative")
+
+	p.check(strings.HasPrefix(c.Metrics.Path, "/"), "metrics.path must start with /, got %q", c.Metrics.Path)
+	if c.Metrics.Port != "" {
+		p.check(validPort(c.Metrics.Port), "metrics.port must be a port number, got %q", c.Metrics.Port)
+		p.check(c.Metrics.Port != c.Server.Port, "metrics.port must differ from server.port")
+	}
+
+	if c.GRPC.Enabled {
+		p.check(validPort(c.GRPC.Port), "grpc.port must be a port number, got %q", c.GRPC.Port)
+		p.check(c.GRPC.Port != c.Server.Port && c.GRPC.Port != c.Metrics.Port, "grpc.port must differ from server.port and metrics.port")
+	}
+
+	p.check(c.Outbox.StreamPrefix != "", "outbox.stream_prefix is required")
+	p.check(c.Outbox.StreamMaxLen >= 0, "outbox.stream_max_len must not be negative")
+	p.check(c.Outbox.PollInterval > 0 && c.Outbox.BatchSize > 0, "outbox.poll_interval and outbox.batch_size must be positive")
+	p.check(c.Outbox.MaxAttempts > 0, "outbox.max_attempts must be positive")
+	p.check(c.Outbox.RetryBaseDelay > 0 && c.Outbox.RetryMaxDelay >= c.Outbox.RetryBaseDelay,
+		"outbox.retry_max_delay must be at least outbox.retry_base_delay")
+	p.check(c.Outbox.Retention > 0, "outbox.retention must be positive")
+
+	p.check(c.Consumer.Group != "" && c.Consumer.StreamPrefix != "", "consumer.group and consumer.stream_prefix are required")
+	p.check(c.Consumer.BatchSize > 0 && c.Consumer.Block > 0 && c.Consumer.ClaimIdle > 0,
+		"consumer.batch_size, consumer.block and consumer.claim_idle must be positive")
+	p.check(c.Consumer.DedupeRetention > 0, "consumer.dedupe_retention must be positive")
+
+	p.check(c.Webhooks.PollInterval > 0 && c.Webhooks.BatchSize > 0 && c.Webhooks.Timeout > 0,
+		"webhooks.poll_interval, webhooks.batch_size and webhooks.timeout must be positive")
+	p.check(c.Webhooks.MaxAttempts > 0 && c.Webhooks.DisableAfter > 0, "webhooks.max_attempts and webhooks.disable_after must be positive")
+	p.check(c.Webhooks.RetryBaseDelay > 0 && c.Webhooks.RetryMaxDelay >= c.Webhooks.RetryBaseDelay,
+		"webhooks.retry_max_delay must be at least webhooks.retry_base_delay")
+	p.check(c.Webhooks.Retention > 0, "webhooks.retention must be positive")
+	p.check(!c.Webhooks.AllowPrivateTargets || c.Server.Mode != "release",
+		"webhooks.allow_private_targets must not be enabled in release mode")
+
+	return p
+}
+
+type problems []string
+
+func (p *problems) add(format string, args ...interface{}) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+func (p *problems) check(ok bool, format string, args ...interface{}) {
+	if !ok {
+		p.add(format, args...)
+	}
+}
+
+func validPort(port string) bool {
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535
+}
+
+func validIPOrCIDR(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
+}
+
+func oneOf(value string, allowed ...string) bool {
+	for _, a := range allowed {
+		if value == a {
+			return true
+		}
+	}
+	return false
+}
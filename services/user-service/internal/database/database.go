@@ -4,15 +4,18 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"strings"
+
 	"user-service/internal/config"
 
 	_ "github.com/lib/pq"
 )
 
-func Connect(cfg *config.DatabaseConfig) (*sql.DB, error) {
+// Connect opens the Postgres pool described by cfg and checks it can be reached.
+func Connect(cfg config.DatabaseConfig) (*sql.DB, error) {
 	dsn := fmt.Sprintf(
 		"host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode,
+		dsnValue(cfg.Host), cfg.Port, dsnValue(cfg.User), dsnValue(cfg.Password), dsnValue(cfg.DBName), dsnValue(cfg.SSLMode),
 	)
 
 	db, err := sql.Open("postgres", dsn)
@@ -26,96 +29,15 @@ func Connect(cfg *config.DatabaseConfig) (*sql.DB, error) {
 	}
 
 	// Set connection pool settings
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(10)
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
 
 	log.Println("Successfully connected to PostgreSQL database")
 	return db, nil
 }
 
-func CreateTables(db *sql.DB) error {
-	// Users table
-	usersTable := `
-	CREATE TABLE IF NOT EXISTS users (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		email VARCHAR(255) UNIQUE NOT NULL,
-		password_hash VARCHAR(255) NOT NULL,
-		first_name VARCHAR(100) NOT NULL,
-		last_name VARCHAR(100) NOT NULL,
-		phone VARCHAR(20),
-		avatar_url VARCHAR(500),
-		is_active BOOLEAN DEFAULT true,
-		is_verified BOOLEAN DEFAULT false,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Refresh tokens table
-	refreshTokensTable := `
-	CREATE TABLE IF NOT EXISTS refresh_tokens (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-		token_hash VARCHAR(255) NOT NULL,
-		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		UNIQUE(token_hash)
-	);`
-
-	// User sessions table (for tracking active sessions)
-	userSessionsTable := `
-	CREATE TABLE IF NOT EXISTS user_sessions (
-		id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-		user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-		session_token VARCHAR(255) NOT NULL,
-		ip_address INET,
-		user_agent TEXT,
-		expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
-		created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
-		updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
-	);`
-
-	// Create indexes
-	createIndexes := `
-	CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-	CREATE INDEX IF NOT EXISTS idx_users_is_active ON users(is_active);
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user_id ON refresh_tokens(user_id);
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_token_hash ON refresh_tokens(token_hash);
-	CREATE INDEX IF NOT EXISTS idx_refresh_tokens_expires_at ON refresh_tokens(expires_at);
-	CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
-	CREATE INDEX IF NOT EXISTS idx_user_sessions_session_token ON user_sessions(session_token);
-	CREATE INDEX IF NOT EXISTS idx_user_sessions_expires_at ON user_sessions(expires_at);`
-
-	// Create trigger for updated_at
-	updateTrigger := `
-	CREATE OR REPLACE FUNCTION update_updated_at_column()
-	RETURNS TRIGGER AS $$
-	BEGIN
-		NEW.updated_at = CURRENT_TIMESTAMP;
-		RETURN NEW;
-	END;
-	$$ language 'plpgsql';
-
-	DROP TRIGGER IF EXISTS update_users_updated_at ON users;
-	CREATE TRIGGER update_users_updated_at
-		BEFORE UPDATE ON users
-		FOR EACH ROW
-		EXECUTE FUNCTION update_updated_at_column();
-
-	DROP TRIGGER IF EXISTS update_user_sessions_updated_at ON user_sessions;
-	CREATE TRIGGER update_user_sessions_updated_at
-		BEFORE UPDATE ON user_sessions
-		FOR EACH ROW
-		EXECUTE FUNCTION update_updated_at_column();`
-
-	// Execute all table creation statements
-	tables := []string{usersTable, refreshTokensTable, userSessionsTable, createIndexes, updateTrigger}
-
-	for _, table := range tables {
-		if _, err := db.Exec(table); err != nil {
-			return fmt.Errorf("failed to create table/index: %w", err)
-		}
-	}
-
-	log.Println("Database tables created successfully")
-	return nil
+// dsnValue quotes a connection string value so passwords with spaces or
+// quotes survive.
+func dsnValue(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
 }
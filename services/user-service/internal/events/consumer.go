@@ -0,0 +1,212 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// retryDelay is how long the consumer waits after Redis fails before trying
+// again.
+const retryDelay = 2 * time.Second
+
+// Handler processes one event. An error leaves the event pending so it is
+// delivered again; events that can never succeed should be logged and
+// acknowledged by returning nil.
+type Handler func(ctx context.Context, event *Envelope) error
+
+// RedisConsumerOptions configures a RedisConsumer.
+type RedisConsumerOptions struct {
+	// Group is the consumer group shared by every replica, so each event is
+	// handled by one of them.
+	Group string
+	// Consumer names this replica within the group.
+	Consumer string
+	Streams  []string
+	// BatchSize is the most entries read per stream at once.
+	BatchSize int64
+	// Block is how long a read waits for new entries.
+	Block time.Duration
+	// ClaimIdle is how long an entry may stay unacknowledged before it is
+	// delivered again, here or on another replica.
+	ClaimIdle time.Duration
+}
+
+// RedisConsumer reads events from Redis streams through a consumer group and
+// passes them to a Handler. Entries are acknowledged once handled; a failed
+// entry stays pending and is claimed again after ClaimIdle, so delivery is at
+// least once and handlers must be idempotent.
+type RedisConsumer struct {
+	client  redis.UniversalClient
+	opts    RedisConsumerOptions
+	handler Handler
+}
+
+func NewRedisConsumer(client redis.UniversalClient, opts RedisConsumerOptions, handler Handler) *RedisConsumer {
+	return &RedisConsumer{client: client, opts: opts, handler: handler}
+}
+
+// Run consumes until ctx is cancelled. It first creates the group on any
+// stream that lacks it, starting from the oldest entry, then finishes what
+// this consumer left pending before its last stop.
+func (c *RedisConsumer) Run(ctx context.Context) {
+	for {
+		err := c.ensureGroups(ctx)
+		if err == nil {
+			break
+		}
+		logrus.WithError(err).Warn("Failed to create event consumer groups, retrying")
+		if !sleep(ctx, retryDelay) {
+			return
+		}
+	}
+
+	c.read(ctx, "0")
+	lastClaim := time.Now()
+
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= c.opts.ClaimIdle {
+			c.claimStale(ctx)
+			lastClaim = time.Now()
+		}
+		if err := c.read(ctx, ">"); err != nil && !sleep(ctx, retryDelay) {
+			return
+		}
+	}
+}
+
+func (c *RedisConsumer) ensureGroups(ctx context.Context) error {
+	for _, stream := range c.opts.Streams {
+		err := c.client.XGroupCreateMkStream(ctx, stream, c.opts.Group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return err
+		}
+	}
+	return nil
+}
+
+// read handles one batch of new entries when id is ">". With id "0" it
+// instead walks this consumer's pending entries until it has seen them all.
+func (c *RedisConsumer) read(ctx context.Context, id string) error {
+	ids := make(map[string]string, len(c.opts.Streams))
+	for _, stream := range c.opts.Streams {
+		ids[stream] = id
+	}
+
+	for {
+		args := make([]string, 0, 2*len(c.opts.Streams))
+		args = append(args, c.opts.Streams...)
+		for _, stream := range c.opts.Streams {
+			args = append(args, ids[stream])
+		}
+
+		block := c.opts.Block
+		if id != ">" {
+			// Pending entries are there or not; don't wait.
+			block = -1
+		}
+
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.opts.Group,
+			Consumer: c.opts.Consumer,
+			Streams:  args,
+			Count:    c.opts.BatchSize,
+			Block:    block,
+		}).Result()
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			logrus.WithError(err).Warn("Failed to read events")
+			return err
+		}
+
+		read := 0
+		for _, stream := range streams {
+			for _, message := range stream.Messages {
+				if ctx.Err() != nil {
+					return nil
+				}
+				c.handle(ctx, stream.Stream, message)
+				ids[stream.Stream] = message.ID
+				read++
+			}
+		}
+		if id == ">" || read == 0 {
+			return nil
+		}
+	}
+}
+
+// claimStale takes over entries that have been pending for ClaimIdle, whether
+// a handler failed on them or the replica reading them died.
+func (c *RedisConsumer) claimStale(ctx context.Context) {
+	for _, stream := range c.opts.Streams {
+		start := "0-0"
+		for ctx.Err() == nil {
+			messages, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+				Stream:   stream,
+				Group:    c.opts.Group,
+				Consumer: c.opts.Consumer,
+				MinIdle:  c.opts.ClaimIdle,
+				Start:    start,
+				Count:    c.opts.BatchSize,
+			}).Result()
+			if err != nil {
+				logrus.WithError(err).WithField("stream", stream).Warn("Failed to claim stale events")
+				break
+			}
+			for _, message := range messages {
+				c.handle(ctx, stream, message)
+			}
+			if next == "0-0" {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+func (c *RedisConsumer) handle(ctx context.Context, stream string, message redis.XMessage) {
+	log := logrus.WithFields(logrus.Fields{"stream": stream, "message_id": message.ID})
+
+	var event Envelope
+	payload, _ := message.Values["payload"].(string)
+	if err := json.Unmarshal([]byte(payload), &event); err != nil || event.EventID == "" {
+		// Redelivering can't fix a malformed entry.
+		log.WithError(err).Error("Dropping malformed event")
+		c.ack(ctx, stream, message.ID)
+		return
+	}
+
+	if err := c.handler(ctx, &event); err != nil {
+		log.WithError(err).WithFields(logrus.Fields{"event_id": event.EventID, "event_type": event.EventType}).
+			Warn("Failed to handle event, it will be retried")
+		return
+	}
+	c.ack(ctx, stream, message.ID)
+}
+
+func (c *RedisConsumer) ack(ctx context.Context, stream, id string) {
+	if err := c.client.XAck(ctx, stream, c.opts.Group, id).Err(); err != nil {
+		// The entry is delivered again; the handler dedupes it.
+		logrus.WithError(err).WithField("message_id", id).Warn("Failed to acknowledge event")
+	}
+}
+
+// sleep waits for d and reports whether ctx is still live.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,80 @@
+// Package events defines the envelope every service wraps its events in (see
+// shared/contracts/api-contracts.md) and the user lifecycle events this
+// service emits.
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// Version is the envelope version from the shared contract.
+	Version = "1.0"
+	// Source identifies this service in the envelopes it emits.
+	Source = "user-service"
+)
+
+// User lifecycle event types.
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+	// UserVerified is only sent to webhooks; on the bus a verification is a
+	// user.updated with isVerified set.
+	UserVerified = "user.verified"
+)
+
+// Envelope is the standard event structure shared by all services.
+type Envelope struct {
+	EventID   string          `json:"eventId"`
+	EventType string          `json:"eventType"`
+	Version   string          `json:"version"`
+	Timestamp time.Time       `json:"timestamp"`
+	Source    string          `json:"source"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// New wraps data in an envelope with a fresh event ID.
+func New(eventType string, data interface{}) (*Envelope, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{
+		EventID:   uuid.New().String(),
+		EventType: eventType,
+		Version:   Version,
+		Timestamp: time.Now().UTC(),
+		Source:    Source,
+		Data:      raw,
+	}, nil
+}
+
+// UserData is the payload of the user.* events
+// (shared/schemas/events/user-created.json).
+type UserData struct {
+	UserID     string `json:"userId"`
+	Email      string `json:"email"`
+	Username   string `json:"username"`
+	Role       string `json:"role"`
+	IsActive   bool   `json:"isActive"`
+	IsVerified bool   `json:"isVerified"`
+}
+
+// NewUserEvent builds a user.* event describing the user as it is now.
+func NewUserEvent(eventType string, user *models.User) (*Envelope, error) {
+	return New(eventType, UserData{
+		UserID:     user.ID,
+		Email:      user.Email,
+		Username:   user.Username,
+		Role:       user.Role,
+		IsActive:   user.IsActive,
+		IsVerified: user.IsVerified,
+	})
+}
@@ -0,0 +1,72 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+)
+
+// Event types from the order and payment services that this service
+// consumes.
+const (
+	OrderPlaced     = "order.placed"
+	OrderDelivered  = "order.delivered"
+	OrderCancelled  = "order.cancelled"
+	PaymentRefunded = "payment.refunded"
+)
+
+// currencyExponents is how many decimals each currency of the shared schemas
+// has (ISO 4217).
+var currencyExponents = map[string]int{
+	"USD": 2,
+	"EUR": 2,
+	"VND": 0,
+}
+
+// OrderData is the payload of the order.* events
+// (shared/schemas/events/order-placed.json). Only the fields this service
+// uses are decoded. Amounts stay decimal text so they convert to minor
+// units exactly.
+type OrderData struct {
+	OrderID     string      `json:"orderId"`
+	UserID      string      `json:"userId"`
+	TotalAmount json.Number `json:"totalAmount"`
+	Currency    string      `json:"currency"`
+	Status      string      `json:"status"`
+}
+
+// PaymentData is the payload of the payment.* events
+// (shared/schemas/events/payment-processed.json).
+type PaymentData struct {
+	PaymentID string      `json:"paymentId"`
+	OrderID   string      `json:"orderId"`
+	UserID    string      `json:"userId"`
+	Amount    json.Number `json:"amount"`
+	Currency  string      `json:"currency"`
+	Status    string      `json:"status"`
+}
+
+// MinorUnits converts a decimal amount to an integer count of the currency's
+// minor units, e.g. 12.5 USD to 1250 cents. Negative amounts, unknown
+// currencies and amounts finer than the currency's minor unit are rejected.
+func MinorUnits(amount json.Number, currency string) (int64, error) {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	value, ok := new(big.Rat).SetString(amount.String())
+	if !ok || value.Sign() < 0 {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+
+	value.Mul(value, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)))
+	if !value.IsInt() {
+		return 0, fmt.Errorf("amount %s has more than %d decimals for %s", amount, exponent, currency)
+	}
+	minor := value.Num()
+	if !minor.IsInt64() {
+		return 0, fmt.Errorf("amount %s is too large", amount)
+	}
+	return minor.Int64(), nil
+}
@@ -0,0 +1,39 @@
+package events
+
+import (
+	"context"
+	"sync"
+)
+
+// Publisher delivers events to the message bus. Delivery is at least once:
+// the outbox relay retries until Publish succeeds, so consumers must dedupe
+// on EventID.
+type Publisher interface {
+	Publish(ctx context.Context, event *Envelope) error
+}
+
+// MemoryPublisher keeps published events in process memory, for tests and
+// local runs without Redis.
+type MemoryPublisher struct {
+	mu     sync.Mutex
+	events []*Envelope
+}
+
+func NewMemoryPublisher() *MemoryPublisher {
+	return &MemoryPublisher{}
+}
+
+func (p *MemoryPublisher) Publish(_ context.Context, event *Envelope) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	copied := *event
+	p.events = append(p.events, &copied)
+	return nil
+}
+
+// Published returns the events published so far, oldest first.
+func (p *MemoryPublisher) Published() []*Envelope {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]*Envelope(nil), p.events...)
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisPublisher appends each event to a Redis stream named after its type,
+// e.g. "events:user.created". Entries carry the eventId and eventType as
+// fields next to the JSON envelope, so consumers can route without decoding.
+type RedisPublisher struct {
+	client redis.UniversalClient
+	prefix string
+	maxLen int64
+}
+
+// NewRedisPublisher publishes to streams named prefix+eventType, trimmed to
+// roughly maxLen entries each; a maxLen of 0 leaves them unbounded.
+func NewRedisPublisher(client redis.UniversalClient, prefix string, maxLen int64) *RedisPublisher {
+	return &RedisPublisher{client: client, prefix: prefix, maxLen: maxLen}
+}
+
+// Stream returns the name of the stream events of eventType go to.
+func (p *RedisPublisher) Stream(eventType string) string {
+	return p.prefix + eventType
+}
+
+func (p *RedisPublisher) Publish(ctx context.Context, event *Envelope) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	return p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.Stream(event.EventType),
+		MaxLen: p.maxLen,
+		Approx: p.maxLen > 0,
+		Values: map[string]interface{}{
+			"eventId":   event.EventID,
+			"eventType": event.EventType,
+			"payload":   payload,
+		},
+	}).Err()
+}
@@ -0,0 +1,145 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"runtime/debug"
+	"strings"
+
+	"user-service/internal/middleware"
+	"user-service/internal/tokens"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// ServiceKeyHeader is the metadata key internal callers put their service
+// key in (gRPC metadata keys are lowercase).
+const ServiceKeyHeader = "x-service-key"
+
+// TokenValidator verifies user access tokens; *tokens.Validator implements
+// it so gRPC and HTTP accept exactly the same tokens.
+type TokenValidator interface {
+	Validate(ctx context.Context, token string) (*tokens.Claims, error)
+}
+
+// Caller is who made a call: another service (by service key) or a user (by
+// access token), in which case Claims is set.
+type Caller struct {
+	Service bool
+	Claims  *tokens.Claims
+}
+
+type callerKey struct{}
+
+// CallerFrom returns the authenticated caller of the call ctx belongs to.
+func CallerFrom(ctx context.Context) (Caller, bool) {
+	caller, ok := ctx.Value(callerKey{}).(Caller)
+	return caller, ok
+}
+
+type authenticator struct {
+	serviceKeys middleware.ServiceKeys
+	tokens      TokenValidator
+}
+
+func (a *authenticator) unary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if isPublic(info.FullMethod) {
+		return handler(ctx, req)
+	}
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a *authenticator) stream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if isPublic(info.FullMethod) {
+		return handler(srv, ss)
+	}
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+func (a *authenticator) authenticate(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	if key := firstValue(md, ServiceKeyHeader); key != "" {
+		if !a.serviceKeys.Valid(key) {
+			return nil, status.Error(codes.Unauthenticated, "invalid service key")
+		}
+		return context.WithValue(ctx, callerKey{}, Caller{Service: true}), nil
+	}
+
+	header := firstValue(md, "authorization")
+	if header == "" {
+		return nil, status.Error(codes.Unauthenticated, "a service key or bearer token is required")
+	}
+	token := strings.TrimPrefix(header, "Bearer ")
+	if token == header {
+		return nil, status.Error(codes.Unauthenticated, "invalid authorization format")
+	}
+
+	claims, err := a.tokens.Validate(ctx, token)
+	if err != nil {
+		var tokenErr *tokens.Error
+		if errors.As(err, &tokenErr) {
+			return nil, status.Error(codes.Unauthenticated, tokenErr.Message)
+		}
+		return nil, status.Error(codes.Unavailable, "could not validate token")
+	}
+	return context.WithValue(ctx, callerKey{}, Caller{Claims: claims}), nil
+}
+
+// isPublic lists the methods callable without credentials so orchestrators
+// and tools like grpcurl work out of the box.
+func isPublic(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/grpc.health.v1.Health/") ||
+		strings.HasPrefix(fullMethod, "/grpc.reflection.")
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// recoverUnary turns a panic in a handler into an Internal error instead of
+// crashing the process, like gin's recovery middleware does for HTTP.
+func recoverUnary(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer recoverPanic(info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+func recoverStream(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recoverPanic(info.FullMethod, &err)
+	return handler(srv, ss)
+}
+
+func recoverPanic(method string, err *error) {
+	if r := recover(); r != nil {
+		logrus.WithFields(logrus.Fields{
+			"method": method,
+			"panic":  r,
+			"stack":  string(debug.Stack()),
+		}).Error("gRPC handler panicked")
+		*err = status.Error(codes.Internal, "internal error")
+	}
+}
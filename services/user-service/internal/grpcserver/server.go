@@ -0,0 +1,82 @@
+// Package grpcserver serves the user service over gRPC so other services
+// (orders, carts) can look users up and validate tokens without going
+// through the gateway and JSON.
+package grpcserver
+
+import (
+	"context"
+	"net"
+
+	"user-service/internal/config"
+	"user-service/internal/middleware"
+	"user-service/internal/services"
+	userpb "user-service/proto/user"
+
+	"google.golang.org/grpc"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+// Server is the gRPC listener together with its health service.
+type Server struct {
+	addr   string
+	grpc   *grpc.Server
+	health *grpchealth.Server
+}
+
+// New builds the server. Every call except health checks and reflection must
+// carry one of serviceKeys or a user access token.
+func New(cfg config.GRPCConfig, host string, serviceKeys middleware.ServiceKeys, users services.UserService, tokens TokenValidator) *Server {
+	auth := &authenticator{serviceKeys: serviceKeys, tokens: tokens}
+
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(recoverUnary, auth.unary),
+		grpc.ChainStreamInterceptor(recoverStream, auth.stream),
+	)
+	userpb.RegisterUserServiceServer(srv, NewUserServer(users, tokens))
+
+	health := grpchealth.NewServer()
+	health.SetServingStatus(userpb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, health)
+
+	if cfg.Reflection {
+		reflection.Register(srv)
+	}
+
+	return &Server{addr: net.JoinHostPort(host, cfg.Port), grpc: srv, health: health}
+}
+
+// ListenAndServe listens on the configured address and serves until Shutdown.
+func (s *Server) ListenAndServe() error {
+	lis, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(lis)
+}
+
+// Serve serves on lis until Shutdown.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Shutdown reports NOT_SERVING to health checks, then waits for in-flight
+// calls to finish. Calls still running when ctx ends are cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.health.Shutdown()
+
+	done := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.grpc.Stop()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,154 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+	userpb "user-service/proto/user"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// MaxBatchSize bounds the IDs accepted by one BatchGetUsers call.
+const MaxBatchSize = 100
+
+// UserServer implements the read-only RPCs of user.UserService. Account
+// changes stay on the HTTP API, so the write RPCs report Unimplemented.
+type UserServer struct {
+	userpb.UnimplementedUserServiceServer
+	users  services.UserService
+	tokens TokenValidator
+}
+
+func NewUserServer(users services.UserService, tokens TokenValidator) *UserServer {
+	return &UserServer{users: users, tokens: tokens}
+}
+
+func (s *UserServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.GetUserResponse, error) {
+	if _, err := uuid.Parse(req.GetId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "id must be a UUID")
+	}
+
+	user, err := s.users.GetUserByID(req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userpb.GetUserResponse{User: toProtoUser(user)}, nil
+}
+
+// BatchGetUsers returns the users found, in request order, and lists the IDs
+// that matched nobody. Duplicate IDs are answered once.
+func (s *UserServer) BatchGetUsers(ctx context.Context, req *userpb.BatchGetUsersRequest) (*userpb.BatchGetUsersResponse, error) {
+	if len(req.GetIds()) > MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "at most %d ids per call", MaxBatchSize)
+	}
+
+	var ids, notFound []string
+	seen := make(map[string]bool, len(req.GetIds()))
+	for _, id := range req.GetIds() {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := uuid.Parse(id); err != nil {
+			notFound = append(notFound, id)
+			continue
+		}
+		ids = append(ids, id)
+	}
+
+	users, err := s.users.GetUsersByIDs(ids)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	byID := make(map[string]*models.User, len(users))
+	for _, user := range users {
+		byID[user.ID] = user
+	}
+
+	resp := &userpb.BatchGetUsersResponse{NotFoundIds: notFound}
+	for _, id := range ids {
+		if user, ok := byID[id]; ok {
+			resp.Users = append(resp.Users, toProtoUser(user))
+		} else {
+			resp.NotFoundIds = append(resp.NotFoundIds, id)
+		}
+	}
+	return resp, nil
+}
+
+// ValidateToken checks a user's access token with the same rules as the HTTP
+// auth middleware. A rejected token is a normal response with valid=false.
+func (s *UserServer) ValidateToken(ctx context.Context, req *userpb.ValidateTokenRequest) (*userpb.ValidateTokenResponse, error) {
+	if req.GetAccessToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "access_token is required")
+	}
+
+	claims, err := s.tokens.Validate(ctx, req.GetAccessToken())
+	if err != nil {
+		var tokenErr *tokens.Error
+		if errors.As(err, &tokenErr) {
+			return &userpb.ValidateTokenResponse{Valid: false, Reason: tokenErr.Reason}, nil
+		}
+		return nil, status.Error(codes.Unavailable, "could not validate token")
+	}
+
+	return &userpb.ValidateTokenResponse{
+		Valid:     true,
+		UserId:    claims.UserID,
+		Email:     claims.Email,
+		Role:      claims.Role,
+		SessionId: claims.SessionID,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+	}, nil
+}
+
+func (s *UserServer) GetUserRoles(ctx context.Context, req *userpb.GetUserRolesRequest) (*userpb.GetUserRolesResponse, error) {
+	if _, err := uuid.Parse(req.GetUserId()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, "user_id must be a UUID")
+	}
+
+	user, err := s.users.GetUserByID(req.GetUserId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &userpb.GetUserRolesResponse{UserId: user.ID, Roles: []string{user.Role}}, nil
+}
+
+func toProtoUser(user *models.User) *userpb.User {
+	pb := &userpb.User{
+		Id:         user.ID,
+		Username:   user.Username,
+		Email:      user.Email,
+		FirstName:  user.FirstName,
+		LastName:   user.LastName,
+		Role:       user.Role,
+		IsActive:   user.IsActive,
+		IsVerified: user.IsVerified,
+		CreatedAt:  user.CreatedAt.Unix(),
+		UpdatedAt:  user.UpdatedAt.Unix(),
+	}
+	if user.Phone != nil {
+		pb.Phone = *user.Phone
+	}
+	if user.AvatarURL != nil {
+		pb.AvatarUrl = *user.AvatarURL
+	}
+	return pb
+}
+
+// toStatus maps service errors to gRPC codes; unexpected errors are logged
+// and hidden behind Internal.
+func toStatus(err error) error {
+	if errors.Is(err, services.ErrUserNotFound) {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	logrus.WithError(err).Error("gRPC call failed")
+	return status.Error(codes.Internal, "internal error")
+}
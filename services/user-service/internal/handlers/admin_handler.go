@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AdminHandler serves account operations reserved for administrators.
+type AdminHandler struct {
+	userService  services.UserService
+	statsService services.CustomerStatsService
+	validator    *validator.Validate
+}
+
+func NewAdminHandler(userService services.UserService, statsService services.CustomerStatsService) *AdminHandler {
+	return &AdminHandler{
+		userService:  userService,
+		statsService: statsService,
+		validator:    validator.New(),
+	}
+}
+
+// ListUsers lists users with filters, search and sorting. Without a cursor
+// it pages by page/limit and reports the total; passing the nextCursor of a
+// page continues after it without counting, which stays fast on large tables.
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	var query models.UserListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid query parameters",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+	if err := h.validator.Struct(query); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	page, err := h.userService.ListUsers(query)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": gin.H{
+					"code":    "INVALID_CURSOR",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to list users",
+			},
+		})
+		return
+	}
+
+	var pagination interface{} = page.Pagination
+	if page.Cursor != nil {
+		pagination = page.Cursor
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"data":       page.Users,
+		"pagination": pagination,
+		"meta": gin.H{
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"version":   "1.0",
+		},
+	})
+}
+
+// GetUser returns a user together with their order statistics.
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, err := h.userService.GetUserByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "USER_NOT_FOUND",
+				"message": services.ErrUserNotFound.Error(),
+			},
+		})
+		return
+	}
+
+	stats, err := h.statsService.GetStats(user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to load customer statistics",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": models.AdminUser{User: *user, Stats: stats},
+	})
+}
+
+// UnlockLogin lifts a brute-force lockout on a user's account.
+func (h *AdminHandler) UnlockLogin(c *gin.Context) {
+	if err := h.userService.UnlockLogin(c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "UNLOCK_FAILED",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"message": "Account unlocked successfully",
+		},
+	})
+}
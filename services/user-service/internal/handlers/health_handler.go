@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"user-service/internal/buildinfo"
+	"user-service/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+type HealthHandler struct {
+	checks *health.Registry
+	ready  func() bool
+}
+
+// NewHealthHandler serves the health endpoints from checks. ready flips to
+// false when shutdown starts, which fails /health and /health/ready so load
+// balancers stop routing here while in-flight requests finish.
+func NewHealthHandler(checks *health.Registry, ready func() bool) *HealthHandler {
+	return &HealthHandler{checks: checks, ready: ready}
+}
+
+// Health reports the service and dependency status in the shared contract
+// shape. It is public, so it only says whether each dependency is up; errors
+// and versions are logged and served by Details.
+func (h *HealthHandler) Health(c *gin.Context) {
+	h.report(c, false)
+}
+
+// Details adds per-check results and build information to the health report.
+// It must sit behind authentication.
+func (h *HealthHandler) Details(c *gin.Context) {
+	h.report(c, true)
+}
+
+// Ready is the readiness probe: 200 only while the service accepts traffic
+// and every critical dependency is healthy.
+func (h *HealthHandler) Ready(c *gin.Context) {
+	h.report(c, false)
+}
+
+// Live is the liveness probe. It checks no dependencies, so an outage of the
+// database doesn't get the process restarted.
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":    health.StatusHealthy,
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+func (h *HealthHandler) report(c *gin.Context, detailed bool) {
+	report := h.checks.Check()
+
+	shuttingDown := !h.ready()
+	status, code := report.Status, http.StatusOK
+	if shuttingDown {
+		status = health.StatusUnhealthy
+	}
+	if status != health.StatusHealthy {
+		code = http.StatusServiceUnavailable
+	}
+
+	body := gin.H{
+		"status":       status,
+		"service":      "user-service",
+		"timestamp":    report.CheckedAt.UTC(),
+		"version":      buildinfo.Version,
+		"dependencies": report.Dependencies(),
+	}
+	if shuttingDown {
+		body["shutting_down"] = true
+	}
+	if detailed {
+		body["checks"] = report.Checks
+		body["build"] = buildinfo.Get()
+	}
+	c.JSON(code, body)
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
+)
+
+type IntrospectionHandler struct {
+	introspectionService services.IntrospectionService
+	validator            *validator.Validate
+}
+
+func NewIntrospectionHandler(introspectionService services.IntrospectionService) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		introspectionService: introspectionService,
+		validator:            validator.New(),
+	}
+}
+
+// Introspect implements RFC 7662. The body is the bare introspection response
+// rather than the usual data envelope, so standard OAuth clients can read it.
+func (h *IntrospectionHandler) Introspect(c *gin.Context) {
+	var req models.IntrospectionRequest
+
+	// Form-encoded per the RFC, JSON for convenience.
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	response, err := h.introspectionService.Introspect(c.Request.Context(), req.Token)
+	if err != nil {
+		logrus.WithError(err).Error("Token introspection failed")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error": gin.H{
+				"code":    "SERVICE_UNAVAILABLE",
+				"message": "Token status could not be determined",
+			},
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, response)
+}
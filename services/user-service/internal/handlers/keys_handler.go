@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"user-service/internal/keystore"
+
+	"github.com/gin-gonic/gin"
+)
+
+type KeysHandler struct {
+	keys keystore.KeyStore
+}
+
+func NewKeysHandler(keys keystore.KeyStore) *KeysHandler {
+	return &KeysHandler{keys: keys}
+}
+
+// JWKS publishes the public keys that verify access tokens. New keys are
+// listed before they sign, and retired keys stay listed until every token they
+// signed has expired.
+func (h *KeysHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(keystore.JWKSMaxAge/time.Second)))
+	c.JSON(http.StatusOK, h.keys.JWKS())
+}
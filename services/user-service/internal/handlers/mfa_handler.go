@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+type MFAHandler struct {
+	mfaService  services.MFAService
+	userService services.UserService
+	validator   *validator.Validate
+}
+
+func NewMFAHandler(mfaService services.MFAService, userService services.UserService) *MFAHandler {
+	return &MFAHandler{
+		mfaService:  mfaService,
+		userService: userService,
+		validator:   validator.New(),
+	}
+}
+
+// Enroll starts enrollment for a signed-in user.
+func (h *MFAHandler) Enroll(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User ID not found in token",
+			},
+		})
+		return
+	}
+
+	user, err := h.userService.GetUserByID(userID.(string))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "USER_NOT_FOUND",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	enrollment, err := h.mfaService.Enroll(user)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": enrollment,
+		"meta": gin.H{
+			"message": "Scan the QR code and confirm with a code from your authenticator app",
+		},
+	})
+}
+
+// EnrollWithChallenge starts enrollment during login for accounts whose role
+// requires MFA but which have not set it up yet.
+func (h *MFAHandler) EnrollWithChallenge(c *gin.Context) {
+	var req models.MFAChallengeEnrollRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	enrollment, err := h.mfaService.EnrollWithChallenge(req.ChallengeToken)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": enrollment,
+		"meta": gin.H{
+			"message": "Scan the QR code and complete login with a code from your authenticator app",
+		},
+	})
+}
+
+func (h *MFAHandler) Confirm(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User ID not found in token",
+			},
+		})
+		return
+	}
+
+	var req models.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	codes, err := h.mfaService.Confirm(userID.(string), req.Code)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": models.MFARecoveryCodesResponse{RecoveryCodes: codes},
+		"meta": gin.H{
+			"message": "Multi-factor authentication enabled, store the recovery codes somewhere safe",
+		},
+	})
+}
+
+func (h *MFAHandler) Disable(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User ID not found in token",
+			},
+		})
+		return
+	}
+
+	var req models.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	role, _ := c.Get("user_role")
+	roleName, _ := role.(string)
+
+	if err := h.mfaService.Disable(userID.(string), roleName, req.Code); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"message": "Multi-factor authentication disabled",
+		},
+	})
+}
+
+func (h *MFAHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User ID not found in token",
+			},
+		})
+		return
+	}
+
+	var req models.MFACodeRequest
+	if !h.bind(c, &req) {
+		return
+	}
+
+	codes, err := h.mfaService.RegenerateRecoveryCodes(userID.(string), req.Code)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": models.MFARecoveryCodesResponse{RecoveryCodes: codes},
+		"meta": gin.H{
+			"message": "Recovery codes regenerated, previous codes no longer work",
+		},
+	})
+}
+
+func (h *MFAHandler) bind(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+
+	return true
+}
+
+func (h *MFAHandler) respondError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	code := "MFA_ERROR"
+
+	switch {
+	case errors.Is(err, services.ErrInvalidMFAChallenge), errors.Is(err, services.ErrInvalidMFACode):
+		status, code = http.StatusUnauthorized, "MFA_FAILED"
+	case errors.Is(err, services.ErrMFANotEnrolled):
+		status, code = http.StatusBadRequest, "MFA_NOT_ENROLLED"
+	case errors.Is(err, services.ErrMFAAlreadyEnabled):
+		status, code = http.StatusConflict, "MFA_ALREADY_ENABLED"
+	case errors.Is(err, services.ErrMFARequiredByPolicy):
+		status, code = http.StatusForbidden, "MFA_REQUIRED"
+	}
+
+	message := err.Error()
+	if status == http.StatusInternalServerError {
+		message = "Failed to process multi-factor authentication request"
+	}
+
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
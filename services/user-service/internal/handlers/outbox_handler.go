@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// OutboxHandler serves the outbox dead letters to administrators.
+type OutboxHandler struct {
+	outboxService services.OutboxService
+	validator     *validator.Validate
+}
+
+func NewOutboxHandler(outboxService services.OutboxService) *OutboxHandler {
+	return &OutboxHandler{
+		outboxService: outboxService,
+		validator:     validator.New(),
+	}
+}
+
+// ListDeadLetters lists events that exhausted their publish attempts, most
+// recent failure first.
+func (h *OutboxHandler) ListDeadLetters(c *gin.Context) {
+	var query models.PageQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid query parameters",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&query); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	dead, pagination, err := h.outboxService.ListDeadLetters(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to list dead letters",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       dead,
+		"pagination": pagination,
+	})
+}
+
+// RequeueDeadLetter schedules a dead letter to be published again.
+func (h *OutboxHandler) RequeueDeadLetter(c *gin.Context) {
+	err := h.outboxService.RequeueDeadLetter(c.Param("id"))
+	if errors.Is(err, services.ErrDeadLetterNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "DEAD_LETTER_NOT_FOUND",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to requeue dead letter",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"message": "Event requeued for publishing",
+		},
+	})
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// RoleHandler serves role management and role assignment to administrators.
+type RoleHandler struct {
+	roleService services.RoleService
+	validator   *validator.Validate
+}
+
+func NewRoleHandler(roleService services.RoleService) *RoleHandler {
+	return &RoleHandler{
+		roleService: roleService,
+		validator:   validator.New(),
+	}
+}
+
+// ListPermissions lists the permissions roles can grant.
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.roleService.ListPermissions()
+	if err != nil {
+		respondRoleError(c, err, "Failed to list permissions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": permissions})
+}
+
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roleService.ListRoles()
+	if err != nil {
+		respondRoleError(c, err, "Failed to list roles")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, err := h.roleService.GetRole(c.Param("name"))
+	if err != nil {
+		respondRoleError(c, err, "Failed to load role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": role})
+}
+
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	var req models.CreateRoleRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	role, err := h.roleService.CreateRole(&req)
+	if err != nil {
+		respondRoleError(c, err, "Failed to create role")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": role})
+}
+
+// UpdateRole changes a role's description or replaces its permissions.
+// Holders get the new permissions when their access token is refreshed.
+func (h *RoleHandler) UpdateRole(c *gin.Context) {
+	var req models.UpdateRoleRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	role, err := h.roleService.UpdateRole(c.Param("name"), &req)
+	if err != nil {
+		respondRoleError(c, err, "Failed to update role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": role})
+}
+
+// DeleteRole removes a custom role nobody holds.
+func (h *RoleHandler) DeleteRole(c *gin.Context) {
+	if err := h.roleService.DeleteRole(c.Param("name")); err != nil {
+		respondRoleError(c, err, "Failed to delete role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"message": "Role deleted",
+		},
+	})
+}
+
+// AssignRole gives a user another role. Their current access tokens are
+// revoked so the next refresh carries the new permissions.
+func (h *RoleHandler) AssignRole(c *gin.Context) {
+	var req models.AssignRoleRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	user, err := h.roleService.AssignRole(c.GetString("user_id"), c.Param("id"), req.Role)
+	if err != nil {
+		respondRoleError(c, err, "Failed to assign role")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": user})
+}
+
+func (h *RoleHandler) bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+	return true
+}
+
+func respondRoleError(c *gin.Context, err error, message string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, services.ErrRoleNotFound):
+		status, code, message = http.StatusNotFound, "ROLE_NOT_FOUND", err.Error()
+	case errors.Is(err, services.ErrUserNotFound):
+		status, code, message = http.StatusNotFound, "USER_NOT_FOUND", err.Error()
+	case errors.Is(err, services.ErrRoleExists):
+		status, code, message = http.StatusConflict, "ROLE_EXISTS", err.Error()
+	case errors.Is(err, services.ErrRoleInUse):
+		status, code, message = http.StatusConflict, "ROLE_IN_USE", err.Error()
+	case errors.Is(err, services.ErrSystemRole), errors.Is(err, services.ErrAdminRoleFixed):
+		status, code, message = http.StatusConflict, "ROLE_PROTECTED", err.Error()
+	case errors.Is(err, services.ErrOwnRole):
+		status, code, message = http.StatusForbidden, "FORBIDDEN", err.Error()
+	case errors.Is(err, services.ErrInvalidRoleName), errors.Is(err, services.ErrUnknownPermission):
+		status, code, message = http.StatusUnprocessableEntity, "VALIDATION_ERROR", err.Error()
+	}
+
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
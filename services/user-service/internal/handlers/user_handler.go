@@ -1,9 +1,18 @@
 package handlers
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"sort"
+	"strconv"
 
+	"user-service/internal/lockout"
 	"user-service/internal/models"
+	"user-service/internal/password"
 	"user-service/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -48,6 +57,9 @@ func (h *UserHandler) Register(c *gin.Context) {
 	}
 
 	user, err := h.userService.Register(&req)
+	if respondPasswordPolicy(c, err) {
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
@@ -91,8 +103,22 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userService.Login(&req)
+	response, challenge, err := h.userService.Login(&req, clientInfo(c))
 	if err != nil {
+		if respondLocked(c, err) {
+			return
+		}
+
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"code":    "EMAIL_NOT_VERIFIED",
+					"message": err.Error(),
+				},
+			})
+			return
+		}
+
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": gin.H{
 				"code":    "LOGIN_FAILED",
@@ -102,6 +128,64 @@ func (h *UserHandler) Login(c *gin.Context) {
 		return
 	}
 
+	if challenge != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"data": challenge,
+			"meta": gin.H{
+				"message": "Multi-factor authentication required",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data": response,
+		"meta": gin.H{
+			"message": "Login successful",
+		},
+	})
+}
+
+func (h *UserHandler) VerifyMFA(c *gin.Context) {
+	var req models.MFAVerifyRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	if err := h.validator.Struct(&req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	response, err := h.userService.VerifyMFA(&req, clientInfo(c))
+	if err != nil {
+		if respondLocked(c, err) {
+			return
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "MFA_FAILED",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"data": response,
 		"meta": gin.H{
@@ -110,6 +194,24 @@ func (h *UserHandler) Login(c *gin.Context) {
 	})
 }
 
+// respondLocked answers 429 with a Retry-After header when err is a
+// *lockout.LockedError and reports whether it did.
+func respondLocked(c *gin.Context, err error) bool {
+	var locked *lockout.LockedError
+	if !errors.As(err, &locked) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error": gin.H{
+			"code":    "TOO_MANY_ATTEMPTS",
+			"message": err.Error(),
+		},
+	})
+	return true
+}
+
 func (h *UserHandler) GetProfile(c *gin.Context) {
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -163,6 +265,7 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 		return
 	}
 
+	req.ClearEmpty()
 	if err := h.validator.Struct(&req); err != nil {
 		c.JSON(http.StatusUnprocessableEntity, gin.H{
 			"error": gin.H{
@@ -175,22 +278,63 @@ func (h *UserHandler) UpdateProfile(c *gin.Context) {
 	}
 
 	user, err := h.userService.UpdateProfile(userID.(string), &req)
-	if err != nil {
+	respondProfileUpdate(c, user, err)
+}
+
+// PatchProfile applies a JSON Merge Patch (RFC 7396) to the profile: members
+// with a value replace the field, null clears a nullable field and absent
+// members are left unchanged.
+func (h *UserHandler) PatchProfile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User ID not found in token",
+			},
+		})
+		return
+	}
+
+	if contentType := c.ContentType(); contentType != mergePatchContentType && contentType != gin.MIMEJSON {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"error": gin.H{
+				"code":    "UNSUPPORTED_MEDIA_TYPE",
+				"message": "Content-Type must be " + mergePatchContentType,
+			},
+		})
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": gin.H{
-				"code":    "UPDATE_FAILED",
-				"message": err.Error(),
+				"code":    "VALIDATION_ERROR",
+				"message": "Merge patch must be a JSON object",
+				"details": err.Error(),
 			},
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"data": user,
-		"meta": gin.H{
-			"message": "Profile updated successfully",
-		},
-	})
+	req, err := profilePatch(patch)
+	if err == nil {
+		err = h.validator.Struct(req)
+	}
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return
+	}
+
+	user, err := h.userService.UpdateProfile(userID.(string), req)
+	respondProfileUpdate(c, user, err)
 }
 
 func (h *UserHandler) DeleteAccount(c *gin.Context) {
@@ -226,7 +370,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 	userID := c.Param("id")
 
 	user, err := h.userService.GetUserByID(userID)
-	if err != nil {
+	if errors.Is(err, services.ErrUserNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": gin.H{
 				"code":    "USER_NOT_FOUND",
@@ -235,6 +379,15 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "INTERNAL_ERROR",
+				"message": "Failed to load user",
+			},
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"data": user,
@@ -266,7 +419,16 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		return
 	}
 
-	response, err := h.userService.RefreshToken(req.RefreshToken)
+	response, err := h.userService.RefreshToken(req.RefreshToken, clientInfo(c))
+	if errors.Is(err, services.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "REFRESH_TOKEN_REUSED",
+				"message": "Refresh token was already used; the session has been revoked",
+			},
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{
 			"error": gin.H{
@@ -284,3 +446,163 @@ func (h *UserHandler) RefreshToken(c *gin.Context) {
 		},
 	})
 }
+
+func (h *UserHandler) Logout(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User ID not found in token",
+			},
+		})
+		return
+	}
+
+	err := h.userService.Logout(userID, c.GetString("session_id"), c.GetString("token_id"), c.GetTime("token_expires_at"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "LOGOUT_FAILED",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"message": "Logged out successfully",
+		},
+	})
+}
+
+func (h *UserHandler) LogoutAll(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": gin.H{
+				"code":    "UNAUTHORIZED",
+				"message": "User ID not found in token",
+			},
+		})
+		return
+	}
+
+	if err := h.userService.LogoutAll(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "LOGOUT_FAILED",
+				"message": err.Error(),
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"message": "Logged out from all devices",
+		},
+	})
+}
+
+// respondPasswordPolicy writes a 422 listing each failed password rule when
+// err is a policy violation, and reports whether it did.
+func respondPasswordPolicy(c *gin.Context, err error) bool {
+	var policyErr *password.PolicyError
+	if !errors.As(err, &policyErr) {
+		return false
+	}
+
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error": gin.H{
+			"code":    "PASSWORD_POLICY_VIOLATION",
+			"message": "Password does not meet the password policy",
+			"details": policyErr.Violations,
+		},
+	})
+	return true
+}
+
+const mergePatchContentType = "application/merge-patch+json"
+
+// profilePatch turns a flat merge patch into an update request. Null members
+// become fields to clear; the rest must be known profile fields.
+func profilePatch(patch map[string]json.RawMessage) (*models.UpdateUserRequest, error) {
+	req := &models.UpdateUserRequest{}
+	values := make(map[string]json.RawMessage, len(patch))
+
+	for name, raw := range patch {
+		if string(bytes.TrimSpace(raw)) == "null" {
+			if !models.NullableProfileFields[name] {
+				return nil, fmt.Errorf("field %q cannot be null", name)
+			}
+			req.Clear = append(req.Clear, name)
+			continue
+		}
+		values[name] = raw
+	}
+	sort.Strings(req.Clear)
+
+	body, err := json.Marshal(values)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(req); err != nil {
+		return nil, err
+	}
+	req.ClearEmpty()
+
+	return req, nil
+}
+
+func respondProfileUpdate(c *gin.Context, user *models.User, err error) {
+	switch {
+	case errors.Is(err, services.ErrEmailTaken):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"code":    "EMAIL_TAKEN",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrUsernameTaken):
+		c.JSON(http.StatusConflict, gin.H{
+			"error": gin.H{
+				"code":    "USERNAME_TAKEN",
+				"message": err.Error(),
+			},
+		})
+	case errors.Is(err, services.ErrUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"code":    "USER_NOT_FOUND",
+				"message": err.Error(),
+			},
+		})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"code":    "UPDATE_FAILED",
+				"message": "Failed to update profile",
+			},
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"data": user,
+			"meta": gin.H{
+				"message": "Profile updated successfully",
+			},
+		})
+	}
+}
+
+// clientInfo captures where a request came from for session tracking.
+func clientInfo(c *gin.Context) models.ClientInfo {
+	return models.ClientInfo{
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+}
@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// WebhookHandler serves webhook subscription management to administrators.
+type WebhookHandler struct {
+	webhookService services.WebhookService
+	validator      *validator.Validate
+}
+
+func NewWebhookHandler(webhookService services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		validator:      validator.New(),
+	}
+}
+
+// CreateSubscription registers a partner endpoint. The response carries the
+// signing secret, which is never shown again.
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	sub, err := h.webhookService.CreateSubscription(&req)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to create webhook subscription")
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"data": sub,
+		"meta": gin.H{
+			"message": "Webhook subscription created; store the secret now, it is not shown again",
+		},
+	})
+}
+
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	var query models.PageQuery
+	if !h.bindQuery(c, &query) {
+		return
+	}
+
+	subs, pagination, err := h.webhookService.ListSubscriptions(query)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to list webhook subscriptions")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       subs,
+		"pagination": pagination,
+	})
+}
+
+func (h *WebhookHandler) GetSubscription(c *gin.Context) {
+	sub, err := h.webhookService.GetSubscription(c.Param("id"))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to load webhook subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sub})
+}
+
+// UpdateSubscription changes a subscription; setting is_active to true
+// re-enables one that was disabled after persistent failures.
+func (h *WebhookHandler) UpdateSubscription(c *gin.Context) {
+	var req models.UpdateWebhookRequest
+	if !h.bindJSON(c, &req) {
+		return
+	}
+
+	sub, err := h.webhookService.UpdateSubscription(c.Param("id"), &req)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to update webhook subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sub})
+}
+
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	if err := h.webhookService.DeleteSubscription(c.Param("id")); err != nil {
+		respondWebhookError(c, err, "Failed to delete webhook subscription")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"meta": gin.H{
+			"message": "Webhook subscription deleted",
+		},
+	})
+}
+
+// ListDeliveries lists a subscription's deliveries, newest first.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var query models.PageQuery
+	if !h.bindQuery(c, &query) {
+		return
+	}
+
+	deliveries, pagination, err := h.webhookService.ListDeliveries(c.Param("id"), query)
+	if err != nil {
+		respondWebhookError(c, err, "Failed to list webhook deliveries")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":       deliveries,
+		"pagination": pagination,
+	})
+}
+
+// GetDelivery returns a delivery with its attempt log.
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery, err := h.webhookService.GetDelivery(c.Param("id"), c.Param("deliveryId"))
+	if err != nil {
+		respondWebhookError(c, err, "Failed to load webhook delivery")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": delivery})
+}
+
+// ReplayDelivery schedules a delivery to be sent again.
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	if err := h.webhookService.ReplayDelivery(c.Param("id"), c.Param("deliveryId")); err != nil {
+		respondWebhookError(c, err, "Failed to replay webhook delivery")
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"meta": gin.H{
+			"message": "Delivery scheduled for replay",
+		},
+	})
+}
+
+func (h *WebhookHandler) bindJSON(c *gin.Context, req interface{}) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid request body",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+	return h.validate(c, req)
+}
+
+func (h *WebhookHandler) bindQuery(c *gin.Context, query *models.PageQuery) bool {
+	if err := c.ShouldBindQuery(query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Invalid query parameters",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+	return h.validate(c, query)
+}
+
+func (h *WebhookHandler) validate(c *gin.Context, req interface{}) bool {
+	if err := h.validator.Struct(req); err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error": gin.H{
+				"code":    "VALIDATION_ERROR",
+				"message": "Validation failed",
+				"details": err.Error(),
+			},
+		})
+		return false
+	}
+	return true
+}
+
+func respondWebhookError(c *gin.Context, err error, message string) {
+	status, code := http.StatusInternalServerError, "INTERNAL_ERROR"
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		status, code, message = http.StatusNotFound, "WEBHOOK_NOT_FOUND", err.Error()
+	case errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		status, code, message = http.StatusNotFound, "WEBHOOK_DELIVERY_NOT_FOUND", err.Error()
+	case errors.Is(err, services.ErrInvalidWebhookURL):
+		status, code, message = http.StatusUnprocessableEntity, "VALIDATION_ERROR", err.Error()
+	}
+
+	c.JSON(status, gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+		},
+	})
+}
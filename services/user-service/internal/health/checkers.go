@@ -0,0 +1,44 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Postgres checks that the database answers a ping.
+func Postgres(db *sql.DB) Checker {
+	return CheckerFunc(func(ctx context.Context) (string, error) {
+		return "", db.PingContext(ctx)
+	})
+}
+
+// Redis checks that Redis answers a PING.
+func Redis(client *redis.Client) Checker {
+	return CheckerFunc(func(ctx context.Context) (string, error) {
+		return "", client.Ping(ctx).Err()
+	})
+}
+
+// MigrationVersioner is the part of database.Migrator the migration check uses.
+type MigrationVersioner interface {
+	Version(ctx context.Context) (current int64, pending int, err error)
+}
+
+// Migrations checks that every embedded migration has been applied, so the
+// code never runs against an older schema than it was built for.
+func Migrations(m MigrationVersioner) Checker {
+	return CheckerFunc(func(ctx context.Context) (string, error) {
+		current, pending, err := m.Version(ctx)
+		if err != nil {
+			return "", err
+		}
+		detail := fmt.Sprintf("version %d", current)
+		if pending > 0 {
+			return detail, fmt.Errorf("%d migration(s) pending", pending)
+		}
+		return detail, nil
+	})
+}
@@ -0,0 +1,138 @@
+// Package health runs dependency checks for the health endpoints. Results are
+// cached for a short time so frequent probes from load balancers and
+// orchestrators don't turn into a steady load on the database.
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	StatusHealthy   = "healthy"
+	StatusUnhealthy = "unhealthy"
+)
+
+// Checker probes one dependency. detail is optional extra information, such
+// as the schema version, shown next to the result.
+type Checker interface {
+	Check(ctx context.Context) (detail string, err error)
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) (string, error)
+
+func (f CheckerFunc) Check(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    string `json:"status"`
+	Critical  bool   `json:"critical"`
+	Detail    string `json:"detail,omitempty"`
+	Error     string `json:"error,omitempty"`
+	LatencyMS int64  `json:"latency_ms"`
+}
+
+// Report is the outcome of all checks. Status is unhealthy when any critical
+// check failed; a failed non-critical check only shows in its own result.
+type Report struct {
+	Status    string            `json:"status"`
+	CheckedAt time.Time         `json:"-"`
+	Checks    map[string]Result `json:"checks"`
+}
+
+// Dependencies maps every check to its status.
+func (r Report) Dependencies() map[string]string {
+	deps := make(map[string]string, len(r.Checks))
+	for name, result := range r.Checks {
+		deps[name] = result.Status
+	}
+	return deps
+}
+
+type registered struct {
+	name     string
+	critical bool
+	checker  Checker
+}
+
+// Registry runs the registered checks concurrently, each bounded by timeout,
+// and reuses the last report for ttl. Concurrent callers during a run wait
+// for it instead of starting their own.
+type Registry struct {
+	timeout time.Duration
+	ttl     time.Duration
+	checks  []registered
+
+	mu     sync.Mutex
+	cached *Report
+}
+
+func NewRegistry(timeout, ttl time.Duration) *Registry {
+	return &Registry{timeout: timeout, ttl: ttl}
+}
+
+// Add registers a check. Critical checks decide readiness.
+func (r *Registry) Add(name string, critical bool, checker Checker) {
+	r.checks = append(r.checks, registered{name: name, critical: critical, checker: checker})
+}
+
+// Check returns the cached report if it is fresh, otherwise runs every check.
+func (r *Registry) Check() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.cached != nil && time.Since(r.cached.CheckedAt) < r.ttl {
+		return *r.cached
+	}
+
+	report := r.run()
+	r.cached = &report
+	return report
+}
+
+func (r *Registry) run() Report {
+	results := make([]Result, len(r.checks))
+
+	var wg sync.WaitGroup
+	for i, c := range r.checks {
+		i, c := i, c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// Not tied to the request: a cancelled probe must not cache a failure.
+			ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+			defer cancel()
+
+			start := time.Now()
+			detail, err := c.checker.Check(ctx)
+			result := Result{
+				Status:    StatusHealthy,
+				Critical:  c.critical,
+				Detail:    detail,
+				LatencyMS: time.Since(start).Milliseconds(),
+			}
+			if err != nil {
+				result.Status = StatusUnhealthy
+				result.Error = err.Error()
+				logrus.WithError(err).WithFields(logrus.Fields{"check": c.name, "critical": c.critical}).Warn("Health check failed")
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusHealthy, CheckedAt: time.Now(), Checks: make(map[string]Result, len(results))}
+	for i, c := range r.checks {
+		report.Checks[c.name] = results[i]
+		if c.critical && results[i].Status != StatusHealthy {
+			report.Status = StatusUnhealthy
+		}
+	}
+	return report
+}
@@ -0,0 +1,310 @@
+package keystore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// minReloadInterval bounds how often an unknown kid may trigger a reload, so
+// tokens with made-up key IDs can't be used to hammer the database.
+const minReloadInterval = 30 * time.Second
+
+// JWKSMaxAge is how long verifiers may cache the published key set.
+const JWKSMaxAge = 5 * time.Minute
+
+// Signer hands out the key new tokens are signed with.
+type Signer interface {
+	SigningKey() (*Key, error)
+}
+
+// Resolver looks up the key a token was signed with by its kid header.
+type Resolver interface {
+	VerificationKey(kid string) (*Key, error)
+}
+
+// KeyStore is implemented by every store in this package.
+type KeyStore interface {
+	Signer
+	Resolver
+	JWKS() JWKSet
+}
+
+// Store keeps the database-backed key set in memory. Replicas share keys
+// through the jwt_signing_keys table and pick up rotations on refresh.
+//
+// A rotated-in key is pending at first: it is published in JWKS but only
+// starts signing once every replica has refreshed and cached key sets have
+// expired, so verifiers never see a kid they don't know.
+type Store struct {
+	repo              repository.SigningKeyRepository
+	algorithm         string
+	rotationInterval  time.Duration
+	verificationGrace time.Duration
+	refreshInterval   time.Duration
+	publishLead       time.Duration
+	now               func() time.Time
+
+	mu        sync.RWMutex
+	signing   *Key
+	pending   *Key
+	pendingAt time.Time
+	rotatedAt time.Time
+	keys      map[string]*Key
+	published []*Key
+	loadedAt  time.Time
+}
+
+func NewStore(repo repository.SigningKeyRepository, cfg config.JWTConfig) *Store {
+	refreshInterval := time.Duration(cfg.KeyRefreshInterval) * time.Minute
+	return &Store{
+		repo:              repo,
+		algorithm:         cfg.Algorithm,
+		rotationInterval:  time.Duration(cfg.KeyRotationInterval) * time.Hour,
+		verificationGrace: time.Duration(cfg.KeyVerificationGrace) * time.Hour,
+		refreshInterval:   refreshInterval,
+		publishLead:       refreshInterval + JWKSMaxAge,
+		now:               time.Now,
+		keys:              make(map[string]*Key),
+	}
+}
+
+// SetClock replaces the time source, letting tests skip through key activation.
+func (s *Store) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// Init loads the key set and creates the first signing key if there is none.
+func (s *Store) Init() error {
+	if err := s.Reload(); err != nil {
+		return err
+	}
+	return s.rotateIfDue()
+}
+
+// Reload replaces the in-memory key set with what is currently stored.
+func (s *Store) Reload() error {
+	stored, err := s.repo.ListValid()
+	if err != nil {
+		return err
+	}
+
+	s.mu.RLock()
+	now := s.now()
+	s.mu.RUnlock()
+
+	keys := make(map[string]*Key, len(stored))
+	published := make([]*Key, 0, len(stored))
+	var signing, pending *Key
+	var pendingAt, rotatedAt time.Time
+
+	for _, m := range stored {
+		key, err := FromModel(m)
+		if err != nil {
+			logrus.WithError(err).WithField("kid", m.KID).Error("Skipping unreadable signing key")
+			continue
+		}
+
+		keys[key.ID] = key
+		published = append(published, key)
+
+		if rotatedAt.IsZero() && m.RetiredAt == nil {
+			rotatedAt = m.CreatedAt
+		}
+
+		// Rows are ordered newest first, so the first active key signs and
+		// the last pending one before it is the next to take over.
+		switch {
+		case signing != nil:
+		case m.ActivatesAt.After(now):
+			pending, pendingAt = key, m.ActivatesAt
+		default:
+			signing = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.published = published
+	s.signing = signing
+	s.pending = pending
+	s.pendingAt = pendingAt
+	s.rotatedAt = rotatedAt
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Rotate publishes a new signing key right away. It takes over signing once
+// cached key sets include it, and the previous one keeps verifying tokens for
+// the configured grace period after that.
+func (s *Store) Rotate() error {
+	s.mu.RLock()
+	now := s.now()
+	s.mu.RUnlock()
+
+	return s.rotate(now)
+}
+
+func (s *Store) rotateIfDue() error {
+	s.mu.RLock()
+	now := s.now()
+	due := (s.signing == nil && s.pending == nil) || now.Sub(s.rotatedAt) >= s.rotationInterval
+	s.mu.RUnlock()
+
+	if !due {
+		return nil
+	}
+
+	return s.rotate(now.Add(-s.rotationInterval))
+}
+
+func (s *Store) rotate(staleBefore time.Time) error {
+	key, err := Generate(s.algorithm)
+	if err != nil {
+		return err
+	}
+
+	m, err := key.ToModel()
+	if err != nil {
+		return err
+	}
+
+	// The very first key has nobody to replace and signs immediately.
+	s.mu.RLock()
+	m.ActivatesAt = s.now()
+	if s.signing != nil || s.pending != nil {
+		m.ActivatesAt = m.ActivatesAt.Add(s.publishLead)
+	}
+	s.mu.RUnlock()
+
+	rotated, err := s.repo.RotateIfStale(m, staleBefore, m.ActivatesAt.Add(s.verificationGrace))
+	if err != nil {
+		return err
+	}
+	if rotated {
+		logrus.WithFields(logrus.Fields{
+			"kid":          key.ID,
+			"alg":          key.Algorithm,
+			"activates_at": m.ActivatesAt,
+		}).Info("Rotated JWT signing key")
+	}
+
+	return s.Reload()
+}
+
+// Run refreshes the key set and rotates on schedule until ctx is cancelled.
+func (s *Store) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Reload(); err != nil {
+				logrus.WithError(err).Error("Failed to reload JWT signing keys")
+				continue
+			}
+			if err := s.rotateIfDue(); err != nil {
+				logrus.WithError(err).Error("Failed to rotate JWT signing key")
+			}
+			if err := s.repo.DeleteExpired(); err != nil {
+				logrus.WithError(err).Warn("Failed to delete expired JWT signing keys")
+			}
+		}
+	}
+}
+
+func (s *Store) SigningKey() (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.pending != nil && !s.now().Before(s.pendingAt) {
+		return s.pending, nil
+	}
+	if s.signing == nil {
+		return nil, ErrNoSigningKey
+	}
+	return s.signing, nil
+}
+
+func (s *Store) VerificationKey(kid string) (*Key, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	fresh := time.Since(s.loadedAt) < minReloadInterval
+	s.mu.RUnlock()
+
+	if ok {
+		return key, nil
+	}
+	if fresh {
+		return nil, ErrUnknownKey
+	}
+
+	// The key may have been rotated in by another replica since our last refresh.
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok := s.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+func (s *Store) JWKS() JWKSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(s.published))}
+	for _, key := range s.published {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
+
+// StaticStore serves a fixed set of keys, the first one signing. It suits
+// tests and tools that don't need rotation.
+type StaticStore struct {
+	keys []*Key
+}
+
+func NewStaticStore(keys ...*Key) *StaticStore {
+	return &StaticStore{keys: keys}
+}
+
+func (s *StaticStore) SigningKey() (*Key, error) {
+	if len(s.keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return s.keys[0], nil
+}
+
+func (s *StaticStore) VerificationKey(kid string) (*Key, error) {
+	for _, key := range s.keys {
+		if key.ID == kid {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (s *StaticStore) JWKS() JWKSet {
+	set := JWKSet{Keys: make([]JWK, 0, len(s.keys))}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, key.JWK())
+	}
+	return set
+}
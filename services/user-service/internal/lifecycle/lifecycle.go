@@ -0,0 +1,192 @@
+// Package lifecycle starts the service's servers and background workers and
+// shuts them down in order when the process is asked to stop.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type server struct {
+	name     string
+	serve    func() error
+	shutdown func(ctx context.Context) error
+}
+
+type worker struct {
+	name string
+	run  func(ctx context.Context)
+}
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Manager runs servers and workers until SIGINT/SIGTERM, the parent context
+// ending or a server failing, then shuts down in this order:
+//
+//  1. readiness flips to false, and the manager waits drainDelay so load
+//     balancers stop sending new requests;
+//  2. servers stop accepting connections and finish in-flight requests;
+//  3. workers are cancelled and waited for;
+//  4. closers run in reverse registration order (pools opened first close last).
+//
+// Steps 2-4 share the shutdown timeout.
+type Manager struct {
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+
+	servers []server
+	workers []worker
+	closers []closer
+
+	ready atomic.Bool
+}
+
+// New creates a Manager. shutdownTimeout bounds the whole shutdown after the
+// drain delay.
+func New(shutdownTimeout, drainDelay time.Duration) *Manager {
+	return &Manager{shutdownTimeout: shutdownTimeout, drainDelay: drainDelay}
+}
+
+// AddServer registers a listener. serve must block until shutdown makes it
+// return; a nil error or http.ErrServerClosed counts as a clean stop.
+func (m *Manager) AddServer(name string, serve func() error, shutdown func(ctx context.Context) error) {
+	m.servers = append(m.servers, server{name: name, serve: serve, shutdown: shutdown})
+}
+
+// AddHTTPServer registers srv, served on its Addr.
+func (m *Manager) AddHTTPServer(name string, srv *http.Server) {
+	m.AddServer(name, srv.ListenAndServe, srv.Shutdown)
+}
+
+// Go registers a background worker. run must return once ctx is cancelled.
+func (m *Manager) Go(name string, run func(ctx context.Context)) {
+	m.workers = append(m.workers, worker{name: name, run: run})
+}
+
+// OnShutdown registers a resource to release after servers and workers have
+// stopped.
+func (m *Manager) OnShutdown(name string, close func() error) {
+	m.closers = append(m.closers, closer{name: name, close: close})
+}
+
+// Ready reports whether the service should receive traffic: true once Run has
+// started everything and false again as soon as shutdown begins.
+func (m *Manager) Ready() bool {
+	return m.ready.Load()
+}
+
+// Run starts everything and blocks until shutdown has finished. It returns
+// the first server failure, or a shutdown error, if any.
+func (m *Manager) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	failed := make(chan error, len(m.servers))
+	for _, s := range m.servers {
+		s := s
+		go func() {
+			logrus.WithField("server", s.name).Info("Server starting")
+			if err := s.serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				failed <- fmt.Errorf("%s: %w", s.name, err)
+			}
+		}()
+	}
+
+	workerCtx, cancelWorkers := context.WithCancel(context.Background())
+	defer cancelWorkers()
+	var workers sync.WaitGroup
+	for _, w := range m.workers {
+		w := w
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			w.run(workerCtx)
+			logrus.WithField("worker", w.name).Debug("Worker stopped")
+		}()
+	}
+
+	m.ready.Store(true)
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		logrus.Info("Shutdown requested")
+	case runErr = <-failed:
+		logrus.WithError(runErr).Error("Server failed, shutting down")
+	}
+	stop()
+
+	m.ready.Store(false)
+	if runErr == nil && m.drainDelay > 0 {
+		logrus.WithField("delay", m.drainDelay).Info("Readiness failing, waiting for traffic to drain")
+		time.Sleep(m.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), m.shutdownTimeout)
+	defer cancel()
+
+	errs := []error{runErr}
+	errs = append(errs, m.stopServers(shutdownCtx)...)
+
+	cancelWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-shutdownCtx.Done():
+		errs = append(errs, errors.New("workers did not stop before the shutdown deadline"))
+	}
+
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		c := m.closers[i]
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("close %s: %w", c.name, err))
+		}
+	}
+
+	err := errors.Join(errs...)
+	if err == nil {
+		logrus.Info("Shutdown complete")
+	}
+	return err
+}
+
+// stopServers shuts every server down concurrently so one slow server doesn't
+// eat the others' share of the deadline.
+func (m *Manager) stopServers(ctx context.Context) []error {
+	var (
+		mu   sync.Mutex
+		errs []error
+		wg   sync.WaitGroup
+	)
+	for _, s := range m.servers {
+		s := s
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("shutdown %s: %w", s.name, err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errs
+}
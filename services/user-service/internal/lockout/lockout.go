@@ -0,0 +1,157 @@
+// Package lockout tracks failed login attempts and decides how long a client
+// has to wait before trying again: short exponential back-off after a few
+// failures, then a temporary lockout.
+package lockout
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxBackoffShift keeps the exponential delay from overflowing.
+const maxBackoffShift = 20
+
+// LockedError is returned while a key has to wait before the next attempt.
+type LockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("too many failed login attempts, retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// Policy turns a failure count into a wait time.
+type Policy struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	Threshold       int
+	LockoutDuration time.Duration
+	Window          time.Duration
+}
+
+// PoliciesFromConfig returns the per-account and per-IP policies. They share
+// the back-off curve but lock out at different thresholds, since many users
+// can sit behind one address.
+func PoliciesFromConfig(cfg config.LockoutConfig) (account, ip Policy) {
+	account = Policy{
+		FreeAttempts:    cfg.FreeAttempts,
+		BaseDelay:       time.Duration(cfg.BaseDelay) * time.Second,
+		MaxDelay:        time.Duration(cfg.MaxDelay) * time.Second,
+		Threshold:       cfg.Threshold,
+		LockoutDuration: time.Duration(cfg.Duration) * time.Minute,
+		Window:          time.Duration(cfg.Window) * time.Minute,
+	}
+	ip = account
+	ip.Threshold = cfg.IPThreshold
+	return account, ip
+}
+
+// DelayAfter returns how long to wait after the given number of consecutive
+// failures. Once the threshold is reached every further failure locks the key
+// for the full lockout duration again.
+func (p Policy) DelayAfter(failures int) time.Duration {
+	if p.Threshold > 0 && failures >= p.Threshold {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts || p.BaseDelay <= 0 {
+		return 0
+	}
+
+	shift := failures - p.FreeAttempts - 1
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	delay := p.BaseDelay << shift
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Tracker stores failure counts per key.
+type Tracker interface {
+	// Check returns how long the key still has to wait, zero if it may try now.
+	Check(ctx context.Context, key string) (time.Duration, error)
+	// Fail records a failed attempt and returns the resulting wait.
+	Fail(ctx context.Context, key string) (time.Duration, error)
+	// Reset forgets all failures of the key.
+	Reset(ctx context.Context, key string) error
+}
+
+// Guard applies account and client IP tracking to logins. Tracker errors are
+// logged and otherwise ignored: an unavailable store must not block sign-in.
+type Guard struct {
+	accounts Tracker
+	ips      Tracker
+}
+
+func NewGuard(accounts, ips Tracker) *Guard {
+	return &Guard{accounts: accounts, ips: ips}
+}
+
+// Check returns a *LockedError when either the account or the IP must wait.
+func (g *Guard) Check(ctx context.Context, email, ip string) error {
+	wait := g.wait(ctx, g.accounts.Check, accountKey(email))
+	if ip != "" {
+		if ipWait := g.wait(ctx, g.ips.Check, ipKey(ip)); ipWait > wait {
+			wait = ipWait
+		}
+	}
+	return lockedError(wait)
+}
+
+// Fail records a failed login for the account and the IP and returns a
+// *LockedError if the next attempt has to wait.
+func (g *Guard) Fail(ctx context.Context, email, ip string) error {
+	wait := g.wait(ctx, g.accounts.Fail, accountKey(email))
+	if ip != "" {
+		if ipWait := g.wait(ctx, g.ips.Fail, ipKey(ip)); ipWait > wait {
+			wait = ipWait
+		}
+	}
+	return lockedError(wait)
+}
+
+// Succeed clears the account's failures. The IP count is left alone so one
+// valid account cannot be used to reset an address that is guessing others.
+func (g *Guard) Succeed(ctx context.Context, email string) {
+	if err := g.accounts.Reset(ctx, accountKey(email)); err != nil {
+		logrus.WithError(err).Warn("Failed to reset login attempts")
+	}
+}
+
+// Unlock lifts a lockout on the account, e.g. after an admin verified the user.
+func (g *Guard) Unlock(ctx context.Context, email string) error {
+	return g.accounts.Reset(ctx, accountKey(email))
+}
+
+func (g *Guard) wait(ctx context.Context, op func(context.Context, string) (time.Duration, error), key string) time.Duration {
+	wait, err := op(ctx, key)
+	if err != nil {
+		logrus.WithError(err).WithField("key", key).Warn("Login attempt tracking unavailable")
+		return 0
+	}
+	return wait
+}
+
+func lockedError(wait time.Duration) error {
+	if wait <= 0 {
+		return nil
+	}
+	return &LockedError{RetryAfter: wait}
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,94 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type attempts struct {
+	failures    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// MemoryTracker keeps failure counts in process memory. It suits tests and
+// single-instance development setups.
+type MemoryTracker struct {
+	policy Policy
+	now    func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*attempts
+}
+
+func NewMemoryTracker(policy Policy) *MemoryTracker {
+	return &MemoryTracker{
+		policy: policy,
+		now:    time.Now,
+		keys:   make(map[string]*attempts),
+	}
+}
+
+// SetClock replaces the time source, letting tests skip through back-off.
+func (t *MemoryTracker) SetClock(now func() time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.now = now
+}
+
+func (t *MemoryTracker) Check(_ context.Context, key string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	a := t.current(key)
+	if a == nil {
+		return 0, nil
+	}
+	if wait := a.lockedUntil.Sub(t.now()); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+func (t *MemoryTracker) Fail(_ context.Context, key string) (time.Duration, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	a := t.current(key)
+	if a == nil {
+		a = &attempts{}
+		t.keys[key] = a
+	}
+
+	a.failures++
+	a.lastFailure = now
+
+	delay := t.policy.DelayAfter(a.failures)
+	a.lockedUntil = now.Add(delay)
+	return delay, nil
+}
+
+func (t *MemoryTracker) Reset(_ context.Context, key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.keys, key)
+	return nil
+}
+
+// current returns the key's attempts, dropping them once the window passed.
+func (t *MemoryTracker) current(key string) *attempts {
+	a, ok := t.keys[key]
+	if !ok {
+		return nil
+	}
+
+	now := t.now()
+	if now.Sub(a.lastFailure) > t.policy.Window && !now.Before(a.lockedUntil) {
+		delete(t.keys, key)
+		return nil
+	}
+	return a
+}
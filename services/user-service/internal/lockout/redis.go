@@ -0,0 +1,82 @@
+package lockout
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const keyPrefix = "auth:login-attempts:"
+
+// RedisTracker shares failure counts between replicas. Each key is a hash
+// with the failure count and the lock expiry, expiring after the window.
+type RedisTracker struct {
+	client redis.UniversalClient
+	policy Policy
+}
+
+func NewRedisTracker(client redis.UniversalClient, policy Policy) *RedisTracker {
+	return &RedisTracker{client: client, policy: policy}
+}
+
+func (t *RedisTracker) Check(ctx context.Context, key string) (time.Duration, error) {
+	raw, err := t.client.HGet(ctx, keyPrefix+key, "locked_until").Result()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return remaining(raw)
+}
+
+func (t *RedisTracker) Fail(ctx context.Context, key string) (time.Duration, error) {
+	redisKey := keyPrefix + key
+
+	var failures *redis.IntCmd
+	if _, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		failures = pipe.HIncrBy(ctx, redisKey, "failures", 1)
+		pipe.PExpire(ctx, redisKey, t.policy.Window)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	delay := t.policy.DelayAfter(int(failures.Val()))
+	if delay <= 0 {
+		return 0, nil
+	}
+
+	ttl := t.policy.Window
+	if delay > ttl {
+		ttl = delay
+	}
+
+	lockedUntil := time.Now().Add(delay).UnixMilli()
+	if _, err := t.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, "locked_until", lockedUntil)
+		pipe.PExpire(ctx, redisKey, ttl)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return delay, nil
+}
+
+func (t *RedisTracker) Reset(ctx context.Context, key string) error {
+	return t.client.Del(ctx, keyPrefix+key).Err()
+}
+
+func remaining(lockedUntil string) (time.Duration, error) {
+	ms, err := strconv.ParseInt(lockedUntil, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if wait := time.Until(time.UnixMilli(ms)); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
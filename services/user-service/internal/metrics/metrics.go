@@ -0,0 +1,179 @@
+// Package metrics defines the service's Prometheus collectors. They are
+// registered with the default registry, next to the Go runtime and process
+// collectors, and exposed by Handler.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"user-service/internal/password"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "user_service"
+
+var (
+	// HTTPRequestDuration is labelled with the route template (/api/v1/users/:id),
+	// never the raw path, so IDs don't create a series per user.
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_in_flight",
+		Help:      "HTTP requests currently being served.",
+	})
+
+	// Logins counts login attempts by result (success, failure, mfa_required)
+	// and, for failures, the reason.
+	Logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "logins_total",
+		Help:      "Login attempts by result and failure reason.",
+	}, []string{"result", "reason"})
+
+	Registrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "registrations_total",
+		Help:      "Registration attempts by result.",
+	}, []string{"result"})
+
+	TokenRefreshes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "token_refreshes_total",
+		Help:      "Refresh token exchanges by result and failure reason.",
+	}, []string{"result", "reason"})
+
+	TokenValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "token_validation_failures_total",
+		Help:      "Access tokens rejected by the auth middleware, by reason.",
+	}, []string{"reason"})
+
+	PasswordHashDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "password",
+		Name:      "hash_duration_seconds",
+		Help:      "Time spent hashing and verifying passwords, by operation and algorithm.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "algorithm"})
+
+	// OutboxEvents counts publish attempts by the outbox relay by outcome:
+	// published, retried or dead.
+	OutboxEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "outbox",
+		Name:      "events_total",
+		Help:      "Outbox publish attempts by outcome.",
+	}, []string{"outcome"})
+
+	// ConsumedEvents counts events from other services by type and result:
+	// processed, duplicate, skipped (can never apply) or failed (retried).
+	ConsumedEvents = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "events_total",
+		Help:      "Consumed events by type and result.",
+	}, []string{"event_type", "result"})
+
+	// WebhookDeliveries counts webhook delivery attempts by outcome:
+	// delivered, retried or failed (attempts exhausted).
+	WebhookDeliveries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "Webhook delivery attempts by outcome.",
+	}, []string{"outcome"})
+
+	// WebhookSubscriptionsDisabled counts subscriptions disabled after
+	// failing too many times in a row.
+	WebhookSubscriptionsDisabled = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "subscriptions_disabled_total",
+		Help:      "Webhook subscriptions disabled after persistent delivery failures.",
+	})
+)
+
+// Outbox relay outcomes.
+const (
+	OutboxPublished = "published"
+	OutboxRetried   = "retried"
+	OutboxDead      = "dead"
+)
+
+// Event consumer results.
+const (
+	ConsumerProcessed = "processed"
+	ConsumerDuplicate = "duplicate"
+	ConsumerSkipped   = "skipped"
+	ConsumerFailed    = "failed"
+)
+
+// Webhook delivery outcomes.
+const (
+	WebhookDelivered = "delivered"
+	WebhookRetried   = "retried"
+	WebhookFailed    = "failed"
+)
+
+// Login results and the reason used for failures that don't have one.
+const (
+	ResultSuccess     = "success"
+	ResultFailure     = "failure"
+	ResultMFARequired = "mfa_required"
+	ReasonError       = "error"
+)
+
+// Handler serves every registered collector in the Prometheus text format.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDBStats exports the connection pool statistics of db (open, in use
+// and idle connections, waits and closes) labelled with name.
+func RegisterDBStats(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+type instrumentedHasher struct {
+	password.Hasher
+	algorithm string
+}
+
+// InstrumentHasher records how long h takes to hash and verify passwords.
+// algorithm labels new hashes; verifications are labelled with the algorithm
+// of the stored hash.
+func InstrumentHasher(h password.Hasher, algorithm string) password.Hasher {
+	return &instrumentedHasher{Hasher: h, algorithm: algorithm}
+}
+
+func (h *instrumentedHasher) Hash(plaintext string) (string, error) {
+	defer observeSince(time.Now(), "hash", h.algorithm)
+	return h.Hasher.Hash(plaintext)
+}
+
+func (h *instrumentedHasher) Verify(plaintext, encoded string) (bool, error) {
+	defer observeSince(time.Now(), "verify", password.AlgorithmOf(encoded))
+	return h.Hasher.Verify(plaintext, encoded)
+}
+
+func observeSince(start time.Time, operation, algorithm string) {
+	PasswordHashDuration.WithLabelValues(operation, algorithm).Observe(time.Since(start).Seconds())
+}
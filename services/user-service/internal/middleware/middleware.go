@@ -1,22 +1,39 @@
 package middleware
 
 import (
+	"crypto/subtle"
+	"errors"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"user-service/internal/metrics"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/sirupsen/logrus"
 )
 
-func CORSMiddleware() gin.HandlerFunc {
+// CORSMiddleware answers preflight requests and sets CORS headers for the
+// given origins; "*" allows any origin.
+func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[origin] = true
+	}
+
 	return gin.HandlerFunc(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		if origin := c.GetHeader("Origin"); allowed["*"] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		} else if allowed[origin] {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Add("Vary", "Origin")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -54,14 +71,47 @@ func LoggingMiddleware() gin.HandlerFunc {
 	})
 }
 
+// MetricsMiddleware records the latency of every request by method, route
+// template and status. Requests that match no route share the "unmatched"
+// label so scanners can't create unbounded series.
+func MetricsMiddleware() gin.HandlerFunc {
+	return gin.HandlerFunc(func(c *gin.Context) {
+		start := time.Now()
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(time.Since(start).Seconds())
+	})
+}
+
 func RecoveryMiddleware() gin.HandlerFunc {
 	return gin.Recovery()
 }
 
-func JWTAuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware authenticates requests by their bearer token and checks the
+// permissions routes require.
+type AuthMiddleware struct {
+	tokens *tokens.Validator
+	roles  services.RolePermissions
+}
+
+func NewAuthMiddleware(validator *tokens.Validator, roles services.RolePermissions) *AuthMiddleware {
+	return &AuthMiddleware{tokens: validator, roles: roles}
+}
+
+func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			metrics.TokenValidationFailures.WithLabelValues("missing").Inc()
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": gin.H{
 					"code":    "UNAUTHORIZED",
@@ -74,6 +124,7 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
+			metrics.TokenValidationFailures.WithLabelValues("malformed_header").Inc()
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": gin.H{
 					"code":    "UNAUTHORIZED",
@@ -84,30 +135,130 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, jwt.ErrSignatureInvalid
+		claims, err := m.tokens.Validate(c.Request.Context(), tokenString)
+		if err != nil {
+			var tokenErr *tokens.Error
+			if !errors.As(err, &tokenErr) {
+				logrus.WithError(err).Error("Failed to validate access token")
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"error": gin.H{
+						"code":    "SERVICE_UNAVAILABLE",
+						"message": "Token could not be validated",
+					},
+				})
+				c.Abort()
+				return
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"code":    tokenErr.Code,
+					"message": tokenErr.Message,
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("user_email", claims.Email)
+		c.Set("user_role", claims.Role)
+		if claims.Permissions != nil {
+			c.Set("user_permissions", claims.Permissions)
+		}
+		c.Set("session_id", claims.SessionID)
+		c.Set("token_id", claims.TokenID)
+		c.Set("token_expires_at", claims.ExpiresAt)
+
+		c.Next()
+	}
+}
+
+// RequirePermission only lets through tokens holding the permission in
+// their scope. Tokens issued without a scope claim are checked against the
+// current permissions of their role instead. It must run after RequireAuth.
+func (m *AuthMiddleware) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		permissions, err := m.permissions(c)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to resolve role permissions")
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": gin.H{
+					"code":    "INTERNAL_ERROR",
+					"message": "Failed to check permissions",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		for _, granted := range permissions {
+			if granted == permission {
+				c.Next()
+				return
 			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": gin.H{
+				"code":    "FORBIDDEN",
+				"message": "Insufficient permissions",
+				"details": "requires " + permission,
+			},
 		})
+		c.Abort()
+	}
+}
+
+func (m *AuthMiddleware) permissions(c *gin.Context) ([]string, error) {
+	if permissions, ok := c.Get("user_permissions"); ok {
+		return permissions.([]string), nil
+	}
+	return m.roles.PermissionsForRole(c.Request.Context(), c.GetString("user_role"))
+}
+
+// ServiceKeyHeader carries a service key on requests from other services.
+const ServiceKeyHeader = "X-Service-Key"
+
+// ServiceKeys are the keys other services authenticate with.
+type ServiceKeys [][]byte
 
-		if err != nil || !token.Valid {
+func NewServiceKeys(keys []string) ServiceKeys {
+	serviceKeys := make(ServiceKeys, 0, len(keys))
+	for _, key := range keys {
+		serviceKeys = append(serviceKeys, []byte(key))
+	}
+	return serviceKeys
+}
+
+// Valid reports whether key is one of the service keys, comparing in
+// constant time.
+func (k ServiceKeys) Valid(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, known := range k {
+		if subtle.ConstantTimeCompare([]byte(key), known) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireServiceKey only lets through requests carrying a valid service key
+// in the X-Service-Key header.
+func RequireServiceKey(keys ServiceKeys) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !keys.Valid(c.GetHeader(ServiceKeyHeader)) {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"error": gin.H{
 					"code":    "UNAUTHORIZED",
-					"message": "Invalid or expired token",
+					"message": "A valid service key is required",
 				},
 			})
 			c.Abort()
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("user_id", claims["sub"])
-			c.Set("user_email", claims["email"])
-			c.Set("user_role", claims["role"])
-		}
-
 		c.Next()
 	}
 }
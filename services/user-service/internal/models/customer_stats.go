@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+// CustomerStats aggregates a user's orders as reported by the order and
+// payment services. Cancelled orders are not counted, and LifetimeSpend is
+// net of cancellations and refunds, per currency, in the currency's minor
+// units (cents for USD, dong for VND).
+type CustomerStats struct {
+	OrderCount          int              `json:"order_count"`
+	DeliveredOrderCount int              `json:"delivered_order_count"`
+	CancelledOrderCount int              `json:"cancelled_order_count"`
+	LifetimeSpend       map[string]int64 `json:"lifetime_spend"`
+	FirstOrderAt        *time.Time       `json:"first_order_at"`
+	LastOrderAt         *time.Time       `json:"last_order_at"`
+}
+
+// CustomerOrder is an order as announced by order.placed. TotalAmount is in
+// minor units of Currency.
+type CustomerOrder struct {
+	OrderID     string
+	UserID      string
+	TotalAmount int64
+	Currency    string
+	PlacedAt    time.Time
+}
+
+// AdminUser is the administrator's view of a user.
+type AdminUser struct {
+	User
+	Stats *CustomerStats `json:"stats"`
+}
@@ -0,0 +1,45 @@
+package models
+
+// PageQuery is the page/limit query of paginated list endpoints.
+type PageQuery struct {
+	Page  int `form:"page" validate:"omitempty,min=1"`
+	Limit int `form:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// Default page size of paginated list endpoints.
+const DefaultPageLimit = 20
+
+// Normalize fills in the first page and the default page size.
+func (q *PageQuery) Normalize() {
+	if q.Page == 0 {
+		q.Page = 1
+	}
+	if q.Limit == 0 {
+		q.Limit = DefaultPageLimit
+	}
+}
+
+// Offset is the number of items before the requested page.
+func (q PageQuery) Offset() int {
+	return (q.Page - 1) * q.Limit
+}
+
+// Pagination is the pagination block of the shared paginated response
+// contract. Lists that also support keyset pagination set NextCursor to
+// continue after the page.
+type Pagination struct {
+	Page       int    `json:"page"`
+	Limit      int    `json:"limit"`
+	Total      int    `json:"total"`
+	TotalPages int    `json:"totalPages"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+func NewPagination(q PageQuery, total int) Pagination {
+	return Pagination{
+		Page:       q.Page,
+		Limit:      q.Limit,
+		Total:      total,
+		TotalPages: (total + q.Limit - 1) / q.Limit,
+	}
+}
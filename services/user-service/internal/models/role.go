@@ -0,0 +1,58 @@
+package models
+
+import (
+	"time"
+)
+
+// Built-in roles, seeded by migration 000010. New accounts get RoleUser;
+// the others are only granted by an administrator.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permissions checked by the HTTP routes. The permissions table holds the
+// same names.
+const (
+	PermissionProfileRead    = "profile:read"
+	PermissionProfileWrite   = "profile:write"
+	PermissionUsersRead      = "users:read"
+	PermissionUsersWrite     = "users:write"
+	PermissionRolesManage    = "roles:manage"
+	PermissionEventsManage   = "events:manage"
+	PermissionWebhooksManage = "webhooks:manage"
+)
+
+// Role groups the permissions granted to the users holding it. System roles
+// are the built-in ones and cannot be deleted.
+type Role struct {
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	IsSystem    bool      `json:"is_system" db:"is_system"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type Permission struct {
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+}
+
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=20"`
+	Description string   `json:"description,omitempty" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"dive,required"`
+}
+
+// UpdateRoleRequest changes a role. Nil fields are left unchanged; a
+// non-nil Permissions replaces the role's permissions.
+type UpdateRoleRequest struct {
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+	Permissions []string `json:"permissions,omitempty" validate:"dive,required"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,max=20"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+// SigningKey is a JWT signing key pair. A new key is published from CreatedAt
+// and signs from ActivatesAt. Retired keys no longer sign but keep verifying
+// tokens until ExpiresAt.
+type SigningKey struct {
+	KID           string     `json:"kid" db:"kid"`
+	Algorithm     string     `json:"algorithm" db:"algorithm"`
+	PrivateKeyPEM string     `json:"-" db:"private_key"`
+	PublicKeyPEM  string     `json:"-" db:"public_key"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ActivatesAt   time.Time  `json:"activates_at" db:"activates_at"`
+	RetiredAt     *time.Time `json:"retired_at,omitempty" db:"retired_at"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+}
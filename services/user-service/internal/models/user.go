@@ -1,25 +1,37 @@
 package models
 
 import (
+	"sort"
 	"time"
 )
 
 type User struct {
-	ID        string    `json:"id" db:"id"`
-	Email     string    `json:"email" db:"email" validate:"required,email"`
-	Username  string    `json:"username" db:"username" validate:"required,min=3,max=50"`
-	Password  string    `json:"-" db:"password_hash" validate:"required,min=8"`
-	Role      string    `json:"role" db:"role" validate:"required,oneof=user admin moderator"`
-	IsActive  bool      `json:"is_active" db:"is_active"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID                string     `json:"id" db:"id"`
+	Email             string     `json:"email" db:"email" validate:"required,email"`
+	Username          string     `json:"username" db:"username" validate:"required,min=3,max=50"`
+	Password          string     `json:"-" db:"password_hash" validate:"required,min=8"`
+	Role              string     `json:"role" db:"role" validate:"required,max=20"`
+	FirstName         string     `json:"first_name" db:"first_name" validate:"max=100"`
+	LastName          string     `json:"last_name" db:"last_name" validate:"max=100"`
+	Phone             *string    `json:"phone" db:"phone" validate:"omitempty,e164"`
+	AvatarURL         *string    `json:"avatar_url" db:"avatar_url" validate:"omitempty,url,max=500"`
+	IsActive          bool       `json:"is_active" db:"is_active"`
+	IsVerified        bool       `json:"is_verified" db:"is_verified"`
+	PasswordChangedAt *time.Time `json:"-" db:"password_changed_at"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
 }
 
 type CreateUserRequest struct {
 	Email    string `json:"email" validate:"required,email"`
 	Username string `json:"username" validate:"required,min=3,max=50"`
-	Password string `json:"password" validate:"required,min=8"`
-	Role     string `json:"role,omitempty" validate:"omitempty,oneof=user admin moderator"`
+	Password string `json:"password" validate:"required"`
+	// Role may only be "user"; other roles are granted by an administrator.
+	Role      string `json:"role,omitempty" validate:"omitempty,oneof=user"`
+	FirstName string `json:"first_name,omitempty" validate:"max=100"`
+	LastName  string `json:"last_name,omitempty" validate:"max=100"`
+	Phone     string `json:"phone,omitempty" validate:"omitempty,e164"`
+	AvatarURL string `json:"avatar_url,omitempty" validate:"omitempty,url,max=500"`
 }
 
 type LoginRequest struct {
@@ -27,27 +39,157 @@ type LoginRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
+// UpdateUserRequest holds profile changes. Nil fields are left unchanged;
+// Clear lists nullable fields set to null, or to an empty string.
 type UpdateUserRequest struct {
-	Username string `json:"username,omitempty" validate:"omitempty,min=3,max=50"`
-	Email    string `json:"email,omitempty" validate:"omitempty,email"`
+	Username  *string  `json:"username,omitempty" validate:"omitempty,min=3,max=50"`
+	Email     *string  `json:"email,omitempty" validate:"omitempty,email"`
+	FirstName *string  `json:"first_name,omitempty" validate:"omitempty,max=100"`
+	LastName  *string  `json:"last_name,omitempty" validate:"omitempty,max=100"`
+	Phone     *string  `json:"phone,omitempty" validate:"omitempty,e164"`
+	AvatarURL *string  `json:"avatar_url,omitempty" validate:"omitempty,url,max=500"`
+	Clear     []string `json:"-"`
+}
+
+// NullableProfileFields are the profile fields a merge patch may set to null.
+var NullableProfileFields = map[string]bool{
+	"phone":      true,
+	"avatar_url": true,
+}
+
+// ClearEmpty moves nullable fields set to an empty string to Clear, so a
+// missing value is always stored as NULL. It runs before validation, which
+// would reject the empty string.
+func (r *UpdateUserRequest) ClearEmpty() {
+	if r.Phone != nil && *r.Phone == "" {
+		r.Phone = nil
+		r.Clear = append(r.Clear, "phone")
+	}
+	if r.AvatarURL != nil && *r.AvatarURL == "" {
+		r.AvatarURL = nil
+		r.Clear = append(r.Clear, "avatar_url")
+	}
+	sort.Strings(r.Clear)
 }
 
 type LoginResponse struct {
 	AccessToken  string `json:"access_token"`
 	RefreshToken string `json:"refresh_token"`
 	User         User   `json:"user"`
+	// RecoveryCodes is only set when the login also completed MFA enrollment.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
 }
 
 type RefreshTokenRequest struct {
 	RefreshToken string `json:"refresh_token" validate:"required"`
 }
 
+// UserSession is a signed-in device. It is also the refresh token family:
+// every token rotated from the login's first refresh token belongs to it.
 type UserSession struct {
-	ID           string    `json:"id" db:"id"`
-	UserID       string    `json:"user_id" db:"user_id"`
-	RefreshToken string    `json:"refresh_token" db:"refresh_token"`
-	ExpiresAt    time.Time `json:"expires_at" db:"expires_at"`
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`
-	IPAddress    string    `json:"ip_address" db:"ip_address"`
-	UserAgent    string    `json:"user_agent" db:"user_agent"`
+	ID         string     `json:"id" db:"id"`
+	UserID     string     `json:"user_id" db:"user_id"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt time.Time  `json:"last_used_at" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	IPAddress  string     `json:"ip_address" db:"ip_address"`
+	UserAgent  string     `json:"user_agent" db:"user_agent"`
+
+	// Derived from UserAgent when sessions are listed.
+	Device  string `json:"device,omitempty" db:"-"`
+	Browser string `json:"browser,omitempty" db:"-"`
+	OS      string `json:"os,omitempty" db:"-"`
+	Current bool   `json:"current" db:"-"`
+}
+
+// ClientInfo describes the client a request came from.
+type ClientInfo struct {
+	IPAddress string
+	UserAgent string
+}
+
+type RefreshToken struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	SessionID string     `json:"session_id" db:"session_id"`
+	ParentID  *string    `json:"parent_id,omitempty" db:"parent_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type SecurityEvent struct {
+	Type       string                 `json:"type"`
+	UserID     string                 `json:"user_id"`
+	SessionID  string                 `json:"session_id,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	OccurredAt time.Time              `json:"occurred_at"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" validate:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type EmailVerificationToken struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" validate:"required"`
+	NewPassword     string `json:"new_password" validate:"required"`
+}
+
+type PasswordResetToken struct {
+	ID        string     `json:"id" db:"id"`
+	UserID    string     `json:"user_id" db:"user_id"`
+	TokenHash string     `json:"-" db:"token_hash"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+}
+
+// IntrospectionRequest is the RFC 7662 request, sent form-encoded or as JSON.
+// token_type_hint is accepted but ignored: only access tokens can be active.
+type IntrospectionRequest struct {
+	Token         string `json:"token" form:"token" validate:"required"`
+	TokenTypeHint string `json:"token_type_hint,omitempty" form:"token_type_hint"`
+}
+
+// IntrospectionResponse is the RFC 7662 response. For an inactive token only
+// Active is set, so callers learn nothing about why it was rejected.
+type IntrospectionResponse struct {
+	Active    bool   `json:"active"`
+	Scope     string `json:"scope,omitempty"`
+	TokenType string `json:"token_type,omitempty"`
+	Sub       string `json:"sub,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Email     string `json:"email,omitempty"`
+	Role      string `json:"role,omitempty"`
+	SessionID string `json:"sid,omitempty"`
+	Jti       string `json:"jti,omitempty"`
+	Exp       int64  `json:"exp,omitempty"`
+	Iat       int64  `json:"iat,omitempty"`
+	// AccountStatus is "active", or "unverified" while the email address is
+	// unconfirmed. Deactivated accounts make the token inactive instead.
+	AccountStatus string `json:"account_status,omitempty"`
 }
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// UserListQuery filters, sorts and pages the admin user list. Without Cursor
+// it pages by offset and counts the total; with Cursor it continues after
+// the last user of the previous page (keyset pagination), which stays fast on
+// large tables and skips the count.
+type UserListQuery struct {
+	PageQuery
+	// Search matches a case-insensitive fragment of the email, username or
+	// name.
+	Search      string     `form:"q" validate:"omitempty,max=100"`
+	Role        string     `form:"role" validate:"omitempty,max=20"`
+	IsActive    *bool      `form:"is_active"`
+	IsVerified  *bool      `form:"is_verified"`
+	CreatedFrom *time.Time `form:"created_from"`
+	CreatedTo   *time.Time `form:"created_to"`
+	Sort        string     `form:"sort" validate:"omitempty,oneof=created_at updated_at email username"`
+	Order       string     `form:"order" validate:"omitempty,oneof=asc desc"`
+	Cursor      string     `form:"cursor" validate:"omitempty,max=512"`
+}
+
+// UserFilter narrows a user listing; zero fields don't filter.
+type UserFilter struct {
+	Search      string
+	Role        string
+	IsActive    *bool
+	IsVerified  *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// UserOrder sorts a user listing by Field, ties broken by ID in the same
+// direction.
+type UserOrder struct {
+	Field string
+	Desc  bool
+}
+
+// UserKey is the position of a user in a listing: its sort field value, as
+// text, and its ID.
+type UserKey struct {
+	Value string
+	ID    string
+}
+
+// CursorPagination is the pagination block of keyset pages. NextCursor is
+// empty on the last page.
+type CursorPagination struct {
+	Limit      int    `json:"limit"`
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// UserPage is one page of the admin user list. Pagination is set for offset
+// pages and Cursor for keyset pages.
+type UserPage struct {
+	Users      []*User
+	Pagination *Pagination
+	Cursor     *CursorPagination
+}
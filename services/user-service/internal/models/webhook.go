@@ -0,0 +1,83 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery states.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// WebhookSubscription is a partner endpoint that receives the user events
+// listed in Events. Secret signs every payload and is only shown when the
+// subscription is created.
+type WebhookSubscription struct {
+	ID                  string     `json:"id" db:"id"`
+	URL                 string     `json:"url" db:"url"`
+	Description         string     `json:"description" db:"description"`
+	Events              []string   `json:"events" db:"events"`
+	Secret              string     `json:"-" db:"secret"`
+	IsActive            bool       `json:"is_active" db:"is_active"`
+	ConsecutiveFailures int        `json:"consecutive_failures" db:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+	DisabledReason      *string    `json:"disabled_reason,omitempty" db:"disabled_reason"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// CreatedWebhookSubscription is returned once, on creation, with the secret
+// the partner verifies signatures with.
+type CreatedWebhookSubscription struct {
+	WebhookSubscription
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery is one event on its way to one subscription. Payload is
+// the event envelope exactly as it is sent.
+type WebhookDelivery struct {
+	ID             string            `json:"id" db:"id"`
+	SubscriptionID string            `json:"subscription_id" db:"subscription_id"`
+	EventID        string            `json:"event_id" db:"event_id"`
+	EventType      string            `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage   `json:"payload" db:"payload"`
+	Status         string            `json:"status" db:"status"`
+	Attempts       int               `json:"attempts" db:"attempts"`
+	LastError      *string           `json:"last_error,omitempty" db:"last_error"`
+	NextAttemptAt  time.Time         `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt    *time.Time        `json:"delivered_at,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time         `json:"updated_at" db:"updated_at"`
+	AttemptLog     []*WebhookAttempt `json:"attempt_log,omitempty" db:"-"`
+}
+
+// WebhookAttempt records one HTTP request made for a delivery. StatusCode is
+// nil when no response arrived.
+type WebhookAttempt struct {
+	ID          string    `json:"id" db:"id"`
+	DeliveryID  string    `json:"delivery_id" db:"delivery_id"`
+	StatusCode  *int      `json:"status_code" db:"status_code"`
+	Error       *string   `json:"error,omitempty" db:"error"`
+	DurationMs  int64     `json:"duration_ms" db:"duration_ms"`
+	AttemptedAt time.Time `json:"attempted_at" db:"attempted_at"`
+}
+
+// CreateWebhookRequest subscribes url to the listed user events.
+type CreateWebhookRequest struct {
+	URL         string   `json:"url" validate:"required,url,max=500"`
+	Description string   `json:"description,omitempty" validate:"max=255"`
+	Events      []string `json:"events" validate:"required,min=1,dive,oneof=user.created user.updated user.verified user.deleted"`
+}
+
+// UpdateWebhookRequest changes a subscription; nil fields are left alone.
+// Setting IsActive re-enables a disabled subscription and resets its failure
+// count.
+type UpdateWebhookRequest struct {
+	URL         *string  `json:"url,omitempty" validate:"omitempty,url,max=500"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=255"`
+	Events      []string `json:"events,omitempty" validate:"omitempty,min=1,dive,oneof=user.created user.updated user.verified user.deleted"`
+	IsActive    *bool    `json:"is_active,omitempty"`
+}
@@ -0,0 +1,163 @@
+// Package outbox publishes the events that repositories write to the outbox
+// table alongside the changes they describe. Delivery is at least once: an
+// event is retried with exponential back-off until the publisher accepts it,
+// and after MaxAttempts failures it is parked in the dead letters until an
+// operator requeues it.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/events"
+	"user-service/internal/metrics"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxBackoffShift keeps the exponential delay from overflowing.
+const maxBackoffShift = 20
+
+// claimLease is how long claimed events are hidden from other relays. If this
+// process dies mid-batch, the rest of the batch becomes due again after it.
+const claimLease = time.Minute
+
+// purgeInterval is how often published events past retention are deleted.
+const purgeInterval = time.Hour
+
+// Backoff turns a number of failed attempts into the wait before the next.
+type Backoff struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+// DelayAfter returns Base doubled for every failure after the first, capped
+// at Max.
+func (b Backoff) DelayAfter(failures int) time.Duration {
+	shift := failures - 1
+	if shift < 0 {
+		shift = 0
+	}
+	if shift > maxBackoffShift {
+		shift = maxBackoffShift
+	}
+	delay := b.Base << shift
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+	return delay
+}
+
+// Relay moves events from the outbox to a Publisher.
+type Relay struct {
+	repo         repository.OutboxRepository
+	publisher    events.Publisher
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	backoff      Backoff
+	retention    time.Duration
+}
+
+func NewRelay(repo repository.OutboxRepository, publisher events.Publisher, cfg config.OutboxConfig) *Relay {
+	return &Relay{
+		repo:         repo,
+		publisher:    publisher,
+		pollInterval: time.Duration(cfg.PollInterval) * time.Millisecond,
+		batchSize:    cfg.BatchSize,
+		maxAttempts:  cfg.MaxAttempts,
+		backoff: Backoff{
+			Base: time.Duration(cfg.RetryBaseDelay) * time.Second,
+			Max:  time.Duration(cfg.RetryMaxDelay) * time.Second,
+		},
+		retention: time.Duration(cfg.Retention) * time.Hour,
+	}
+}
+
+// Run relays events until ctx is cancelled. A full batch is followed by the
+// next one straight away, so a backlog drains without waiting for the timer.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.pollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+
+	for {
+		for {
+			claimed, err := r.RelayBatch(ctx)
+			if err != nil {
+				logrus.WithError(err).Error("Failed to claim outbox events")
+			}
+			if err != nil || claimed < r.batchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			if deleted, err := r.repo.DeletePublishedBefore(lastPurge.Add(-r.retention)); err != nil {
+				logrus.WithError(err).Warn("Failed to delete published outbox events")
+			} else if deleted > 0 {
+				logrus.WithField("deleted", deleted).Info("Deleted published outbox events past retention")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayBatch publishes one batch of due events and returns how many it
+// claimed.
+func (r *Relay) RelayBatch(ctx context.Context) (int, error) {
+	claimed, err := r.repo.ClaimDue(r.batchSize, claimLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range claimed {
+		r.relay(ctx, event)
+	}
+	return len(claimed), nil
+}
+
+func (r *Relay) relay(ctx context.Context, event *models.OutboxEvent) {
+	log := logrus.WithFields(logrus.Fields{"event_id": event.ID, "event_type": event.EventType})
+
+	var envelope events.Envelope
+	err := json.Unmarshal(event.Payload, &envelope)
+	if err == nil {
+		err = r.publisher.Publish(ctx, &envelope)
+	}
+	if err == nil {
+		if err := r.repo.MarkPublished(event.ID); err != nil {
+			// It stays pending and is published again; consumers dedupe.
+			log.WithError(err).Warn("Published outbox event could not be marked as published")
+		}
+		metrics.OutboxEvents.WithLabelValues(metrics.OutboxPublished).Inc()
+		return
+	}
+
+	failures := event.Attempts + 1
+	if failures >= r.maxAttempts {
+		log.WithError(err).WithField("attempts", failures).Error("Outbox event moved to dead letters")
+		if err := r.repo.MarkDead(event.ID, err.Error()); err != nil {
+			log.WithError(err).Error("Failed to move outbox event to dead letters")
+		}
+		metrics.OutboxEvents.WithLabelValues(metrics.OutboxDead).Inc()
+		return
+	}
+
+	delay := r.backoff.DelayAfter(failures)
+	log.WithError(err).WithFields(logrus.Fields{"attempts": failures, "retry_in": delay}).Warn("Failed to publish outbox event")
+	if err := r.repo.MarkFailed(event.ID, err.Error(), time.Now().Add(delay)); err != nil {
+		log.WithError(err).Error("Failed to reschedule outbox event")
+	}
+	metrics.OutboxEvents.WithLabelValues(metrics.OutboxRetried).Inc()
+}
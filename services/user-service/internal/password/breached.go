@@ -0,0 +1,91 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// prefixLength matches the k-anonymity ranges of the Have I Been Pwned API:
+// hashes are bucketed by their first five hex characters.
+const prefixLength = 5
+
+//go:embed breached_passwords.txt
+var defaultBreachedList string
+
+// BreachedList reports whether a password is known to be compromised.
+type BreachedList interface {
+	Contains(password string) bool
+}
+
+// PrefixIndex holds SHA-1 hashes bucketed by prefix, each bucket a sorted
+// list of suffixes. Only hashes are kept in memory, never plain passwords.
+type PrefixIndex struct {
+	buckets map[string][]string
+}
+
+// LoadPrefixIndex reads one upper- or lower-case SHA-1 hex hash per line,
+// optionally followed by ":count". Blank lines and lines starting with # are
+// ignored.
+func LoadPrefixIndex(r io.Reader) (*PrefixIndex, error) {
+	index := &PrefixIndex{buckets: make(map[string][]string)}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		hash = strings.ToUpper(hash)
+		if len(hash) != sha1.Size*2 {
+			return nil, fmt.Errorf("breached password list line %d: not a SHA-1 hash", line)
+		}
+
+		prefix := hash[:prefixLength]
+		index.buckets[prefix] = append(index.buckets[prefix], hash[prefixLength:])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, suffixes := range index.buckets {
+		sort.Strings(suffixes)
+	}
+	return index, nil
+}
+
+// LoadPrefixIndexFile loads a list from disk, or the bundled list when path
+// is empty.
+func LoadPrefixIndexFile(path string) (*PrefixIndex, error) {
+	if path == "" {
+		return LoadPrefixIndex(strings.NewReader(defaultBreachedList))
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return LoadPrefixIndex(f)
+}
+
+func (i *PrefixIndex) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+
+	suffixes := i.buckets[hash[:prefixLength]]
+	suffix := hash[prefixLength:]
+	n := sort.SearchStrings(suffixes, suffix)
+	return n < len(suffixes) && suffixes[n] == suffix
+}
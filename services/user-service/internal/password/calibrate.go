@@ -0,0 +1,68 @@
+package password
+
+import (
+	"time"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// calibrationRuns is how many hashes are timed per candidate; the fastest
+	// one counts so a busy machine doesn't inflate the result.
+	calibrationRuns = 3
+
+	maxArgon2Iterations = 64
+	maxBcryptCost       = 16
+)
+
+const calibrationPassword = "calibration-Passw0rd"
+
+// CalibrateArgon2id finds the smallest iteration count for which hashing with
+// the given memory (KiB) and parallelism takes at least target on this
+// machine. It returns the parameters and the measured duration.
+func CalibrateArgon2id(target time.Duration, memory uint32, parallelism uint8) (Argon2idParams, time.Duration) {
+	params := Argon2idParams{Memory: memory, Parallelism: parallelism}
+	salt := make([]byte, argon2SaltLength)
+
+	var took time.Duration
+	for params.Iterations = 1; params.Iterations <= maxArgon2Iterations; params.Iterations++ {
+		took = fastest(func() {
+			argon2.IDKey([]byte(calibrationPassword), salt, params.Iterations, params.Memory, params.Parallelism, argon2KeyLength)
+		})
+		if took >= target {
+			return params, took
+		}
+	}
+
+	params.Iterations = maxArgon2Iterations
+	return params, took
+}
+
+// CalibrateBcrypt finds the smallest cost for which hashing takes at least
+// target on this machine. It returns the cost and the measured duration.
+func CalibrateBcrypt(target time.Duration) (int, time.Duration) {
+	var took time.Duration
+	for cost := bcrypt.DefaultCost; cost <= maxBcryptCost; cost++ {
+		took = fastest(func() {
+			bcrypt.GenerateFromPassword([]byte(calibrationPassword), cost)
+		})
+		if took >= target {
+			return cost, took
+		}
+	}
+
+	return maxBcryptCost, took
+}
+
+func fastest(run func()) time.Duration {
+	var best time.Duration
+	for i := 0; i < calibrationRuns; i++ {
+		start := time.Now()
+		run()
+		if took := time.Since(start); i == 0 || took < best {
+			best = took
+		}
+	}
+	return best
+}
@@ -0,0 +1,209 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"user-service/internal/config"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithm names as configured and as they appear in encoded hashes.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+)
+
+var (
+	ErrUnknownHashFormat = errors.New("unrecognised password hash format")
+	ErrUnknownAlgorithm  = errors.New("unsupported password hash algorithm")
+)
+
+// Hasher turns passwords into self-describing encoded hashes. Argon2id hashes
+// use the PHC string format ($argon2id$v=19$m=...,t=...,p=...$salt$hash) and
+// bcrypt hashes its modular crypt format ($2a$10$...), so the algorithm and
+// parameters of every stored hash can be read back from the hash itself.
+type Hasher interface {
+	// Hash encodes password with the current algorithm and parameters.
+	Hash(password string) (string, error)
+	// Verify reports whether password matches encoded, whichever supported
+	// algorithm produced it.
+	Verify(password, encoded string) (bool, error)
+	// NeedsRehash reports whether encoded was made with a different algorithm
+	// or weaker parameters than Hash currently uses.
+	NeedsRehash(encoded string) bool
+}
+
+// Argon2idParams are the Argon2id cost parameters. Memory is in KiB.
+type Argon2idParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+type hasher struct {
+	algorithm  string
+	argon2id   Argon2idParams
+	bcryptCost int
+}
+
+// NewHasher builds a Hasher that creates hashes with cfg.Algorithm and
+// verifies both Argon2id and bcrypt hashes.
+func NewHasher(cfg config.PasswordHashConfig) (Hasher, error) {
+	h := &hasher{
+		algorithm:  cfg.Algorithm,
+		bcryptCost: cfg.BcryptCost,
+		argon2id: Argon2idParams{
+			Memory:      uint32(cfg.Argon2Memory),
+			Iterations:  uint32(cfg.Argon2Iterations),
+			Parallelism: uint8(cfg.Argon2Parallelism),
+		},
+	}
+
+	switch cfg.Algorithm {
+	case AlgorithmArgon2id:
+		if cfg.Argon2Memory < 8*cfg.Argon2Parallelism || cfg.Argon2Iterations < 1 ||
+			cfg.Argon2Parallelism < 1 || cfg.Argon2Parallelism > 255 {
+			return nil, fmt.Errorf("invalid argon2id parameters m=%d t=%d p=%d",
+				cfg.Argon2Memory, cfg.Argon2Iterations, cfg.Argon2Parallelism)
+		}
+	case AlgorithmBcrypt:
+		if cfg.BcryptCost < bcrypt.MinCost || cfg.BcryptCost > bcrypt.MaxCost {
+			return nil, fmt.Errorf("invalid bcrypt cost %d", cfg.BcryptCost)
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, cfg.Algorithm)
+	}
+
+	return h, nil
+}
+
+func (h *hasher) Hash(password string) (string, error) {
+	if h.algorithm == AlgorithmBcrypt {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		return string(hashed), err
+	}
+	return hashArgon2id(password, h.argon2id)
+}
+
+func (h *hasher) Verify(password, encoded string) (bool, error) {
+	switch {
+	case isArgon2id(encoded):
+		params, salt, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false, err
+		}
+		derived := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		return subtle.ConstantTimeCompare(derived, key) == 1, nil
+
+	case isBcrypt(encoded):
+		err := bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+
+	default:
+		return false, ErrUnknownHashFormat
+	}
+}
+
+func (h *hasher) NeedsRehash(encoded string) bool {
+	switch h.algorithm {
+	case AlgorithmArgon2id:
+		if !isArgon2id(encoded) {
+			return true
+		}
+		params, _, key, err := decodeArgon2id(encoded)
+		if err != nil {
+			return false
+		}
+		return params.Memory < h.argon2id.Memory ||
+			params.Iterations < h.argon2id.Iterations ||
+			params.Parallelism < h.argon2id.Parallelism ||
+			len(key) < argon2KeyLength
+
+	default:
+		if !isBcrypt(encoded) {
+			return true
+		}
+		cost, err := bcrypt.Cost([]byte(encoded))
+		return err == nil && cost < h.bcryptCost
+	}
+}
+
+func hashArgon2id(password string, params Argon2idParams) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, argon2KeyLength)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+func decodeArgon2id(encoded string) (Argon2idParams, []byte, []byte, error) {
+	var params Argon2idParams
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, hash
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	return params, salt, key, nil
+}
+
+func isArgon2id(encoded string) bool {
+	return strings.HasPrefix(encoded, "$argon2id$")
+}
+
+func isBcrypt(encoded string) bool {
+	return strings.HasPrefix(encoded, "$2a$") || strings.HasPrefix(encoded, "$2b$") || strings.HasPrefix(encoded, "$2y$")
+}
+
+// AlgorithmOf names the algorithm that produced encoded, or "unknown".
+func AlgorithmOf(encoded string) string {
+	switch {
+	case isArgon2id(encoded):
+		return AlgorithmArgon2id
+	case isBcrypt(encoded):
+		return AlgorithmBcrypt
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,183 @@
+// Package password holds the rules new passwords must satisfy.
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"user-service/internal/config"
+)
+
+// Rule names reported to clients; the frontend maps them to messages.
+const (
+	RuleMinLength        = "min_length"
+	RuleMaxLength        = "max_length"
+	RuleUppercase        = "uppercase"
+	RuleLowercase        = "lowercase"
+	RuleDigit            = "digit"
+	RuleSymbol           = "symbol"
+	RuleCharClasses      = "char_classes"
+	RuleContainsEmail    = "contains_email"
+	RuleContainsUsername = "contains_username"
+	RuleBreached         = "breached"
+	RuleReused           = "reused"
+)
+
+// minPersonalLength keeps very short usernames from rejecting half of all
+// passwords.
+const minPersonalLength = 3
+
+// Violation is one failed rule.
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// PolicyError lists every rule a password failed.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return "password does not meet policy: " + strings.Join(messages, "; ")
+}
+
+// Owner is the account a password is checked for.
+type Owner struct {
+	Email    string
+	Username string
+}
+
+// Policy validates new passwords.
+type Policy struct {
+	MinLength      int
+	MaxLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	MinCharClasses int
+	Breached       BreachedList
+	// HistorySize is how many previous passwords CheckReuse is given.
+	HistorySize int
+}
+
+// NewPolicy builds the policy from configuration, loading the breached
+// password list from cfg.BreachedListFile or the bundled default.
+func NewPolicy(cfg config.PasswordPolicyConfig) (*Policy, error) {
+	var breached BreachedList
+	if cfg.CheckBreached {
+		index, err := LoadPrefixIndexFile(cfg.BreachedListFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load breached password list: %w", err)
+		}
+		breached = index
+	}
+
+	return &Policy{
+		MinLength:      cfg.MinLength,
+		MaxLength:      cfg.MaxLength,
+		RequireUpper:   cfg.RequireUpper,
+		RequireLower:   cfg.RequireLower,
+		RequireDigit:   cfg.RequireDigit,
+		RequireSymbol:  cfg.RequireSymbol,
+		MinCharClasses: cfg.MinCharClasses,
+		Breached:       breached,
+		HistorySize:    cfg.HistorySize,
+	}, nil
+}
+
+// Validate returns a *PolicyError listing every failed rule, or nil.
+func (p *Policy) Validate(password string, owner Owner) error {
+	var violations []Violation
+	fail := func(rule, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		fail(RuleMinLength, "must be at least %d characters long", p.MinLength)
+	}
+	// bcrypt only looks at the first 72 bytes, so longer input is refused
+	// rather than silently truncated.
+	if p.MaxLength > 0 && len(password) > p.MaxLength {
+		fail(RuleMaxLength, "must be at most %d bytes long", p.MaxLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+
+	if p.RequireUpper && !upper {
+		fail(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		fail(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		fail(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		fail(RuleSymbol, "must contain a symbol")
+	}
+	if classes := countTrue(upper, lower, digit, symbol); classes < p.MinCharClasses {
+		fail(RuleCharClasses, "must mix at least %d of: uppercase, lowercase, digits, symbols", p.MinCharClasses)
+	}
+
+	lowered := strings.ToLower(password)
+	if local, _, _ := strings.Cut(strings.ToLower(owner.Email), "@"); len(local) >= minPersonalLength && strings.Contains(lowered, local) {
+		fail(RuleContainsEmail, "must not contain your email address")
+	}
+	if username := strings.ToLower(owner.Username); len(username) >= minPersonalLength && strings.Contains(lowered, username) {
+		fail(RuleContainsUsername, "must not contain your username")
+	}
+
+	if p.Breached != nil && p.Breached.Contains(password) {
+		fail(RuleBreached, "is too common or has appeared in a data breach")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// CheckReuse returns a *PolicyError when password matches one of the given
+// encoded hashes, typically the current password and the recent history.
+func (p *Policy) CheckReuse(hasher Hasher, password string, hashes []string) error {
+	for _, hash := range hashes {
+		if ok, _ := hasher.Verify(password, hash); ok {
+			return &PolicyError{Violations: []Violation{{
+				Rule:    RuleReused,
+				Message: "must not match a password you used recently",
+			}}}
+		}
+	}
+	return nil
+}
+
+func countTrue(values ...bool) int {
+	n := 0
+	for _, v := range values {
+		if v {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,354 @@
+package repository
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+
+	"user-service/internal/events"
+	"user-service/internal/models"
+)
+
+// CustomerStatsRepository maintains the per-user order aggregates. Every
+// Record method applies one event at most once: it returns false, changing
+// nothing, when eventID was already processed.
+//
+// Each event type arrives on its own stream, so a delivered, cancelled or
+// refund event can come before the order.placed of its order. Such events
+// are parked, not marked processed, and applied when the order is placed.
+type CustomerStatsRepository interface {
+	// RecordOrderPlaced counts a new order and applies the events parked for
+	// it. Orders of unknown users are marked processed but not counted.
+	RecordOrderPlaced(eventID string, order *models.CustomerOrder) (bool, error)
+	RecordOrderDelivered(eventID, orderID string) (bool, error)
+	// RecordOrderCancelled stops counting an order that was not delivered.
+	RecordOrderCancelled(eventID, orderID string) (bool, error)
+	// RecordRefund takes amount, in minor units of currency, off the order's
+	// contribution to lifetime spend, never below zero. Refunds in another
+	// currency than the order's are ignored.
+	RecordRefund(eventID, orderID, currency string, amount int64) (bool, error)
+	// GetByUserID returns the user's stats; users without orders get zeros.
+	GetByUserID(userID string) (*models.CustomerStats, error)
+	// DeleteProcessedBefore forgets processed event IDs, and drops parked
+	// events whose order never arrived, older than before.
+	DeleteProcessedBefore(before time.Time) (int64, error)
+}
+
+// orderEvent is a delivered, cancelled or refund event, as parked in
+// pending_order_events.
+type orderEvent struct {
+	eventID   string
+	eventType string
+	orderID   string
+	currency  string
+	amount    int64
+}
+
+type customerStatsRepository struct {
+	db *sql.DB
+}
+
+func NewCustomerStatsRepository(db *sql.DB) CustomerStatsRepository {
+	return &customerStatsRepository{db: db}
+}
+
+// inOrder runs fn in a transaction holding a lock on orderID, so the events
+// of one order are applied one at a time.
+func (r *customerStatsRepository) inOrder(orderID string, fn func(tx *sql.Tx) (bool, error)) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, orderID); err != nil {
+		return false, err
+	}
+
+	applied, err := fn(tx)
+	if err != nil || !applied {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// markProcessed records eventID as processed, returning false if it already
+// was.
+func markProcessed(tx *sql.Tx, eventID, eventType string) (bool, error) {
+	result, err := tx.Exec(`INSERT INTO processed_events (event_id, event_type) VALUES ($1, $2) ON CONFLICT DO NOTHING`, eventID, eventType)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *customerStatsRepository) RecordOrderPlaced(eventID string, order *models.CustomerOrder) (bool, error) {
+	return r.inOrder(order.OrderID, func(tx *sql.Tx) (bool, error) {
+		if applied, err := markProcessed(tx, eventID, events.OrderPlaced); err != nil || !applied {
+			return false, err
+		}
+
+		query := `
+			INSERT INTO customer_orders (order_id, user_id, total_amount, currency, placed_at)
+			SELECT $1, $2, $3, $4, $5 WHERE EXISTS (SELECT 1 FROM users WHERE id = $2)
+			ON CONFLICT (order_id) DO NOTHING
+		`
+		result, err := tx.Exec(query, order.OrderID, order.UserID, order.TotalAmount, order.Currency, order.PlacedAt)
+		if err != nil {
+			return false, err
+		}
+		if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+			return err == nil, err
+		}
+
+		query = `
+			INSERT INTO customer_stats (user_id, order_count, first_order_at, last_order_at)
+			VALUES ($1, 1, $2, $2)
+			ON CONFLICT (user_id) DO UPDATE SET
+				order_count = customer_stats.order_count + 1,
+				first_order_at = LEAST(customer_stats.first_order_at, EXCLUDED.first_order_at),
+				last_order_at = GREATEST(customer_stats.last_order_at, EXCLUDED.last_order_at)
+		`
+		if _, err := tx.Exec(query, order.UserID, order.PlacedAt); err != nil {
+			return false, err
+		}
+		if err := addSpend(tx, order.UserID, order.Currency, order.TotalAmount); err != nil {
+			return false, err
+		}
+
+		return true, applyParked(tx, order.OrderID)
+	})
+}
+
+func (r *customerStatsRepository) RecordOrderDelivered(eventID, orderID string) (bool, error) {
+	return r.recordForOrder(orderEvent{eventID: eventID, eventType: events.OrderDelivered, orderID: orderID})
+}
+
+func (r *customerStatsRepository) RecordOrderCancelled(eventID, orderID string) (bool, error) {
+	return r.recordForOrder(orderEvent{eventID: eventID, eventType: events.OrderCancelled, orderID: orderID})
+}
+
+func (r *customerStatsRepository) RecordRefund(eventID, orderID, currency string, amount int64) (bool, error) {
+	return r.recordForOrder(orderEvent{eventID: eventID, eventType: events.PaymentRefunded, orderID: orderID, currency: currency, amount: amount})
+}
+
+// recordForOrder applies an event about an order, or parks it when the
+// order has not been placed yet.
+func (r *customerStatsRepository) recordForOrder(event orderEvent) (bool, error) {
+	return r.inOrder(event.orderID, func(tx *sql.Tx) (bool, error) {
+		var placed, processed bool
+		query := `
+			SELECT EXISTS (SELECT 1 FROM customer_orders WHERE order_id = $1),
+				EXISTS (SELECT 1 FROM processed_events WHERE event_id = $2)
+		`
+		if err := tx.QueryRow(query, event.orderID, event.eventID).Scan(&placed, &processed); err != nil {
+			return false, err
+		}
+
+		if !placed && !processed {
+			query = `
+				INSERT INTO pending_order_events (event_id, event_type, order_id, currency, amount)
+				VALUES ($1, $2, $3, $4, $5)
+				ON CONFLICT DO NOTHING
+			`
+			result, err := tx.Exec(query, event.eventID, event.eventType, event.orderID, event.currency, event.amount)
+			if err != nil {
+				return false, err
+			}
+			rows, err := result.RowsAffected()
+			return rows > 0, err
+		}
+
+		if applied, err := markProcessed(tx, event.eventID, event.eventType); err != nil || !applied {
+			return false, err
+		}
+		return true, applyToOrder(tx, event)
+	})
+}
+
+// applyParked applies, in arrival order, the events parked for an order
+// that has just been placed.
+func applyParked(tx *sql.Tx, orderID string) error {
+	query := `
+		DELETE FROM pending_order_events WHERE order_id = $1
+		RETURNING event_id, event_type, currency, amount, received_at
+	`
+	rows, err := tx.Query(query, orderID)
+	if err != nil {
+		return err
+	}
+
+	type parked struct {
+		orderEvent
+		receivedAt time.Time
+	}
+	var pending []parked
+	for rows.Next() {
+		p := parked{orderEvent: orderEvent{orderID: orderID}}
+		if err := rows.Scan(&p.eventID, &p.eventType, &p.currency, &p.amount, &p.receivedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	sort.SliceStable(pending, func(i, j int) bool { return pending[i].receivedAt.Before(pending[j].receivedAt) })
+	for _, p := range pending {
+		applied, err := markProcessed(tx, p.eventID, p.eventType)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			continue
+		}
+		if err := applyToOrder(tx, p.orderEvent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyToOrder(tx *sql.Tx, event orderEvent) error {
+	switch event.eventType {
+	case events.OrderDelivered:
+		return applyDelivered(tx, event.orderID)
+	case events.OrderCancelled:
+		return applyCancelled(tx, event.orderID)
+	default:
+		return applyRefund(tx, event.orderID, event.currency, event.amount)
+	}
+}
+
+func applyDelivered(tx *sql.Tx, orderID string) error {
+	var userID string
+	err := tx.QueryRow(`UPDATE customer_orders SET status = 'delivered' WHERE order_id = $1 AND status = 'placed' RETURNING user_id`, orderID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE customer_stats SET delivered_order_count = delivered_order_count + 1 WHERE user_id = $1`, userID)
+	return err
+}
+
+func applyCancelled(tx *sql.Tx, orderID string) error {
+	var userID, currency string
+	var counted int64
+	query := `
+		UPDATE customer_orders SET status = 'cancelled'
+		WHERE order_id = $1 AND status = 'placed'
+		RETURNING user_id, currency, total_amount - refunded_amount
+	`
+	err := tx.QueryRow(query, orderID).Scan(&userID, &currency, &counted)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	query = `
+		UPDATE customer_stats SET order_count = order_count - 1, cancelled_order_count = cancelled_order_count + 1
+		WHERE user_id = $1
+	`
+	if _, err := tx.Exec(query, userID); err != nil {
+		return err
+	}
+
+	return addSpend(tx, userID, currency, -counted)
+}
+
+func applyRefund(tx *sql.Tx, orderID, currency string, amount int64) error {
+	var userID, status string
+	var refunded int64
+	// The refund can't exceed what is left of the order.
+	query := `
+		WITH previous AS (
+			SELECT order_id, refunded_amount FROM customer_orders WHERE order_id = $1 AND currency = $3 FOR UPDATE
+		)
+		UPDATE customer_orders o SET refunded_amount = LEAST(o.total_amount, o.refunded_amount + $2)
+		FROM previous WHERE o.order_id = previous.order_id
+		RETURNING o.user_id, o.status, o.refunded_amount - previous.refunded_amount
+	`
+	err := tx.QueryRow(query, orderID, amount, currency).Scan(&userID, &status, &refunded)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// A cancelled order no longer counts towards spend.
+	if status == "cancelled" {
+		return nil
+	}
+	return addSpend(tx, userID, currency, -refunded)
+}
+
+func addSpend(tx *sql.Tx, userID, currency string, amount int64) error {
+	query := `
+		INSERT INTO customer_spend (user_id, currency, amount) VALUES ($1, $2, GREATEST($3, 0))
+		ON CONFLICT (user_id, currency) DO UPDATE SET amount = GREATEST(customer_spend.amount + $3, 0)
+	`
+	_, err := tx.Exec(query, userID, currency, amount)
+	return err
+}
+
+func (r *customerStatsRepository) GetByUserID(userID string) (*models.CustomerStats, error) {
+	stats := &models.CustomerStats{LifetimeSpend: map[string]int64{}}
+
+	query := `
+		SELECT order_count, delivered_order_count, cancelled_order_count, first_order_at, last_order_at
+		FROM customer_stats WHERE user_id = $1
+	`
+	err := r.db.QueryRow(query, userID).Scan(&stats.OrderCount, &stats.DeliveredOrderCount,
+		&stats.CancelledOrderCount, &stats.FirstOrderAt, &stats.LastOrderAt)
+	if err == sql.ErrNoRows {
+		return stats, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`SELECT currency, amount FROM customer_spend WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var currency string
+		var amount int64
+		if err := rows.Scan(&currency, &amount); err != nil {
+			return nil, err
+		}
+		stats.LifetimeSpend[currency] = amount
+	}
+	return stats, rows.Err()
+}
+
+func (r *customerStatsRepository) DeleteProcessedBefore(before time.Time) (int64, error) {
+	var deleted int64
+	for _, query := range []string{
+		`DELETE FROM processed_events WHERE processed_at < $1`,
+		`DELETE FROM pending_order_events WHERE received_at < $1`,
+	} {
+		result, err := r.db.Exec(query, before)
+		if err != nil {
+			return deleted, err
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return deleted, err
+		}
+		deleted += rows
+	}
+	return deleted, nil
+}
@@ -1,55 +1,32 @@
 package repository
 
 import (
-	"database/sql"
+	"context"
 	"fmt"
-	"os"
+	"net"
+	"time"
 
-	_ "github.com/lib/pq"
-)
-
-func NewPostgresConnection() (*sql.DB, error) {
-	host := os.Getenv("DB_HOST")
-	if host == "" {
-		host = "localhost"
-	}
-
-	port := os.Getenv("DB_PORT")
-	if port == "" {
-		port = "5432"
-	}
-
-	user := os.Getenv("DB_USER")
-	if user == "" {
-		user = "postgres"
-	}
+	"user-service/internal/config"
 
-	password := os.Getenv("DB_PASSWORD")
-	if password == "" {
-		password = "password"
-	}
-
-	dbname := os.Getenv("DB_NAME")
-	if dbname == "" {
-		dbname = "user_db"
-	}
-
-	sslmode := os.Getenv("DB_SSLMODE")
-	if sslmode == "" {
-		sslmode = "disable"
-	}
+	"github.com/redis/go-redis/v9"
+)
 
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=%s",
-		host, port, user, password, dbname, sslmode)
+// NewRedisConnection creates a Redis client and checks it can be reached. The
+// client is returned even when the ping fails, since go-redis reconnects on
+// its own and callers are expected to degrade gracefully meanwhile.
+func NewRedisConnection(cfg config.RedisConfig) (*redis.Client, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(cfg.Host, cfg.Port),
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
 
-	db, err := sql.Open("postgres", connStr)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database connection: %w", err)
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	if err := client.Ping(ctx).Err(); err != nil {
+		return client, fmt.Errorf("failed to ping redis: %w", err)
 	}
 
-	return db, nil
+	return client, nil
 }
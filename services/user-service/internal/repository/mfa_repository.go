@@ -0,0 +1,212 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type MFARepository interface {
+	GetByUserID(userID string) (*models.UserMFA, error)
+	SavePending(userID, secret string) error
+	// ListSecrets returns the user ID and stored secret of every factor.
+	ListSecrets() ([]*models.UserMFA, error)
+	// ReplaceSecret stores secret in place of current, unless the factor
+	// changed in the meantime.
+	ReplaceSecret(userID, current, secret string) error
+	Enable(userID string, recoveryCodeHashes []string) error
+	MarkStepUsed(userID string, step int64) (bool, error)
+	ReplaceRecoveryCodes(userID string, codeHashes []string) error
+	ConsumeRecoveryCode(userID, codeHash string) (bool, error)
+	// ConsumeChallenge records a login challenge as used. It reports false
+	// when the challenge had already been used.
+	ConsumeChallenge(challengeID, userID string, expiresAt time.Time) (bool, error)
+	Delete(userID string) error
+}
+
+type mfaRepository struct {
+	db *sql.DB
+}
+
+func NewMFARepository(db *sql.DB) MFARepository {
+	return &mfaRepository{db: db}
+}
+
+func (r *mfaRepository) GetByUserID(userID string) (*models.UserMFA, error) {
+	mfa := &models.UserMFA{}
+	query := `
+		SELECT user_id, secret, enabled_at, last_used_step, created_at, updated_at
+		FROM user_mfa WHERE user_id = $1
+	`
+
+	err := r.db.QueryRow(query, userID).Scan(
+		&mfa.UserID, &mfa.Secret, &mfa.EnabledAt, &mfa.LastUsedStep, &mfa.CreatedAt, &mfa.UpdatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return mfa, err
+}
+
+// SavePending stores a new, not yet confirmed secret. An already enabled
+// factor is left untouched so enrollment can't be used to swap it silently.
+func (r *mfaRepository) SavePending(userID, secret string) error {
+	query := `
+		INSERT INTO user_mfa (user_id, secret, enabled_at, last_used_step, created_at, updated_at)
+		VALUES ($1, $2, NULL, 0, $3, $3)
+		ON CONFLICT (user_id) DO UPDATE
+		SET secret = EXCLUDED.secret, last_used_step = 0, updated_at = EXCLUDED.updated_at
+		WHERE user_mfa.enabled_at IS NULL
+	`
+
+	_, err := r.db.Exec(query, userID, secret, time.Now())
+	return err
+}
+
+func (r *mfaRepository) ListSecrets() ([]*models.UserMFA, error) {
+	rows, err := r.db.Query(`SELECT user_id, secret FROM user_mfa`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var factors []*models.UserMFA
+	for rows.Next() {
+		mfa := &models.UserMFA{}
+		if err := rows.Scan(&mfa.UserID, &mfa.Secret); err != nil {
+			return nil, err
+		}
+		factors = append(factors, mfa)
+	}
+
+	return factors, rows.Err()
+}
+
+func (r *mfaRepository) ReplaceSecret(userID, current, secret string) error {
+	_, err := r.db.Exec(`UPDATE user_mfa SET secret = $3 WHERE user_id = $1 AND secret = $2`, userID, current, secret)
+	return err
+}
+
+func (r *mfaRepository) Enable(userID string, recoveryCodeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`UPDATE user_mfa SET enabled_at = NOW() WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if err := replaceRecoveryCodes(tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// MarkStepUsed records the TOTP step that was just accepted. It reports false
+// when that step (or a later one) has already been used, which blocks replays.
+func (r *mfaRepository) MarkStepUsed(userID string, step int64) (bool, error) {
+	query := `UPDATE user_mfa SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2`
+
+	result, err := r.db.Exec(query, userID, step)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func (r *mfaRepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func replaceRecoveryCodes(tx *sql.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO mfa_recovery_codes (id, user_id, code_hash, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+	now := time.Now()
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec(query, uuid.New().String(), userID, hash, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *mfaRepository) ConsumeRecoveryCode(userID, codeHash string) (bool, error) {
+	query := `
+		UPDATE mfa_recovery_codes SET used_at = NOW()
+		WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL
+	`
+
+	result, err := r.db.Exec(query, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func (r *mfaRepository) ConsumeChallenge(challengeID, userID string, expiresAt time.Time) (bool, error) {
+	// Expired challenges are rejected by their signature check anyway.
+	if _, err := r.db.Exec(`DELETE FROM mfa_used_challenges WHERE expires_at < NOW()`); err != nil {
+		return false, err
+	}
+
+	query := `
+		INSERT INTO mfa_used_challenges (jti, user_id, expires_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (jti) DO NOTHING
+	`
+
+	result, err := r.db.Exec(query, challengeID, userID, expiresAt)
+	if err != nil {
+		return false, err
+	}
+
+	rows, err := result.RowsAffected()
+	return rows == 1, err
+}
+
+func (r *mfaRepository) Delete(userID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_mfa WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
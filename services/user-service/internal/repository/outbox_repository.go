@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
+
+	"user-service/internal/events"
+	"user-service/internal/models"
+)
+
+type OutboxRepository interface {
+	// ClaimDue returns up to limit pending events whose next attempt is due,
+	// oldest first, and pushes their next attempt back by lease so other
+	// relays skip them while they are being published.
+	ClaimDue(limit int, lease time.Duration) ([]*models.OutboxEvent, error)
+	MarkPublished(id string) error
+	// MarkFailed records a failed attempt and schedules the next one.
+	MarkFailed(id, lastError string, nextAttemptAt time.Time) error
+	// MarkDead records the last failed attempt and moves the event to the
+	// dead letters.
+	MarkDead(id, lastError string) error
+	// ListDead returns a page of dead letters, most recent failure first,
+	// and their total count.
+	ListDead(limit, offset int) ([]*models.OutboxEvent, int, error)
+	// Requeue makes a dead letter pending again with a fresh attempt count.
+	// It reports false when no dead letter has the ID.
+	Requeue(id string) (bool, error)
+	DeletePublishedBefore(before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db *sql.DB
+}
+
+func NewOutboxRepository(db *sql.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+const outboxColumns = `id, aggregate_id, event_type, payload, status, attempts, last_error,
+	next_attempt_at, created_at, updated_at`
+
+func scanOutboxEvent(row rowScanner) (*models.OutboxEvent, error) {
+	event := &models.OutboxEvent{}
+	var payload []byte
+	err := row.Scan(
+		&event.ID, &event.AggregateID, &event.EventType, &payload, &event.Status, &event.Attempts,
+		&event.LastError, &event.NextAttemptAt, &event.CreatedAt, &event.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	event.Payload = payload
+	return event, nil
+}
+
+// enqueueEvent writes event to the outbox inside tx, so it is published if
+// and only if the change it describes commits.
+func enqueueEvent(tx *sql.Tx, aggregateID string, event *events.Envelope) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO outbox_events (id, aggregate_id, event_type, payload, created_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+	`
+	_, err = tx.Exec(query, event.EventID, aggregateID, event.EventType, payload, event.Timestamp)
+	return err
+}
+
+// enqueueUserEvent writes a user.* event describing user, and queues it for
+// the webhooks subscribed to it.
+func enqueueUserEvent(tx *sql.Tx, eventType string, user *models.User) error {
+	event, err := events.NewUserEvent(eventType, user)
+	if err != nil {
+		return err
+	}
+	if err := enqueueEvent(tx, user.ID, event); err != nil {
+		return err
+	}
+	return enqueueWebhookDeliveries(tx, event)
+}
+
+func (r *outboxRepository) ClaimDue(limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	// SKIP LOCKED lets several replicas relay concurrently without handing
+	// out the same event twice.
+	query := `
+		UPDATE outbox_events SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY created_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + outboxColumns
+
+	rows, err := r.db.Query(query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []*models.OutboxEvent
+	for rows.Next() {
+		event, err := scanOutboxEvent(rows)
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING gives no order guarantee.
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].CreatedAt.Before(claimed[j].CreatedAt) })
+	return claimed, nil
+}
+
+func (r *outboxRepository) MarkPublished(id string) error {
+	query := `UPDATE outbox_events SET status = 'published', attempts = attempts + 1, last_error = NULL, published_at = NOW() WHERE id = $1`
+	_, err := r.db.Exec(query, id)
+	return err
+}
+
+func (r *outboxRepository) MarkFailed(id, lastError string, nextAttemptAt time.Time) error {
+	query := `UPDATE outbox_events SET attempts = attempts + 1, last_error = $2, next_attempt_at = $3 WHERE id = $1`
+	_, err := r.db.Exec(query, id, lastError, nextAttemptAt)
+	return err
+}
+
+func (r *outboxRepository) MarkDead(id, lastError string) error {
+	query := `UPDATE outbox_events SET status = 'dead', attempts = attempts + 1, last_error = $2 WHERE id = $1`
+	_, err := r.db.Exec(query, id, lastError)
+	return err
+}
+
+func (r *outboxRepository) ListDead(limit, offset int) ([]*models.OutboxEvent, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM outbox_dead_letters`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT id, aggregate_id, event_type, payload, 'dead', attempts, last_error, failed_at, created_at, failed_at
+		FROM outbox_dead_letters
+		ORDER BY failed_at DESC, id
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var dead []*models.OutboxEvent
+	for rows.Next() {
+		event, err := scanOutboxEvent(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		dead = append(dead, event)
+	}
+	return dead, total, rows.Err()
+}
+
+func (r *outboxRepository) Requeue(id string) (bool, error) {
+	query := `
+		UPDATE outbox_events SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+		WHERE id = $1 AND status = 'dead'
+	`
+	result, err := r.db.Exec(query, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *outboxRepository) DeletePublishedBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM outbox_events WHERE status = 'published' AND published_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+)
+
+type PasswordRepository interface {
+	GetHistory(userID string, limit int) ([]string, error)
+	GetChangedAt(userID string) (*time.Time, error)
+	ChangePassword(userID, keepSessionID, passwordHash string, historySize int) (time.Time, error)
+}
+
+type passwordRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordRepository(db *sql.DB) PasswordRepository {
+	return &passwordRepository{db: db}
+}
+
+// GetHistory returns up to limit previous password hashes, newest first.
+func (r *passwordRepository) GetHistory(userID string, limit int) ([]string, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	query := `
+		SELECT password_hash FROM password_history
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`
+
+	rows, err := r.db.Query(query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// GetChangedAt returns when the user last changed or reset their password, or
+// nil if they never have.
+func (r *passwordRepository) GetChangedAt(userID string) (*time.Time, error) {
+	var changedAt *time.Time
+	err := r.db.QueryRow(`SELECT password_changed_at FROM users WHERE id = $1`, userID).Scan(&changedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return changedAt, err
+}
+
+// ChangePassword stores the new hash, keeping the old one in the history, and
+// revokes every session of the user except keepSessionID in one transaction.
+// It returns the recorded change time.
+func (r *passwordRepository) ChangePassword(userID, keepSessionID, passwordHash string, historySize int) (time.Time, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	changedAt, err := replacePasswordHash(tx, userID, passwordHash, historySize)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	query := `
+		UPDATE user_sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND id::text <> $2 AND revoked_at IS NULL
+	`
+
+	if _, err := tx.Exec(query, userID, keepSessionID); err != nil {
+		return time.Time{}, err
+	}
+
+	return changedAt, tx.Commit()
+}
+
+// replacePasswordHash moves the current hash into password_history, trims the
+// history to historySize entries and stores the new hash.
+func replacePasswordHash(tx *sql.Tx, userID, passwordHash string, historySize int) (time.Time, error) {
+	if historySize > 0 {
+		if _, err := tx.Exec(`
+			INSERT INTO password_history (user_id, password_hash)
+			SELECT id, password_hash FROM users WHERE id = $1
+		`, userID); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if _, err := tx.Exec(`
+		DELETE FROM password_history
+		WHERE user_id = $1 AND id NOT IN (
+			SELECT id FROM password_history
+			WHERE user_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		)
+	`, userID, historySize); err != nil {
+		return time.Time{}, err
+	}
+
+	changedAt := time.Now()
+	query := `UPDATE users SET password_hash = $1, password_changed_at = $2 WHERE id = $3`
+	if _, err := tx.Exec(query, passwordHash, changedAt, userID); err != nil {
+		return time.Time{}, err
+	}
+
+	return changedAt, nil
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type PasswordResetRepository interface {
+	CreateToken(token *models.PasswordResetToken) error
+	GetLatestByUserID(userID string) (*models.PasswordResetToken, error)
+	DeleteUnusedByUserID(userID string) error
+	GetUserIDByToken(tokenHash string) (string, error)
+	ResetPassword(tokenHash, passwordHash string, historySize int) (string, time.Time, error)
+}
+
+type passwordResetRepository struct {
+	db *sql.DB
+}
+
+func NewPasswordResetRepository(db *sql.DB) PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (r *passwordResetRepository) CreateToken(token *models.PasswordResetToken) error {
+	token.ID = uuid.New().String()
+	token.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO password_reset_tokens (id, user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(query, token.ID, token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	return err
+}
+
+func (r *passwordResetRepository) GetLatestByUserID(userID string) (*models.PasswordResetToken, error) {
+	token := &models.PasswordResetToken{}
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM password_reset_tokens WHERE user_id = $1
+		ORDER BY created_at DESC LIMIT 1
+	`
+
+	err := r.db.QueryRow(query, userID).Scan(
+		&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return token, err
+}
+
+func (r *passwordResetRepository) DeleteUnusedByUserID(userID string) error {
+	query := `DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
+
+// GetUserIDByToken returns the owner of a usable token, or an empty string.
+func (r *passwordResetRepository) GetUserIDByToken(tokenHash string) (string, error) {
+	var userID string
+	query := `
+		SELECT user_id FROM password_reset_tokens
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+	`
+
+	err := r.db.QueryRow(query, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+
+	return userID, err
+}
+
+// ResetPassword consumes the token, stores the new password hash (keeping the
+// old one in the history) and drops every session of the owner in one
+// transaction. It returns the user ID and the time of the change, or an empty
+// string when no usable token matches the hash.
+func (r *passwordResetRepository) ResetPassword(tokenHash, passwordHash string, historySize int) (string, time.Time, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	query := `
+		UPDATE password_reset_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	err = tx.QueryRow(query, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	changedAt, err := replacePasswordHash(tx, userID, passwordHash, historySize)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM password_reset_tokens WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return "", time.Time{}, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM user_sessions WHERE user_id = $1`, userID); err != nil {
+		return "", time.Time{}, err
+	}
+
+	return userID, changedAt, tx.Commit()
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+
+	"user-service/internal/models"
+
+	"github.com/lib/pq"
+)
+
+var (
+	ErrDuplicateRole = errors.New("role already exists")
+	ErrRoleInUse     = errors.New("role is assigned to users")
+)
+
+type RoleRepository interface {
+	// ListRoles returns every role with its permissions, by name.
+	ListRoles() ([]*models.Role, error)
+	// GetRole returns nil when no role has the name.
+	GetRole(name string) (*models.Role, error)
+	// CreateRole returns ErrDuplicateRole when the name is taken.
+	CreateRole(role *models.Role) error
+	// UpdateRole writes the description and replaces the permissions; it
+	// returns sql.ErrNoRows when the role is gone.
+	UpdateRole(role *models.Role) error
+	// DeleteRole removes a role that is not a system role. It reports false
+	// when there is no such role and returns ErrRoleInUse while users hold it.
+	DeleteRole(name string) (bool, error)
+	ListPermissions() ([]*models.Permission, error)
+	// PermissionsForRole returns the permissions of a role, sorted; an
+	// unknown role has none.
+	PermissionsForRole(role string) ([]string, error)
+}
+
+type roleRepository struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) RoleRepository {
+	return &roleRepository{db: db}
+}
+
+const roleSelect = `SELECT r.name, r.description, r.is_system, r.created_at, r.updated_at,
+	COALESCE(array_agg(rp.permission ORDER BY rp.permission) FILTER (WHERE rp.permission IS NOT NULL), '{}')
+	FROM roles r LEFT JOIN role_permissions rp ON rp.role = r.name`
+
+func scanRole(row rowScanner) (*models.Role, error) {
+	role := &models.Role{}
+	err := row.Scan(&role.Name, &role.Description, &role.IsSystem, &role.CreatedAt, &role.UpdatedAt, pq.Array(&role.Permissions))
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+func (r *roleRepository) ListRoles() ([]*models.Role, error) {
+	rows, err := r.db.Query(roleSelect + ` GROUP BY r.name ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var roles []*models.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+func (r *roleRepository) GetRole(name string) (*models.Role, error) {
+	role, err := scanRole(r.db.QueryRow(roleSelect+` WHERE r.name = $1 GROUP BY r.name`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return role, err
+}
+
+func (r *roleRepository) CreateRole(role *models.Role) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO roles (name, description)
+		VALUES ($1, $2)
+		ON CONFLICT (name) DO NOTHING
+		RETURNING is_system, created_at, updated_at`
+
+	err = tx.QueryRow(query, role.Name, role.Description).Scan(&role.IsSystem, &role.CreatedAt, &role.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return ErrDuplicateRole
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := grantPermissions(tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *roleRepository) UpdateRole(role *models.Role) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE roles SET description = $2 WHERE name = $1 RETURNING is_system, created_at, updated_at`
+	if err := tx.QueryRow(query, role.Name, role.Description).Scan(&role.IsSystem, &role.CreatedAt, &role.UpdatedAt); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM role_permissions WHERE role = $1`, role.Name); err != nil {
+		return err
+	}
+	if err := grantPermissions(tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *roleRepository) DeleteRole(name string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM roles WHERE name = $1 AND NOT is_system`, name)
+	if err != nil {
+		// users.role references the role
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23503" {
+			return false, ErrRoleInUse
+		}
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *roleRepository) ListPermissions() ([]*models.Permission, error) {
+	rows, err := r.db.Query(`SELECT name, description FROM permissions ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var permissions []*models.Permission
+	for rows.Next() {
+		permission := &models.Permission{}
+		if err := rows.Scan(&permission.Name, &permission.Description); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func (r *roleRepository) PermissionsForRole(role string) ([]string, error) {
+	rows, err := r.db.Query(`SELECT permission FROM role_permissions WHERE role = $1 ORDER BY permission`, role)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	permissions := []string{}
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+func grantPermissions(tx *sql.Tx, role string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+	_, err := tx.Exec(`
+		INSERT INTO role_permissions (role, permission)
+		SELECT $1, unnest($2::text[])
+		ON CONFLICT DO NOTHING`, role, pq.Array(permissions))
+	return err
+}
@@ -0,0 +1,194 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository interface {
+	CreateSession(session *models.UserSession, token *models.RefreshToken) error
+	GetSession(sessionID string) (*models.UserSession, error)
+	ListActiveByUserID(userID string) ([]*models.UserSession, error)
+	GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken(oldTokenID string, next *models.RefreshToken, client models.ClientInfo) (bool, error)
+	RevokeSession(sessionID string) error
+	RevokeAllForUser(userID string) error
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+// CreateSession stores a new session together with the first refresh token of
+// its family.
+func (r *sessionRepository) CreateSession(session *models.UserSession, token *models.RefreshToken) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	session.ID = uuid.New().String()
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+
+	query := `
+		INSERT INTO user_sessions (id, user_id, ip_address, user_agent, expires_at, created_at, last_used_at)
+		VALUES ($1, $2, NULLIF($3, '')::inet, NULLIF($4, ''), $5, $6, $7)
+	`
+
+	if _, err := tx.Exec(query, session.ID, session.UserID, session.IPAddress,
+		session.UserAgent, session.ExpiresAt, session.CreatedAt, session.LastUsedAt); err != nil {
+		return err
+	}
+
+	token.SessionID = session.ID
+	if err := insertRefreshToken(tx, token); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const sessionColumns = `id, user_id, expires_at, created_at, last_used_at, revoked_at,
+	COALESCE(host(ip_address), ''), COALESCE(user_agent, '')`
+
+func scanSession(row interface{ Scan(...interface{}) error }) (*models.UserSession, error) {
+	session := &models.UserSession{}
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.ExpiresAt, &session.CreatedAt,
+		&session.LastUsedAt, &session.RevokedAt, &session.IPAddress, &session.UserAgent,
+	)
+	return session, err
+}
+
+func (r *sessionRepository) GetSession(sessionID string) (*models.UserSession, error) {
+	query := `SELECT ` + sessionColumns + ` FROM user_sessions WHERE id = $1`
+
+	session, err := scanSession(r.db.QueryRow(query, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return session, err
+}
+
+// ListActiveByUserID returns the user's sessions that are neither revoked nor
+// expired, most recently used first.
+func (r *sessionRepository) ListActiveByUserID(userID string) ([]*models.UserSession, error) {
+	query := `
+		SELECT ` + sessionColumns + `
+		FROM user_sessions
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY last_used_at DESC
+	`
+
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []*models.UserSession
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *sessionRepository) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	token := &models.RefreshToken{}
+	query := `
+		SELECT id, user_id, session_id, parent_id, token_hash, expires_at, used_at, created_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`
+
+	err := r.db.QueryRow(query, tokenHash).Scan(
+		&token.ID, &token.UserID, &token.SessionID, &token.ParentID,
+		&token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return token, err
+}
+
+// RotateRefreshToken marks the old token as used and stores its successor in
+// the same family, recording the client on the session. It reports false when
+// the old token had already been used, meaning another request won the race
+// with the same token.
+func (r *sessionRepository) RotateRefreshToken(oldTokenID string, next *models.RefreshToken, client models.ClientInfo) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1 AND used_at IS NULL`, oldTokenID)
+	if err != nil {
+		return false, err
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows != 1 {
+		return false, err
+	}
+
+	next.ParentID = &oldTokenID
+	if err := insertRefreshToken(tx, next); err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE user_sessions
+		SET expires_at = $1, last_used_at = NOW(),
+			ip_address = COALESCE(NULLIF($2, '')::inet, ip_address),
+			user_agent = COALESCE(NULLIF($3, ''), user_agent)
+		WHERE id = $4
+	`
+
+	if _, err := tx.Exec(query, next.ExpiresAt, client.IPAddress, client.UserAgent, next.SessionID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *sessionRepository) RevokeSession(sessionID string) error {
+	query := `UPDATE user_sessions SET revoked_at = NOW() WHERE id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, sessionID)
+	return err
+}
+
+func (r *sessionRepository) RevokeAllForUser(userID string) error {
+	query := `UPDATE user_sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
+
+func insertRefreshToken(tx *sql.Tx, token *models.RefreshToken) error {
+	token.ID = uuid.New().String()
+	token.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO refresh_tokens (id, user_id, session_id, parent_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := tx.Exec(query, token.ID, token.UserID, token.SessionID, token.ParentID,
+		token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	return err
+}
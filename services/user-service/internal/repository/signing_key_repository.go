@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"user-service/internal/models"
+)
+
+// signingKeyRotationLock serialises rotations across replicas.
+const signingKeyRotationLock = 7305001
+
+type SigningKeyRepository interface {
+	ListValid() ([]*models.SigningKey, error)
+	RotateIfStale(key *models.SigningKey, staleBefore, retiredExpiresAt time.Time) (bool, error)
+	DeleteExpired() error
+}
+
+type signingKeyRepository struct {
+	db *sql.DB
+}
+
+func NewSigningKeyRepository(db *sql.DB) SigningKeyRepository {
+	return &signingKeyRepository{db: db}
+}
+
+// ListValid returns every key that may still verify tokens, newest first.
+func (r *signingKeyRepository) ListValid() ([]*models.SigningKey, error) {
+	query := `
+		SELECT kid, algorithm, private_key, public_key, created_at, activates_at, retired_at, expires_at
+		FROM jwt_signing_keys
+		WHERE expires_at IS NULL OR expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*models.SigningKey
+	for rows.Next() {
+		key := &models.SigningKey{}
+		if err := rows.Scan(
+			&key.KID, &key.Algorithm, &key.PrivateKeyPEM, &key.PublicKeyPEM,
+			&key.CreatedAt, &key.ActivatesAt, &key.RetiredAt, &key.ExpiresAt,
+		); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// RotateIfStale inserts key as the next signing key unless the newest one was
+// created after staleBefore (typically because another replica just rotated).
+// The previous signing key is retired once key.ActivatesAt is reached and keeps
+// verifying until retiredExpiresAt.
+func (r *signingKeyRepository) RotateIfStale(key *models.SigningKey, staleBefore, retiredExpiresAt time.Time) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, signingKeyRotationLock); err != nil {
+		return false, err
+	}
+
+	var current time.Time
+	err = tx.QueryRow(`
+		SELECT created_at FROM jwt_signing_keys
+		WHERE retired_at IS NULL
+		ORDER BY created_at DESC LIMIT 1
+	`).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if err == nil && current.After(staleBefore) {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE jwt_signing_keys SET retired_at = $1, expires_at = $2
+		WHERE retired_at IS NULL
+	`, key.ActivatesAt, retiredExpiresAt); err != nil {
+		return false, err
+	}
+
+	key.CreatedAt = time.Now()
+	if _, err := tx.Exec(`
+		INSERT INTO jwt_signing_keys (kid, algorithm, private_key, public_key, created_at, activates_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`, key.KID, key.Algorithm, key.PrivateKeyPEM, key.PublicKeyPEM, key.CreatedAt, key.ActivatesAt); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (r *signingKeyRepository) DeleteExpired() error {
+	_, err := r.db.Exec(`DELETE FROM jwt_signing_keys WHERE expires_at IS NOT NULL AND expires_at <= NOW()`)
+	return err
+}
@@ -2,23 +2,54 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"time"
 
+	"user-service/internal/events"
 	"user-service/internal/models"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+var (
+	ErrDuplicateEmail    = errors.New("email is already in use")
+	ErrDuplicateUsername = errors.New("username is already in use")
+)
+
+// userColumns is the column list scanUser expects, in order.
+const userColumns = `id, email, username, password_hash, role, first_name, last_name, phone, avatar_url,
+	is_active, is_verified, password_changed_at, created_at, updated_at`
+
+// updatableUserColumns whitelists the columns Update may write.
+var updatableUserColumns = map[string]bool{
+	"email":       true,
+	"username":    true,
+	"first_name":  true,
+	"last_name":   true,
+	"phone":       true,
+	"avatar_url":  true,
+	"is_verified": true,
+	"role":        true,
+}
+
 type UserRepository interface {
 	Create(user *models.User) error
 	GetByID(id string) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	GetByUsername(username string) (*models.User, error)
+	GetByIDs(ids []string) ([]*models.User, error)
+	// List returns up to limit users matching filter in the given order,
+	// starting after the given key when there is one and otherwise skipping
+	// offset users.
+	List(filter models.UserFilter, order models.UserOrder, after *models.UserKey, limit, offset int) ([]*models.User, error)
+	Count(filter models.UserFilter) (int, error)
 	Update(id string, updates map[string]interface{}) error
+	UpdatePasswordHash(id, oldHash, newHash string) error
 	Delete(id string) error
-	CreateSession(session *models.UserSession) error
-	GetSessionByRefreshToken(token string) (*models.UserSession, error)
-	DeleteSession(sessionID string) error
 }
 
 type userRepository struct {
@@ -29,70 +60,190 @@ func NewUserRepository(db *sql.DB) UserRepository {
 	return &userRepository{db: db}
 }
 
+// Create inserts the user and queues a user.created event in the same
+// transaction.
 func (r *userRepository) Create(user *models.User) error {
 	user.ID = uuid.New().String()
 	user.CreatedAt = time.Now()
 	user.UpdatedAt = time.Now()
 
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `
-		INSERT INTO users (id, email, username, password_hash, role, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO users (id, email, username, password_hash, role, first_name, last_name, phone, avatar_url, is_active, is_verified, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
-	_, err := r.db.Exec(query, user.ID, user.Email, user.Username, user.Password, user.Role, user.IsActive, user.CreatedAt, user.UpdatedAt)
-	return err
+	_, err = tx.Exec(query, user.ID, user.Email, user.Username, user.Password, user.Role,
+		user.FirstName, user.LastName, user.Phone, user.AvatarURL,
+		user.IsActive, user.IsVerified, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return uniqueViolation(err)
+	}
+
+	if err := enqueueUserEvent(tx, events.UserCreated, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (r *userRepository) GetByID(id string) (*models.User, error) {
-	user := &models.User{}
-	query := `
-		SELECT id, email, username, password_hash, role, is_active, created_at, updated_at
-		FROM users WHERE id = $1
-	`
+	return r.getBy("id", id)
+}
 
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID, &user.Email, &user.Username, &user.Password,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-	)
+func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+	return r.getBy("email", email)
+}
 
-	if err == sql.ErrNoRows {
+func (r *userRepository) GetByUsername(username string) (*models.User, error) {
+	return r.getBy("username", username)
+}
+
+// GetByIDs loads the users with the given IDs, in no particular order. IDs
+// that don't exist are skipped.
+func (r *userRepository) GetByIDs(ids []string) ([]*models.User, error) {
+	if len(ids) == 0 {
 		return nil, nil
 	}
 
-	return user, err
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE id = ANY($1)`, userColumns)
+	rows, err := r.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
 }
 
-func (r *userRepository) GetByEmail(email string) (*models.User, error) {
-	user := &models.User{}
-	query := `
-		SELECT id, email, username, password_hash, role, is_active, created_at, updated_at
-		FROM users WHERE email = $1
-	`
+// userSortColumns whitelists the columns List may sort by, with the type a
+// cursor value is cast to.
+var userSortColumns = map[string]string{
+	"created_at": "timestamptz",
+	"updated_at": "timestamptz",
+	"email":      "text",
+	"username":   "text",
+}
 
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID, &user.Email, &user.Username, &user.Password,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-	)
+// userSearchExpression is what a search fragment is matched against; the
+// trigram index from migration 000009 covers exactly this expression.
+const userSearchExpression = `(email || ' ' || username || ' ' || first_name || ' ' || last_name)`
 
-	if err == sql.ErrNoRows {
-		return nil, nil
+func (r *userRepository) List(filter models.UserFilter, order models.UserOrder, after *models.UserKey, limit, offset int) ([]*models.User, error) {
+	castTo, ok := userSortColumns[order.Field]
+	if !ok {
+		return nil, fmt.Errorf("cannot sort users by %q", order.Field)
+	}
+	direction, comparison := "ASC", ">"
+	if order.Desc {
+		direction, comparison = "DESC", "<"
 	}
 
-	return user, err
+	conditions, args := userFilterConditions(filter)
+	if after != nil {
+		args = append(args, after.Value, after.ID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) %s ($%d::%s, $%d::uuid)",
+			order.Field, comparison, len(args)-1, castTo, len(args)))
+	} else {
+		args = append(args, offset)
+	}
+	args = append(args, limit)
+
+	query := fmt.Sprintf(`SELECT %s FROM users %s ORDER BY %s %s, id %s`,
+		userColumns, whereClause(conditions), order.Field, direction, direction)
+	if after == nil {
+		query += fmt.Sprintf(` OFFSET $%d`, len(args)-1)
+	}
+	query += fmt.Sprintf(` LIMIT $%d`, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
 }
 
-func (r *userRepository) GetByUsername(username string) (*models.User, error) {
-	user := &models.User{}
-	query := `
-		SELECT id, email, username, password_hash, role, is_active, created_at, updated_at
-		FROM users WHERE username = $1
-	`
+func (r *userRepository) Count(filter models.UserFilter) (int, error) {
+	conditions, args := userFilterConditions(filter)
 
-	err := r.db.QueryRow(query, username).Scan(
-		&user.ID, &user.Email, &user.Username, &user.Password,
-		&user.Role, &user.IsActive, &user.CreatedAt, &user.UpdatedAt,
-	)
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM users `+whereClause(conditions), args...).Scan(&total)
+	return total, err
+}
+
+// userFilterConditions turns filter into SQL conditions on numbered
+// placeholders and their arguments. created_from is inclusive and created_to
+// exclusive.
+func userFilterConditions(filter models.UserFilter) ([]string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	add := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Search != "" {
+		add(userSearchExpression+` ILIKE $%d`, "%"+escapeLike(filter.Search)+"%")
+	}
+	if filter.Role != "" {
+		add("role = $%d", filter.Role)
+	}
+	if filter.IsActive != nil {
+		add("is_active = $%d", *filter.IsActive)
+	}
+	if filter.IsVerified != nil {
+		add("is_verified = $%d", *filter.IsVerified)
+	}
+	if filter.CreatedFrom != nil {
+		add("created_at >= $%d", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		add("created_at < $%d", *filter.CreatedTo)
+	}
+	return conditions, args
+}
+
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+// escapeLike makes the LIKE wildcards in s match literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// getBy loads the user whose column equals value. column is always one of the
+// constants above, never caller input.
+func (r *userRepository) getBy(column, value string) (*models.User, error) {
+	query := fmt.Sprintf(`SELECT %s FROM users WHERE %s = $1`, userColumns, column)
 
+	user, err := scanUser(r.db.QueryRow(query, value))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
@@ -100,53 +251,124 @@ func (r *userRepository) GetByUsername(username string) (*models.User, error) {
 	return user, err
 }
 
+// Update writes the given columns in a single statement and queues a
+// user.updated event with the result. Column names must be in
+// updatableUserColumns; a nil value stores NULL. It returns sql.ErrNoRows
+// when the user does not exist and ErrDuplicateEmail or ErrDuplicateUsername
+// when a unique constraint is hit.
 func (r *userRepository) Update(id string, updates map[string]interface{}) error {
-	// Implementation for dynamic updates would go here
-	// For simplicity, implementing specific update methods
-	return nil
-}
+	if len(updates) == 0 {
+		return nil
+	}
 
-func (r *userRepository) Delete(id string) error {
-	query := `UPDATE users SET is_active = false, updated_at = $1 WHERE id = $2`
-	_, err := r.db.Exec(query, time.Now(), id)
-	return err
-}
+	columns := make([]string, 0, len(updates))
+	for column := range updates {
+		if !updatableUserColumns[column] {
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+		columns = append(columns, column)
+	}
+	// Sorted so the same set of fields always produces the same statement.
+	sort.Strings(columns)
 
-func (r *userRepository) CreateSession(session *models.UserSession) error {
-	session.ID = uuid.New().String()
-	session.CreatedAt = time.Now()
+	assignments := make([]string, 0, len(columns)+1)
+	args := make([]interface{}, 0, len(columns)+2)
+	for i, column := range columns {
+		assignments = append(assignments, fmt.Sprintf("%s = $%d", column, i+1))
+		args = append(args, updates[column])
+	}
+	assignments = append(assignments, fmt.Sprintf("updated_at = $%d", len(args)+1))
+	args = append(args, time.Now(), id)
 
-	query := `
-		INSERT INTO user_sessions (id, user_id, refresh_token, expires_at, created_at, ip_address, user_agent)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-	`
+	query := fmt.Sprintf(`UPDATE users SET %s WHERE id = $%d RETURNING %s`,
+		strings.Join(assignments, ", "), len(args), userColumns)
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	_, err := r.db.Exec(query, session.ID, session.UserID, session.RefreshToken,
-		session.ExpiresAt, session.CreatedAt, session.IPAddress, session.UserAgent)
+	user, err := scanUser(tx.QueryRow(query, args...))
+	if err != nil {
+		return uniqueViolation(err)
+	}
+
+	if err := enqueueUserEvent(tx, events.UserUpdated, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdatePasswordHash swaps in a re-encoded hash of the same password. It is a
+// no-op when the stored hash no longer equals oldHash, so a concurrent
+// password change is never overwritten, and it leaves password_changed_at
+// alone because existing tokens stay valid.
+func (r *userRepository) UpdatePasswordHash(id, oldHash, newHash string) error {
+	query := `UPDATE users SET password_hash = $1 WHERE id = $2 AND password_hash = $3`
+	_, err := r.db.Exec(query, newHash, id, oldHash)
 	return err
 }
 
-func (r *userRepository) GetSessionByRefreshToken(token string) (*models.UserSession, error) {
-	session := &models.UserSession{}
-	query := `
-		SELECT id, user_id, refresh_token, expires_at, created_at, ip_address, user_agent
-		FROM user_sessions WHERE refresh_token = $1 AND expires_at > NOW()
-	`
+// Delete deactivates the account and queues a user.deleted event. Deleting an
+// account that is already inactive does nothing.
+func (r *userRepository) Delete(id string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	err := r.db.QueryRow(query, token).Scan(
-		&session.ID, &session.UserID, &session.RefreshToken,
-		&session.ExpiresAt, &session.CreatedAt, &session.IPAddress, &session.UserAgent,
-	)
+	query := fmt.Sprintf(`UPDATE users SET is_active = false, updated_at = $1 WHERE id = $2 AND is_active RETURNING %s`, userColumns)
 
+	user, err := scanUser(tx.QueryRow(query, time.Now(), id))
 	if err == sql.ErrNoRows {
-		return nil, nil
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return session, err
+	if err := enqueueUserEvent(tx, events.UserDeleted, user); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (r *userRepository) DeleteSession(sessionID string) error {
-	query := `DELETE FROM user_sessions WHERE id = $1`
-	_, err := r.db.Exec(query, sessionID)
+// uniqueViolation maps unique constraint errors on users to the matching
+// sentinel error.
+func uniqueViolation(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23505" {
+		return err
+	}
+
+	switch {
+	case strings.Contains(pqErr.Constraint, "email"):
+		return ErrDuplicateEmail
+	case strings.Contains(pqErr.Constraint, "username"):
+		return ErrDuplicateUsername
+	}
 	return err
 }
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads a row selected with userColumns.
+func scanUser(row rowScanner) (*models.User, error) {
+	user := &models.User{}
+	err := row.Scan(
+		&user.ID, &user.Email, &user.Username, &user.Password, &user.Role,
+		&user.FirstName, &user.LastName, &user.Phone, &user.AvatarURL,
+		&user.IsActive, &user.IsVerified, &user.PasswordChangedAt, &user.CreatedAt, &user.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"user-service/internal/events"
+	"user-service/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type VerificationRepository interface {
+	CreateToken(token *models.EmailVerificationToken) error
+	GetLatestByUserID(userID string) (*models.EmailVerificationToken, error)
+	ConsumeToken(tokenHash string) (string, error)
+	DeleteUnusedByUserID(userID string) error
+}
+
+type verificationRepository struct {
+	db *sql.DB
+}
+
+func NewVerificationRepository(db *sql.DB) VerificationRepository {
+	return &verificationRepository{db: db}
+}
+
+func (r *verificationRepository) CreateToken(token *models.EmailVerificationToken) error {
+	token.ID = uuid.New().String()
+	token.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO email_verification_tokens (id, user_id, token_hash, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err := r.db.Exec(query, token.ID, token.UserID, token.TokenHash, token.ExpiresAt, token.CreatedAt)
+	return err
+}
+
+func (r *verificationRepository) GetLatestByUserID(userID string) (*models.EmailVerificationToken, error) {
+	token := &models.EmailVerificationToken{}
+	query := `
+		SELECT id, user_id, token_hash, expires_at, used_at, created_at
+		FROM email_verification_tokens WHERE user_id = $1
+		ORDER BY created_at DESC LIMIT 1
+	`
+
+	err := r.db.QueryRow(query, userID).Scan(
+		&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return token, err
+}
+
+// ConsumeToken marks an unexpired, unused token as used and flags its owner as
+// verified in a single transaction, queueing a user.updated event and a
+// user.verified webhook. It returns the user ID, or an empty string when no
+// usable token matches the hash.
+func (r *verificationRepository) ConsumeToken(tokenHash string) (string, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	query := `
+		UPDATE email_verification_tokens SET used_at = NOW()
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > NOW()
+		RETURNING user_id
+	`
+
+	err = tx.QueryRow(query, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	user, err := scanUser(tx.QueryRow(`UPDATE users SET is_verified = true WHERE id = $1 RETURNING `+userColumns, userID))
+	if err != nil {
+		return "", err
+	}
+	if err := enqueueUserEvent(tx, events.UserUpdated, user); err != nil {
+		return "", err
+	}
+	verified, err := events.NewUserEvent(events.UserVerified, user)
+	if err != nil {
+		return "", err
+	}
+	if err := enqueueWebhookDeliveries(tx, verified); err != nil {
+		return "", err
+	}
+
+	// Any other outstanding link for this user is now pointless.
+	if _, err := tx.Exec(`DELETE FROM email_verification_tokens WHERE user_id = $1 AND used_at IS NULL`, userID); err != nil {
+		return "", err
+	}
+
+	return userID, tx.Commit()
+}
+
+func (r *verificationRepository) DeleteUnusedByUserID(userID string) error {
+	query := `DELETE FROM email_verification_tokens WHERE user_id = $1 AND used_at IS NULL`
+	_, err := r.db.Exec(query, userID)
+	return err
+}
@@ -0,0 +1,410 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"user-service/internal/events"
+	"user-service/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+type WebhookRepository interface {
+	CreateSubscription(sub *models.WebhookSubscription) error
+	// GetSubscription returns nil when no subscription has the ID.
+	GetSubscription(id string) (*models.WebhookSubscription, error)
+	// ListSubscriptions returns a page of subscriptions, newest first, and
+	// their total count.
+	ListSubscriptions(limit, offset int) ([]*models.WebhookSubscription, int, error)
+	// UpdateSubscription writes the URL, description, events and active
+	// state. Re-activating a subscription clears its failure count; it
+	// returns sql.ErrNoRows when the subscription is gone.
+	UpdateSubscription(sub *models.WebhookSubscription) error
+	// DeleteSubscription removes a subscription with its deliveries. It
+	// reports false when no subscription has the ID.
+	DeleteSubscription(id string) (bool, error)
+
+	// ClaimDue returns up to limit pending deliveries of active subscriptions
+	// whose next attempt is due, oldest first, and pushes their next attempt
+	// back by lease so other dispatchers skip them.
+	ClaimDue(limit int, lease time.Duration) ([]*models.WebhookDelivery, error)
+	// MarkDelivered logs a successful attempt and resets the subscription's
+	// failure count.
+	MarkDelivered(id string, attempt *models.WebhookAttempt) error
+	// MarkFailed logs a failed attempt and schedules the next one at
+	// nextAttemptAt, or gives up on the delivery when it is nil. It reports
+	// whether this failure disabled the subscription, which happens once it
+	// has failed disableAfter times in a row.
+	MarkFailed(id string, attempt *models.WebhookAttempt, nextAttemptAt *time.Time, disableAfter int) (bool, error)
+	// ListDeliveries returns a page of a subscription's deliveries, newest
+	// first, and their total count.
+	ListDeliveries(subscriptionID string, limit, offset int) ([]*models.WebhookDelivery, int, error)
+	// GetDelivery returns a delivery with its attempt log, or nil when the
+	// subscription has no such delivery.
+	GetDelivery(subscriptionID, id string) (*models.WebhookDelivery, error)
+	// Replay makes a delivery pending again with a fresh attempt count. It
+	// reports false when the subscription has no such delivery.
+	Replay(subscriptionID, id string) (bool, error)
+	// DeleteFinishedBefore removes delivered and failed deliveries last
+	// touched before the given time.
+	DeleteFinishedBefore(before time.Time) (int64, error)
+}
+
+type webhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+const webhookSubscriptionColumns = `id, url, description, events, secret, is_active, consecutive_failures,
+	disabled_at, disabled_reason, created_at, updated_at`
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts, last_error,
+	next_attempt_at, delivered_at, created_at, updated_at`
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	sub := &models.WebhookSubscription{}
+	err := row.Scan(
+		&sub.ID, &sub.URL, &sub.Description, pq.Array(&sub.Events), &sub.Secret, &sub.IsActive,
+		&sub.ConsecutiveFailures, &sub.DisabledAt, &sub.DisabledReason, &sub.CreatedAt, &sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	var payload []byte
+	err := row.Scan(
+		&delivery.ID, &delivery.SubscriptionID, &delivery.EventID, &delivery.EventType, &payload,
+		&delivery.Status, &delivery.Attempts, &delivery.LastError, &delivery.NextAttemptAt,
+		&delivery.DeliveredAt, &delivery.CreatedAt, &delivery.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = payload
+	return delivery, nil
+}
+
+// enqueueWebhookDeliveries queues event for every active subscription that
+// asked for its type, inside tx, so webhooks fire if and only if the change
+// commits.
+func enqueueWebhookDeliveries(tx *sql.Tx, event *events.Envelope) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, created_at, next_attempt_at)
+		SELECT id, $1::uuid, $2::varchar, $3::jsonb, $4::timestamptz, $4::timestamptz FROM webhook_subscriptions
+		WHERE is_active AND $2::varchar = ANY(events)
+		ON CONFLICT (subscription_id, event_id) DO NOTHING
+	`
+	_, err = tx.Exec(query, event.EventID, event.EventType, payload, event.Timestamp)
+	return err
+}
+
+func (r *webhookRepository) CreateSubscription(sub *models.WebhookSubscription) error {
+	sub.ID = uuid.New().String()
+	sub.IsActive = true
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = sub.CreatedAt
+
+	query := `
+		INSERT INTO webhook_subscriptions (id, url, description, events, secret, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	_, err := r.db.Exec(query, sub.ID, sub.URL, sub.Description, pq.Array(sub.Events), sub.Secret,
+		sub.IsActive, sub.CreatedAt, sub.UpdatedAt)
+	return err
+}
+
+func (r *webhookRepository) GetSubscription(id string) (*models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions WHERE id = $1`
+	sub, err := scanWebhookSubscription(r.db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sub, err
+}
+
+func (r *webhookRepository) ListSubscriptions(limit, offset int) ([]*models.WebhookSubscription, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM webhook_subscriptions`).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions
+		ORDER BY created_at DESC, id
+		LIMIT $1 OFFSET $2
+	`
+	rows, err := r.db.Query(query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var subs []*models.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, total, rows.Err()
+}
+
+func (r *webhookRepository) UpdateSubscription(sub *models.WebhookSubscription) error {
+	// The CASEs read the row as it was, so they only fire on a change of
+	// the active state.
+	query := `
+		UPDATE webhook_subscriptions SET
+			url = $2, description = $3, events = $4, is_active = $5,
+			consecutive_failures = CASE WHEN $5 AND NOT is_active THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN $5 THEN NULL WHEN is_active THEN NOW() ELSE disabled_at END,
+			disabled_reason = CASE WHEN $5 THEN NULL WHEN is_active THEN 'disabled by an administrator' ELSE disabled_reason END
+		WHERE id = $1
+		RETURNING ` + webhookSubscriptionColumns
+
+	updated, err := scanWebhookSubscription(r.db.QueryRow(query, sub.ID, sub.URL, sub.Description,
+		pq.Array(sub.Events), sub.IsActive))
+	if err != nil {
+		return err
+	}
+	*sub = *updated
+	return nil
+}
+
+func (r *webhookRepository) DeleteSubscription(id string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *webhookRepository) ClaimDue(limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	// SKIP LOCKED lets several replicas dispatch concurrently without
+	// sending the same delivery twice.
+	query := `
+		UPDATE webhook_deliveries SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT d.id FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND s.is_active
+			ORDER BY d.created_at
+			LIMIT $1
+			FOR UPDATE OF d SKIP LOCKED
+		)
+		RETURNING ` + webhookDeliveryColumns
+
+	rows, err := r.db.Query(query, limit, lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var claimed []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING gives no order guarantee.
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].CreatedAt.Before(claimed[j].CreatedAt) })
+	return claimed, nil
+}
+
+func logWebhookAttempt(tx *sql.Tx, attempt *models.WebhookAttempt) error {
+	query := `
+		INSERT INTO webhook_delivery_attempts (delivery_id, status_code, error, duration_ms, attempted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+	return tx.QueryRow(query, attempt.DeliveryID, attempt.StatusCode, attempt.Error,
+		attempt.DurationMs, attempt.AttemptedAt).Scan(&attempt.ID)
+}
+
+func (r *webhookRepository) MarkDelivered(id string, attempt *models.WebhookAttempt) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	attempt.DeliveryID = id
+	if err := logWebhookAttempt(tx, attempt); err != nil {
+		return err
+	}
+
+	var subscriptionID string
+	query := `
+		UPDATE webhook_deliveries SET status = 'delivered', attempts = attempts + 1, last_error = NULL, delivered_at = NOW()
+		WHERE id = $1
+		RETURNING subscription_id
+	`
+	if err := tx.QueryRow(query, id).Scan(&subscriptionID); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(`UPDATE webhook_subscriptions SET consecutive_failures = 0 WHERE id = $1 AND consecutive_failures > 0`, subscriptionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *webhookRepository) MarkFailed(id string, attempt *models.WebhookAttempt, nextAttemptAt *time.Time, disableAfter int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	attempt.DeliveryID = id
+	if err := logWebhookAttempt(tx, attempt); err != nil {
+		return false, err
+	}
+
+	status := models.WebhookDeliveryFailed
+	if nextAttemptAt != nil {
+		status = models.WebhookDeliveryPending
+	}
+
+	var subscriptionID string
+	query := `
+		UPDATE webhook_deliveries SET status = $2, attempts = attempts + 1, last_error = $3,
+			next_attempt_at = COALESCE($4, next_attempt_at)
+		WHERE id = $1
+		RETURNING subscription_id
+	`
+	if err := tx.QueryRow(query, id, status, attempt.Error, nextAttemptAt).Scan(&subscriptionID); err != nil {
+		return false, err
+	}
+
+	var failures int
+	var active bool
+	query = `
+		UPDATE webhook_subscriptions SET consecutive_failures = consecutive_failures + 1
+		WHERE id = $1
+		RETURNING consecutive_failures, is_active
+	`
+	if err := tx.QueryRow(query, subscriptionID).Scan(&failures, &active); err != nil {
+		return false, err
+	}
+
+	disabled := active && failures >= disableAfter
+	if disabled {
+		reason := fmt.Sprintf("disabled after %d consecutive failed attempts", failures)
+		query = `UPDATE webhook_subscriptions SET is_active = false, disabled_at = NOW(), disabled_reason = $2 WHERE id = $1`
+		if _, err := tx.Exec(query, subscriptionID, reason); err != nil {
+			return false, err
+		}
+	}
+
+	return disabled, tx.Commit()
+}
+
+func (r *webhookRepository) ListDeliveries(subscriptionID string, limit, offset int) ([]*models.WebhookDelivery, int, error) {
+	var total int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM webhook_deliveries WHERE subscription_id = $1`, subscriptionID).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := `
+		SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2 OFFSET $3
+	`
+	rows, err := r.db.Query(query, subscriptionID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var deliveries []*models.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, total, rows.Err()
+}
+
+func (r *webhookRepository) GetDelivery(subscriptionID, id string) (*models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE id = $1 AND subscription_id = $2`
+	delivery, err := scanWebhookDelivery(r.db.QueryRow(query, id, subscriptionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT id, delivery_id, status_code, error, duration_ms, attempted_at
+		FROM webhook_delivery_attempts
+		WHERE delivery_id = $1
+		ORDER BY attempted_at, id
+	`
+	rows, err := r.db.Query(query, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	delivery.AttemptLog = []*models.WebhookAttempt{}
+	for rows.Next() {
+		attempt := &models.WebhookAttempt{}
+		if err := rows.Scan(&attempt.ID, &attempt.DeliveryID, &attempt.StatusCode, &attempt.Error,
+			&attempt.DurationMs, &attempt.AttemptedAt); err != nil {
+			return nil, err
+		}
+		delivery.AttemptLog = append(delivery.AttemptLog, attempt)
+	}
+	return delivery, rows.Err()
+}
+
+func (r *webhookRepository) Replay(subscriptionID, id string) (bool, error) {
+	query := `
+		UPDATE webhook_deliveries SET status = 'pending', attempts = 0, last_error = NULL, next_attempt_at = NOW()
+		WHERE id = $1 AND subscription_id = $2
+	`
+	result, err := r.db.Exec(query, id, subscriptionID)
+	if err != nil {
+		return false, err
+	}
+	rows, err := result.RowsAffected()
+	return rows > 0, err
+}
+
+func (r *webhookRepository) DeleteFinishedBefore(before time.Time) (int64, error) {
+	result, err := r.db.Exec(`DELETE FROM webhook_deliveries WHERE status <> 'pending' AND updated_at < $1`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,144 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// purgeInterval bounds how often expired entries are swept on write.
+const purgeInterval = time.Minute
+
+// cutoff revokes every token issued at or before issuedBefore until
+// expiresAt.
+type cutoff struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+// MemoryStore keeps revocations in process memory. It is used as the local
+// fallback next to Redis and on its own in tests.
+type MemoryStore struct {
+	mu        sync.Mutex
+	tokens    map[string]time.Time
+	sessions  map[string]time.Time
+	users     map[string]cutoff
+	roles     map[string]cutoff
+	nextPurge time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens:   make(map[string]time.Time),
+		sessions: make(map[string]time.Time),
+		users:    make(map[string]cutoff),
+		roles:    make(map[string]cutoff),
+	}
+}
+
+func (s *MemoryStore) Revoke(_ context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[tokenID] = expiresAt
+	s.purgeLocked(time.Now())
+	return nil
+}
+
+func (s *MemoryStore) RevokeUser(_ context.Context, userID string, issuedBefore time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	raiseLocked(s.users, userID, issuedBefore, now.Add(ttl))
+	s.purgeLocked(now)
+	return nil
+}
+
+func (s *MemoryStore) RevokeRole(_ context.Context, role string, issuedBefore time.Time, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	raiseLocked(s.roles, role, issuedBefore, now.Add(ttl))
+	s.purgeLocked(now)
+	return nil
+}
+
+// raiseLocked records a cut-off without moving an existing one backwards or
+// shortening its lifetime, like the Redis script does.
+func raiseLocked(cutoffs map[string]cutoff, key string, issuedBefore, expiresAt time.Time) {
+	if current, ok := cutoffs[key]; ok {
+		if current.issuedBefore.After(issuedBefore) {
+			issuedBefore = current.issuedBefore
+		}
+		if current.expiresAt.After(expiresAt) {
+			expiresAt = current.expiresAt
+		}
+	}
+	cutoffs[key] = cutoff{issuedBefore: issuedBefore, expiresAt: expiresAt}
+}
+
+func (s *MemoryStore) RevokeSession(_ context.Context, sessionID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.sessions[sessionID] = now.Add(ttl)
+	s.purgeLocked(now)
+	return nil
+}
+
+func (s *MemoryStore) IsRevoked(_ context.Context, token Token) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, ok := s.tokens[token.ID]; ok && now.Before(expiresAt) {
+		return true, nil
+	}
+	if token.SessionID != "" {
+		if expiresAt, ok := s.sessions[token.SessionID]; ok && now.Before(expiresAt) {
+			return true, nil
+		}
+	}
+	if s.users[token.UserID].covers(token, now) {
+		return true, nil
+	}
+	if token.Role != "" && s.roles[token.Role].covers(token, now) {
+		return true, nil
+	}
+	return false, nil
+}
+
+func (c cutoff) covers(token Token, now time.Time) bool {
+	return now.Before(c.expiresAt) && !token.IssuedAt.After(c.issuedBefore)
+}
+
+func (s *MemoryStore) purgeLocked(now time.Time) {
+	if now.Before(s.nextPurge) {
+		return
+	}
+	s.nextPurge = now.Add(purgeInterval)
+
+	for id, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, id)
+		}
+	}
+	for id, expiresAt := range s.sessions {
+		if !now.Before(expiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+	for id, cutoff := range s.users {
+		if !now.Before(cutoff.expiresAt) {
+			delete(s.users, id)
+		}
+	}
+	for role, cutoff := range s.roles {
+		if !now.Before(cutoff.expiresAt) {
+			delete(s.roles, role)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package revocation
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	tokenKeyPrefix   = "auth:revoked:token:"
+	sessionKeyPrefix = "auth:revoked:session:"
+	userKeyPrefix    = "auth:revoked:user:"
+	roleKeyPrefix    = "auth:revoked:role:"
+)
+
+// raiseCutoff stores a user's or role's cut-off, in Unix microseconds, unless a later one is already set, so
+// concurrent revocations can't move it backwards. The key lives as long as
+// the longest TTL it was given.
+var raiseCutoff = redis.NewScript(`
+local cutoff = tonumber(ARGV[1])
+local current = tonumber(redis.call('GET', KEYS[1]))
+if current and current > cutoff then
+	cutoff = current
+end
+local ttl = tonumber(ARGV[2])
+local remaining = redis.call('PTTL', KEYS[1])
+if remaining > ttl then
+	ttl = remaining
+end
+redis.call('SET', KEYS[1], cutoff, 'PX', ttl)
+return cutoff
+`)
+
+// RedisStore shares revocations between replicas. Keys expire together with
+// the tokens they cover, so the set never grows beyond live tokens.
+type RedisStore struct {
+	client redis.UniversalClient
+}
+
+func NewRedisStore(client redis.UniversalClient) *RedisStore {
+	return &RedisStore{client: client}
+}
+
+func (s *RedisStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	return s.client.Set(ctx, tokenKeyPrefix+tokenID, 1, ttl).Err()
+}
+
+func (s *RedisStore) RevokeUser(ctx context.Context, userID string, issuedBefore time.Time, ttl time.Duration) error {
+	return raiseCutoff.Run(ctx, s.client, []string{userKeyPrefix + userID}, issuedBefore.UnixMicro(), ttl.Milliseconds()).Err()
+}
+
+func (s *RedisStore) RevokeRole(ctx context.Context, role string, issuedBefore time.Time, ttl time.Duration) error {
+	return raiseCutoff.Run(ctx, s.client, []string{roleKeyPrefix + role}, issuedBefore.UnixMicro(), ttl.Milliseconds()).Err()
+}
+
+func (s *RedisStore) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	return s.client.Set(ctx, sessionKeyPrefix+sessionID, 1, ttl).Err()
+}
+
+func (s *RedisStore) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	keys := []string{tokenKeyPrefix + token.ID}
+	if token.SessionID != "" {
+		keys = append(keys, sessionKeyPrefix+token.SessionID)
+	}
+
+	pipe := s.client.Pipeline()
+	exists := pipe.Exists(ctx, keys...)
+	cutoffs := []*redis.StringCmd{pipe.Get(ctx, userKeyPrefix+token.UserID)}
+	if token.Role != "" {
+		cutoffs = append(cutoffs, pipe.Get(ctx, roleKeyPrefix+token.Role))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return false, err
+	}
+
+	if exists.Val() > 0 {
+		return true, nil
+	}
+
+	for _, cutoff := range cutoffs {
+		raw, err := cutoff.Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		issuedBefore, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return false, err
+		}
+		if token.IssuedAt.UnixMicro() <= issuedBefore {
+			return true, nil
+		}
+	}
+	return false, nil
+}
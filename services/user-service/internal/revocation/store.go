@@ -0,0 +1,106 @@
+// Package revocation tracks access tokens that must stop working before they
+// expire, either individually (by jti), per session (remote sign-out), for
+// every token a user was issued before a cut-off (logout everywhere, account
+// deletion) or for every token issued to a role before a cut-off (permissions
+// taken away from the role).
+package revocation
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Token identifies an access token being checked.
+type Token struct {
+	ID        string
+	UserID    string
+	SessionID string
+	Role      string
+	IssuedAt  time.Time
+}
+
+// Store records revoked access tokens. Entries only need to live as long as
+// the tokens they cover, so every write carries an expiry.
+type Store interface {
+	// Revoke invalidates a single token until it would have expired anyway.
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	// RevokeUser invalidates every token of the user issued at or before
+	// issuedBefore. ttl should be at least the access token lifetime.
+	RevokeUser(ctx context.Context, userID string, issuedBefore time.Time, ttl time.Duration) error
+	// RevokeRole invalidates every token carrying the role issued at or
+	// before issuedBefore. ttl should be at least the access token lifetime.
+	RevokeRole(ctx context.Context, role string, issuedBefore time.Time, ttl time.Duration) error
+	// RevokeSession invalidates every token issued for the session. ttl
+	// should be at least the access token lifetime.
+	RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error
+	// IsRevoked reports whether a token is covered by any kind of entry.
+	IsRevoked(ctx context.Context, token Token) (bool, error)
+}
+
+// FallbackStore writes to both a shared primary store (Redis) and a local
+// one, and answers from the local store when the primary is unreachable.
+// Revocations made on this replica therefore keep working during an outage.
+type FallbackStore struct {
+	primary Store
+	local   Store
+}
+
+func NewFallbackStore(primary, local Store) *FallbackStore {
+	return &FallbackStore{primary: primary, local: local}
+}
+
+func (s *FallbackStore) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if err := s.local.Revoke(ctx, tokenID, expiresAt); err != nil {
+		return err
+	}
+	if err := s.primary.Revoke(ctx, tokenID, expiresAt); err != nil {
+		logrus.WithError(err).Warn("Token revocation not shared: primary store unavailable")
+	}
+	return nil
+}
+
+func (s *FallbackStore) RevokeUser(ctx context.Context, userID string, issuedBefore time.Time, ttl time.Duration) error {
+	if err := s.local.RevokeUser(ctx, userID, issuedBefore, ttl); err != nil {
+		return err
+	}
+	if err := s.primary.RevokeUser(ctx, userID, issuedBefore, ttl); err != nil {
+		logrus.WithError(err).Warn("User token revocation not shared: primary store unavailable")
+	}
+	return nil
+}
+
+func (s *FallbackStore) RevokeRole(ctx context.Context, role string, issuedBefore time.Time, ttl time.Duration) error {
+	if err := s.local.RevokeRole(ctx, role, issuedBefore, ttl); err != nil {
+		return err
+	}
+	if err := s.primary.RevokeRole(ctx, role, issuedBefore, ttl); err != nil {
+		logrus.WithError(err).Warn("Role token revocation not shared: primary store unavailable")
+	}
+	return nil
+}
+
+func (s *FallbackStore) RevokeSession(ctx context.Context, sessionID string, ttl time.Duration) error {
+	if err := s.local.RevokeSession(ctx, sessionID, ttl); err != nil {
+		return err
+	}
+	if err := s.primary.RevokeSession(ctx, sessionID, ttl); err != nil {
+		logrus.WithError(err).Warn("Session revocation not shared: primary store unavailable")
+	}
+	return nil
+}
+
+func (s *FallbackStore) IsRevoked(ctx context.Context, token Token) (bool, error) {
+	revoked, err := s.local.IsRevoked(ctx, token)
+	if err != nil || revoked {
+		return revoked, err
+	}
+
+	revoked, err = s.primary.IsRevoked(ctx, token)
+	if err != nil {
+		logrus.WithError(err).Warn("Revocation check fell back to local store")
+		return false, nil
+	}
+	return revoked, nil
+}
@@ -3,18 +3,37 @@ package routes
 import (
 	"user-service/internal/handlers"
 	"user-service/internal/middleware"
+	"user-service/internal/models"
 
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, userHandler *handlers.UserHandler, authMiddleware *middleware.AuthMiddleware) {
+// Handlers groups the HTTP handlers mounted by SetupRoutes.
+type Handlers struct {
+	Health        *handlers.HealthHandler
+	User          *handlers.UserHandler
+	Verification  *handlers.VerificationHandler
+	PasswordReset *handlers.PasswordResetHandler
+	Password      *handlers.PasswordHandler
+	MFA           *handlers.MFAHandler
+	Keys          *handlers.KeysHandler
+	Sessions      *handlers.SessionHandler
+	Admin         *handlers.AdminHandler
+	Introspection *handlers.IntrospectionHandler
+	Outbox        *handlers.OutboxHandler
+	Webhooks      *handlers.WebhookHandler
+	Roles         *handlers.RoleHandler
+}
+
+func SetupRoutes(router *gin.Engine, h Handlers, authMiddleware *middleware.AuthMiddleware, serviceKeys middleware.ServiceKeys) {
 	// Health check
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "ok",
-			"service": "user-service",
-		})
-	})
+	router.GET("/health", h.Health.Health)
+	router.GET("/health/live", h.Health.Live)
+	router.GET("/health/ready", h.Health.Ready)
+	router.GET("/health/details", middleware.RequireServiceKey(serviceKeys), h.Health.Details)
+
+	// Public keys for verifying access tokens
+	router.GET("/.well-known/jwks.json", h.Keys.JWKS)
 
 	// API v1 group
 	v1 := router.Group("/api/v1")
@@ -22,24 +41,92 @@ func SetupRoutes(router *gin.Engine, userHandler *handlers.UserHandler, authMidd
 		// Public routes
 		auth := v1.Group("/auth")
 		{
-			auth.POST("/register", userHandler.Register)
-			auth.POST("/login", userHandler.Login)
-			auth.POST("/refresh", userHandler.RefreshToken)
+			auth.POST("/register", h.User.Register)
+			auth.POST("/login", h.User.Login)
+			auth.POST("/refresh", h.User.RefreshToken)
+			auth.POST("/verify-email", h.Verification.VerifyEmail)
+			auth.POST("/resend-verification", h.Verification.ResendVerification)
+			auth.POST("/forgot-password", h.PasswordReset.ForgotPassword)
+			auth.POST("/reset-password", h.PasswordReset.ResetPassword)
+			auth.POST("/mfa/verify", h.User.VerifyMFA)
+			auth.POST("/mfa/enroll", h.MFA.EnrollWithChallenge)
+			auth.POST("/logout", authMiddleware.RequireAuth(), h.User.Logout)
+			auth.POST("/logout-all", authMiddleware.RequireAuth(), h.User.LogoutAll)
+			auth.POST("/introspect", middleware.RequireServiceKey(serviceKeys), h.Introspection.Introspect)
 		}
 
-		// Public user routes (for internal service communication)
+		// User lookup for staff; other services use the gRPC API
 		users := v1.Group("/users")
+		users.Use(authMiddleware.RequireAuth(), authMiddleware.RequirePermission(models.PermissionUsersRead))
 		{
-			users.GET("/:id", userHandler.GetUserByID)
+			users.GET("/:id", h.User.GetUserByID)
 		}
 
 		// Protected routes
 		protected := v1.Group("/user")
 		protected.Use(authMiddleware.RequireAuth())
 		{
-			protected.GET("/profile", userHandler.GetProfile)
-			protected.PUT("/profile", userHandler.UpdateProfile)
-			protected.DELETE("/account", userHandler.DeleteAccount)
+			canRead := authMiddleware.RequirePermission(models.PermissionProfileRead)
+			canWrite := authMiddleware.RequirePermission(models.PermissionProfileWrite)
+
+			protected.GET("/profile", canRead, h.User.GetProfile)
+			protected.PUT("/profile", canWrite, h.User.UpdateProfile)
+			protected.PATCH("/profile", canWrite, h.User.PatchProfile)
+			protected.PUT("/password", canWrite, h.Password.ChangePassword)
+			protected.DELETE("/account", canWrite, h.User.DeleteAccount)
+			protected.POST("/mfa/enroll", canWrite, h.MFA.Enroll)
+			protected.POST("/mfa/confirm", canWrite, h.MFA.Confirm)
+			protected.POST("/mfa/disable", canWrite, h.MFA.Disable)
+			protected.POST("/mfa/recovery-codes", canWrite, h.MFA.RegenerateRecoveryCodes)
+			protected.GET("/sessions", canRead, h.Sessions.ListSessions)
+			protected.DELETE("/sessions/:id", canWrite, h.Sessions.RevokeSession)
+		}
+
+		// Admin routes, each gated by the permission it needs
+		admin := v1.Group("/admin")
+		admin.Use(authMiddleware.RequireAuth())
+		{
+			adminUsers := admin.Group("/users")
+			{
+				canRead := authMiddleware.RequirePermission(models.PermissionUsersRead)
+				canWrite := authMiddleware.RequirePermission(models.PermissionUsersWrite)
+
+				adminUsers.GET("", canRead, h.Admin.ListUsers)
+				adminUsers.GET("/:id", canRead, h.Admin.GetUser)
+				adminUsers.POST("/:id/unlock", canWrite, h.Admin.UnlockLogin)
+				adminUsers.PUT("/:id/role", authMiddleware.RequirePermission(models.PermissionRolesManage), h.Roles.AssignRole)
+			}
+
+			roles := admin.Group("/roles")
+			roles.Use(authMiddleware.RequirePermission(models.PermissionRolesManage))
+			{
+				roles.GET("", h.Roles.ListRoles)
+				roles.POST("", h.Roles.CreateRole)
+				roles.GET("/:name", h.Roles.GetRole)
+				roles.PATCH("/:name", h.Roles.UpdateRole)
+				roles.DELETE("/:name", h.Roles.DeleteRole)
+			}
+			admin.GET("/permissions", authMiddleware.RequirePermission(models.PermissionRolesManage), h.Roles.ListPermissions)
+
+			outbox := admin.Group("/outbox")
+			outbox.Use(authMiddleware.RequirePermission(models.PermissionEventsManage))
+			{
+				outbox.GET("/dead-letters", h.Outbox.ListDeadLetters)
+				outbox.POST("/dead-letters/:id/requeue", h.Outbox.RequeueDeadLetter)
+			}
+
+			webhooks := admin.Group("/webhooks")
+			webhooks.Use(authMiddleware.RequirePermission(models.PermissionWebhooksManage))
+			{
+				webhooks.POST("", h.Webhooks.CreateSubscription)
+				webhooks.GET("", h.Webhooks.ListSubscriptions)
+				webhooks.GET("/:id", h.Webhooks.GetSubscription)
+				webhooks.PATCH("/:id", h.Webhooks.UpdateSubscription)
+				webhooks.DELETE("/:id", h.Webhooks.DeleteSubscription)
+				webhooks.GET("/:id/deliveries", h.Webhooks.ListDeliveries)
+				webhooks.GET("/:id/deliveries/:deliveryId", h.Webhooks.GetDelivery)
+				webhooks.POST("/:id/deliveries/:deliveryId/replay", h.Webhooks.ReplayDelivery)
+			}
 		}
 	}
 }
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"user-service/internal/events"
+	"user-service/internal/metrics"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// CustomerStatsEvents are the event types CustomerStatsService consumes.
+var CustomerStatsEvents = []string{
+	events.OrderPlaced,
+	events.OrderDelivered,
+	events.OrderCancelled,
+	events.PaymentRefunded,
+}
+
+// CustomerStatsService keeps per-user order statistics from the order and
+// payment services' events.
+type CustomerStatsService interface {
+	// HandleEvent applies one event. Redelivered events are ignored, and
+	// events that can never apply (bad payload, unknown type) are logged and
+	// dropped; only failures worth retrying are returned.
+	HandleEvent(ctx context.Context, event *events.Envelope) error
+	GetStats(userID string) (*models.CustomerStats, error)
+	// PurgeProcessedEvents forgets processed event IDs older than the dedupe
+	// retention, hourly, until ctx is cancelled.
+	PurgeProcessedEvents(ctx context.Context)
+}
+
+type customerStatsService struct {
+	statsRepo       repository.CustomerStatsRepository
+	dedupeRetention time.Duration
+}
+
+// NewCustomerStatsService remembers processed event IDs for dedupeRetention,
+// which must exceed how long a redelivery can take.
+func NewCustomerStatsService(statsRepo repository.CustomerStatsRepository, dedupeRetention time.Duration) CustomerStatsService {
+	return &customerStatsService{statsRepo: statsRepo, dedupeRetention: dedupeRetention}
+}
+
+func (s *customerStatsService) HandleEvent(_ context.Context, event *events.Envelope) error {
+	applied, err := s.apply(event)
+
+	result := metrics.ConsumerProcessed
+	switch {
+	case err != nil && isPermanent(err):
+		logrus.WithError(err).WithFields(logrus.Fields{"event_id": event.EventID, "event_type": event.EventType}).
+			Warn("Skipping event that cannot be applied")
+		result, err = metrics.ConsumerSkipped, nil
+	case err != nil:
+		result = metrics.ConsumerFailed
+	case !applied:
+		result = metrics.ConsumerDuplicate
+	}
+	metrics.ConsumedEvents.WithLabelValues(event.EventType, result).Inc()
+
+	return err
+}
+
+// permanentError marks an event that retrying can't fix.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func isPermanent(err error) bool {
+	_, ok := err.(*permanentError)
+	return ok
+}
+
+func invalidEvent(format string, args ...interface{}) error {
+	return &permanentError{err: fmt.Errorf(format, args...)}
+}
+
+func (s *customerStatsService) apply(event *events.Envelope) (bool, error) {
+	switch event.EventType {
+	case events.OrderPlaced, events.OrderDelivered, events.OrderCancelled:
+		var order events.OrderData
+		if err := json.Unmarshal(event.Data, &order); err != nil {
+			return false, invalidEvent("decode order: %v", err)
+		}
+		if order.OrderID == "" {
+			return false, invalidEvent("orderId is required")
+		}
+
+		switch event.EventType {
+		case events.OrderDelivered:
+			return s.statsRepo.RecordOrderDelivered(event.EventID, order.OrderID)
+		case events.OrderCancelled:
+			return s.statsRepo.RecordOrderCancelled(event.EventID, order.OrderID)
+		}
+
+		if _, err := uuid.Parse(order.UserID); err != nil {
+			return false, invalidEvent("userId %q is not a user ID", order.UserID)
+		}
+		total, err := events.MinorUnits(order.TotalAmount, order.Currency)
+		if err != nil {
+			return false, invalidEvent("order total: %v", err)
+		}
+		return s.statsRepo.RecordOrderPlaced(event.EventID, &models.CustomerOrder{
+			OrderID:     order.OrderID,
+			UserID:      order.UserID,
+			TotalAmount: total,
+			Currency:    order.Currency,
+			PlacedAt:    event.Timestamp,
+		})
+
+	case events.PaymentRefunded:
+		var payment events.PaymentData
+		if err := json.Unmarshal(event.Data, &payment); err != nil {
+			return false, invalidEvent("decode payment: %v", err)
+		}
+		if payment.OrderID == "" {
+			return false, invalidEvent("orderId is required")
+		}
+		amount, err := events.MinorUnits(payment.Amount, payment.Currency)
+		if err != nil {
+			return false, invalidEvent("refund amount: %v", err)
+		}
+		return s.statsRepo.RecordRefund(event.EventID, payment.OrderID, payment.Currency, amount)
+
+	default:
+		return false, invalidEvent("unexpected event type %q", event.EventType)
+	}
+}
+
+func (s *customerStatsService) GetStats(userID string) (*models.CustomerStats, error) {
+	return s.statsRepo.GetByUserID(userID)
+}
+
+func (s *customerStatsService) PurgeProcessedEvents(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.statsRepo.DeleteProcessedBefore(time.Now().Add(-s.dedupeRetention)); err != nil {
+				logrus.WithError(err).Warn("Failed to delete old processed event IDs")
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"user-service/internal/mailer"
+	"user-service/internal/models"
+)
+
+func actionLink(baseURL, path, token string) string {
+	return strings.TrimRight(baseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+func verificationEmail(user *models.User, link string, ttl time.Duration) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Confirm your email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\n"+
+				"The link expires in %s. If you did not create an account, you can ignore this email.\n",
+			user.Username, link, ttl),
+	}
+}
+
+func passwordResetEmail(user *models.User, link string, ttl time.Duration) mailer.Message {
+	return mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one:\n\n%s\n\n"+
+				"The link expires in %s and can only be used once. If you did not request a reset, you can ignore this email.\n",
+			user.Username, link, ttl),
+	}
+}
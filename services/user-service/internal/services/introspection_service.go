@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"sync"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/tokens"
+)
+
+// maxIntrospectionCacheEntries bounds the cache so a flood of distinct
+// tokens can't grow it without limit.
+const maxIntrospectionCacheEntries = 10000
+
+// TokenValidator verifies access tokens; *tokens.Validator implements it.
+type TokenValidator interface {
+	Validate(ctx context.Context, token string) (*tokens.Claims, error)
+}
+
+// IntrospectionService answers RFC 7662 token introspection for other
+// services: a token is active only if it verifies, is not revoked, and its
+// account still exists and is active.
+type IntrospectionService interface {
+	Introspect(ctx context.Context, token string) (*models.IntrospectionResponse, error)
+}
+
+type introspectionService struct {
+	tokens   TokenValidator
+	userRepo repository.UserRepository
+	ttl      time.Duration
+
+	mu    sync.Mutex
+	cache map[[sha256.Size]byte]introspectionEntry
+}
+
+type introspectionEntry struct {
+	response  models.IntrospectionResponse
+	expiresAt time.Time
+}
+
+// NewIntrospectionService caches each answer for ttl (never past the token's
+// own expiry); a zero ttl disables the cache.
+func NewIntrospectionService(tokens TokenValidator, userRepo repository.UserRepository, ttl time.Duration) IntrospectionService {
+	return &introspectionService{
+		tokens:   tokens,
+		userRepo: userRepo,
+		ttl:      ttl,
+		cache:    make(map[[sha256.Size]byte]introspectionEntry),
+	}
+}
+
+func (s *introspectionService) Introspect(ctx context.Context, token string) (*models.IntrospectionResponse, error) {
+	// Keyed by hash so the cache never holds usable tokens.
+	key := sha256.Sum256([]byte(token))
+	now := time.Now()
+
+	if response, ok := s.cached(key, now); ok {
+		return response, nil
+	}
+
+	response, err := s.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := now.Add(s.ttl)
+	if response.Active && time.Unix(response.Exp, 0).Before(expiresAt) {
+		expiresAt = time.Unix(response.Exp, 0)
+	}
+	s.store(key, *response, expiresAt, now)
+
+	return response, nil
+}
+
+func (s *introspectionService) introspect(ctx context.Context, token string) (*models.IntrospectionResponse, error) {
+	claims, err := s.tokens.Validate(ctx, token)
+	if err != nil {
+		var tokenErr *tokens.Error
+		if errors.As(err, &tokenErr) {
+			return &models.IntrospectionResponse{Active: false}, nil
+		}
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(claims.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return &models.IntrospectionResponse{Active: false}, nil
+	}
+
+	status := "active"
+	if !user.IsVerified {
+		status = "unverified"
+	}
+
+	return &models.IntrospectionResponse{
+		Active:        true,
+		Scope:         claims.Scope,
+		TokenType:     "access_token",
+		Sub:           user.ID,
+		Username:      user.Username,
+		Email:         user.Email,
+		Role:          claims.Role,
+		SessionID:     claims.SessionID,
+		Jti:           claims.TokenID,
+		Exp:           claims.ExpiresAt.Unix(),
+		Iat:           claims.IssuedAt.Unix(),
+		AccountStatus: status,
+	}, nil
+}
+
+func (s *introspectionService) cached(key [sha256.Size]byte, now time.Time) (*models.IntrospectionResponse, bool) {
+	if s.ttl <= 0 {
+		return nil, false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		return nil, false
+	}
+	response := entry.response
+	return &response, true
+}
+
+func (s *introspectionService) store(key [sha256.Size]byte, response models.IntrospectionResponse, expiresAt, now time.Time) {
+	if s.ttl <= 0 || !now.Before(expiresAt) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.cache) >= maxIntrospectionCacheEntries {
+		for k, entry := range s.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(s.cache, k)
+			}
+		}
+		// Still full of live entries: start over rather than evict one by one.
+		if len(s.cache) >= maxIntrospectionCacheEntries {
+			s.cache = make(map[[sha256.Size]byte]introspectionEntry)
+		}
+	}
+	s.cache[key] = introspectionEntry{response: response, expiresAt: expiresAt}
+}
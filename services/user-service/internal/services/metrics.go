@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+
+	"user-service/internal/lockout"
+	"user-service/internal/metrics"
+	"user-service/internal/models"
+)
+
+type instrumentedUserService struct {
+	UserService
+}
+
+// NewInstrumentedUserService counts the logins, registrations and token
+// refreshes going through next. A login interrupted by an MFA challenge is
+// counted as mfa_required, and again as success or failure when the second
+// factor is checked.
+func NewInstrumentedUserService(next UserService) UserService {
+	return &instrumentedUserService{UserService: next}
+}
+
+func (s *instrumentedUserService) Register(req *models.CreateUserRequest) (*models.User, error) {
+	user, err := s.UserService.Register(req)
+	result := metrics.ResultSuccess
+	if err != nil {
+		result = metrics.ResultFailure
+	}
+	metrics.Registrations.WithLabelValues(result).Inc()
+	return user, err
+}
+
+func (s *instrumentedUserService) Login(req *models.LoginRequest, client models.ClientInfo) (*models.LoginResponse, *models.MFAChallenge, error) {
+	response, challenge, err := s.UserService.Login(req, client)
+	switch {
+	case err != nil:
+		metrics.Logins.WithLabelValues(metrics.ResultFailure, loginFailureReason(err)).Inc()
+	case challenge != nil:
+		metrics.Logins.WithLabelValues(metrics.ResultMFARequired, "").Inc()
+	default:
+		metrics.Logins.WithLabelValues(metrics.ResultSuccess, "").Inc()
+	}
+	return response, challenge, err
+}
+
+func (s *instrumentedUserService) VerifyMFA(req *models.MFAVerifyRequest, client models.ClientInfo) (*models.LoginResponse, error) {
+	response, err := s.UserService.VerifyMFA(req, client)
+	if err != nil {
+		metrics.Logins.WithLabelValues(metrics.ResultFailure, "mfa_failed").Inc()
+	} else {
+		metrics.Logins.WithLabelValues(metrics.ResultSuccess, "").Inc()
+	}
+	return response, err
+}
+
+func (s *instrumentedUserService) RefreshToken(refreshToken string, client models.ClientInfo) (*models.LoginResponse, error) {
+	response, err := s.UserService.RefreshToken(refreshToken, client)
+	switch {
+	case err == nil:
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultSuccess, "").Inc()
+	case errors.Is(err, ErrInvalidRefreshToken):
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultFailure, "invalid").Inc()
+	case errors.Is(err, ErrRefreshTokenReused):
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultFailure, "reused").Inc()
+	default:
+		metrics.TokenRefreshes.WithLabelValues(metrics.ResultFailure, metrics.ReasonError).Inc()
+	}
+	return response, err
+}
+
+func loginFailureReason(err error) string {
+	var locked *lockout.LockedError
+	switch {
+	case errors.As(err, &locked):
+		return "locked"
+	case errors.Is(err, ErrInvalidCredentials):
+		return "invalid_credentials"
+	case errors.Is(err, ErrAccountDeactivated):
+		return "deactivated"
+	case errors.Is(err, ErrEmailNotVerified):
+		return "email_not_verified"
+	default:
+		return metrics.ReasonError
+	}
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/secretbox"
+	"user-service/internal/totp"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidMFAChallenge = errors.New("invalid or expired MFA challenge")
+	ErrInvalidMFACode      = errors.New("invalid authentication code")
+	ErrMFANotEnrolled      = errors.New("multi-factor authentication is not enrolled")
+	ErrMFAAlreadyEnabled   = errors.New("multi-factor authentication is already enabled")
+	ErrMFARequiredByPolicy = errors.New("multi-factor authentication is mandatory for this role")
+)
+
+const mfaChallengeType = "mfa_challenge"
+
+type MFAService interface {
+	RequiredForRole(role string) bool
+	Challenge(user *models.User) (*models.MFAChallenge, error)
+	Enroll(user *models.User) (*models.MFAEnrollment, error)
+	EnrollWithChallenge(challengeToken string) (*models.MFAEnrollment, error)
+	Confirm(userID, code string) ([]string, error)
+	VerifyChallenge(challengeToken, code string) (string, []string, error)
+	Disable(userID, role, code string) error
+	RegenerateRecoveryCodes(userID, code string) ([]string, error)
+	// EncryptStoredSecrets encrypts secrets stored before encryption at rest
+	// was introduced and returns how many it encrypted.
+	EncryptStoredSecrets() (int, error)
+}
+
+type mfaService struct {
+	userRepo      repository.UserRepository
+	mfaRepo       repository.MFARepository
+	issuer        string
+	requiredRoles map[string]bool
+	challengeTTL  time.Duration
+	recoveryCodes int
+	secret        []byte
+	secrets       *secretbox.Box
+}
+
+func NewMFAService(userRepo repository.UserRepository, mfaRepo repository.MFARepository, cfg config.MFAConfig, authCfg config.AuthConfig) MFAService {
+	required := make(map[string]bool, len(cfg.RequiredRoles))
+	for _, role := range cfg.RequiredRoles {
+		required[role] = true
+	}
+
+	return &mfaService{
+		userRepo:      userRepo,
+		mfaRepo:       mfaRepo,
+		issuer:        cfg.Issuer,
+		requiredRoles: required,
+		challengeTTL:  time.Duration(cfg.ChallengeTTL) * time.Minute,
+		recoveryCodes: cfg.RecoveryCodeCount,
+		secret:        []byte(authCfg.TokenSigningSecret),
+		secrets:       secretbox.New(cfg.EncryptionKey),
+	}
+}
+
+func (s *mfaService) RequiredForRole(role string) bool {
+	return s.requiredRoles[role]
+}
+
+// Challenge returns nil when the user can be signed in with a password alone.
+// Users whose role mandates MFA but who have not enrolled yet get a challenge
+// that only allows enrollment.
+func (s *mfaService) Challenge(user *models.User) (*models.MFAChallenge, error) {
+	mfa, err := s.mfaRepo.GetByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	enrolled := mfa.Enabled()
+	if !enrolled && !s.RequiredForRole(user.Role) {
+		return nil, nil
+	}
+
+	token, err := s.newChallengeToken(user.ID, !enrolled)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MFAChallenge{
+		MFARequired:        true,
+		EnrollmentRequired: !enrolled,
+		ChallengeToken:     token,
+		ExpiresIn:          int(s.challengeTTL.Seconds()),
+	}, nil
+}
+
+func (s *mfaService) Enroll(user *models.User) (*models.MFAEnrollment, error) {
+	mfa, err := s.mfaRepo.GetByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := s.secrets.Seal(secret, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mfaRepo.SavePending(user.ID, sealed); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollment{
+		Secret:     secret,
+		OTPAuthURL: totp.KeyURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *mfaService) EnrollWithChallenge(challengeToken string) (*models.MFAEnrollment, error) {
+	challenge, err := s.parseChallengeToken(challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	if !challenge.enroll {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	user, err := s.userRepo.GetByID(challenge.userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	return s.Enroll(user)
+}
+
+// Confirm activates a pending enrollment and returns the plaintext recovery
+// codes. They are shown once; only their hashes are stored.
+func (s *mfaService) Confirm(userID, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa == nil {
+		return nil, ErrMFANotEnrolled
+	}
+	if mfa.Enabled() {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	if err := s.checkTOTP(mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.Enable(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// VerifyChallenge completes the second login step and returns the user ID.
+// When the challenge was an enrollment challenge the factor is activated and
+// the new recovery codes are returned as well.
+//
+// A challenge allows a single attempt: it is used up even when the code is
+// wrong, so every further guess needs the password again. On ErrInvalidMFACode
+// the user ID is returned too, letting the caller count the failure.
+func (s *mfaService) VerifyChallenge(challengeToken, code string) (string, []string, error) {
+	challenge, err := s.parseChallengeToken(challengeToken)
+	if err != nil {
+		return "", nil, err
+	}
+
+	fresh, err := s.mfaRepo.ConsumeChallenge(challenge.id, challenge.userID, challenge.expiresAt)
+	if err != nil {
+		return "", nil, err
+	}
+	if !fresh {
+		return "", nil, ErrInvalidMFAChallenge
+	}
+
+	mfa, err := s.mfaRepo.GetByUserID(challenge.userID)
+	if err != nil {
+		return "", nil, err
+	}
+	if mfa == nil {
+		return "", nil, ErrMFANotEnrolled
+	}
+
+	if !mfa.Enabled() {
+		if !challenge.enroll {
+			return "", nil, ErrInvalidMFAChallenge
+		}
+		codes, err := s.Confirm(challenge.userID, code)
+		if err != nil {
+			return challengeFailure(challenge.userID, err)
+		}
+		return challenge.userID, codes, nil
+	}
+
+	if err := s.checkCode(mfa, code); err != nil {
+		return challengeFailure(challenge.userID, err)
+	}
+
+	return challenge.userID, nil, nil
+}
+
+func challengeFailure(userID string, err error) (string, []string, error) {
+	if errors.Is(err, ErrInvalidMFACode) {
+		return userID, nil, err
+	}
+	return "", nil, err
+}
+
+func (s *mfaService) Disable(userID, role, code string) error {
+	if s.RequiredForRole(role) {
+		return ErrMFARequiredByPolicy
+	}
+
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if !mfa.Enabled() {
+		return ErrMFANotEnrolled
+	}
+
+	if err := s.checkCode(mfa, code); err != nil {
+		return err
+	}
+
+	return s.mfaRepo.Delete(userID)
+}
+
+// openSecret decrypts the stored TOTP secret. Secrets from before encryption
+// at rest are read as they are until EncryptStoredSecrets has run.
+func (s *mfaService) openSecret(mfa *models.UserMFA) (string, error) {
+	if !secretbox.Sealed(mfa.Secret) {
+		return mfa.Secret, nil
+	}
+	return s.secrets.Open(mfa.Secret, mfa.UserID)
+}
+
+func (s *mfaService) EncryptStoredSecrets() (int, error) {
+	factors, err := s.mfaRepo.ListSecrets()
+	if err != nil {
+		return 0, err
+	}
+
+	encrypted := 0
+	for _, mfa := range factors {
+		if secretbox.Sealed(mfa.Secret) {
+			continue
+		}
+		sealed, err := s.secrets.Seal(mfa.Secret, mfa.UserID)
+		if err != nil {
+			return encrypted, err
+		}
+		if err := s.mfaRepo.ReplaceSecret(mfa.UserID, mfa.Secret, sealed); err != nil {
+			return encrypted, err
+		}
+		encrypted++
+	}
+	return encrypted, nil
+}
+
+func (s *mfaService) RegenerateRecoveryCodes(userID, code string) ([]string, error) {
+	mfa, err := s.mfaRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !mfa.Enabled() {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if err := s.checkTOTP(mfa, code); err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := s.generateRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mfaRepo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// checkCode accepts either a TOTP code or an unused recovery code.
+func (s *mfaService) checkCode(mfa *models.UserMFA, code string) error {
+	err := s.checkTOTP(mfa, code)
+	if err != ErrInvalidMFACode {
+		return err
+	}
+
+	consumed, err := s.mfaRepo.ConsumeRecoveryCode(mfa.UserID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+	if !consumed {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *mfaService) checkTOTP(mfa *models.UserMFA, code string) error {
+	secret, err := s.openSecret(mfa)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	fresh, err := s.mfaRepo.MarkStepUsed(mfa.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+func (s *mfaService) newChallengeToken(userID string, enroll bool) (string, error) {
+	claims := jwt.MapClaims{
+		"sub":    userID,
+		"type":   mfaChallengeType,
+		"enroll": enroll,
+		"exp":    time.Now().Add(s.challengeTTL).Unix(),
+		"iat":    time.Now().Unix(),
+		"jti":    uuid.New().String(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(s.secret)
+}
+
+// mfaChallenge is a verified challenge token.
+type mfaChallenge struct {
+	id        string
+	userID    string
+	enroll    bool
+	expiresAt time.Time
+}
+
+func (s *mfaService) parseChallengeToken(challengeToken string) (*mfaChallenge, error) {
+	token, err := jwt.Parse(challengeToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return s.secret, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || claims["type"] != mfaChallengeType {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	challenge := &mfaChallenge{}
+	challenge.id, _ = claims["jti"].(string)
+	challenge.userID, _ = claims["sub"].(string)
+	challenge.enroll, _ = claims["enroll"].(bool)
+	if challenge.userID == "" {
+		return nil, ErrInvalidMFAChallenge
+	}
+	if _, err := uuid.Parse(challenge.id); err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	challenge.expiresAt = expiresAt.Time
+
+	return challenge, nil
+}
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// generateRecoveryCodes returns codes formatted as "xxxxx-xxxxx" together
+// with the hashes to persist.
+func (s *mfaService) generateRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, s.recoveryCodes)
+	hashes := make([]string, 0, s.recoveryCodes)
+
+	for i := 0; i < s.recoveryCodes; i++ {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, nil, err
+		}
+
+		encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))[:10]
+		code := encoded[:5] + "-" + encoded[5:]
+
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(normalized)
+}
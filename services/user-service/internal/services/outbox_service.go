@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter not found")
+
+// OutboxService lets administrators inspect events the relay gave up on and
+// send them again.
+type OutboxService interface {
+	ListDeadLetters(page models.PageQuery) ([]*models.OutboxEvent, models.Pagination, error)
+	RequeueDeadLetter(id string) error
+}
+
+type outboxService struct {
+	outboxRepo repository.OutboxRepository
+}
+
+func NewOutboxService(outboxRepo repository.OutboxRepository) OutboxService {
+	return &outboxService{outboxRepo: outboxRepo}
+}
+
+func (s *outboxService) ListDeadLetters(page models.PageQuery) ([]*models.OutboxEvent, models.Pagination, error) {
+	page.Normalize()
+	dead, total, err := s.outboxRepo.ListDead(page.Limit, page.Offset())
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if dead == nil {
+		dead = []*models.OutboxEvent{}
+	}
+	return dead, models.NewPagination(page, total), nil
+}
+
+// RequeueDeadLetter makes a dead letter pending again; the relay publishes it
+// on its next poll with a fresh set of attempts.
+func (s *outboxService) RequeueDeadLetter(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrDeadLetterNotFound
+	}
+
+	requeued, err := s.outboxRepo.Requeue(id)
+	if err != nil {
+		return err
+	}
+	if !requeued {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
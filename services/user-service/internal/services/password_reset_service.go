@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/mailer"
+	"user-service/internal/models"
+	"user-service/internal/password"
+	"user-service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired password reset token")
+
+// maxQueuedResetRequests bounds the reset requests waiting for
+// SendResetEmails; requests beyond it are dropped.
+const maxQueuedResetRequests = 256
+
+type PasswordResetService interface {
+	RequestReset(email string) error
+	ResetPassword(token, newPassword string) error
+	// SendResetEmails handles the queued reset requests one at a time until
+	// ctx is cancelled.
+	SendResetEmails(ctx context.Context)
+}
+
+type passwordResetService struct {
+	userRepo    repository.UserRepository
+	resetRepo   repository.PasswordResetRepository
+	history     repository.PasswordRepository
+	passwords   PasswordService
+	mailer      mailer.Mailer
+	policy      *password.Policy
+	hasher      password.Hasher
+	appBaseURL  string
+	secret      []byte
+	tokenTTL    time.Duration
+	resendDelay time.Duration
+	queue       chan string
+}
+
+func NewPasswordResetService(userRepo repository.UserRepository, resetRepo repository.PasswordResetRepository, history repository.PasswordRepository, passwords PasswordService, m mailer.Mailer, policy *password.Policy, hasher password.Hasher, cfg config.AuthConfig, mailCfg config.MailConfig) PasswordResetService {
+	return &passwordResetService{
+		userRepo:    userRepo,
+		resetRepo:   resetRepo,
+		history:     history,
+		passwords:   passwords,
+		mailer:      m,
+		policy:      policy,
+		hasher:      hasher,
+		appBaseURL:  mailCfg.AppBaseURL,
+		secret:      []byte(cfg.TokenSigningSecret),
+		tokenTTL:    time.Duration(cfg.PasswordResetTokenTTL) * time.Minute,
+		resendDelay: time.Duration(cfg.PasswordResetResendDelay) * time.Second,
+		queue:       make(chan string, maxQueuedResetRequests),
+	}
+}
+
+// RequestReset queues a reset link for the address. The lookup and the mail
+// happen in SendResetEmails, so the caller gets the same answer, just as
+// fast, whether or not the email exists.
+func (s *passwordResetService) RequestReset(email string) error {
+	select {
+	case s.queue <- email:
+	default:
+		logrus.Warn("Password reset queue is full, dropping request")
+	}
+	return nil
+}
+
+func (s *passwordResetService) SendResetEmails(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case email := <-s.queue:
+			if err := s.sendReset(email); err != nil {
+				logrus.WithError(err).Error("Failed to send password reset email")
+			}
+		}
+	}
+}
+
+// sendReset issues a reset link unless the address is unknown, inactive or
+// was sent one less than resendDelay ago.
+func (s *passwordResetService) sendReset(email string) error {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive {
+		return nil
+	}
+
+	latest, err := s.resetRepo.GetLatestByUserID(user.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < s.resendDelay {
+		return nil
+	}
+
+	return s.issueToken(user)
+}
+
+func (s *passwordResetService) issueToken(user *models.User) error {
+	if err := s.resetRepo.DeleteUnusedByUserID(user.ID); err != nil {
+		return err
+	}
+
+	token, hash, err := newSignedToken(s.secret)
+	if err != nil {
+		return err
+	}
+
+	record := &models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.tokenTTL),
+	}
+
+	if err := s.resetRepo.CreateToken(record); err != nil {
+		return err
+	}
+
+	link := actionLink(s.appBaseURL, "/reset-password", token)
+	return s.mailer.Send(passwordResetEmail(user, link, s.tokenTTL))
+}
+
+func (s *passwordResetService) ResetPassword(token, newPassword string) error {
+	hash, ok := verifySignedToken(s.secret, token)
+	if !ok {
+		return ErrInvalidResetToken
+	}
+
+	ownerID, err := s.resetRepo.GetUserIDByToken(hash)
+	if err != nil {
+		return err
+	}
+	if ownerID == "" {
+		return ErrInvalidResetToken
+	}
+
+	owner, err := s.userRepo.GetByID(ownerID)
+	if err != nil {
+		return err
+	}
+	if owner == nil {
+		return ErrInvalidResetToken
+	}
+
+	if err := s.policy.Validate(newPassword, password.Owner{Email: owner.Email, Username: owner.Username}); err != nil {
+		return err
+	}
+	if err := checkPasswordHistory(s.history, s.policy, s.hasher, owner, newPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	userID, changedAt, err := s.resetRepo.ResetPassword(hash, hashedPassword, s.policy.HistorySize)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return ErrInvalidResetToken
+	}
+
+	// A reset is how an account is taken back, so access tokens held by
+	// whoever had it stop working now rather than when they expire.
+	s.passwords.PasswordChanged(context.Background(), userID, changedAt)
+
+	logrus.WithField("user_id", userID).Info("Password reset completed, all sessions revoked")
+	return nil
+}
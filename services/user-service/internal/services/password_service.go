@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"user-service/internal/lockout"
+	"user-service/internal/models"
+	"user-service/internal/password"
+	"user-service/internal/repository"
+	"user-service/internal/revocation"
+	"user-service/internal/tokens"
+
+	"github.com/sirupsen/logrus"
+)
+
+// passwordChangeCacheTTL bounds how long a replica may keep accepting tokens
+// issued before a password change made on another replica. The revocation
+// store normally closes that gap sooner.
+const passwordChangeCacheTTL = 30 * time.Second
+
+// passwordChangeCacheSweep is the cache size at which expired entries are
+// dropped.
+const passwordChangeCacheSweep = 10000
+
+var ErrInvalidCurrentPassword = errors.New("current password is incorrect")
+
+type PasswordService interface {
+	ChangePassword(userID, sessionID string, req *models.ChangePasswordRequest, client models.ClientInfo) error
+	PasswordChangedAt(ctx context.Context, userID string) (time.Time, error)
+	// PasswordChanged makes access tokens issued before a password change
+	// stop working. ChangePassword calls it itself; password resets call it
+	// after storing the new password.
+	PasswordChanged(ctx context.Context, userID string, changedAt time.Time)
+}
+
+type passwordService struct {
+	userRepo     repository.UserRepository
+	passwordRepo repository.PasswordRepository
+	revocations  revocation.Store
+	guard        *lockout.Guard
+	policy       *password.Policy
+	hasher       password.Hasher
+	accessTTL    time.Duration
+
+	mu        sync.Mutex
+	changedAt map[string]cachedChange
+}
+
+type cachedChange struct {
+	at       time.Time
+	loadedAt time.Time
+}
+
+func NewPasswordService(userRepo repository.UserRepository, passwordRepo repository.PasswordRepository, revocations revocation.Store, guard *lockout.Guard, policy *password.Policy, hasher password.Hasher, accessTTL time.Duration) PasswordService {
+	return &passwordService{
+		userRepo:     userRepo,
+		passwordRepo: passwordRepo,
+		revocations:  revocations,
+		guard:        guard,
+		policy:       policy,
+		hasher:       hasher,
+		accessTTL:    accessTTL,
+		changedAt:    make(map[string]cachedChange),
+	}
+}
+
+// ChangePassword replaces the password of a signed-in user after checking the
+// current one. Every other session is signed out and access tokens issued
+// before the change stop working, including the caller's: the client keeps its
+// session and picks up a new access token through the refresh endpoint.
+// Wrong current passwords count as failed logins, so a stolen access token
+// can't be used to guess the password.
+func (s *passwordService) ChangePassword(userID, sessionID string, req *models.ChangePasswordRequest, client models.ClientInfo) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive {
+		return errors.New("user not found or inactive")
+	}
+
+	ctx := context.Background()
+	if err := s.guard.Check(ctx, user.Email, client.IPAddress); err != nil {
+		return err
+	}
+	if match, _ := s.hasher.Verify(req.CurrentPassword, user.Password); !match {
+		if err := s.guard.Fail(ctx, user.Email, client.IPAddress); err != nil {
+			return err
+		}
+		return ErrInvalidCurrentPassword
+	}
+	s.guard.Succeed(ctx, user.Email)
+
+	if err := s.policy.Validate(req.NewPassword, password.Owner{Email: user.Email, Username: user.Username}); err != nil {
+		return err
+	}
+	if err := checkPasswordHistory(s.passwordRepo, s.policy, s.hasher, user, req.NewPassword); err != nil {
+		return err
+	}
+
+	hashedPassword, err := s.hasher.Hash(req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	changedAt, err := s.passwordRepo.ChangePassword(userID, sessionID, hashedPassword, s.policy.HistorySize)
+	if err != nil {
+		return err
+	}
+
+	s.PasswordChanged(ctx, userID, changedAt)
+
+	logrus.WithField("user_id", userID).Info("Password changed, other sessions revoked")
+	return nil
+}
+
+// PasswordChanged updates the cached change time, so this replica rejects
+// older tokens at once, and revokes them in the shared store for the others.
+func (s *passwordService) PasswordChanged(ctx context.Context, userID string, changedAt time.Time) {
+	s.remember(userID, changedAt)
+
+	if err := s.revocations.RevokeUser(ctx, userID, tokens.Cutoff(changedAt), s.accessTTL); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to revoke tokens after password change")
+	}
+}
+
+// PasswordChangedAt returns when the user last changed or reset their
+// password, or the zero time if they never have. Lookups are cached briefly
+// since the auth middleware calls this on every request.
+func (s *passwordService) PasswordChangedAt(ctx context.Context, userID string) (time.Time, error) {
+	s.mu.Lock()
+	cached, ok := s.changedAt[userID]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < passwordChangeCacheTTL {
+		return cached.at, nil
+	}
+
+	changedAt, err := s.passwordRepo.GetChangedAt(userID)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	var at time.Time
+	if changedAt != nil {
+		at = *changedAt
+	}
+
+	s.remember(userID, at)
+	return at, nil
+}
+
+func (s *passwordService) remember(userID string, at time.Time) {
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.changedAt) >= passwordChangeCacheSweep {
+		for id, cached := range s.changedAt {
+			if now.Sub(cached.loadedAt) >= passwordChangeCacheTTL {
+				delete(s.changedAt, id)
+			}
+		}
+	}
+	s.changedAt[userID] = cachedChange{at: at, loadedAt: now}
+}
+
+// checkPasswordHistory rejects the user's current password and the ones kept
+// in their history.
+func checkPasswordHistory(history repository.PasswordRepository, policy *password.Policy, hasher password.Hasher, user *models.User, newPassword string) error {
+	previous, err := history.GetHistory(user.ID, policy.HistorySize)
+	if err != nil {
+		return err
+	}
+
+	return policy.CheckReuse(hasher, newPassword, append([]string{user.Password}, previous...))
+}
@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/revocation"
+	"user-service/internal/tokens"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("a role with this name already exists")
+	ErrRoleInUse         = errors.New("role is still assigned to users")
+	ErrSystemRole        = errors.New("built-in roles cannot be deleted")
+	ErrAdminRoleFixed    = errors.New("the admin role always holds every permission")
+	ErrInvalidRoleName   = errors.New("role name must start with a lowercase letter and contain only lowercase letters, digits, '-' and '_'")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrOwnRole           = errors.New("administrators cannot change their own role")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*$`)
+
+// RolePermissions resolves the permissions currently granted to a role.
+type RolePermissions interface {
+	PermissionsForRole(ctx context.Context, role string) ([]string, error)
+}
+
+// RoleService manages roles, the permissions they grant and which role each
+// user holds.
+type RoleService interface {
+	ListRoles() ([]*models.Role, error)
+	GetRole(name string) (*models.Role, error)
+	CreateRole(req *models.CreateRoleRequest) (*models.Role, error)
+	UpdateRole(name string, req *models.UpdateRoleRequest) (*models.Role, error)
+	DeleteRole(name string) error
+	ListPermissions() ([]*models.Permission, error)
+	AssignRole(actorID, userID, role string) (*models.User, error)
+	PermissionsForRole(ctx context.Context, role string) ([]string, error)
+}
+
+type roleService struct {
+	roleRepo    repository.RoleRepository
+	userRepo    repository.UserRepository
+	revocations revocation.Store
+	accessTTL   time.Duration
+}
+
+func NewRoleService(roleRepo repository.RoleRepository, userRepo repository.UserRepository, revocations revocation.Store, accessTTL time.Duration) RoleService {
+	return &roleService{
+		roleRepo:    roleRepo,
+		userRepo:    userRepo,
+		revocations: revocations,
+		accessTTL:   accessTTL,
+	}
+}
+
+func (s *roleService) ListRoles() ([]*models.Role, error) {
+	roles, err := s.roleRepo.ListRoles()
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		roles = []*models.Role{}
+	}
+	return roles, nil
+}
+
+func (s *roleService) GetRole(name string) (*models.Role, error) {
+	role, err := s.roleRepo.GetRole(name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+func (s *roleService) CreateRole(req *models.CreateRoleRequest) (*models.Role, error) {
+	if !roleNamePattern.MatchString(req.Name) {
+		return nil, ErrInvalidRoleName
+	}
+	permissions, err := s.knownPermissions(req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissions,
+	}
+	if err := s.roleRepo.CreateRole(role); err != nil {
+		if errors.Is(err, repository.ErrDuplicateRole) {
+			return nil, ErrRoleExists
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+// UpdateRole applies the non-nil fields of req. Users holding the role get
+// added permissions when their access token is next refreshed. Taking a
+// permission away revokes every access token issued for the role, so it
+// stops working at once rather than when those tokens expire.
+func (s *roleService) UpdateRole(name string, req *models.UpdateRoleRequest) (*models.Role, error) {
+	role, err := s.GetRole(name)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := false
+	if req.Description != nil {
+		role.Description = *req.Description
+	}
+	if req.Permissions != nil {
+		if role.Name == models.RoleAdmin {
+			return nil, ErrAdminRoleFixed
+		}
+		permissions, err := s.knownPermissions(req.Permissions)
+		if err != nil {
+			return nil, err
+		}
+		removed = removesAny(role.Permissions, permissions)
+		role.Permissions = permissions
+	}
+
+	if err := s.roleRepo.UpdateRole(role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRoleNotFound
+		}
+		return nil, err
+	}
+
+	if removed {
+		if err := s.revocations.RevokeRole(context.Background(), role.Name, tokens.Cutoff(time.Now()), s.accessTTL); err != nil {
+			return nil, fmt.Errorf("revoke tokens of role %s: %w", role.Name, err)
+		}
+		logrus.WithField("role", role.Name).Info("Role permissions reduced, access tokens revoked")
+	}
+	return role, nil
+}
+
+func (s *roleService) DeleteRole(name string) error {
+	role, err := s.GetRole(name)
+	if err != nil {
+		return err
+	}
+	if role.IsSystem {
+		return ErrSystemRole
+	}
+
+	deleted, err := s.roleRepo.DeleteRole(name)
+	if err != nil {
+		if errors.Is(err, repository.ErrRoleInUse) {
+			return ErrRoleInUse
+		}
+		return err
+	}
+	if !deleted {
+		return ErrRoleNotFound
+	}
+	return nil
+}
+
+func (s *roleService) ListPermissions() ([]*models.Permission, error) {
+	permissions, err := s.roleRepo.ListPermissions()
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		permissions = []*models.Permission{}
+	}
+	return permissions, nil
+}
+
+// AssignRole gives a user another role. The user's access tokens are
+// revoked so the new permissions apply from their next refresh; sessions
+// stay signed in.
+func (s *roleService) AssignRole(actorID, userID, role string) (*models.User, error) {
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrUserNotFound
+	}
+	if actorID == userID {
+		return nil, ErrOwnRole
+	}
+	if _, err := s.GetRole(role); err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	user.Password = ""
+	if user.Role == role {
+		return user, nil
+	}
+
+	if err := s.userRepo.Update(userID, map[string]interface{}{"role": role}); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	if err := s.revocations.RevokeUser(context.Background(), userID, tokens.Cutoff(time.Now()), s.accessTTL); err != nil {
+		logrus.WithError(err).WithField("user_id", userID).Warn("Failed to revoke tokens after role change")
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"user_id":  userID,
+		"actor_id": actorID,
+		"from":     user.Role,
+		"to":       role,
+	}).Info("User role changed")
+
+	user.Role = role
+	return user, nil
+}
+
+func (s *roleService) PermissionsForRole(ctx context.Context, role string) ([]string, error) {
+	return s.roleRepo.PermissionsForRole(role)
+}
+
+// removesAny reports whether after lacks a permission held in before.
+func removesAny(before, after []string) bool {
+	kept := make(map[string]bool, len(after))
+	for _, permission := range after {
+		kept[permission] = true
+	}
+	for _, permission := range before {
+		if !kept[permission] {
+			return true
+		}
+	}
+	return false
+}
+
+// knownPermissions checks that every requested permission exists and
+// returns them sorted without duplicates.
+func (s *roleService) knownPermissions(requested []string) ([]string, error) {
+	known, err := s.roleRepo.ListPermissions()
+	if err != nil {
+		return nil, err
+	}
+	exists := make(map[string]bool, len(known))
+	for _, permission := range known {
+		exists[permission.Name] = true
+	}
+
+	seen := make(map[string]bool, len(requested))
+	permissions := make([]string, 0, len(requested))
+	for _, permission := range requested {
+		if !exists[permission] {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownPermission, permission)
+		}
+		if !seen[permission] {
+			seen[permission] = true
+			permissions = append(permissions, permission)
+		}
+	}
+	sort.Strings(permissions)
+	return permissions, nil
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"time"
+
+	"user-service/internal/models"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+)
+
+// SecurityEventEmitter receives security-relevant events such as detected
+// refresh token reuse.
+type SecurityEventEmitter interface {
+	Emit(event models.SecurityEvent)
+}
+
+type logSecurityEventEmitter struct{}
+
+// NewLogSecurityEventEmitter returns an emitter that writes events to the
+// service log at warning level.
+func NewLogSecurityEventEmitter() SecurityEventEmitter {
+	return logSecurityEventEmitter{}
+}
+
+func (logSecurityEventEmitter) Emit(event models.SecurityEvent) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	fields := logrus.Fields{
+		"event":       event.Type,
+		"user_id":     event.UserID,
+		"session_id":  event.SessionID,
+		"occurred_at": event.OccurredAt,
+	}
+	for k, v := range event.Details {
+		fields[k] = v
+	}
+
+	logrus.WithFields(fields).Warn("Security event")
+}
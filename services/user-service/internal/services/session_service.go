@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/revocation"
+
+	"github.com/google/uuid"
+	"github.com/mssola/useragent"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionService lets users see where they are signed in and sign devices
+// out remotely.
+type SessionService interface {
+	ListSessions(userID, currentSessionID string) ([]*models.UserSession, error)
+	RevokeSession(userID, sessionID string) error
+}
+
+type sessionService struct {
+	sessionRepo repository.SessionRepository
+	revocations revocation.Store
+	accessTTL   time.Duration
+}
+
+func NewSessionService(sessionRepo repository.SessionRepository, revocations revocation.Store, accessTTL time.Duration) SessionService {
+	return &sessionService{
+		sessionRepo: sessionRepo,
+		revocations: revocations,
+		accessTTL:   accessTTL,
+	}
+}
+
+func (s *sessionService) ListSessions(userID, currentSessionID string) ([]*models.UserSession, error) {
+	sessions, err := s.sessionRepo.ListActiveByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		describeUserAgent(session)
+		session.Current = session.ID == currentSessionID
+	}
+
+	if sessions == nil {
+		sessions = []*models.UserSession{}
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs a device out: its refresh token family stops working
+// and access tokens already issued to it are revoked.
+func (s *sessionService) RevokeSession(userID, sessionID string) error {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return ErrSessionNotFound
+	}
+	session, err := s.sessionRepo.GetSession(sessionID)
+	if err != nil {
+		return err
+	}
+	// Other users' sessions are reported as missing rather than forbidden.
+	if session == nil || session.UserID != userID || session.RevokedAt != nil {
+		return ErrSessionNotFound
+	}
+
+	if err := s.sessionRepo.RevokeSession(sessionID); err != nil {
+		return err
+	}
+	return s.revocations.RevokeSession(context.Background(), sessionID, s.accessTTL)
+}
+
+// describeUserAgent fills the device, browser and OS fields of a session from
+// its raw user agent.
+func describeUserAgent(session *models.UserSession) {
+	if session.UserAgent == "" {
+		return
+	}
+
+	ua := useragent.New(session.UserAgent)
+
+	switch {
+	case ua.Bot():
+		session.Device = "bot"
+	case strings.Contains(session.UserAgent, "iPad") || strings.Contains(session.UserAgent, "Tablet"):
+		session.Device = "tablet"
+	case ua.Mobile():
+		session.Device = "mobile"
+	default:
+		session.Device = "desktop"
+	}
+
+	name, version := ua.Browser()
+	session.Browser = joinNonEmpty(name, majorVersion(version))
+
+	os := ua.OSInfo()
+	session.OS = joinNonEmpty(os.Name, strings.ReplaceAll(os.Version, "_", "."))
+}
+
+func majorVersion(version string) string {
+	major, _, _ := strings.Cut(version, ".")
+	return major
+}
+
+func joinNonEmpty(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return strings.Join(out, " ")
+}
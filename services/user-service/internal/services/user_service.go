@@ -1,33 +1,91 @@
 package services
 
 import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
-	"os"
+	"fmt"
+	"strings"
 	"time"
 
+	"user-service/internal/config"
+	"user-service/internal/keystore"
+	"user-service/internal/lockout"
 	"user-service/internal/models"
+	"user-service/internal/password"
 	"user-service/internal/repository"
+	"user-service/internal/revocation"
+	"user-service/internal/tokens"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrAccountDeactivated  = errors.New("account is deactivated")
+	ErrEmailNotVerified    = errors.New("email address has not been verified")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrEmailTaken          = errors.New("email is already in use")
+	ErrUsernameTaken       = errors.New("username is already in use")
+	ErrInvalidCursor       = errors.New("cursor is invalid or does not match the sort order")
 )
 
 type UserService interface {
 	Register(req *models.CreateUserRequest) (*models.User, error)
-	Login(req *models.LoginRequest) (*models.LoginResponse, error)
+	Login(req *models.LoginRequest, client models.ClientInfo) (*models.LoginResponse, *models.MFAChallenge, error)
+	VerifyMFA(req *models.MFAVerifyRequest, client models.ClientInfo) (*models.LoginResponse, error)
 	GetUserByID(id string) (*models.User, error)
+	GetUsersByIDs(ids []string) ([]*models.User, error)
+	ListUsers(query models.UserListQuery) (*models.UserPage, error)
 	UpdateProfile(id string, req *models.UpdateUserRequest) (*models.User, error)
 	DeleteAccount(id string) error
-	RefreshToken(refreshToken string) (*models.LoginResponse, error)
+	RefreshToken(refreshToken string, client models.ClientInfo) (*models.LoginResponse, error)
+	Logout(userID, sessionID, tokenID string, tokenExpiresAt time.Time) error
+	LogoutAll(userID string) error
+	UnlockLogin(userID string) error
 }
 
 type userService struct {
-	userRepo repository.UserRepository
+	userRepo     repository.UserRepository
+	sessionRepo  repository.SessionRepository
+	verification VerificationService
+	mfa          MFAService
+	keys         keystore.Signer
+	revocations  revocation.Store
+	guard        *lockout.Guard
+	passwords    *password.Policy
+	hasher       password.Hasher
+	events       SecurityEventEmitter
+	roles        RolePermissions
+	authConfig   config.AuthConfig
+	accessTTL    time.Duration
+	refreshTTL   time.Duration
 }
 
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &userService{userRepo: userRepo}
+func NewUserService(userRepo repository.UserRepository, sessionRepo repository.SessionRepository, verification VerificationService, mfa MFAService, keys keystore.Signer, revocations revocation.Store, guard *lockout.Guard, passwords *password.Policy, hasher password.Hasher, events SecurityEventEmitter, roles RolePermissions, authConfig config.AuthConfig, accessTTL, refreshTTL time.Duration) UserService {
+	return &userService{
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		verification: verification,
+		mfa:          mfa,
+		keys:         keys,
+		revocations:  revocations,
+		guard:        guard,
+		passwords:    passwords,
+		hasher:       hasher,
+		events:       events,
+		roles:        roles,
+		authConfig:   authConfig,
+		accessTTL:    accessTTL,
+		refreshTTL:   refreshTTL,
+	}
 }
 
 func (s *userService) Register(req *models.CreateUserRequest) (*models.User, error) {
@@ -48,72 +106,161 @@ func (s *userService) Register(req *models.CreateUserRequest) (*models.User, err
 		return nil, errors.New("user with this username already exists")
 	}
 
-	// Hash password
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
-	if err != nil {
+	if err := s.passwords.Validate(req.Password, password.Owner{Email: req.Email, Username: req.Username}); err != nil {
 		return nil, err
 	}
 
-	// Set default role if not provided
-	role := req.Role
-	if role == "" {
-		role = "user"
+	// Hash password
+	hashedPassword, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		return nil, err
 	}
 
+	// Other roles are only granted by an administrator
 	user := &models.User{
-		Email:    req.Email,
-		Username: req.Username,
-		Password: string(hashedPassword),
-		Role:     role,
-		IsActive: true,
+		Email:     req.Email,
+		Username:  req.Username,
+		Password:  hashedPassword,
+		Role:      models.RoleUser,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Phone:     optional(req.Phone),
+		AvatarURL: optional(req.AvatarURL),
+		IsActive:  true,
 	}
 
 	if err := s.userRepo.Create(user); err != nil {
 		return nil, err
 	}
 
+	// The account exists at this point; a failed send can be retried through resend-verification.
+	if err := s.verification.IssueToken(user); err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to issue email verification token")
+	}
+
 	// Clear password before returning
 	user.Password = ""
 	return user, nil
 }
 
-func (s *userService) Login(req *models.LoginRequest) (*models.LoginResponse, error) {
+// Login checks the password and either signs the user in or, when a second
+// factor is enrolled or mandated by role, returns an MFA challenge instead.
+// Repeated failures for the same account or client IP are throttled with a
+// *lockout.LockedError.
+func (s *userService) Login(req *models.LoginRequest, client models.ClientInfo) (*models.LoginResponse, *models.MFAChallenge, error) {
+	ctx := context.Background()
+	if err := s.guard.Check(ctx, req.Email, client.IPAddress); err != nil {
+		return nil, nil, err
+	}
+
 	user, err := s.userRepo.GetByEmail(req.Email)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if user == nil {
-		return nil, errors.New("invalid credentials")
+		// Unknown emails count too, so probing for accounts is throttled alike.
+		s.guard.Fail(ctx, req.Email, client.IPAddress)
+		return nil, nil, ErrInvalidCredentials
 	}
 
 	if !user.IsActive {
-		return nil, errors.New("account is deactivated")
+		return nil, nil, ErrAccountDeactivated
 	}
 
 	// Verify password
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+	match, err := s.hasher.Verify(req.Password, user.Password)
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Error("Failed to verify stored password hash")
+	}
+	if !match {
+		s.guard.Fail(ctx, req.Email, client.IPAddress)
+		return nil, nil, ErrInvalidCredentials
+	}
+	s.rehashIfNeeded(user, req.Password)
+
+	if s.authConfig.RequireEmailVerification && !user.IsVerified {
+		return nil, nil, ErrEmailNotVerified
+	}
+
+	// With a second factor the failures are only cleared once it passes.
+	challenge, err := s.mfa.Challenge(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge != nil {
+		return nil, challenge, nil
 	}
+	s.guard.Succeed(ctx, req.Email)
 
-	// Generate tokens
-	accessToken, err := s.generateAccessToken(user)
+	response, err := s.createSession(user, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return response, nil, nil
+}
+
+// VerifyMFA completes a login that was interrupted by an MFA challenge. A
+// wrong code counts as a failed login for the account and the client IP, and
+// returns a *lockout.LockedError once the next attempt has to wait.
+func (s *userService) VerifyMFA(req *models.MFAVerifyRequest, client models.ClientInfo) (*models.LoginResponse, error) {
+	ctx := context.Background()
+	userID, recoveryCodes, verifyErr := s.mfa.VerifyChallenge(req.ChallengeToken, req.Code)
+	if verifyErr != nil && !errors.Is(verifyErr, ErrInvalidMFACode) {
+		return nil, verifyErr
+	}
+
+	user, err := s.userRepo.GetByID(userID)
 	if err != nil {
 		return nil, err
 	}
+	if user == nil || !user.IsActive {
+		return nil, errors.New("user not found or inactive")
+	}
 
-	refreshToken, err := s.generateRefreshToken(user)
+	if verifyErr != nil {
+		if err := s.guard.Fail(ctx, user.Email, client.IPAddress); err != nil {
+			return nil, err
+		}
+		return nil, verifyErr
+	}
+	s.guard.Succeed(ctx, user.Email)
+
+	response, err := s.createSession(user, client)
+	if err != nil {
+		return nil, err
+	}
+
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+// createSession starts a new refresh token family for the user.
+func (s *userService) createSession(user *models.User, client models.ClientInfo) (*models.LoginResponse, error) {
+	refreshToken, err := generateRefreshToken()
 	if err != nil {
 		return nil, err
 	}
 
-	// Save refresh token session
+	expiresAt := time.Now().Add(s.refreshTTL)
 	session := &models.UserSession{
-		UserID:       user.ID,
-		RefreshToken: refreshToken,
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour), // 30 days
+		UserID:    user.ID,
+		ExpiresAt: expiresAt,
+		IPAddress: client.IPAddress,
+		UserAgent: client.UserAgent,
+	}
+	token := &models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: expiresAt,
+	}
+
+	if err := s.sessionRepo.CreateSession(session, token); err != nil {
+		return nil, err
 	}
 
-	if err := s.userRepo.CreateSession(session); err != nil {
+	accessToken, err := s.generateAccessToken(user, session.ID)
+	if err != nil {
 		return nil, err
 	}
 
@@ -128,12 +275,15 @@ func (s *userService) Login(req *models.LoginRequest) (*models.LoginResponse, er
 }
 
 func (s *userService) GetUserByID(id string) (*models.User, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUserNotFound
+	}
 	user, err := s.userRepo.GetByID(id)
 	if err != nil {
 		return nil, err
 	}
 	if user == nil {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	// Clear password before returning
@@ -141,26 +291,246 @@ func (s *userService) GetUserByID(id string) (*models.User, error) {
 	return user, nil
 }
 
+// GetUsersByIDs looks up several users at once; unknown IDs are left out of
+// the result.
+func (s *userService) GetUsersByIDs(ids []string) ([]*models.User, error) {
+	users, err := s.userRepo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clear passwords before returning
+	for _, user := range users {
+		user.Password = ""
+	}
+	return users, nil
+}
+
+// ListUsers returns one page of the admin user list, newest first unless
+// the query sorts otherwise. Offset pages carry the total; every page that is
+// not the last also carries a cursor to continue after it.
+func (s *userService) ListUsers(query models.UserListQuery) (*models.UserPage, error) {
+	query.Normalize()
+	order := models.UserOrder{Field: query.Sort, Desc: query.Order != "asc"}
+	if order.Field == "" {
+		order.Field = "created_at"
+	}
+	filter := models.UserFilter{
+		Search:      strings.TrimSpace(query.Search),
+		Role:        query.Role,
+		IsActive:    query.IsActive,
+		IsVerified:  query.IsVerified,
+		CreatedFrom: query.CreatedFrom,
+		CreatedTo:   query.CreatedTo,
+	}
+
+	var after *models.UserKey
+	if query.Cursor != "" {
+		key, err := decodeUserCursor(query.Cursor, order)
+		if err != nil {
+			return nil, err
+		}
+		after = key
+	}
+
+	// One extra row tells whether another page follows.
+	users, err := s.userRepo.List(filter, order, after, query.Limit+1, query.Offset())
+	if err != nil {
+		return nil, err
+	}
+	var nextCursor string
+	if len(users) > query.Limit {
+		users = users[:query.Limit]
+		nextCursor = encodeUserCursor(order, users[len(users)-1])
+	}
+	if users == nil {
+		users = []*models.User{}
+	}
+	for _, user := range users {
+		user.Password = ""
+	}
+
+	if after != nil {
+		return &models.UserPage{
+			Users:  users,
+			Cursor: &models.CursorPagination{Limit: query.Limit, NextCursor: nextCursor},
+		}, nil
+	}
+
+	total, err := s.userRepo.Count(filter)
+	if err != nil {
+		return nil, err
+	}
+	pagination := models.NewPagination(query.PageQuery, total)
+	pagination.NextCursor = nextCursor
+	return &models.UserPage{Users: users, Pagination: &pagination}, nil
+}
+
+// userCursor is the decoded form of a user list cursor. It records the sort
+// order it was issued for so it cannot be replayed against another one.
+type userCursor struct {
+	Field string `json:"f"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+func encodeUserCursor(order models.UserOrder, user *models.User) string {
+	var value string
+	switch order.Field {
+	case "created_at":
+		value = user.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "updated_at":
+		value = user.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case "email":
+		value = user.Email
+	case "username":
+		value = user.Username
+	}
+
+	raw, _ := json.Marshal(userCursor{Field: order.Field, Desc: order.Desc, Value: value, ID: user.ID})
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeUserCursor(cursor string, order models.UserOrder) (*models.UserKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var decoded userCursor
+	if err := json.Unmarshal(raw, &decoded); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if decoded.Field != order.Field || decoded.Desc != order.Desc {
+		return nil, ErrInvalidCursor
+	}
+	if _, err := uuid.Parse(decoded.ID); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if order.Field == "created_at" || order.Field == "updated_at" {
+		if _, err := time.Parse(time.RFC3339Nano, decoded.Value); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &models.UserKey{Value: decoded.Value, ID: decoded.ID}, nil
+}
+
+// UpdateProfile applies the non-nil fields of req and clears the fields it
+// lists in Clear. Changing the email address marks it unverified again and
+// sends a new verification link.
 func (s *userService) UpdateProfile(id string, req *models.UpdateUserRequest) (*models.User, error) {
-	// Implementation would update user profile
-	// For now, return the existing user
-	return s.GetUserByID(id)
+	user, err := s.userRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.IsActive {
+		return nil, ErrUserNotFound
+	}
+
+	updates := make(map[string]interface{})
+
+	if req.Email != nil && *req.Email != user.Email {
+		existing, err := s.userRepo.GetByEmail(*req.Email)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrEmailTaken
+		}
+		updates["email"] = *req.Email
+		updates["is_verified"] = false
+	}
+
+	if req.Username != nil && *req.Username != user.Username {
+		existing, err := s.userRepo.GetByUsername(*req.Username)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, ErrUsernameTaken
+		}
+		updates["username"] = *req.Username
+	}
+
+	if req.FirstName != nil {
+		updates["first_name"] = *req.FirstName
+	}
+	if req.LastName != nil {
+		updates["last_name"] = *req.LastName
+	}
+	if req.Phone != nil {
+		updates["phone"] = *req.Phone
+	}
+	if req.AvatarURL != nil {
+		updates["avatar_url"] = *req.AvatarURL
+	}
+
+	for _, field := range req.Clear {
+		if !models.NullableProfileFields[field] {
+			return nil, fmt.Errorf("%s cannot be cleared", field)
+		}
+		updates[field] = nil
+	}
+
+	// The checks above can race with another request; the constraints decide.
+	switch err := s.userRepo.Update(id, updates); {
+	case errors.Is(err, repository.ErrDuplicateEmail):
+		return nil, ErrEmailTaken
+	case errors.Is(err, repository.ErrDuplicateUsername):
+		return nil, ErrUsernameTaken
+	case errors.Is(err, sql.ErrNoRows):
+		return nil, ErrUserNotFound
+	case err != nil:
+		return nil, err
+	}
+
+	updated, err := s.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, changed := updates["email"]; changed {
+		if err := s.verification.IssueToken(updated); err != nil {
+			logrus.WithError(err).WithField("user_id", id).Warn("Failed to issue email verification token")
+		}
+	}
+
+	return updated, nil
 }
 
 func (s *userService) DeleteAccount(id string) error {
-	return s.userRepo.Delete(id)
+	if err := s.userRepo.Delete(id); err != nil {
+		return err
+	}
+	return s.LogoutAll(id)
 }
 
-func (s *userService) RefreshToken(refreshToken string) (*models.LoginResponse, error) {
-	session, err := s.userRepo.GetSessionByRefreshToken(refreshToken)
+// RefreshToken exchanges a refresh token for a new token pair. Each refresh
+// token can be used once; presenting one that was already rotated means it
+// leaked, so the whole family (session) is revoked.
+func (s *userService) RefreshToken(refreshToken string, client models.ClientInfo) (*models.LoginResponse, error) {
+	stored, err := s.sessionRepo.GetRefreshTokenByHash(hashToken(refreshToken))
 	if err != nil {
 		return nil, err
 	}
-	if session == nil {
-		return nil, errors.New("invalid refresh token")
+	if stored == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if stored.UsedAt != nil {
+		return nil, s.revokeReusedFamily(stored)
 	}
 
-	user, err := s.userRepo.GetByID(session.UserID)
+	session, err := s.sessionRepo.GetSession(stored.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if session == nil || session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.userRepo.GetByID(stored.UserID)
 	if err != nil {
 		return nil, err
 	}
@@ -169,28 +539,29 @@ func (s *userService) RefreshToken(refreshToken string) (*models.LoginResponse,
 	}
 
 	// Generate new tokens
-	accessToken, err := s.generateAccessToken(user)
+	accessToken, err := s.generateAccessToken(user, session.ID)
 	if err != nil {
 		return nil, err
 	}
 
-	newRefreshToken, err := s.generateRefreshToken(user)
+	newRefreshToken, err := generateRefreshToken()
 	if err != nil {
 		return nil, err
 	}
 
-	// Delete old session and create new one
-	s.userRepo.DeleteSession(session.ID)
-
-	newSession := &models.UserSession{
-		UserID:       user.ID,
-		RefreshToken: newRefreshToken,
-		ExpiresAt:    time.Now().Add(30 * 24 * time.Hour),
-	}
-
-	if err := s.userRepo.CreateSession(newSession); err != nil {
+	rotated, err := s.sessionRepo.RotateRefreshToken(stored.ID, &models.RefreshToken{
+		UserID:    user.ID,
+		SessionID: session.ID,
+		TokenHash: hashToken(newRefreshToken),
+		ExpiresAt: now.Add(s.refreshTTL),
+	}, client)
+	if err != nil {
 		return nil, err
 	}
+	if !rotated {
+		// A concurrent request rotated this token first.
+		return nil, s.revokeReusedFamily(stored)
+	}
 
 	// Clear password before returning
 	user.Password = ""
@@ -202,28 +573,145 @@ func (s *userService) RefreshToken(refreshToken string) (*models.LoginResponse,
 	}, nil
 }
 
-func (s *userService) generateAccessToken(user *models.User) (string, error) {
-	claims := jwt.MapClaims{
-		"sub":   user.ID,
-		"email": user.Email,
-		"role":  user.Role,
-		"exp":   time.Now().Add(24 * time.Hour).Unix(), // 24 hours
-		"iat":   time.Now().Unix(),
+// Logout ends the session the access token belongs to and revokes the token
+// itself so it stops working before it expires.
+func (s *userService) Logout(userID, sessionID, tokenID string, tokenExpiresAt time.Time) error {
+	if sessionID != "" {
+		session, err := s.sessionRepo.GetSession(sessionID)
+		if err != nil {
+			return err
+		}
+		if session != nil && session.UserID == userID {
+			if err := s.sessionRepo.RevokeSession(sessionID); err != nil {
+				return err
+			}
+			if err := s.revocations.RevokeSession(context.Background(), sessionID, s.accessTTL); err != nil {
+				return err
+			}
+		}
+	}
+
+	if tokenID == "" {
+		return nil
+	}
+	return s.revocations.Revoke(context.Background(), tokenID, tokenExpiresAt)
+}
+
+// LogoutAll ends every session of the user and revokes all access tokens
+// issued so far.
+func (s *userService) LogoutAll(userID string) error {
+	if err := s.sessionRepo.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.revocations.RevokeUser(context.Background(), userID, tokens.Cutoff(time.Now()), s.accessTTL)
+}
+
+// UnlockLogin lifts a login lockout on the account.
+func (s *userService) UnlockLogin(userID string) error {
+	user, err := s.userRepo.GetByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+
+	return s.guard.Unlock(context.Background(), user.Email)
+}
+
+// rehashIfNeeded upgrades a stored hash made with an older algorithm or weaker
+// parameters while the plaintext is at hand. Failures only cost the upgrade.
+func (s *userService) rehashIfNeeded(user *models.User, plaintext string) {
+	if !s.hasher.NeedsRehash(user.Password) {
+		return
+	}
+
+	upgraded, err := s.hasher.Hash(plaintext)
+	if err == nil {
+		err = s.userRepo.UpdatePasswordHash(user.ID, user.Password, upgraded)
+	}
+	if err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Warn("Failed to upgrade password hash")
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	user.Password = upgraded
 }
 
-func (s *userService) generateRefreshToken(user *models.User) (string, error) {
+// revokeReusedFamily ends the session a replayed refresh token belongs to,
+// including the access tokens already handed out for it.
+func (s *userService) revokeReusedFamily(token *models.RefreshToken) error {
+	if err := s.sessionRepo.RevokeSession(token.SessionID); err != nil {
+		return err
+	}
+	if err := s.revocations.RevokeSession(context.Background(), token.SessionID, s.accessTTL); err != nil {
+		return err
+	}
+
+	s.events.Emit(models.SecurityEvent{
+		Type:       SecurityEventRefreshTokenReuse,
+		UserID:     token.UserID,
+		SessionID:  token.SessionID,
+		Details:    map[string]interface{}{"token_id": token.ID},
+		OccurredAt: time.Now(),
+	})
+
+	return ErrRefreshTokenReused
+}
+
+// generateAccessToken embeds the permissions of the user's role as the
+// space-separated scope claim, so routes and other services can authorize
+// without looking the role up.
+func (s *userService) generateAccessToken(user *models.User, sessionID string) (string, error) {
+	permissions, err := s.roles.PermissionsForRole(context.Background(), user.Role)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
 	claims := jwt.MapClaims{
-		"sub":  user.ID,
-		"type": "refresh",
-		"exp":  time.Now().Add(30 * 24 * time.Hour).Unix(), // 30 days
-		"iat":  time.Now().Unix(),
-		"jti":  uuid.New().String(),
+		"sub":                      user.ID,
+		"email":                    user.Email,
+		"role":                     user.Role,
+		"scope":                    strings.Join(permissions, " "),
+		"type":                     "access",
+		"sid":                      sessionID,
+		"jti":                      uuid.New().String(),
+		"exp":                      now.Add(s.accessTTL).Unix(),
+		"iat":                      now.Unix(),
+		tokens.IssuedAtMicrosClaim: now.UnixMicro(),
+	}
+
+	return s.signToken(claims)
+}
+
+// generateRefreshToken returns an opaque random token. Only its SHA-256 is
+// stored, so a database leak doesn't expose usable tokens.
+func generateRefreshToken() (string, error) {
+	raw := make([]byte, signedTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// signToken signs with the current key and sets the kid header so verifiers
+// can pick the matching public key from the JWKS.
+func (s *userService) signToken(claims jwt.MapClaims) (string, error) {
+	key, err := s.keys.SigningKey()
+	if err != nil {
+		return "", err
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	token := jwt.NewWithClaims(key.SigningMethod(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// optional maps an omitted value to NULL.
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
 }
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/mailer"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+var ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+
+type VerificationService interface {
+	IssueToken(user *models.User) error
+	VerifyEmail(token string) error
+	ResendVerification(email string) error
+}
+
+type verificationService struct {
+	userRepo         repository.UserRepository
+	verificationRepo repository.VerificationRepository
+	mailer           mailer.Mailer
+	appBaseURL       string
+	secret           []byte
+	tokenTTL         time.Duration
+	resendDelay      time.Duration
+}
+
+func NewVerificationService(userRepo repository.UserRepository, verificationRepo repository.VerificationRepository, m mailer.Mailer, cfg config.AuthConfig, mailCfg config.MailConfig) VerificationService {
+	return &verificationService{
+		userRepo:         userRepo,
+		verificationRepo: verificationRepo,
+		mailer:           m,
+		appBaseURL:       mailCfg.AppBaseURL,
+		secret:           []byte(cfg.TokenSigningSecret),
+		tokenTTL:         time.Duration(cfg.VerificationTokenTTL) * time.Hour,
+		resendDelay:      time.Duration(cfg.VerificationResendDelay) * time.Second,
+	}
+}
+
+func (s *verificationService) IssueToken(user *models.User) error {
+	if user.IsVerified {
+		return nil
+	}
+
+	// Only the most recently issued link stays valid.
+	if err := s.verificationRepo.DeleteUnusedByUserID(user.ID); err != nil {
+		return err
+	}
+
+	token, hash, err := newSignedToken(s.secret)
+	if err != nil {
+		return err
+	}
+
+	record := &models.EmailVerificationToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.tokenTTL),
+	}
+
+	if err := s.verificationRepo.CreateToken(record); err != nil {
+		return err
+	}
+
+	link := actionLink(s.appBaseURL, "/verify-email", token)
+	return s.mailer.Send(verificationEmail(user, link, s.tokenTTL))
+}
+
+func (s *verificationService) VerifyEmail(token string) error {
+	hash, ok := verifySignedToken(s.secret, token)
+	if !ok {
+		return ErrInvalidVerificationToken
+	}
+
+	userID, err := s.verificationRepo.ConsumeToken(hash)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return ErrInvalidVerificationToken
+	}
+
+	return nil
+}
+
+// ResendVerification issues a new token for an unverified account. Unknown,
+// already verified or throttled addresses are silently ignored so the endpoint
+// can't be used to probe which emails are registered. For the same reason a
+// failure to send is only logged.
+func (s *verificationService) ResendVerification(email string) error {
+	user, err := s.userRepo.GetByEmail(email)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.IsActive || user.IsVerified {
+		return nil
+	}
+
+	if err := s.resend(user); err != nil {
+		logrus.WithError(err).WithField("user_id", user.ID).Error("Failed to resend verification email")
+	}
+	return nil
+}
+
+func (s *verificationService) resend(user *models.User) error {
+	latest, err := s.verificationRepo.GetLatestByUserID(user.ID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && time.Since(latest.CreatedAt) < s.resendDelay {
+		return nil
+	}
+
+	return s.IssueToken(user)
+}
@@ -0,0 +1,237 @@
+package services
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/url"
+
+	"user-service/internal/config"
+	"user-service/internal/models"
+	"user-service/internal/repository"
+	"user-service/internal/webhooks"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWebhookNotFound         = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	ErrInvalidWebhookURL       = errors.New("webhook url must be an absolute https url on a public host")
+)
+
+// webhookSecretPrefix marks secrets so they are recognisable when leaked.
+const webhookSecretPrefix = "whsec_"
+
+// WebhookService lets administrators manage partner webhook subscriptions,
+// inspect their deliveries and send deliveries again.
+type WebhookService interface {
+	CreateSubscription(req *models.CreateWebhookRequest) (*models.CreatedWebhookSubscription, error)
+	ListSubscriptions(page models.PageQuery) ([]*models.WebhookSubscription, models.Pagination, error)
+	GetSubscription(id string) (*models.WebhookSubscription, error)
+	UpdateSubscription(id string, req *models.UpdateWebhookRequest) (*models.WebhookSubscription, error)
+	DeleteSubscription(id string) error
+	ListDeliveries(subscriptionID string, page models.PageQuery) ([]*models.WebhookDelivery, models.Pagination, error)
+	GetDelivery(subscriptionID, deliveryID string) (*models.WebhookDelivery, error)
+	ReplayDelivery(subscriptionID, deliveryID string) error
+}
+
+type webhookService struct {
+	webhookRepo  repository.WebhookRepository
+	allowPrivate bool
+}
+
+func NewWebhookService(webhookRepo repository.WebhookRepository, cfg config.WebhooksConfig) WebhookService {
+	return &webhookService{webhookRepo: webhookRepo, allowPrivate: cfg.AllowPrivateTargets}
+}
+
+// CreateSubscription registers an endpoint with a fresh signing secret,
+// which is returned only here.
+func (s *webhookService) CreateSubscription(req *models.CreateWebhookRequest) (*models.CreatedWebhookSubscription, error) {
+	if !s.validURL(req.URL) {
+		return nil, ErrInvalidWebhookURL
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	sub := &models.WebhookSubscription{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      uniqueEvents(req.Events),
+		Secret:      secret,
+	}
+	if err := s.webhookRepo.CreateSubscription(sub); err != nil {
+		return nil, err
+	}
+
+	return &models.CreatedWebhookSubscription{WebhookSubscription: *sub, Secret: secret}, nil
+}
+
+func (s *webhookService) ListSubscriptions(page models.PageQuery) ([]*models.WebhookSubscription, models.Pagination, error) {
+	page.Normalize()
+	subs, total, err := s.webhookRepo.ListSubscriptions(page.Limit, page.Offset())
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if subs == nil {
+		subs = []*models.WebhookSubscription{}
+	}
+	return subs, models.NewPagination(page, total), nil
+}
+
+func (s *webhookService) GetSubscription(id string) (*models.WebhookSubscription, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrWebhookNotFound
+	}
+
+	sub, err := s.webhookRepo.GetSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrWebhookNotFound
+	}
+	return sub, nil
+}
+
+// UpdateSubscription applies the non-nil fields of req. Re-enabling a
+// subscription resets its failure count; deliveries it missed while disabled
+// are not sent.
+func (s *webhookService) UpdateSubscription(id string, req *models.UpdateWebhookRequest) (*models.WebhookSubscription, error) {
+	sub, err := s.GetSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		if !s.validURL(*req.URL) {
+			return nil, ErrInvalidWebhookURL
+		}
+		sub.URL = *req.URL
+	}
+	if req.Description != nil {
+		sub.Description = *req.Description
+	}
+	if req.Events != nil {
+		sub.Events = uniqueEvents(req.Events)
+	}
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+
+	if err := s.webhookRepo.UpdateSubscription(sub); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *webhookService) DeleteSubscription(id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrWebhookNotFound
+	}
+
+	deleted, err := s.webhookRepo.DeleteSubscription(id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+func (s *webhookService) ListDeliveries(subscriptionID string, page models.PageQuery) ([]*models.WebhookDelivery, models.Pagination, error) {
+	if _, err := s.GetSubscription(subscriptionID); err != nil {
+		return nil, models.Pagination{}, err
+	}
+
+	page.Normalize()
+	deliveries, total, err := s.webhookRepo.ListDeliveries(subscriptionID, page.Limit, page.Offset())
+	if err != nil {
+		return nil, models.Pagination{}, err
+	}
+	if deliveries == nil {
+		deliveries = []*models.WebhookDelivery{}
+	}
+	return deliveries, models.NewPagination(page, total), nil
+}
+
+// GetDelivery returns a delivery with the log of its attempts.
+func (s *webhookService) GetDelivery(subscriptionID, deliveryID string) (*models.WebhookDelivery, error) {
+	if _, err := s.GetSubscription(subscriptionID); err != nil {
+		return nil, err
+	}
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	delivery, err := s.webhookRepo.GetDelivery(subscriptionID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if delivery == nil {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+	return delivery, nil
+}
+
+// ReplayDelivery sends a delivery again, whatever its state, with a fresh set
+// of attempts. The payload is unchanged, so receivers that already processed
+// it can recognise the eventId.
+func (s *webhookService) ReplayDelivery(subscriptionID, deliveryID string) error {
+	if _, err := s.GetSubscription(subscriptionID); err != nil {
+		return err
+	}
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return ErrWebhookDeliveryNotFound
+	}
+
+	replayed, err := s.webhookRepo.Replay(subscriptionID, deliveryID)
+	if err != nil {
+		return err
+	}
+	if !replayed {
+		return ErrWebhookDeliveryNotFound
+	}
+	return nil
+}
+
+// validURL accepts https endpoints whose host isn't obviously internal. The
+// dispatcher checks the resolved address again on every connection.
+func (s *webhookService) validURL(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	if s.allowPrivate {
+		return u.Scheme == "http" || u.Scheme == "https"
+	}
+	return u.Scheme == "https" && webhooks.PublicHost(u.Hostname())
+}
+
+func uniqueEvents(eventTypes []string) []string {
+	seen := make(map[string]bool, len(eventTypes))
+	unique := make([]string, 0, len(eventTypes))
+	for _, eventType := range eventTypes {
+		if !seen[eventType] {
+			seen[eventType] = true
+			unique = append(unique, eventType)
+		}
+	}
+	return unique
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(b), nil
+}
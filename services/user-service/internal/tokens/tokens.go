@@ -0,0 +1,199 @@
+// Package tokens verifies the access tokens this service issues. The HTTP
+// auth middleware, token introspection and the gRPC interceptors all go
+// through Validator, so every entry point accepts exactly the same tokens.
+package tokens
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"user-service/internal/keystore"
+	"user-service/internal/metrics"
+	"user-service/internal/revocation"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// IssuedAtMicrosClaim holds when an access token was issued, in microseconds
+// since the Unix epoch. iat only has second precision, too coarse to tell
+// whether a token came before or after a revocation made in the same second.
+const IssuedAtMicrosClaim = "iat_us"
+
+// Cutoff is the revocation cut-off for a change made at t: tokens issued at
+// or before it predate the change, tokens issued afterwards don't. Every
+// revocation by issue time goes through it so they agree on precision.
+func Cutoff(t time.Time) time.Time {
+	return t.Truncate(time.Microsecond)
+}
+
+// PasswordChanges reports when a user last changed their password, or the
+// zero time if they never have.
+type PasswordChanges interface {
+	PasswordChangedAt(ctx context.Context, userID string) (time.Time, error)
+}
+
+// Claims are the claims of a verified access token. Permissions are the
+// scopes of the token; they are nil for tokens issued before permissions
+// were embedded, which carry no scope claim.
+type Claims struct {
+	UserID      string
+	Email       string
+	Role        string
+	Scope       string
+	Permissions []string
+	SessionID   string
+	TokenID     string
+	IssuedAt    time.Time
+	ExpiresAt   time.Time
+}
+
+// Error explains why Validate rejected a token. Reason is a short label such
+// as "expired" or "revoked"; Code and Message are what clients see.
+type Error struct {
+	Reason  string
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Validator checks access tokens. Tokens are matched to their verification
+// key through the kid header and rejected once revoked by logout, account or
+// role changes, or when they predate the user's last password change.
+type Validator struct {
+	keys        keystore.Resolver
+	revocations revocation.Store
+	passwords   PasswordChanges
+}
+
+func NewValidator(keys keystore.Resolver, revocations revocation.Store, passwords PasswordChanges) *Validator {
+	return &Validator{keys: keys, revocations: revocations, passwords: passwords}
+}
+
+// Validate verifies an access token and returns its claims. Rejections are
+// *Error values; anything else is a failure to reach the revocation or
+// password stores, and says nothing about the token.
+func (v *Validator) Validate(ctx context.Context, tokenString string) (*Claims, error) {
+	token, err := jwt.Parse(tokenString, v.keyFunc,
+		jwt.WithValidMethods([]string{keystore.AlgRS256, keystore.AlgEdDSA}))
+
+	if err != nil || !token.Valid || !isAccessToken(token) {
+		return nil, reject(parseFailureReason(err), "UNAUTHORIZED", "Invalid or expired token")
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	userID, _ := claims.GetSubject()
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+	scope, hasScope := claims["scope"].(string)
+	sessionID, _ := claims["sid"].(string)
+	tokenID, _ := claims["jti"].(string)
+	iat, _ := claims.GetIssuedAt()
+	expiresAt, _ := claims.GetExpirationTime()
+
+	if tokenID == "" || iat == nil || expiresAt == nil {
+		return nil, reject("invalid", "UNAUTHORIZED", "Invalid or expired token")
+	}
+	issuedAt := issueTime(claims, iat.Time)
+
+	revoked, err := v.revocations.IsRevoked(ctx, revocation.Token{
+		ID:        tokenID,
+		UserID:    userID,
+		SessionID: sessionID,
+		Role:      role,
+		IssuedAt:  issuedAt,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("check revocation: %w", err)
+	}
+	if revoked {
+		return nil, reject("revoked", "TOKEN_REVOKED", "Token has been revoked")
+	}
+
+	changedAt, err := v.passwords.PasswordChangedAt(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("look up password change: %w", err)
+	}
+	if !changedAt.IsZero() && !issuedAt.After(Cutoff(changedAt)) {
+		return nil, reject("password_changed", "TOKEN_REVOKED", "Token was issued before the last password change")
+	}
+
+	var permissions []string
+	if hasScope {
+		permissions = append([]string{}, strings.Fields(scope)...)
+	}
+
+	return &Claims{
+		UserID:      userID,
+		Email:       email,
+		Role:        role,
+		Scope:       scope,
+		Permissions: permissions,
+		SessionID:   sessionID,
+		TokenID:     tokenID,
+		IssuedAt:    issuedAt,
+		ExpiresAt:   expiresAt.Time,
+	}, nil
+}
+
+// issueTime reads the precise issue time, falling back to iat for tokens
+// issued without it. Those count as issued at the start of their second, so
+// a cut-off in that second still covers them.
+func issueTime(claims jwt.MapClaims, iat time.Time) time.Time {
+	if micros, ok := claims[IssuedAtMicrosClaim].(float64); ok {
+		issuedAt := time.UnixMicro(int64(micros))
+		if issuedAt.Truncate(time.Second).Equal(iat) {
+			return issuedAt
+		}
+	}
+	return iat
+}
+
+func reject(reason, code, message string) *Error {
+	metrics.TokenValidationFailures.WithLabelValues(reason).Inc()
+	return &Error{Reason: reason, Code: code, Message: message}
+}
+
+func (v *Validator) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, keystore.ErrUnknownKey
+	}
+
+	key, err := v.keys.VerificationKey(kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// A key only verifies tokens made with its own algorithm.
+	if token.Method.Alg() != key.Algorithm {
+		return nil, jwt.ErrSignatureInvalid
+	}
+
+	return key.Public, nil
+}
+
+// parseFailureReason labels a token rejected after parsing; a nil err means
+// the token verified but is not an access token.
+func parseFailureReason(err error) string {
+	switch {
+	case err == nil:
+		return "wrong_type"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, jwt.ErrTokenSignatureInvalid), errors.Is(err, jwt.ErrSignatureInvalid), errors.Is(err, keystore.ErrUnknownKey):
+		return "bad_signature"
+	default:
+		return "invalid"
+	}
+}
+
+func isAccessToken(token *jwt.Token) bool {
+	claims, ok := token.Claims.(jwt.MapClaims)
+	return ok && claims["type"] == "access"
+}
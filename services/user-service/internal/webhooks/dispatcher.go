@@ -0,0 +1,229 @@
+// Package webhooks sends user events to partner endpoints. Repositories queue
+// a delivery per subscribed endpoint in the same transaction as the user
+// change; the dispatcher POSTs it, signed with the subscription's secret,
+// and retries with exponential back-off until it succeeds or MaxAttempts is
+// reached. A subscription that keeps failing is disabled until an
+// administrator re-enables it.
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"user-service/internal/buildinfo"
+	"user-service/internal/config"
+	"user-service/internal/metrics"
+	"user-service/internal/models"
+	"user-service/internal/outbox"
+	"user-service/internal/repository"
+
+	"github.com/sirupsen/logrus"
+)
+
+// purgeInterval is how often finished deliveries past retention are deleted.
+const purgeInterval = time.Hour
+
+// maxResponseBody bounds how much of a response is read before the
+// connection is reused.
+const maxResponseBody = 64 << 10
+
+// Dispatcher delivers queued webhook deliveries.
+type Dispatcher struct {
+	repo         repository.WebhookRepository
+	client       *http.Client
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	disableAfter int
+	backoff      outbox.Backoff
+	lease        time.Duration
+	retention    time.Duration
+}
+
+func NewDispatcher(repo repository.WebhookRepository, cfg config.WebhooksConfig) *Dispatcher {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	return &Dispatcher{
+		repo: repo,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: newTransport(cfg.AllowPrivateTargets, timeout),
+			// A redirect is answered, not followed: the endpoint must be
+			// updated on the subscription instead.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		pollInterval: time.Duration(cfg.PollInterval) * time.Millisecond,
+		batchSize:    cfg.BatchSize,
+		maxAttempts:  cfg.MaxAttempts,
+		disableAfter: cfg.DisableAfter,
+		backoff: outbox.Backoff{
+			Base: time.Duration(cfg.RetryBaseDelay) * time.Second,
+			Max:  time.Duration(cfg.RetryMaxDelay) * time.Second,
+		},
+		// Long enough for a whole batch to time out before another
+		// dispatcher may pick the deliveries up.
+		lease:     timeout + time.Minute,
+		retention: time.Duration(cfg.Retention) * time.Hour,
+	}
+}
+
+// Run dispatches deliveries until ctx is cancelled. A full batch is followed
+// by the next one straight away, so a backlog drains without waiting for the
+// timer.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+
+	for {
+		for {
+			claimed, err := d.DispatchBatch(ctx)
+			if err != nil {
+				logrus.WithError(err).Error("Failed to claim webhook deliveries")
+			}
+			if err != nil || claimed < d.batchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if time.Since(lastPurge) >= purgeInterval {
+			lastPurge = time.Now()
+			if deleted, err := d.repo.DeleteFinishedBefore(lastPurge.Add(-d.retention)); err != nil {
+				logrus.WithError(err).Warn("Failed to delete finished webhook deliveries")
+			} else if deleted > 0 {
+				logrus.WithField("deleted", deleted).Info("Deleted webhook deliveries past retention")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchBatch sends one batch of due deliveries concurrently and returns
+// how many it claimed. Deliveries are not ordered; receivers should order
+// by the envelope's timestamp and dedupe on its eventId.
+func (d *Dispatcher) DispatchBatch(ctx context.Context) (int, error) {
+	claimed, err := d.repo.ClaimDue(d.batchSize, d.lease)
+	if err != nil {
+		return 0, err
+	}
+
+	subscriptions := make(map[string]*models.WebhookSubscription)
+	var wg sync.WaitGroup
+	for _, delivery := range claimed {
+		sub, ok := subscriptions[delivery.SubscriptionID]
+		if !ok {
+			if sub, err = d.repo.GetSubscription(delivery.SubscriptionID); err != nil {
+				logrus.WithError(err).WithField("subscription_id", delivery.SubscriptionID).Error("Failed to load webhook subscription")
+			}
+			subscriptions[delivery.SubscriptionID] = sub
+		}
+		// Deleted meanwhile, or unreadable: the lease runs out and the next
+		// claim decides again.
+		if sub == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer wg.Done()
+			d.deliver(ctx, sub, delivery)
+		}(delivery)
+	}
+	wg.Wait()
+
+	return len(claimed), nil
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	log := logrus.WithFields(logrus.Fields{
+		"delivery_id":     delivery.ID,
+		"subscription_id": sub.ID,
+		"event_type":      delivery.EventType,
+	})
+
+	started := time.Now()
+	statusCode, err := d.send(ctx, sub, delivery)
+	if err != nil && ctx.Err() != nil {
+		// Shutting down: not the endpoint's fault. The delivery is sent
+		// again once its lease runs out.
+		return
+	}
+
+	attempt := &models.WebhookAttempt{
+		DurationMs:  time.Since(started).Milliseconds(),
+		AttemptedAt: started,
+	}
+	if statusCode != 0 {
+		attempt.StatusCode = &statusCode
+	}
+
+	if err == nil {
+		if err := d.repo.MarkDelivered(delivery.ID, attempt); err != nil {
+			// It stays pending and is sent again; receivers dedupe.
+			log.WithError(err).Warn("Delivered webhook could not be marked as delivered")
+		}
+		metrics.WebhookDeliveries.WithLabelValues(metrics.WebhookDelivered).Inc()
+		return
+	}
+
+	message := err.Error()
+	attempt.Error = &message
+	failures := delivery.Attempts + 1
+
+	var nextAttemptAt *time.Time
+	outcome := metrics.WebhookFailed
+	if failures < d.maxAttempts {
+		next := time.Now().Add(d.backoff.DelayAfter(failures))
+		nextAttemptAt = &next
+		outcome = metrics.WebhookRetried
+		log.WithError(err).WithFields(logrus.Fields{"attempts": failures, "retry_at": next}).Warn("Webhook delivery failed")
+	} else {
+		log.WithError(err).WithField("attempts", failures).Error("Webhook delivery failed for the last time")
+	}
+	metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+
+	disabled, err := d.repo.MarkFailed(delivery.ID, attempt, nextAttemptAt, d.disableAfter)
+	if err != nil {
+		log.WithError(err).Error("Failed to record webhook delivery failure")
+		return
+	}
+	if disabled {
+		metrics.WebhookSubscriptionsDisabled.Inc()
+		log.WithField("url", sub.URL).Error("Webhook subscription disabled after persistent failures")
+	}
+}
+
+// send POSTs the delivery and returns the response status, or 0 when none
+// arrived. Only 2xx responses count as delivered.
+func (d *Dispatcher) send(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "user-service-webhooks/"+buildinfo.Version)
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenTarget is returned for endpoints on loopback, private,
+// link-local or otherwise internal addresses. Deliveries are signed and their
+// responses recorded, so such targets would let a subscription probe the
+// service's own network.
+var ErrForbiddenTarget = errors.New("webhook endpoint address is not public")
+
+// internalNetworks are ranges not covered by the net.IP predicates that are
+// still unreachable for partners: "this network", carrier-grade NAT (common
+// for cluster pod ranges) and benchmarking.
+var internalNetworks = mustParseCIDRs("0.0.0.0/8", "100.64.0.0/10", "198.18.0.0/15")
+
+// PublicAddress reports whether ip may receive webhook deliveries.
+func PublicAddress(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range internalNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// PublicHost reports whether host, as written in an endpoint URL, may be
+// public. Names are only judged by the dialer once they resolve.
+func PublicHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return PublicAddress(ip)
+	}
+	return true
+}
+
+// newTransport returns the transport deliveries are sent through. Unless
+// private targets are allowed, every connection is checked after DNS
+// resolution, so a name that later resolves to an internal address (DNS
+// rebinding) is refused as well. Proxies from the environment are not used,
+// since the check would only see the proxy.
+func newTransport(allowPrivate bool, timeout time.Duration) *http.Transport {
+	dialer := &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !PublicAddress(ip) {
+				return fmt.Errorf("%w: %s", ErrForbiddenTarget, host)
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS customer_spend;
+DROP TABLE IF EXISTS customer_stats;
+DROP TABLE IF EXISTS customer_orders;
+DROP TABLE IF EXISTS processed_events;
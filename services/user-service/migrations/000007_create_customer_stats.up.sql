@@ -0,0 +1,53 @@
+-- Events from other services already applied, so redeliveries are ignored
+CREATE TABLE IF NOT EXISTS processed_events (
+    event_id VARCHAR(128) PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    processed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Orders seen on order.placed, so cancellations and refunds can be undone
+-- against the amount that was counted
+CREATE TABLE IF NOT EXISTS customer_orders (
+    order_id VARCHAR(128) PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    total_amount NUMERIC(14, 2) NOT NULL,
+    refunded_amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    currency VARCHAR(3) NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'placed' CHECK (status IN ('placed', 'delivered', 'cancelled')),
+    placed_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Per-user aggregates; cancelled orders are not counted
+CREATE TABLE IF NOT EXISTS customer_stats (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    order_count INTEGER NOT NULL DEFAULT 0,
+    delivered_order_count INTEGER NOT NULL DEFAULT 0,
+    cancelled_order_count INTEGER NOT NULL DEFAULT 0,
+    first_order_at TIMESTAMP WITH TIME ZONE,
+    last_order_at TIMESTAMP WITH TIME ZONE,
+    updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Lifetime spend net of cancellations and refunds, per currency
+CREATE TABLE IF NOT EXISTS customer_spend (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    currency VARCHAR(3) NOT NULL,
+    amount NUMERIC(14, 2) NOT NULL DEFAULT 0,
+    PRIMARY KEY (user_id, currency)
+);
+
+CREATE INDEX IF NOT EXISTS idx_processed_events_processed_at ON processed_events(processed_at);
+CREATE INDEX IF NOT EXISTS idx_customer_orders_user_id ON customer_orders(user_id);
+
+DROP TRIGGER IF EXISTS update_customer_orders_updated_at ON customer_orders;
+CREATE TRIGGER update_customer_orders_updated_at
+    BEFORE UPDATE ON customer_orders
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+DROP TRIGGER IF EXISTS update_customer_stats_updated_at ON customer_stats;
+CREATE TRIGGER update_customer_stats_updated_at
+    BEFORE UPDATE ON customer_stats
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
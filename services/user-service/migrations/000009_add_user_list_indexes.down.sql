@@ -0,0 +1,5 @@
+-- pg_trgm is left installed; other schemas may use it
+DROP INDEX IF EXISTS idx_users_role;
+DROP INDEX IF EXISTS idx_users_updated_at_id;
+DROP INDEX IF EXISTS idx_users_created_at_id;
+DROP INDEX IF EXISTS idx_users_search;
@@ -0,0 +1,10 @@
+-- Admin user list: trigram index for case-insensitive fragment search (the
+-- expression must match userSearchExpression in the user repository) and
+-- indexes backing the default sort and keyset pagination
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_users_search ON users
+    USING GIN ((email || ' ' || username || ' ' || first_name || ' ' || last_name) gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_created_at_id ON users(created_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_updated_at_id ON users(updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_users_role ON users(role);
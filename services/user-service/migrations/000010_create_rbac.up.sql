@@ -0,0 +1,62 @@
+-- Role-based access control. Routes check permissions, never role names;
+-- roles only group permissions and access tokens carry the permissions of
+-- the user's role as scopes. is_system marks the built-in roles, which
+-- cannot be deleted
+CREATE TABLE IF NOT EXISTS roles (
+    name VARCHAR(20) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT '',
+    is_system BOOLEAN NOT NULL DEFAULT false,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+DROP TRIGGER IF EXISTS update_roles_updated_at ON roles;
+CREATE TRIGGER update_roles_updated_at
+    BEFORE UPDATE ON roles
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Permissions are defined by the code that checks them, so they are only
+-- added by migrations
+CREATE TABLE IF NOT EXISTS permissions (
+    name VARCHAR(50) PRIMARY KEY,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+    role VARCHAR(20) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission VARCHAR(50) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+INSERT INTO roles (name, description, is_system) VALUES
+    ('user', 'Customer account', true),
+    ('moderator', 'Support staff who can look up customer accounts', true),
+    ('admin', 'Full access, including role management', true)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('profile:read', 'Read your own profile and sessions'),
+    ('profile:write', 'Change your own profile, password, MFA and sessions'),
+    ('users:read', 'List and look up user accounts'),
+    ('users:write', 'Change other user accounts, such as lifting a login lockout'),
+    ('roles:manage', 'Manage roles and assign them to users'),
+    ('events:manage', 'Inspect and requeue undelivered outbox events'),
+    ('webhooks:manage', 'Manage partner webhook subscriptions')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('user', 'profile:read'),
+    ('user', 'profile:write'),
+    ('moderator', 'profile:read'),
+    ('moderator', 'profile:write'),
+    ('moderator', 'users:read')
+ON CONFLICT DO NOTHING;
+
+-- admin always holds every permission
+INSERT INTO role_permissions (role, permission)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS fk_users_role;
+ALTER TABLE users ADD CONSTRAINT fk_users_role FOREIGN KEY (role) REFERENCES roles(name);
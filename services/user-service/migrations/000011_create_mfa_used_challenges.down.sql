@@ -0,0 +1 @@
+DROP TABLE IF EXISTS mfa_used_challenges;
@@ -0,0 +1,9 @@
+-- Login MFA challenges are single use: the jti is recorded on the first
+-- verification attempt. Rows are only needed until the challenge expires.
+CREATE TABLE IF NOT EXISTS mfa_used_challenges (
+    jti UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_mfa_used_challenges_expires_at ON mfa_used_challenges(expires_at);
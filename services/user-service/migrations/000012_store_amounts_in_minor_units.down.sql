@@ -0,0 +1,13 @@
+ALTER TABLE customer_orders
+    ALTER COLUMN refunded_amount DROP DEFAULT,
+    ALTER COLUMN total_amount TYPE NUMERIC(14, 2)
+        USING total_amount::NUMERIC / CASE currency WHEN 'VND' THEN 1 ELSE 100 END,
+    ALTER COLUMN refunded_amount TYPE NUMERIC(14, 2)
+        USING refunded_amount::NUMERIC / CASE currency WHEN 'VND' THEN 1 ELSE 100 END,
+    ALTER COLUMN refunded_amount SET DEFAULT 0;
+
+ALTER TABLE customer_spend
+    ALTER COLUMN amount DROP DEFAULT,
+    ALTER COLUMN amount TYPE NUMERIC(14, 2)
+        USING amount::NUMERIC / CASE currency WHEN 'VND' THEN 1 ELSE 100 END,
+    ALTER COLUMN amount SET DEFAULT 0;
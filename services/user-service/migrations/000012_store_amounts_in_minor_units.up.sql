@@ -0,0 +1,16 @@
+-- Amounts are integer minor units of their currency (cents, dong) so
+-- refunds and cancellations subtract exactly. VND has no minor unit; the
+-- other supported currencies have two decimals.
+ALTER TABLE customer_orders
+    ALTER COLUMN refunded_amount DROP DEFAULT,
+    ALTER COLUMN total_amount TYPE BIGINT
+        USING ROUND(total_amount * CASE currency WHEN 'VND' THEN 1 ELSE 100 END),
+    ALTER COLUMN refunded_amount TYPE BIGINT
+        USING ROUND(refunded_amount * CASE currency WHEN 'VND' THEN 1 ELSE 100 END),
+    ALTER COLUMN refunded_amount SET DEFAULT 0;
+
+ALTER TABLE customer_spend
+    ALTER COLUMN amount DROP DEFAULT,
+    ALTER COLUMN amount TYPE BIGINT
+        USING ROUND(amount * CASE currency WHEN 'VND' THEN 1 ELSE 100 END),
+    ALTER COLUMN amount SET DEFAULT 0;
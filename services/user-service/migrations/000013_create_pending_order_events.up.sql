@@ -0,0 +1,15 @@
+-- Delivered, cancelled and refund events that arrived before the
+-- order.placed of their order. Each event type has its own stream, so this
+-- happens whenever order.placed waits for a retry. They are not in
+-- processed_events yet and are applied when the order is placed.
+CREATE TABLE IF NOT EXISTS pending_order_events (
+    event_id VARCHAR(128) PRIMARY KEY,
+    event_type VARCHAR(64) NOT NULL,
+    order_id VARCHAR(128) NOT NULL,
+    currency VARCHAR(3) NOT NULL DEFAULT '',
+    amount BIGINT NOT NULL DEFAULT 0,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_order_events_order_id ON pending_order_events(order_id);
+CREATE INDEX IF NOT EXISTS idx_pending_order_events_received_at ON pending_order_events(received_at);
@@ -0,0 +1 @@
+ALTER TABLE jwt_signing_keys DROP COLUMN IF EXISTS activates_at;
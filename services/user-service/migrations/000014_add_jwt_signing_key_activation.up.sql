@@ -0,0 +1,10 @@
+-- A rotated-in key is published in JWKS before it signs, so verifiers that
+-- cache the key set know it by the time tokens carry its kid. Existing keys
+-- have been signing since they were created.
+ALTER TABLE jwt_signing_keys ADD COLUMN IF NOT EXISTS activates_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE jwt_signing_keys SET activates_at = COALESCE(created_at, CURRENT_TIMESTAMP) WHERE activates_at IS NULL;
+
+ALTER TABLE jwt_signing_keys
+    ALTER COLUMN activates_at SET DEFAULT CURRENT_TIMESTAMP,
+    ALTER COLUMN activates_at SET NOT NULL;
@@ -0,0 +1,2 @@
+-- Nothing to restore: NULL was already how cleared fields were stored.
+SELECT 1;
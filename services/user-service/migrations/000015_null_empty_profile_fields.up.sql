@@ -0,0 +1,4 @@
+-- An empty phone or avatar URL means the same as none; keep NULL as the only
+-- representation of a missing value.
+UPDATE users SET phone = NULL WHERE phone = '';
+UPDATE users SET avatar_url = NULL WHERE avatar_url = '';
@@ -0,0 +1,2 @@
+-- Encrypted secrets don't fit the old width, so the column stays wider.
+SELECT 1;
@@ -0,0 +1,3 @@
+-- TOTP secrets are stored encrypted ("v1:" + base64 of nonce, ciphertext and
+-- tag), which no longer fits the plaintext base32 width.
+ALTER TABLE user_mfa ALTER COLUMN secret TYPE VARCHAR(255);
@@ -0,0 +1,11 @@
+// Package migrations holds the versioned SQL migrations for the user service
+// database. Each version has an NNNNNN_name.up.sql script and, where it can
+// be undone, a matching NNNNNN_name.down.sql script.
+package migrations
+
+import "embed"
+
+// FS contains every migration script, embedded into the binaries that run them.
+//
+//go:embed *.sql
+var FS embed.FS
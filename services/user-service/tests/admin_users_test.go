@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type userListResponse struct {
+	Data []struct {
+		ID       string `json:"id"`
+		Email    string `json:"email"`
+		Username string `json:"username"`
+	} `json:"data"`
+	Pagination struct {
+		Page       int    `json:"page"`
+		Limit      int    `json:"limit"`
+		Total      int    `json:"total"`
+		TotalPages int    `json:"totalPages"`
+		NextCursor string `json:"nextCursor"`
+	} `json:"pagination"`
+	Meta struct {
+		Timestamp string `json:"timestamp"`
+		Version   string `json:"version"`
+	} `json:"meta"`
+}
+
+func (r userListResponse) usernames() []string {
+	names := make([]string, len(r.Data))
+	for i, user := range r.Data {
+		names[i] = user.Username
+	}
+	return names
+}
+
+// setupUserList mounts the admin user list over five users created a day
+// apart, alice first.
+func setupUserList(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	users := newFakeUserRepository()
+	created := time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC)
+	for i, u := range []models.User{
+		{Email: "alice@example.com", Username: "alice", FirstName: "Alice", LastName: "Nguyen", Role: "admin", IsActive: true, IsVerified: true},
+		{Email: "bob@example.com", Username: "bob", FirstName: "Bob", LastName: "Tran", Role: "user", IsActive: true, IsVerified: true},
+		{Email: "carol@example.org", Username: "carol", FirstName: "Carol", LastName: "Le", Role: "moderator", IsActive: true},
+		{Email: "dave@example.org", Username: "dave", FirstName: "Dave", LastName: "NGUYEN", Role: "user"},
+		{Email: "erin@example.com", Username: "erin_100", FirstName: "Erin", LastName: "Pham", Role: "user", IsActive: true},
+	} {
+		user := u
+		require.NoError(t, users.Create(&user))
+		users.modify(user.ID, func(stored *models.User) {
+			stored.CreatedAt = created.AddDate(0, 0, i)
+			stored.UpdatedAt = stored.CreatedAt
+		})
+	}
+
+	userService := services.NewUserService(users, newFakeSessionRepository(), nil, noMFA{}, nil, nil, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	admin := handlers.NewAdminHandler(userService, nil)
+	router := gin.New()
+	router.GET("/admin/users", admin.ListUsers)
+	return router
+}
+
+func listUsers(t *testing.T, router *gin.Engine, query url.Values) (*httptest.ResponseRecorder, userListResponse) {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users?"+query.Encode(), nil))
+
+	var response userListResponse
+	if w.Code == http.StatusOK {
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w, response
+}
+
+func TestAdminListUsersDefaultsToNewestFirst(t *testing.T) {
+	router := setupUserList(t)
+
+	w, response := listUsers(t, router, url.Values{"limit": {"2"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"erin_100", "dave"}, response.usernames())
+	assert.Equal(t, 1, response.Pagination.Page)
+	assert.Equal(t, 2, response.Pagination.Limit)
+	assert.Equal(t, 5, response.Pagination.Total)
+	assert.Equal(t, 3, response.Pagination.TotalPages)
+	assert.NotEmpty(t, response.Pagination.NextCursor)
+	assert.Equal(t, "1.0", response.Meta.Version)
+	assert.NotEmpty(t, response.Meta.Timestamp)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	w, response = listUsers(t, router, url.Values{"limit": {"2"}, "page": {"3"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, []string{"alice"}, response.usernames())
+	assert.Empty(t, response.Pagination.NextCursor, "the last page has no cursor")
+}
+
+func TestAdminListUsersFilters(t *testing.T) {
+	router := setupUserList(t)
+
+	cases := []struct {
+		name  string
+		query url.Values
+		want  []string
+	}{
+		{"role", url.Values{"role": {"user"}}, []string{"erin_100", "dave", "bob"}},
+		{"inactive", url.Values{"is_active": {"false"}}, []string{"dave"}},
+		{"verified", url.Values{"is_verified": {"true"}, "sort": {"username"}, "order": {"asc"}}, []string{"alice", "bob"}},
+		{"created range", url.Values{"created_from": {"2026-01-02T09:00:00Z"}, "created_to": {"2026-01-04T09:00:00Z"}}, []string{"carol", "bob"}},
+		{"combined", url.Values{"role": {"user"}, "is_active": {"true"}}, []string{"erin_100", "bob"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, response := listUsers(t, router, tc.query)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, tc.want, response.usernames())
+			assert.Equal(t, len(tc.want), response.Pagination.Total)
+		})
+	}
+}
+
+func TestAdminListUsersSearch(t *testing.T) {
+	router := setupUserList(t)
+
+	cases := []struct {
+		search string
+		want   []string
+	}{
+		{"nguyen", []string{"dave", "alice"}},
+		{"EXAMPLE.ORG", []string{"dave", "carol"}},
+		{"Car", []string{"carol"}},
+		{"nobody", []string{}},
+		{"n_100", []string{"erin_100"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.search, func(t *testing.T) {
+			w, response := listUsers(t, router, url.Values{"q": {tc.search}})
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, tc.want, response.usernames())
+		})
+	}
+}
+
+func TestAdminListUsersKeysetPagination(t *testing.T) {
+	router := setupUserList(t)
+	query := url.Values{"sort": {"email"}, "order": {"asc"}, "limit": {"2"}}
+
+	var seen []string
+	for pages := 0; ; pages++ {
+		require.Less(t, pages, 5, "pagination did not end")
+		w, response := listUsers(t, router, query)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		for _, user := range response.Data {
+			seen = append(seen, user.Email)
+		}
+		if pages > 0 {
+			var raw map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &raw))
+			assert.NotContains(t, raw["pagination"], "total", "keyset pages skip the count")
+		}
+		if response.Pagination.NextCursor == "" {
+			break
+		}
+		query.Set("cursor", response.Pagination.NextCursor)
+	}
+
+	assert.Equal(t, []string{
+		"alice@example.com",
+		"bob@example.com",
+		"carol@example.org",
+		"dave@example.org",
+		"erin@example.com",
+	}, seen)
+}
+
+func TestAdminListUsersRejectsBadQueries(t *testing.T) {
+	router := setupUserList(t)
+
+	_, first := listUsers(t, router, url.Values{"limit": {"2"}})
+	require.NotEmpty(t, first.Pagination.NextCursor)
+
+	cases := []struct {
+		name   string
+		query  url.Values
+		status int
+	}{
+		{"cursor from another order", url.Values{"cursor": {first.Pagination.NextCursor}, "order": {"asc"}}, http.StatusBadRequest},
+		{"cursor from another sort", url.Values{"cursor": {first.Pagination.NextCursor}, "sort": {"email"}}, http.StatusBadRequest},
+		{"garbage cursor", url.Values{"cursor": {"not-a-cursor"}}, http.StatusBadRequest},
+		{"unknown sort", url.Values{"sort": {"password_hash"}}, http.StatusUnprocessableEntity},
+		{"role too long", url.Values{"role": {strings.Repeat("r", 21)}}, http.StatusUnprocessableEntity},
+		{"limit too large", url.Values{"limit": {"500"}}, http.StatusUnprocessableEntity},
+		{"malformed date", url.Values{"created_from": {"yesterday"}}, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, _ := listUsers(t, router, tc.query)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/keystore"
+	"user-service/internal/lockout"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/password"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const originalPassword = "0riginal-Passphrase"
+
+type changePasswordFixture struct {
+	keys      *keystore.StaticStore
+	users     services.UserService
+	passwords services.PasswordService
+	userID    string
+	router    *gin.Engine
+}
+
+func setupChangePassword(t *testing.T) *changePasswordFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+	keys := keystore.NewStaticStore(key)
+	revocations := revocation.NewMemoryStore()
+
+	users := newFakeUserRepository()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(originalPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{
+		Email:    "change@example.com",
+		Username: "change",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}
+	require.NoError(t, users.Create(user))
+
+	sessions := newFakeSessionRepository()
+	policy := newTestPasswordPolicy(t)
+	hasher := newTestHasher(t)
+	guard := newTestGuard()
+	userService := services.NewUserService(users, sessions, nil, noMFA{}, keys, revocations, guard, policy, hasher, &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwordService := services.NewPasswordService(users, newFakePasswordRepository(users, sessions), revocations, guard, policy, hasher, testAccessTTL)
+
+	auth := middleware.NewAuthMiddleware(tokens.NewValidator(keys, revocations, passwordService), seededPermissions{})
+	router := gin.New()
+	router.GET("/me", auth.RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.PUT("/password", auth.RequireAuth(), handlers.NewPasswordHandler(passwordService).ChangePassword)
+
+	return &changePasswordFixture{keys: keys, users: userService, passwords: passwordService, userID: user.ID, router: router}
+}
+
+func (f *changePasswordFixture) login(t *testing.T, pw string) *models.LoginResponse {
+	t.Helper()
+
+	login, _, err := f.users.Login(&models.LoginRequest{Email: "change@example.com", Password: pw}, models.ClientInfo{})
+	require.NoError(t, err)
+	return login
+}
+
+func (f *changePasswordFixture) change(t *testing.T, accessToken, current, next string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	body, err := json.Marshal(models.ChangePasswordRequest{CurrentPassword: current, NewPassword: next})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPut, "/password", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestChangePasswordRequiresCurrentPassword(t *testing.T) {
+	f := setupChangePassword(t)
+	login := f.login(t, originalPassword)
+
+	w := f.change(t, login.AccessToken, "wrong-Passphrase1", "n3w-Passphrase")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "INVALID_CURRENT_PASSWORD")
+
+	f.login(t, originalPassword)
+}
+
+func TestChangePasswordCountsWrongCurrentPasswords(t *testing.T) {
+	f := setupChangePassword(t)
+	login := f.login(t, originalPassword)
+
+	for i := 0; i < testLockoutPolicy.FreeAttempts; i++ {
+		w := f.change(t, login.AccessToken, "wrong-Passphrase1", "n3w-Passphrase")
+		require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	}
+
+	w := f.change(t, login.AccessToken, "wrong-Passphrase1", "n3w-Passphrase")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code, w.Body.String())
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// The wait applies to the right password and to signing in alike.
+	w = f.change(t, login.AccessToken, originalPassword, "n3w-Passphrase")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	var locked *lockout.LockedError
+	_, _, err := f.users.Login(&models.LoginRequest{Email: "change@example.com", Password: originalPassword}, models.ClientInfo{})
+	assert.ErrorAs(t, err, &locked)
+}
+
+func TestChangePasswordRevokesOtherSessionsAndOlderTokens(t *testing.T) {
+	f := setupChangePassword(t)
+	current := f.login(t, originalPassword)
+	other := f.login(t, originalPassword)
+
+	w := f.change(t, current.AccessToken, originalPassword, "n3w-Passphrase")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// Access tokens issued before the change stop working everywhere.
+	assert.Equal(t, http.StatusUnauthorized, authorizedRequest(f.router, http.MethodGet, "/me", current.AccessToken))
+	assert.Equal(t, http.StatusUnauthorized, authorizedRequest(f.router, http.MethodGet, "/me", other.AccessToken))
+
+	// Other sessions are signed out; the current one refreshes into a fresh token.
+	_, err := f.users.RefreshToken(other.RefreshToken, models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+
+	refreshed, err := f.users.RefreshToken(current.RefreshToken, models.ClientInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, authorizedRequest(f.router, http.MethodGet, "/me", refreshed.AccessToken))
+
+	f.login(t, "n3w-Passphrase")
+}
+
+func TestChangePasswordRejectsRecentPasswords(t *testing.T) {
+	f := setupChangePassword(t)
+	change := func(current, next string) error {
+		return f.passwords.ChangePassword(f.userID, "", &models.ChangePasswordRequest{CurrentPassword: current, NewPassword: next}, models.ClientInfo{})
+	}
+	assertReused := func(err error) {
+		t.Helper()
+		var policyErr *password.PolicyError
+		require.ErrorAs(t, err, &policyErr)
+		assert.Equal(t, []string{password.RuleReused}, rules(policyErr))
+	}
+
+	assertReused(change(originalPassword, originalPassword))
+
+	// The test policy keeps three previous passwords besides the current one.
+	history := []string{originalPassword, "f1rst-Passphrase", "s3cond-Passphrase", "th1rd-Passphrase", "f0urth-Passphrase"}
+	for i := 1; i < len(history); i++ {
+		require.NoError(t, change(history[i-1], history[i]))
+	}
+
+	for _, recent := range history[1:] {
+		assertReused(change("f0urth-Passphrase", recent))
+	}
+	assert.NoError(t, change("f0urth-Passphrase", originalPassword))
+}
+
+type unavailablePasswordChanges struct{}
+
+func (unavailablePasswordChanges) PasswordChangedAt(context.Context, string) (time.Time, error) {
+	return time.Time{}, errors.New("connection refused")
+}
+
+func TestPasswordChangeLookupFailureIsNotARejection(t *testing.T) {
+	f := setupChangePassword(t)
+	login := f.login(t, originalPassword)
+
+	key, err := f.keys.SigningKey()
+	require.NoError(t, err)
+	validator := tokens.NewValidator(keystore.NewStaticStore(key), revocation.NewMemoryStore(), unavailablePasswordChanges{})
+
+	_, err = validator.Validate(context.Background(), login.AccessToken)
+	require.Error(t, err)
+	var tokenErr *tokens.Error
+	assert.False(t, errors.As(err, &tokenErr), "an unreachable store says nothing about the token")
+
+	router := gin.New()
+	router.GET("/me", middleware.NewAuthMiddleware(validator, seededPermissions{}).RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	assert.Equal(t, http.StatusServiceUnavailable, authorizedRequest(router, http.MethodGet, "/me", login.AccessToken))
+
+	_, err = services.NewIntrospectionService(validator, newFakeUserRepository(), 0).Introspect(context.Background(), login.AccessToken)
+	assert.Error(t, err, "introspection reports an error instead of active=false")
+}
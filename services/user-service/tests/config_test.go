@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"user-service/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSigningSecret = "0123456789abcdef0123456789abcdef"
+	testEncryptionKey = "fedcba9876543210fedcba9876543210"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadConfigDefaults(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSigningSecret)
+	t.Setenv("MFA_ENCRYPTION_KEY", testEncryptionKey)
+
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+
+	defaults := config.Default()
+	assert.Equal(t, defaults.Database, cfg.Database)
+	assert.Equal(t, defaults.Server, cfg.Server)
+	// The mailed-token secret falls back to the JWT secret.
+	assert.Equal(t, testSigningSecret, cfg.Auth.TokenSigningSecret)
+}
+
+func TestLoadConfigLayersFileThenEnv(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  port: 9090
+  mode: release
+database:
+  host: db.internal
+  name: users
+  auto_migrate: true
+auth:
+  token_signing_secret: `+testSigningSecret+`
+mfa:
+  required_roles: [admin]
+  encryption_key: `+testEncryptionKey+`
+`)
+	t.Setenv("DB_HOST", "db.override")
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, "9090", cfg.Server.Port)
+	assert.Equal(t, "release", cfg.Server.Mode)
+	assert.Equal(t, "db.override", cfg.Database.Host, "env wins over the file")
+	assert.Equal(t, "users", cfg.Database.DBName)
+	assert.True(t, cfg.Database.AutoMigrate)
+	assert.Equal(t, []string{"admin"}, cfg.MFA.RequiredRoles)
+	assert.Equal(t, 5432, cfg.Database.Port, "unset values keep their default")
+}
+
+func TestLoadConfigTOML(t *testing.T) {
+	path := writeConfigFile(t, "config.toml", `
+[database]
+host = "toml-db"
+port = 6543
+
+[auth]
+token_signing_secret = "`+testSigningSecret+`"
+
+[mfa]
+encryption_key = "`+testEncryptionKey+`"
+`)
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "toml-db", cfg.Database.Host)
+	assert.Equal(t, 6543, cfg.Database.Port)
+}
+
+func TestLoadConfigReadsSecretFiles(t *testing.T) {
+	secret := writeConfigFile(t, "signing-secret", testSigningSecret+"\n")
+	password := writeConfigFile(t, "db-password", "s3cret with spaces\n")
+	t.Setenv("AUTH_TOKEN_SIGNING_SECRET_FILE", secret)
+	t.Setenv("DB_PASSWORD_FILE", password)
+	t.Setenv("MFA_ENCRYPTION_KEY", testEncryptionKey)
+
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	assert.Equal(t, testSigningSecret, cfg.Auth.TokenSigningSecret)
+	assert.Equal(t, "s3cret with spaces", cfg.Database.Password)
+}
+
+func TestLoadConfigTrustedProxies(t *testing.T) {
+	t.Setenv("JWT_SECRET", testSigningSecret)
+	t.Setenv("MFA_ENCRYPTION_KEY", testEncryptionKey)
+
+	cfg, err := config.Load("")
+	require.NoError(t, err)
+	assert.Empty(t, cfg.Server.TrustedProxies, "no proxy is trusted by default")
+
+	t.Setenv("SERVER_TRUSTED_PROXIES", "10.0.0.0/8,192.168.1.5")
+	cfg, err = config.Load("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.5"}, cfg.Server.TrustedProxies)
+
+	t.Setenv("SERVER_TRUSTED_PROXIES", "10.0.0.0/8,proxy.internal")
+	_, err = config.Load("")
+	assert.ErrorContains(t, err, `server.trusted_proxies: "proxy.internal" is not an IP address or CIDR`)
+}
+
+func TestLoadConfigReportsEveryProblem(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+server:
+  mode: production
+database:
+  hostname: typo
+`)
+	t.Setenv("DB_PORT", "not-a-number")
+	t.Setenv("REDIS_PASSWORD", "inline")
+	t.Setenv("REDIS_PASSWORD_FILE", writeConfigFile(t, "redis-password", "from-file"))
+
+	_, err := config.Load(path)
+
+	var validation *config.ValidationError
+	require.ErrorAs(t, err, &validation)
+	assert.Len(t, validation.Problems, 6, err.Error())
+	for _, expected := range []string{
+		"database.hostname: unknown setting",
+		"DB_PORT: \"not-a-number\" is not an integer",
+		"REDIS_PASSWORD and REDIS_PASSWORD_FILE are both set",
+		"server.mode must be debug, release or test",
+		"auth.token_signing_secret (or jwt.secret) must be at least 32 bytes",
+		"mfa.encryption_key must be at least 32 bytes",
+	} {
+		assert.Contains(t, err.Error(), expected)
+	}
+}
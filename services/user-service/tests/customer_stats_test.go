@@ -0,0 +1,290 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/events"
+	"user-service/internal/handlers"
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func orderEvent(t *testing.T, eventType string, data interface{}) *events.Envelope {
+	t.Helper()
+	event, err := events.New(eventType, data)
+	require.NoError(t, err)
+	event.Source = "order-service"
+	return event
+}
+
+func setupCustomerStats(t *testing.T) (services.CustomerStatsService, string) {
+	t.Helper()
+	users := newFakeUserRepository()
+	require.NoError(t, users.Create(&models.User{Email: "buyer@example.com", Username: "buyer", Role: "user", IsActive: true}))
+	buyer, _ := users.GetByEmail("buyer@example.com")
+	return services.NewCustomerStatsService(newFakeCustomerStatsRepository(users), time.Hour), buyer.ID
+}
+
+func TestCustomerStatsFollowOrderLifecycle(t *testing.T) {
+	stats, userID := setupCustomerStats(t)
+	ctx := context.Background()
+
+	placed := func(orderID string, amount json.Number) *events.Envelope {
+		return orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: orderID, UserID: userID, TotalAmount: amount, Currency: "VND", Status: "pending"})
+	}
+	first, second, third := placed("o1", "100000"), placed("o2", "50000"), placed("o3", "20000")
+	for _, event := range []*events.Envelope{first, second, third} {
+		require.NoError(t, stats.HandleEvent(ctx, event))
+	}
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderDelivered, events.OrderData{OrderID: "o1", UserID: userID})))
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderCancelled, events.OrderData{OrderID: "o2", UserID: userID})))
+	refund := orderEvent(t, events.PaymentRefunded, events.PaymentData{OrderID: "o3", UserID: userID, Amount: "5000", Currency: "VND", Status: "refunded"})
+	require.NoError(t, stats.HandleEvent(ctx, refund))
+
+	got, err := stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.OrderCount)
+	assert.Equal(t, 1, got.DeliveredOrderCount)
+	assert.Equal(t, 1, got.CancelledOrderCount)
+	assert.Equal(t, map[string]int64{"VND": 115000}, got.LifetimeSpend)
+	require.NotNil(t, got.LastOrderAt)
+	assert.True(t, got.LastOrderAt.Equal(third.Timestamp))
+	assert.True(t, got.FirstOrderAt.Equal(first.Timestamp))
+
+	// Redelivered events change nothing.
+	require.NoError(t, stats.HandleEvent(ctx, first))
+	require.NoError(t, stats.HandleEvent(ctx, refund))
+	again, err := stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Equal(t, got, again)
+}
+
+func TestCustomerStatsRefundOfCancelledOrderIsNotCountedTwice(t *testing.T) {
+	stats, userID := setupCustomerStats(t)
+	ctx := context.Background()
+
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o1", UserID: userID, TotalAmount: "30", Currency: "USD"})))
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o2", UserID: userID, TotalAmount: "70", Currency: "USD"})))
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderCancelled, events.OrderData{OrderID: "o1"})))
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.PaymentRefunded, events.PaymentData{OrderID: "o1", Amount: "30", Currency: "USD"})))
+	// A refund larger than the order only takes off the order.
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.PaymentRefunded, events.PaymentData{OrderID: "o2", Amount: "500", Currency: "USD"})))
+
+	got, err := stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.OrderCount)
+	assert.Equal(t, map[string]int64{"USD": 0}, got.LifetimeSpend)
+}
+
+func TestCustomerStatsSpendIsExact(t *testing.T) {
+	stats, userID := setupCustomerStats(t)
+	ctx := context.Background()
+
+	for i, amount := range []json.Number{"0.10", "0.20", "19.99"} {
+		order := events.OrderData{OrderID: fmt.Sprintf("o%d", i), UserID: userID, TotalAmount: amount, Currency: "USD"}
+		require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderPlaced, order)))
+	}
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.PaymentRefunded, events.PaymentData{OrderID: "o2", Amount: "9.99", Currency: "USD"})))
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderCancelled, events.OrderData{OrderID: "o2"})))
+	// A refund in another currency than the order's is ignored.
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.PaymentRefunded, events.PaymentData{OrderID: "o1", Amount: "20", Currency: "VND"})))
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.PaymentRefunded, events.PaymentData{OrderID: "o0", Amount: "0.1", Currency: "USD"})))
+
+	got, err := stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"USD": 20}, got.LifetimeSpend)
+}
+
+func TestCustomerStatsApplyEventsThatArriveBeforeTheirOrder(t *testing.T) {
+	stats, userID := setupCustomerStats(t)
+	ctx := context.Background()
+
+	// order.placed is still waiting for a retry while the other streams move on.
+	delivered := orderEvent(t, events.OrderDelivered, events.OrderData{OrderID: "o1"})
+	refund := orderEvent(t, events.PaymentRefunded, events.PaymentData{OrderID: "o1", Amount: "10", Currency: "USD"})
+	cancelled := orderEvent(t, events.OrderCancelled, events.OrderData{OrderID: "o2"})
+	for _, event := range []*events.Envelope{refund, delivered, cancelled, refund} {
+		require.NoError(t, stats.HandleEvent(ctx, event))
+	}
+
+	got, err := stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Zero(t, got.OrderCount)
+
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o1", UserID: userID, TotalAmount: "30", Currency: "USD"})))
+	require.NoError(t, stats.HandleEvent(ctx, orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o2", UserID: userID, TotalAmount: "50", Currency: "USD"})))
+	// Once applied, redeliveries are duplicates.
+	require.NoError(t, stats.HandleEvent(ctx, refund))
+	require.NoError(t, stats.HandleEvent(ctx, delivered))
+
+	got, err = stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.OrderCount)
+	assert.Equal(t, 1, got.DeliveredOrderCount)
+	assert.Equal(t, 1, got.CancelledOrderCount)
+	assert.Equal(t, map[string]int64{"USD": 2000}, got.LifetimeSpend)
+}
+
+func TestMinorUnits(t *testing.T) {
+	for _, tc := range []struct {
+		amount   json.Number
+		currency string
+		want     int64
+	}{
+		{"12.5", "USD", 1250},
+		{"0.07", "EUR", 7},
+		{"1e3", "USD", 100000},
+		{"150000", "VND", 150000},
+	} {
+		got, err := events.MinorUnits(tc.amount, tc.currency)
+		require.NoError(t, err, tc.amount)
+		assert.Equal(t, tc.want, got, tc.amount)
+	}
+
+	for _, tc := range []struct {
+		amount   json.Number
+		currency string
+	}{
+		{"0.001", "USD"},
+		{"1.5", "VND"},
+		{"-1", "USD"},
+		{"abc", "USD"},
+		{"10", "GBP"},
+		{"1e30", "VND"},
+	} {
+		_, err := events.MinorUnits(tc.amount, tc.currency)
+		assert.Error(t, err, "%s %s", tc.amount, tc.currency)
+	}
+}
+
+func TestCustomerStatsSkipEventsThatCannotApply(t *testing.T) {
+	stats, userID := setupCustomerStats(t)
+	ctx := context.Background()
+
+	for name, event := range map[string]*events.Envelope{
+		"unknown user": orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o1", UserID: uuid.NewString(), TotalAmount: "10", Currency: "USD"}),
+		"bad user id":  orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o2", UserID: "guest", TotalAmount: "10", Currency: "USD"}),
+		"no order id":  orderEvent(t, events.OrderPlaced, events.OrderData{UserID: userID, TotalAmount: "10", Currency: "USD"}),
+		"bad payload":  {EventID: uuid.NewString(), EventType: events.OrderPlaced, Data: json.RawMessage(`"oops"`)},
+		"unknown type": orderEvent(t, "order.teleported", events.OrderData{OrderID: "o3"}),
+		"sub-cent":     orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o4", UserID: userID, TotalAmount: "10.001", Currency: "USD"}),
+		"negative":     orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o5", UserID: userID, TotalAmount: "-10", Currency: "USD"}),
+		"currency":     orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o6", UserID: userID, TotalAmount: "10", Currency: "GBP"}),
+	} {
+		assert.NoError(t, stats.HandleEvent(ctx, event), name)
+	}
+
+	got, err := stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Zero(t, got.OrderCount)
+	assert.Empty(t, got.LifetimeSpend)
+}
+
+func TestRedisConsumerDeliversOnceToHandler(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	publisher := events.NewRedisPublisher(client, "events:", 0)
+	stats, userID := setupCustomerStats(t)
+
+	// The first attempt fails; the entry stays pending and is claimed again.
+	var calls atomic.Int32
+	handler := func(ctx context.Context, event *events.Envelope) error {
+		if calls.Add(1) == 1 {
+			return errors.New("database unavailable")
+		}
+		return stats.HandleEvent(ctx, event)
+	}
+
+	placed := orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o1", UserID: userID, TotalAmount: "42", Currency: "EUR"})
+	ctx := context.Background()
+	require.NoError(t, publisher.Publish(ctx, placed))
+	require.NoError(t, publisher.Publish(ctx, placed))
+	require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{Stream: "events:order.placed", Values: map[string]interface{}{"payload": "not json"}}).Err())
+
+	consumer := events.NewRedisConsumer(client, events.RedisConsumerOptions{
+		Group:     "user-service",
+		Consumer:  "test",
+		Streams:   []string{"events:order.placed", "events:order.cancelled"},
+		BatchSize: 10,
+		Block:     20 * time.Millisecond,
+		ClaimIdle: 50 * time.Millisecond,
+	}, handler)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		consumer.Run(runCtx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool {
+		pending, err := client.XPending(ctx, "events:order.placed", "user-service").Result()
+		return err == nil && pending.Count == 0 && calls.Load() >= 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	got, err := stats.GetStats(userID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.OrderCount)
+	assert.Equal(t, map[string]int64{"EUR": 4200}, got.LifetimeSpend)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("consumer did not stop")
+	}
+}
+
+func TestAdminGetUserIncludesStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := newFakeUserRepository()
+	require.NoError(t, users.Create(&models.User{Email: "buyer@example.com", Username: "buyer", Role: "user", IsActive: true}))
+	buyer, _ := users.GetByEmail("buyer@example.com")
+
+	statsService := services.NewCustomerStatsService(newFakeCustomerStatsRepository(users), time.Hour)
+	require.NoError(t, statsService.HandleEvent(context.Background(),
+		orderEvent(t, events.OrderPlaced, events.OrderData{OrderID: "o1", UserID: buyer.ID, TotalAmount: "12.5", Currency: "USD"})))
+
+	userService := services.NewUserService(users, newFakeSessionRepository(), nil, noMFA{}, nil, nil, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	admin := handlers.NewAdminHandler(userService, statsService)
+	router := gin.New()
+	router.GET("/admin/users/:id", admin.GetUser)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/"+buyer.ID, nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Data struct {
+			ID    string               `json:"id"`
+			Email string               `json:"email"`
+			Stats models.CustomerStats `json:"stats"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, buyer.ID, response.Data.ID)
+	assert.Equal(t, "buyer@example.com", response.Data.Email)
+	assert.Equal(t, 1, response.Data.Stats.OrderCount)
+	assert.Equal(t, map[string]int64{"USD": 1250}, response.Data.Stats.LifetimeSpend)
+	assert.NotContains(t, w.Body.String(), "password")
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/users/"+uuid.NewString(), nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,1640 @@
+package tests
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/events"
+	"user-service/internal/lockout"
+	"user-service/internal/models"
+	"user-service/internal/password"
+	"user-service/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUserRepository is an in-memory repository.UserRepository used by
+// service-level tests that need real state rather than mock expectations.
+type fakeUserRepository struct {
+	mu    sync.Mutex
+	users map[string]*models.User
+}
+
+func newFakeUserRepository() *fakeUserRepository {
+	return &fakeUserRepository{
+		users: make(map[string]*models.User),
+	}
+}
+
+func (r *fakeUserRepository) Create(user *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	user.ID = uuid.New().String()
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = user.CreatedAt
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *fakeUserRepository) find(match func(*models.User) bool) *models.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, u := range r.users {
+		if match(u) {
+			copied := *u
+			return &copied
+		}
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) GetByID(id string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.ID == id }), nil
+}
+
+func (r *fakeUserRepository) GetByEmail(email string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Email == email }), nil
+}
+
+func (r *fakeUserRepository) GetByUsername(username string) (*models.User, error) {
+	return r.find(func(u *models.User) bool { return u.Username == username }), nil
+}
+
+func (r *fakeUserRepository) GetByIDs(ids []string) ([]*models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*models.User
+	for _, id := range ids {
+		if u, ok := r.users[id]; ok {
+			copied := *u
+			users = append(users, &copied)
+		}
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepository) List(filter models.UserFilter, order models.UserOrder, after *models.UserKey, limit, offset int) ([]*models.User, error) {
+	users := r.matching(filter)
+	sort.Slice(users, func(i, j int) bool {
+		c := compareUserField(users[i], order.Field, userFieldValue(users[j], order.Field))
+		if c == 0 {
+			c = strings.Compare(users[i].ID, users[j].ID)
+		}
+		if order.Desc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	start := offset
+	if after != nil {
+		start = len(users)
+		for i, u := range users {
+			c := compareUserField(u, order.Field, after.Value)
+			if c == 0 {
+				c = strings.Compare(u.ID, after.ID)
+			}
+			if (order.Desc && c < 0) || (!order.Desc && c > 0) {
+				start = i
+				break
+			}
+		}
+	}
+	if start > len(users) {
+		start = len(users)
+	}
+	users = users[start:]
+	if len(users) > limit {
+		users = users[:limit]
+	}
+	return users, nil
+}
+
+func (r *fakeUserRepository) Count(filter models.UserFilter) (int, error) {
+	return len(r.matching(filter)), nil
+}
+
+// matching returns copies of the users that pass filter, mirroring the SQL
+// conditions of the real repository.
+func (r *fakeUserRepository) matching(filter models.UserFilter) []*models.User {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var users []*models.User
+	for _, u := range r.users {
+		text := strings.ToLower(u.Email + " " + u.Username + " " + u.FirstName + " " + u.LastName)
+		switch {
+		case filter.Search != "" && !strings.Contains(text, strings.ToLower(filter.Search)),
+			filter.Role != "" && u.Role != filter.Role,
+			filter.IsActive != nil && u.IsActive != *filter.IsActive,
+			filter.IsVerified != nil && u.IsVerified != *filter.IsVerified,
+			filter.CreatedFrom != nil && u.CreatedAt.Before(*filter.CreatedFrom),
+			filter.CreatedTo != nil && !u.CreatedAt.Before(*filter.CreatedTo):
+			continue
+		}
+		copied := *u
+		users = append(users, &copied)
+	}
+	return users
+}
+
+// modify changes a stored user in place, for tests that need state the
+// service never writes, such as old creation dates.
+func (r *fakeUserRepository) modify(id string, change func(*models.User)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	change(r.users[id])
+}
+
+func userFieldValue(u *models.User, field string) string {
+	switch field {
+	case "created_at":
+		return u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case "updated_at":
+		return u.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	case "email":
+		return u.Email
+	default:
+		return u.Username
+	}
+}
+
+// compareUserField compares a user's sort field with a key value the way
+// PostgreSQL would: timestamps as times, the rest as text.
+func compareUserField(u *models.User, field, value string) int {
+	switch field {
+	case "created_at", "updated_at":
+		t, _ := time.Parse(time.RFC3339Nano, value)
+		own := u.CreatedAt
+		if field == "updated_at" {
+			own = u.UpdatedAt
+		}
+		return own.Compare(t)
+	default:
+		return strings.Compare(userFieldValue(u, field), value)
+	}
+}
+
+func (r *fakeUserRepository) Update(id string, updates map[string]interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	u, ok := r.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	for column, value := range updates {
+		switch column {
+		case "email":
+			u.Email = value.(string)
+		case "username":
+			u.Username = value.(string)
+		case "first_name":
+			u.FirstName = value.(string)
+		case "last_name":
+			u.LastName = value.(string)
+		case "phone":
+			u.Phone = nullableString(value)
+		case "avatar_url":
+			u.AvatarURL = nullableString(value)
+		case "is_verified":
+			u.IsVerified = value.(bool)
+		case "role":
+			u.Role = value.(string)
+		default:
+			return fmt.Errorf("column %q cannot be updated", column)
+		}
+	}
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+func nullableString(value interface{}) *string {
+	if value == nil {
+		return nil
+	}
+	s := value.(string)
+	return &s
+}
+
+func (r *fakeUserRepository) UpdatePasswordHash(id, oldHash, newHash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok && u.Password == oldHash {
+		u.Password = newHash
+	}
+	return nil
+}
+
+func (r *fakeUserRepository) Delete(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if u, ok := r.users[id]; ok {
+		u.IsActive = false
+	}
+	return nil
+}
+
+// fakeSessionRepository is an in-memory repository.SessionRepository.
+type fakeSessionRepository struct {
+	mu       sync.Mutex
+	sessions map[string]*models.UserSession
+	tokens   map[string]*models.RefreshToken
+}
+
+func newFakeSessionRepository() *fakeSessionRepository {
+	return &fakeSessionRepository{
+		sessions: make(map[string]*models.UserSession),
+		tokens:   make(map[string]*models.RefreshToken),
+	}
+}
+
+func (r *fakeSessionRepository) CreateSession(session *models.UserSession, token *models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	session.ID = uuid.New().String()
+	session.CreatedAt = time.Now()
+	session.LastUsedAt = session.CreatedAt
+	stored := *session
+	r.sessions[session.ID] = &stored
+
+	token.SessionID = session.ID
+	r.insertToken(token)
+	return nil
+}
+
+func (r *fakeSessionRepository) insertToken(token *models.RefreshToken) {
+	token.ID = uuid.New().String()
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+}
+
+func (r *fakeSessionRepository) GetSession(sessionID string) (*models.UserSession, error) {
+	// Postgres rejects malformed ids instead of finding nothing.
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return nil, fmt.Errorf("pq: invalid input syntax for type uuid: %q", sessionID)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[sessionID]; ok {
+		copied := *s
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeSessionRepository) ListActiveByUserID(userID string) ([]*models.UserSession, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sessions []*models.UserSession
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.RevokedAt == nil && s.ExpiresAt.After(time.Now()) {
+			copied := *s
+			sessions = append(sessions, &copied)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastUsedAt.After(sessions[j].LastUsedAt)
+	})
+	return sessions, nil
+}
+
+func (r *fakeSessionRepository) GetRefreshTokenByHash(tokenHash string) (*models.RefreshToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.tokens[tokenHash]; ok {
+		copied := *t
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (r *fakeSessionRepository) RotateRefreshToken(oldTokenID string, next *models.RefreshToken, client models.ClientInfo) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.ID != oldTokenID {
+			continue
+		}
+		if t.UsedAt != nil {
+			return false, nil
+		}
+		now := time.Now()
+		t.UsedAt = &now
+		next.ParentID = &oldTokenID
+		r.insertToken(next)
+		session := r.sessions[next.SessionID]
+		session.ExpiresAt = next.ExpiresAt
+		session.LastUsedAt = now
+		if client.IPAddress != "" {
+			session.IPAddress = client.IPAddress
+		}
+		if client.UserAgent != "" {
+			session.UserAgent = client.UserAgent
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (r *fakeSessionRepository) RevokeSession(sessionID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.sessions[sessionID]; ok && s.RevokedAt == nil {
+		now := time.Now()
+		s.RevokedAt = &now
+	}
+	return nil
+}
+
+func (r *fakeSessionRepository) RevokeAllForUser(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for _, s := range r.sessions {
+		if s.UserID == userID && s.RevokedAt == nil {
+			s.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+func (r *fakeSessionRepository) deleteForUser(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for id, s := range r.sessions {
+		if s.UserID == userID {
+			delete(r.sessions, id)
+		}
+	}
+	for hash, t := range r.tokens {
+		if t.UserID == userID {
+			delete(r.tokens, hash)
+		}
+	}
+}
+
+func (r *fakeSessionRepository) sessionCount(userID string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := 0
+	for _, s := range r.sessions {
+		if s.UserID == userID {
+			n++
+		}
+	}
+	return n
+}
+
+// fakePasswordRepository keeps password history and change times on top of
+// fakeUserRepository.
+type fakePasswordRepository struct {
+	mu        sync.Mutex
+	users     *fakeUserRepository
+	sessions  *fakeSessionRepository
+	history   map[string][]string
+	changedAt map[string]time.Time
+}
+
+func newFakePasswordRepository(users *fakeUserRepository, sessions *fakeSessionRepository) *fakePasswordRepository {
+	return &fakePasswordRepository{
+		users:     users,
+		sessions:  sessions,
+		history:   make(map[string][]string),
+		changedAt: make(map[string]time.Time),
+	}
+}
+
+func (r *fakePasswordRepository) GetHistory(userID string, limit int) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hashes := r.history[userID]
+	if len(hashes) > limit {
+		hashes = hashes[:limit]
+	}
+	return append([]string(nil), hashes...), nil
+}
+
+func (r *fakePasswordRepository) GetChangedAt(userID string) (*time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if at, ok := r.changedAt[userID]; ok {
+		return &at, nil
+	}
+	return nil, nil
+}
+
+func (r *fakePasswordRepository) ChangePassword(userID, keepSessionID, passwordHash string, historySize int) (time.Time, error) {
+	changedAt := r.replace(userID, passwordHash, historySize)
+
+	r.sessions.mu.Lock()
+	defer r.sessions.mu.Unlock()
+	for id, s := range r.sessions.sessions {
+		if s.UserID == userID && id != keepSessionID && s.RevokedAt == nil {
+			s.RevokedAt = &changedAt
+		}
+	}
+	return changedAt, nil
+}
+
+func (r *fakePasswordRepository) replace(userID, passwordHash string, historySize int) time.Time {
+	r.users.mu.Lock()
+	user := r.users.users[userID]
+	previous := user.Password
+	user.Password = passwordHash
+	r.users.mu.Unlock()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	hashes := append([]string{previous}, r.history[userID]...)
+	if len(hashes) > historySize {
+		hashes = hashes[:historySize]
+	}
+	r.history[userID] = hashes
+	now := time.Now()
+	r.changedAt[userID] = now
+	return now
+}
+
+// fakePasswordResetRepository mirrors the transactional behaviour of the
+// Postgres implementation on top of fakePasswordRepository.
+type fakePasswordResetRepository struct {
+	mu        sync.Mutex
+	passwords *fakePasswordRepository
+	tokens    map[string]*models.PasswordResetToken
+}
+
+func newFakePasswordResetRepository(passwords *fakePasswordRepository) *fakePasswordResetRepository {
+	return &fakePasswordResetRepository{
+		passwords: passwords,
+		tokens:    make(map[string]*models.PasswordResetToken),
+	}
+}
+
+func (r *fakePasswordResetRepository) CreateToken(token *models.PasswordResetToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uuid.New().String()
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *fakePasswordResetRepository) GetLatestByUserID(userID string) (*models.PasswordResetToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *models.PasswordResetToken
+	for _, t := range r.tokens {
+		if t.UserID == userID && (latest == nil || t.CreatedAt.After(latest.CreatedAt)) {
+			latest = t
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+// backdate shifts the user's tokens d into the past.
+func (r *fakePasswordResetRepository) backdate(userID string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			t.CreatedAt = t.CreatedAt.Add(-d)
+			t.ExpiresAt = t.ExpiresAt.Add(-d)
+		}
+	}
+}
+
+func (r *fakePasswordResetRepository) DeleteUnusedByUserID(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, t := range r.tokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
+
+func (r *fakePasswordResetRepository) GetUserIDByToken(tokenHash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.tokens[tokenHash]
+	if !ok || t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return "", nil
+	}
+	return t.UserID, nil
+}
+
+func (r *fakePasswordResetRepository) ResetPassword(tokenHash, passwordHash string, historySize int) (string, time.Time, error) {
+	r.mu.Lock()
+	t, ok := r.tokens[tokenHash]
+	if !ok || t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		r.mu.Unlock()
+		return "", time.Time{}, nil
+	}
+	now := time.Now()
+	t.UsedAt = &now
+	r.mu.Unlock()
+
+	changedAt := r.passwords.replace(t.UserID, passwordHash, historySize)
+	r.passwords.sessions.deleteForUser(t.UserID)
+	return t.UserID, changedAt, nil
+}
+
+// fakeVerificationRepository is an in-memory repository.VerificationRepository
+// that marks users verified in fakeUserRepository.
+type fakeVerificationRepository struct {
+	mu     sync.Mutex
+	users  *fakeUserRepository
+	tokens map[string]*models.EmailVerificationToken
+}
+
+func newFakeVerificationRepository(users *fakeUserRepository) *fakeVerificationRepository {
+	return &fakeVerificationRepository{
+		users:  users,
+		tokens: make(map[string]*models.EmailVerificationToken),
+	}
+}
+
+func (r *fakeVerificationRepository) CreateToken(token *models.EmailVerificationToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	token.ID = uuid.New().String()
+	token.CreatedAt = time.Now()
+	stored := *token
+	r.tokens[token.TokenHash] = &stored
+	return nil
+}
+
+func (r *fakeVerificationRepository) GetLatestByUserID(userID string) (*models.EmailVerificationToken, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var latest *models.EmailVerificationToken
+	for _, t := range r.tokens {
+		if t.UserID == userID && (latest == nil || t.CreatedAt.After(latest.CreatedAt)) {
+			latest = t
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+	copied := *latest
+	return &copied, nil
+}
+
+func (r *fakeVerificationRepository) ConsumeToken(tokenHash string) (string, error) {
+	r.mu.Lock()
+	t, ok := r.tokens[tokenHash]
+	if !ok || t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		r.mu.Unlock()
+		return "", nil
+	}
+	now := time.Now()
+	t.UsedAt = &now
+	for hash, other := range r.tokens {
+		if other.UserID == t.UserID && other.UsedAt == nil {
+			delete(r.tokens, hash)
+		}
+	}
+	r.mu.Unlock()
+
+	r.users.modify(t.UserID, func(u *models.User) { u.IsVerified = true })
+	return t.UserID, nil
+}
+
+func (r *fakeVerificationRepository) DeleteUnusedByUserID(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hash, t := range r.tokens {
+		if t.UserID == userID && t.UsedAt == nil {
+			delete(r.tokens, hash)
+		}
+	}
+	return nil
+}
+
+// backdate moves every token of the user d into the past, as if it had been
+// issued that much earlier.
+func (r *fakeVerificationRepository) backdate(userID string, d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.tokens {
+		if t.UserID == userID {
+			t.CreatedAt = t.CreatedAt.Add(-d)
+			t.ExpiresAt = t.ExpiresAt.Add(-d)
+		}
+	}
+}
+
+// fakeMFARepository is an in-memory repository.MFARepository.
+type fakeMFARepository struct {
+	mu         sync.Mutex
+	factors    map[string]*models.UserMFA
+	recovery   map[string]map[string]bool
+	challenges map[string]bool
+}
+
+func newFakeMFARepository() *fakeMFARepository {
+	return &fakeMFARepository{
+		factors:    make(map[string]*models.UserMFA),
+		recovery:   make(map[string]map[string]bool),
+		challenges: make(map[string]bool),
+	}
+}
+
+func (r *fakeMFARepository) GetByUserID(userID string) (*models.UserMFA, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mfa, ok := r.factors[userID]
+	if !ok {
+		return nil, nil
+	}
+	copied := *mfa
+	return &copied, nil
+}
+
+func (r *fakeMFARepository) SavePending(userID, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.factors[userID].Enabled() {
+		return nil
+	}
+	r.factors[userID] = &models.UserMFA{UserID: userID, Secret: secret, CreatedAt: time.Now(), UpdatedAt: time.Now()}
+	return nil
+}
+
+func (r *fakeMFARepository) ListSecrets() ([]*models.UserMFA, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var factors []*models.UserMFA
+	for userID, mfa := range r.factors {
+		factors = append(factors, &models.UserMFA{UserID: userID, Secret: mfa.Secret})
+	}
+	return factors, nil
+}
+
+func (r *fakeMFARepository) ReplaceSecret(userID, current, secret string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if mfa, ok := r.factors[userID]; ok && mfa.Secret == current {
+		mfa.Secret = secret
+	}
+	return nil
+}
+
+func (r *fakeMFARepository) Enable(userID string, recoveryCodeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	r.factors[userID].EnabledAt = &now
+	r.replaceRecoveryCodes(userID, recoveryCodeHashes)
+	return nil
+}
+
+func (r *fakeMFARepository) MarkStepUsed(userID string, step int64) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	mfa := r.factors[userID]
+	if mfa.LastUsedStep >= step {
+		return false, nil
+	}
+	mfa.LastUsedStep = step
+	return true, nil
+}
+
+func (r *fakeMFARepository) ReplaceRecoveryCodes(userID string, codeHashes []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.replaceRecoveryCodes(userID, codeHashes)
+	return nil
+}
+
+func (r *fakeMFARepository) replaceRecoveryCodes(userID string, codeHashes []string) {
+	codes := make(map[string]bool, len(codeHashes))
+	for _, hash := range codeHashes {
+		codes[hash] = true
+	}
+	r.recovery[userID] = codes
+}
+
+func (r *fakeMFARepository) ConsumeRecoveryCode(userID, codeHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.recovery[userID][codeHash] {
+		return false, nil
+	}
+	delete(r.recovery[userID], codeHash)
+	return true, nil
+}
+
+func (r *fakeMFARepository) ConsumeChallenge(challengeID, userID string, expiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.challenges[challengeID] {
+		return false, nil
+	}
+	r.challenges[challengeID] = true
+	return true, nil
+}
+
+func (r *fakeMFARepository) Delete(userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.factors, userID)
+	delete(r.recovery, userID)
+	return nil
+}
+
+// testLockoutPolicy allows two free failures, then 1s, 2s, ... and locks for
+// 15 minutes at the fifth failure.
+var testLockoutPolicy = lockout.Policy{
+	FreeAttempts:    2,
+	BaseDelay:       time.Second,
+	MaxDelay:        30 * time.Second,
+	Threshold:       5,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+// fakeOutboxRepository is an in-memory repository.OutboxRepository.
+type fakeOutboxRepository struct {
+	mu     sync.Mutex
+	events map[string]*models.OutboxEvent
+}
+
+func newFakeOutboxRepository() *fakeOutboxRepository {
+	return &fakeOutboxRepository{events: make(map[string]*models.OutboxEvent)}
+}
+
+// enqueue stores event the way the repositories do inside their transactions.
+func (r *fakeOutboxRepository) enqueue(t *testing.T, aggregateID string, event *events.Envelope) {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[event.EventID] = &models.OutboxEvent{
+		ID:            event.EventID,
+		AggregateID:   aggregateID,
+		EventType:     event.EventType,
+		Payload:       payload,
+		Status:        models.OutboxPending,
+		NextAttemptAt: event.Timestamp,
+		CreatedAt:     event.Timestamp,
+		UpdatedAt:     event.Timestamp,
+	}
+}
+
+func (r *fakeOutboxRepository) get(id string) models.OutboxEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.events[id]
+}
+
+// makeDue pretends the scheduled retry time of every pending event has come.
+func (r *fakeOutboxRepository) makeDue() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, e := range r.events {
+		e.NextAttemptAt = time.Now()
+	}
+}
+
+func (r *fakeOutboxRepository) ClaimDue(limit int, lease time.Duration) ([]*models.OutboxEvent, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*models.OutboxEvent
+	for _, e := range r.events {
+		if e.Status == models.OutboxPending && !e.NextAttemptAt.After(time.Now()) {
+			due = append(due, e)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.OutboxEvent, 0, len(due))
+	for _, e := range due {
+		e.NextAttemptAt = time.Now().Add(lease)
+		copied := *e
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (r *fakeOutboxRepository) update(id string, apply func(*models.OutboxEvent)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e, ok := r.events[id]; ok {
+		apply(e)
+		e.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+func (r *fakeOutboxRepository) MarkPublished(id string) error {
+	return r.update(id, func(e *models.OutboxEvent) {
+		e.Status = models.OutboxPublished
+		e.Attempts++
+		e.LastError = nil
+	})
+}
+
+func (r *fakeOutboxRepository) MarkFailed(id, lastError string, nextAttemptAt time.Time) error {
+	return r.update(id, func(e *models.OutboxEvent) {
+		e.Attempts++
+		e.LastError = &lastError
+		e.NextAttemptAt = nextAttemptAt
+	})
+}
+
+func (r *fakeOutboxRepository) MarkDead(id, lastError string) error {
+	return r.update(id, func(e *models.OutboxEvent) {
+		e.Status = models.OutboxDead
+		e.Attempts++
+		e.LastError = &lastError
+	})
+}
+
+func (r *fakeOutboxRepository) ListDead(limit, offset int) ([]*models.OutboxEvent, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var dead []*models.OutboxEvent
+	for _, e := range r.events {
+		if e.Status == models.OutboxDead {
+			copied := *e
+			dead = append(dead, &copied)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool { return dead[i].UpdatedAt.After(dead[j].UpdatedAt) })
+
+	total := len(dead)
+	if offset > total {
+		offset = total
+	}
+	dead = dead[offset:]
+	if len(dead) > limit {
+		dead = dead[:limit]
+	}
+	return dead, total, nil
+}
+
+func (r *fakeOutboxRepository) Requeue(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	e, ok := r.events[id]
+	if !ok || e.Status != models.OutboxDead {
+		return false, nil
+	}
+	e.Status = models.OutboxPending
+	e.Attempts = 0
+	e.NextAttemptAt = time.Now()
+	return true, nil
+}
+
+func (r *fakeOutboxRepository) DeletePublishedBefore(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, e := range r.events {
+		if e.Status == models.OutboxPublished && e.UpdatedAt.Before(before) {
+			delete(r.events, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// fakeCustomerStatsRepository is an in-memory
+// repository.CustomerStatsRepository with the same rules as the SQL one.
+type fakeCustomerStatsRepository struct {
+	mu        sync.Mutex
+	users     *fakeUserRepository
+	processed map[string]bool
+	orders    map[string]*fakeCustomerOrder
+	stats     map[string]*models.CustomerStats
+	// parked holds, per order, events that arrived before the order.
+	parked map[string][]fakeOrderEvent
+}
+
+type fakeCustomerOrder struct {
+	models.CustomerOrder
+	refunded int64
+	status   string
+}
+
+type fakeOrderEvent struct {
+	eventID  string
+	apply    func(order *fakeCustomerOrder)
+	received time.Time
+}
+
+func newFakeCustomerStatsRepository(users *fakeUserRepository) *fakeCustomerStatsRepository {
+	return &fakeCustomerStatsRepository{
+		users:     users,
+		processed: make(map[string]bool),
+		orders:    make(map[string]*fakeCustomerOrder),
+		stats:     make(map[string]*models.CustomerStats),
+		parked:    make(map[string][]fakeOrderEvent),
+	}
+}
+
+func (r *fakeCustomerStatsRepository) statsOf(userID string) *models.CustomerStats {
+	stats, ok := r.stats[userID]
+	if !ok {
+		stats = &models.CustomerStats{LifetimeSpend: map[string]int64{}}
+		r.stats[userID] = stats
+	}
+	return stats
+}
+
+func (r *fakeCustomerStatsRepository) addSpend(userID, currency string, amount int64) {
+	spend := r.statsOf(userID).LifetimeSpend
+	spend[currency] = max(spend[currency]+amount, 0)
+}
+
+func (r *fakeCustomerStatsRepository) RecordOrderPlaced(eventID string, order *models.CustomerOrder) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.processed[eventID] {
+		return false, nil
+	}
+	r.processed[eventID] = true
+
+	if user, _ := r.users.GetByID(order.UserID); user == nil || r.orders[order.OrderID] != nil {
+		return true, nil
+	}
+	placed := &fakeCustomerOrder{CustomerOrder: *order, status: "placed"}
+	r.orders[order.OrderID] = placed
+
+	stats := r.statsOf(order.UserID)
+	stats.OrderCount++
+	placedAt := order.PlacedAt
+	if stats.FirstOrderAt == nil || placedAt.Before(*stats.FirstOrderAt) {
+		stats.FirstOrderAt = &placedAt
+	}
+	if stats.LastOrderAt == nil || placedAt.After(*stats.LastOrderAt) {
+		stats.LastOrderAt = &placedAt
+	}
+	r.addSpend(order.UserID, order.Currency, order.TotalAmount)
+
+	for _, event := range r.parked[order.OrderID] {
+		if !r.processed[event.eventID] {
+			r.processed[event.eventID] = true
+			event.apply(placed)
+		}
+	}
+	delete(r.parked, order.OrderID)
+	return true, nil
+}
+
+// recordForOrder applies an event to its order, or parks it until the order
+// is placed.
+func (r *fakeCustomerStatsRepository) recordForOrder(eventID, orderID string, apply func(order *fakeCustomerOrder)) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.processed[eventID] {
+		return false, nil
+	}
+
+	order := r.orders[orderID]
+	if order == nil {
+		for _, parked := range r.parked[orderID] {
+			if parked.eventID == eventID {
+				return false, nil
+			}
+		}
+		r.parked[orderID] = append(r.parked[orderID], fakeOrderEvent{eventID: eventID, apply: apply, received: time.Now()})
+		return true, nil
+	}
+
+	r.processed[eventID] = true
+	apply(order)
+	return true, nil
+}
+
+func (r *fakeCustomerStatsRepository) RecordOrderDelivered(eventID, orderID string) (bool, error) {
+	return r.recordForOrder(eventID, orderID, func(order *fakeCustomerOrder) {
+		if order.status == "placed" {
+			order.status = "delivered"
+			r.statsOf(order.UserID).DeliveredOrderCount++
+		}
+	})
+}
+
+func (r *fakeCustomerStatsRepository) RecordOrderCancelled(eventID, orderID string) (bool, error) {
+	return r.recordForOrder(eventID, orderID, func(order *fakeCustomerOrder) {
+		if order.status == "placed" {
+			order.status = "cancelled"
+			stats := r.statsOf(order.UserID)
+			stats.OrderCount--
+			stats.CancelledOrderCount++
+			r.addSpend(order.UserID, order.Currency, -(order.TotalAmount - order.refunded))
+		}
+	})
+}
+
+func (r *fakeCustomerStatsRepository) RecordRefund(eventID, orderID, currency string, amount int64) (bool, error) {
+	return r.recordForOrder(eventID, orderID, func(order *fakeCustomerOrder) {
+		if order.Currency != currency {
+			return
+		}
+		refunded := min(amount, order.TotalAmount-order.refunded)
+		order.refunded += refunded
+		if order.status != "cancelled" {
+			r.addSpend(order.UserID, order.Currency, -refunded)
+		}
+	})
+}
+
+func (r *fakeCustomerStatsRepository) GetByUserID(userID string) (*models.CustomerStats, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	copied := *r.statsOf(userID)
+	copied.LifetimeSpend = make(map[string]int64)
+	for currency, amount := range r.stats[userID].LifetimeSpend {
+		copied.LifetimeSpend[currency] = amount
+	}
+	return &copied, nil
+}
+
+func (r *fakeCustomerStatsRepository) DeleteProcessedBefore(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for orderID, parked := range r.parked {
+		kept := parked[:0]
+		for _, event := range parked {
+			if event.received.Before(before) {
+				deleted++
+			} else {
+				kept = append(kept, event)
+			}
+		}
+		r.parked[orderID] = kept
+	}
+	return deleted, nil
+}
+
+// Token lifetimes of the default configuration.
+const (
+	testAccessTTL  = 15 * time.Minute
+	testRefreshTTL = 24 * time.Hour
+)
+
+func newTestGuard() *lockout.Guard {
+	return lockout.NewGuard(lockout.NewMemoryTracker(testLockoutPolicy), lockout.NewMemoryTracker(testLockoutPolicy))
+}
+
+func newTestPasswordPolicy(t *testing.T) *password.Policy {
+	t.Helper()
+
+	policy, err := password.NewPolicy(config.PasswordPolicyConfig{
+		MinLength:      8,
+		MaxLength:      72,
+		MinCharClasses: 2,
+		CheckBreached:  true,
+		HistorySize:    3,
+	})
+	require.NoError(t, err)
+	return policy
+}
+
+// newTestHasher hashes with cheap Argon2id parameters to keep tests fast.
+func newTestHasher(t *testing.T) password.Hasher {
+	t.Helper()
+
+	hasher, err := password.NewHasher(config.PasswordHashConfig{
+		Algorithm:         password.AlgorithmArgon2id,
+		Argon2Memory:      1024,
+		Argon2Iterations:  1,
+		Argon2Parallelism: 1,
+	})
+	require.NoError(t, err)
+	return hasher
+}
+
+func assertPasswordHash(t *testing.T, encoded, plaintext string) {
+	t.Helper()
+
+	match, err := newTestHasher(t).Verify(plaintext, encoded)
+	require.NoError(t, err)
+	require.True(t, match, "stored hash does not match %q", plaintext)
+}
+
+type fakeWebhookRepository struct {
+	mu            sync.Mutex
+	subscriptions map[string]*models.WebhookSubscription
+	deliveries    map[string]*models.WebhookDelivery
+	attempts      map[string][]*models.WebhookAttempt
+}
+
+func newFakeWebhookRepository() *fakeWebhookRepository {
+	return &fakeWebhookRepository{
+		subscriptions: make(map[string]*models.WebhookSubscription),
+		deliveries:    make(map[string]*models.WebhookDelivery),
+		attempts:      make(map[string][]*models.WebhookAttempt),
+	}
+}
+
+// enqueue fans event out to the subscriptions asking for it, the way the
+// repositories do inside their transactions, and returns the delivery IDs.
+func (r *fakeWebhookRepository) enqueue(t *testing.T, event *events.Envelope) []string {
+	t.Helper()
+	payload, err := json.Marshal(event)
+	require.NoError(t, err)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var ids []string
+	for _, sub := range r.subscriptions {
+		if !sub.IsActive || !containsString(sub.Events, event.EventType) {
+			continue
+		}
+		id := uuid.New().String()
+		r.deliveries[id] = &models.WebhookDelivery{
+			ID:             id,
+			SubscriptionID: sub.ID,
+			EventID:        event.EventID,
+			EventType:      event.EventType,
+			Payload:        payload,
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  event.Timestamp,
+			CreatedAt:      event.Timestamp,
+			UpdatedAt:      event.Timestamp,
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (r *fakeWebhookRepository) delivery(id string) models.WebhookDelivery {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.deliveries[id]
+}
+
+func (r *fakeWebhookRepository) subscription(id string) models.WebhookSubscription {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return *r.subscriptions[id]
+}
+
+// makeDue pretends the scheduled retry time of every pending delivery has
+// come.
+func (r *fakeWebhookRepository) makeDue() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.deliveries {
+		d.NextAttemptAt = time.Now()
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *fakeWebhookRepository) CreateSubscription(sub *models.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub.ID = uuid.New().String()
+	sub.IsActive = true
+	sub.CreatedAt = time.Now()
+	sub.UpdatedAt = sub.CreatedAt
+	copied := *sub
+	r.subscriptions[sub.ID] = &copied
+	return nil
+}
+
+func (r *fakeWebhookRepository) GetSubscription(id string) (*models.WebhookSubscription, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sub, ok := r.subscriptions[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *sub
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) ListSubscriptions(limit, offset int) ([]*models.WebhookSubscription, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var subs []*models.WebhookSubscription
+	for _, sub := range r.subscriptions {
+		copied := *sub
+		subs = append(subs, &copied)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CreatedAt.After(subs[j].CreatedAt) })
+
+	total := len(subs)
+	if offset > total {
+		offset = total
+	}
+	subs = subs[offset:]
+	if len(subs) > limit {
+		subs = subs[:limit]
+	}
+	return subs, total, nil
+}
+
+func (r *fakeWebhookRepository) UpdateSubscription(sub *models.WebhookSubscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.subscriptions[sub.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	switch {
+	case sub.IsActive && !stored.IsActive:
+		stored.ConsecutiveFailures = 0
+		stored.DisabledAt = nil
+		stored.DisabledReason = nil
+	case !sub.IsActive && stored.IsActive:
+		now := time.Now()
+		reason := "disabled by an administrator"
+		stored.DisabledAt = &now
+		stored.DisabledReason = &reason
+	}
+	stored.URL = sub.URL
+	stored.Description = sub.Description
+	stored.Events = sub.Events
+	stored.IsActive = sub.IsActive
+	stored.UpdatedAt = time.Now()
+	*sub = *stored
+	return nil
+}
+
+func (r *fakeWebhookRepository) DeleteSubscription(id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.subscriptions[id]; !ok {
+		return false, nil
+	}
+	delete(r.subscriptions, id)
+	for deliveryID, d := range r.deliveries {
+		if d.SubscriptionID == id {
+			delete(r.deliveries, deliveryID)
+			delete(r.attempts, deliveryID)
+		}
+	}
+	return true, nil
+}
+
+func (r *fakeWebhookRepository) ClaimDue(limit int, lease time.Duration) ([]*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*models.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.Status == models.WebhookDeliveryPending && !d.NextAttemptAt.After(time.Now()) && r.subscriptions[d.SubscriptionID].IsActive {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].CreatedAt.Before(due[j].CreatedAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]*models.WebhookDelivery, 0, len(due))
+	for _, d := range due {
+		d.NextAttemptAt = time.Now().Add(lease)
+		copied := *d
+		claimed = append(claimed, &copied)
+	}
+	return claimed, nil
+}
+
+func (r *fakeWebhookRepository) MarkDelivered(id string, attempt *models.WebhookAttempt) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.deliveries[id]
+	r.logAttempt(id, attempt)
+
+	now := time.Now()
+	d.Status = models.WebhookDeliveryDelivered
+	d.Attempts++
+	d.LastError = nil
+	d.DeliveredAt = &now
+	r.subscriptions[d.SubscriptionID].ConsecutiveFailures = 0
+	return nil
+}
+
+func (r *fakeWebhookRepository) MarkFailed(id string, attempt *models.WebhookAttempt, nextAttemptAt *time.Time, disableAfter int) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d := r.deliveries[id]
+	r.logAttempt(id, attempt)
+
+	d.Attempts++
+	d.LastError = attempt.Error
+	if nextAttemptAt != nil {
+		d.NextAttemptAt = *nextAttemptAt
+	} else {
+		d.Status = models.WebhookDeliveryFailed
+	}
+
+	sub := r.subscriptions[d.SubscriptionID]
+	sub.ConsecutiveFailures++
+	if !sub.IsActive || sub.ConsecutiveFailures < disableAfter {
+		return false, nil
+	}
+	now := time.Now()
+	reason := fmt.Sprintf("disabled after %d consecutive failed attempts", sub.ConsecutiveFailures)
+	sub.IsActive = false
+	sub.DisabledAt = &now
+	sub.DisabledReason = &reason
+	return true, nil
+}
+
+func (r *fakeWebhookRepository) logAttempt(id string, attempt *models.WebhookAttempt) {
+	attempt.ID = uuid.New().String()
+	attempt.DeliveryID = id
+	copied := *attempt
+	r.attempts[id] = append(r.attempts[id], &copied)
+}
+
+func (r *fakeWebhookRepository) ListDeliveries(subscriptionID string, limit, offset int) ([]*models.WebhookDelivery, int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deliveries []*models.WebhookDelivery
+	for _, d := range r.deliveries {
+		if d.SubscriptionID == subscriptionID {
+			copied := *d
+			deliveries = append(deliveries, &copied)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+
+	total := len(deliveries)
+	if offset > total {
+		offset = total
+	}
+	deliveries = deliveries[offset:]
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, total, nil
+}
+
+func (r *fakeWebhookRepository) GetDelivery(subscriptionID, id string) (*models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok || d.SubscriptionID != subscriptionID {
+		return nil, nil
+	}
+	copied := *d
+	copied.AttemptLog = append([]*models.WebhookAttempt{}, r.attempts[id]...)
+	return &copied, nil
+}
+
+func (r *fakeWebhookRepository) Replay(subscriptionID, id string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	d, ok := r.deliveries[id]
+	if !ok || d.SubscriptionID != subscriptionID {
+		return false, nil
+	}
+	d.Status = models.WebhookDeliveryPending
+	d.Attempts = 0
+	d.LastError = nil
+	d.NextAttemptAt = time.Now()
+	return true, nil
+}
+
+func (r *fakeWebhookRepository) DeleteFinishedBefore(before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var deleted int64
+	for id, d := range r.deliveries {
+		if d.Status != models.WebhookDeliveryPending && d.UpdatedAt.Before(before) {
+			delete(r.deliveries, id)
+			delete(r.attempts, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// seededRolePermissions mirrors the role permissions seeded by migration
+// 000010.
+var seededRolePermissions = map[string][]string{
+	models.RoleUser:      {"profile:read", "profile:write"},
+	models.RoleModerator: {"profile:read", "profile:write", "users:read"},
+	models.RoleAdmin: {"events:manage", "profile:read", "profile:write", "roles:manage",
+		"users:read", "users:write", "webhooks:manage"},
+}
+
+// seededPermissions grants the built-in roles their seeded permissions.
+type seededPermissions struct{}
+
+func (seededPermissions) PermissionsForRole(ctx context.Context, role string) ([]string, error) {
+	return append([]string{}, seededRolePermissions[role]...), nil
+}
+
+// fakeRoleRepository is an in-memory repository.RoleRepository holding the
+// seeded roles. Like the users.role foreign key, it refuses to delete roles
+// that users hold.
+type fakeRoleRepository struct {
+	mu    sync.Mutex
+	users *fakeUserRepository
+	roles map[string]*models.Role
+}
+
+func newFakeRoleRepository(users *fakeUserRepository) *fakeRoleRepository {
+	r := &fakeRoleRepository{users: users, roles: make(map[string]*models.Role)}
+	for name, permissions := range seededRolePermissions {
+		r.roles[name] = &models.Role{
+			Name:        name,
+			IsSystem:    true,
+			Permissions: append([]string{}, permissions...),
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		}
+	}
+	return r
+}
+
+func (r *fakeRoleRepository) ListRoles() ([]*models.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var roles []*models.Role
+	for _, role := range r.roles {
+		copied := *role
+		roles = append(roles, &copied)
+	}
+	sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+	return roles, nil
+}
+
+func (r *fakeRoleRepository) GetRole(name string) (*models.Role, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, nil
+	}
+	copied := *role
+	copied.Permissions = append([]string{}, role.Permissions...)
+	return &copied, nil
+}
+
+func (r *fakeRoleRepository) CreateRole(role *models.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.roles[role.Name]; ok {
+		return repository.ErrDuplicateRole
+	}
+	role.CreatedAt = time.Now()
+	role.UpdatedAt = role.CreatedAt
+	stored := *role
+	r.roles[role.Name] = &stored
+	return nil
+}
+
+func (r *fakeRoleRepository) UpdateRole(role *models.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.roles[role.Name]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	stored.Description = role.Description
+	stored.Permissions = append([]string{}, role.Permissions...)
+	stored.UpdatedAt = time.Now()
+	role.UpdatedAt = stored.UpdatedAt
+	return nil
+}
+
+func (r *fakeRoleRepository) DeleteRole(name string) (bool, error) {
+	if r.users.find(func(u *models.User) bool { return u.Role == name }) != nil {
+		return false, repository.ErrRoleInUse
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	role, ok := r.roles[name]
+	if !ok || role.IsSystem {
+		return false, nil
+	}
+	delete(r.roles, name)
+	return true, nil
+}
+
+func (r *fakeRoleRepository) ListPermissions() ([]*models.Permission, error) {
+	var permissions []*models.Permission
+	for _, name := range seededRolePermissions[models.RoleAdmin] {
+		permissions = append(permissions, &models.Permission{Name: name})
+	}
+	return permissions, nil
+}
+
+func (r *fakeRoleRepository) PermissionsForRole(role string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if stored, ok := r.roles[role]; ok {
+		return append([]string{}, stored.Permissions...), nil
+	}
+	return []string{}, nil
+}
+
+// fakeSigningKeyRepository keeps signing keys newest first. Expiry is judged
+// against now, which tests move forward to end a grace period.
+type fakeSigningKeyRepository struct {
+	mu   sync.Mutex
+	keys []*models.SigningKey
+	now  time.Time
+}
+
+func newFakeSigningKeyRepository() *fakeSigningKeyRepository {
+	return &fakeSigningKeyRepository{now: time.Now()}
+}
+
+func (r *fakeSigningKeyRepository) advance(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.now = r.now.Add(d)
+}
+
+func (r *fakeSigningKeyRepository) clock() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.now
+}
+
+func (r *fakeSigningKeyRepository) ListValid() ([]*models.SigningKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var valid []*models.SigningKey
+	for _, key := range r.keys {
+		if key.ExpiresAt == nil || key.ExpiresAt.After(r.now) {
+			copied := *key
+			valid = append(valid, &copied)
+		}
+	}
+	return valid, nil
+}
+
+func (r *fakeSigningKeyRepository) RotateIfStale(key *models.SigningKey, staleBefore, retiredExpiresAt time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.keys {
+		if existing.RetiredAt == nil && existing.CreatedAt.After(staleBefore) {
+			return false, nil
+		}
+	}
+	for _, existing := range r.keys {
+		if existing.RetiredAt == nil {
+			retiredAt, expiresAt := key.ActivatesAt, retiredExpiresAt
+			existing.RetiredAt, existing.ExpiresAt = &retiredAt, &expiresAt
+		}
+	}
+
+	key.CreatedAt = r.now
+	copied := *key
+	r.keys = append([]*models.SigningKey{&copied}, r.keys...)
+	return true, nil
+}
+
+func (r *fakeSigningKeyRepository) DeleteExpired() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	kept := r.keys[:0]
+	for _, key := range r.keys {
+		if key.ExpiresAt == nil || key.ExpiresAt.After(r.now) {
+			kept = append(kept, key)
+		}
+	}
+	r.keys = kept
+	return nil
+}
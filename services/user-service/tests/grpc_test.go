@@ -0,0 +1,174 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/grpcserver"
+	"user-service/internal/keystore"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+	userpb "user-service/proto/user"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testServiceKey = "order-service-key-0123456789abcdef"
+
+type grpcFixture struct {
+	conn        *grpc.ClientConn
+	client      userpb.UserServiceClient
+	users       *fakeUserRepository
+	login       *models.LoginResponse
+	revocations *revocation.MemoryStore
+}
+
+func setupGRPC(t *testing.T) *grpcFixture {
+	t.Helper()
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+	keys := keystore.NewStaticStore(key)
+	revocations := revocation.NewMemoryStore()
+	users := newFakeUserRepository()
+	sessions := newFakeSessionRepository()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{
+		Email:    "grpc@example.com",
+		Username: "grpc",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}))
+
+	userService := services.NewUserService(users, sessions, nil, noMFA{}, keys, revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwordService := services.NewPasswordService(users, newFakePasswordRepository(users, sessions), revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), testAccessTTL)
+	validator := tokens.NewValidator(keys, revocations, passwordService)
+
+	login, _, err := userService.Login(&models.LoginRequest{Email: "grpc@example.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpcserver.New(config.GRPCConfig{}, "", middleware.NewServiceKeys([]string{testServiceKey}), userService, validator)
+	go srv.Serve(lis)
+	t.Cleanup(func() { srv.Shutdown(context.Background()) })
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return &grpcFixture{conn: conn, client: userpb.NewUserServiceClient(conn), users: users, login: login, revocations: revocations}
+}
+
+func asService(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, grpcserver.ServiceKeyHeader, testServiceKey)
+}
+
+func TestGRPCRequiresCredentials(t *testing.T) {
+	f := setupGRPC(t)
+	ctx := context.Background()
+	id := f.login.User.ID
+
+	_, err := f.client.GetUser(ctx, &userpb.GetUserRequest{Id: id})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = f.client.GetUser(metadata.AppendToOutgoingContext(ctx, grpcserver.ServiceKeyHeader, "wrong"), &userpb.GetUserRequest{Id: id})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	resp, err := f.client.GetUser(asService(ctx), &userpb.GetUserRequest{Id: id})
+	require.NoError(t, err)
+	assert.Equal(t, "grpc@example.com", resp.GetUser().GetEmail())
+
+	bearer := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+f.login.AccessToken)
+	_, err = f.client.GetUser(bearer, &userpb.GetUserRequest{Id: id})
+	require.NoError(t, err)
+
+	// Health checks are open to orchestrators.
+	health, err := healthpb.NewHealthClient(f.conn).Check(ctx, &healthpb.HealthCheckRequest{Service: "user.UserService"})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, health.GetStatus())
+}
+
+func TestGRPCGetUserErrors(t *testing.T) {
+	f := setupGRPC(t)
+	ctx := asService(context.Background())
+
+	_, err := f.client.GetUser(ctx, &userpb.GetUserRequest{Id: "not-a-uuid"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = f.client.GetUser(ctx, &userpb.GetUserRequest{Id: uuid.NewString()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = f.client.CreateUser(ctx, &userpb.CreateUserRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+}
+
+func TestGRPCBatchGetUsers(t *testing.T) {
+	f := setupGRPC(t)
+	ctx := asService(context.Background())
+
+	require.NoError(t, f.users.Create(&models.User{Email: "second@example.com", Username: "second", Role: "user", IsActive: true}))
+	second, _ := f.users.GetByEmail("second@example.com")
+	missing := uuid.NewString()
+
+	resp, err := f.client.BatchGetUsers(ctx, &userpb.BatchGetUsersRequest{
+		Ids: []string{second.ID, missing, f.login.User.ID, second.ID, "bogus"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, resp.GetUsers(), 2)
+	assert.Equal(t, second.ID, resp.GetUsers()[0].GetId())
+	assert.Equal(t, f.login.User.ID, resp.GetUsers()[1].GetId())
+	assert.ElementsMatch(t, []string{missing, "bogus"}, resp.GetNotFoundIds())
+
+	tooMany := make([]string, grpcserver.MaxBatchSize+1)
+	_, err = f.client.BatchGetUsers(ctx, &userpb.BatchGetUsersRequest{Ids: tooMany})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestGRPCValidateTokenAndRoles(t *testing.T) {
+	f := setupGRPC(t)
+	ctx := asService(context.Background())
+
+	resp, err := f.client.ValidateToken(ctx, &userpb.ValidateTokenRequest{AccessToken: f.login.AccessToken})
+	require.NoError(t, err)
+	assert.True(t, resp.GetValid())
+	assert.Equal(t, f.login.User.ID, resp.GetUserId())
+	assert.Equal(t, "user", resp.GetRole())
+	assert.Greater(t, resp.GetExpiresAt(), time.Now().Unix())
+
+	resp, err = f.client.ValidateToken(ctx, &userpb.ValidateTokenRequest{AccessToken: "garbage"})
+	require.NoError(t, err)
+	assert.False(t, resp.GetValid())
+	assert.Equal(t, "invalid", resp.GetReason())
+
+	require.NoError(t, f.revocations.RevokeUser(context.Background(), f.login.User.ID, time.Now().Add(time.Second), time.Hour))
+	resp, err = f.client.ValidateToken(ctx, &userpb.ValidateTokenRequest{AccessToken: f.login.AccessToken})
+	require.NoError(t, err)
+	assert.False(t, resp.GetValid())
+	assert.Equal(t, "revoked", resp.GetReason())
+
+	roles, err := f.client.GetUserRoles(ctx, &userpb.GetUserRolesRequest{UserId: f.login.User.ID})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user"}, roles.GetRoles())
+}
@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"user-service/internal/handlers"
+	"user-service/internal/health"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeMigrationVersion struct {
+	current int64
+	pending int
+	err     error
+}
+
+func (f fakeMigrationVersion) Version(context.Context) (int64, int, error) {
+	return f.current, f.pending, f.err
+}
+
+func countingCheck(calls *int32, err error) health.Checker {
+	return health.CheckerFunc(func(context.Context) (string, error) {
+		atomic.AddInt32(calls, 1)
+		return "", err
+	})
+}
+
+func TestHealthRegistryOnlyCriticalFailuresMakeItUnhealthy(t *testing.T) {
+	var calls int32
+	checks := health.NewRegistry(time.Second, 0)
+	checks.Add("database", true, countingCheck(&calls, nil))
+	checks.Add("cache", false, countingCheck(&calls, errors.New("connection refused")))
+
+	report := checks.Check()
+	assert.Equal(t, health.StatusHealthy, report.Status)
+	assert.Equal(t, map[string]string{"database": "healthy", "cache": "unhealthy"}, report.Dependencies())
+	assert.Equal(t, "connection refused", report.Checks["cache"].Error)
+
+	checks.Add("migrations", true, health.Migrations(fakeMigrationVersion{current: 4, pending: 1}))
+	report = checks.Check()
+	assert.Equal(t, health.StatusUnhealthy, report.Status)
+	assert.Equal(t, "version 4", report.Checks["migrations"].Detail)
+	assert.Equal(t, "1 migration(s) pending", report.Checks["migrations"].Error)
+}
+
+func TestHealthRegistryCachesResults(t *testing.T) {
+	var calls int32
+	checks := health.NewRegistry(time.Second, time.Minute)
+	checks.Add("database", true, countingCheck(&calls, nil))
+
+	for i := 0; i < 5; i++ {
+		checks.Check()
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	uncached := health.NewRegistry(time.Second, 0)
+	uncached.Add("database", true, countingCheck(&calls, nil))
+	uncached.Check()
+	uncached.Check()
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestHealthRegistryTimesOutSlowChecks(t *testing.T) {
+	checks := health.NewRegistry(20*time.Millisecond, 0)
+	checks.Add("database", true, health.CheckerFunc(func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}))
+
+	start := time.Now()
+	report := checks.Check()
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, health.StatusUnhealthy, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["database"].Error)
+}
+
+func TestRedisHealthCheck(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	_, err := health.Redis(client).Check(context.Background())
+	assert.NoError(t, err)
+
+	mr.Close()
+	_, err = health.Redis(client).Check(context.Background())
+	assert.Error(t, err)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var dbDown, shuttingDown atomic.Bool
+	checks := health.NewRegistry(time.Second, 0)
+	checks.Add("database", true, health.CheckerFunc(func(context.Context) (string, error) {
+		if dbDown.Load() {
+			return "", errors.New("connection refused")
+		}
+		return "", nil
+	}))
+	checks.Add("cache", false, health.CheckerFunc(func(context.Context) (string, error) { return "", nil }))
+
+	h := handlers.NewHealthHandler(checks, func() bool { return !shuttingDown.Load() })
+	router := gin.New()
+	router.GET("/health", h.Health)
+	router.GET("/health/live", h.Live)
+	router.GET("/health/ready", h.Ready)
+	router.GET("/health/details", h.Details)
+
+	get := func(path string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	code, body := get("/health")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "healthy", body["status"])
+	assert.NotEmpty(t, body["version"])
+	assert.NotEmpty(t, body["timestamp"])
+	assert.Equal(t, map[string]interface{}{"database": "healthy", "cache": "healthy"}, body["dependencies"])
+	assert.NotContains(t, body, "checks")
+	assert.NotContains(t, body, "build")
+
+	code, body = get("/health/details")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "checks")
+	assert.Contains(t, body, "build")
+
+	code, _ = get("/health/ready")
+	assert.Equal(t, http.StatusOK, code)
+
+	dbDown.Store(true)
+	code, body = get("/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unhealthy", body["status"])
+	_, body = get("/health")
+	assert.Equal(t, "unhealthy", body["dependencies"].(map[string]interface{})["database"])
+	assert.NotContains(t, body, "checks", "the public report does not leak the error")
+	_, body = get("/health/details")
+	assert.Equal(t, "connection refused", body["checks"].(map[string]interface{})["database"].(map[string]interface{})["error"])
+	code, _ = get("/health/live")
+	assert.Equal(t, http.StatusOK, code, "liveness must not depend on the database")
+
+	dbDown.Store(false)
+	shuttingDown.Store(true)
+	code, body = get("/health")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, true, body["shutting_down"])
+	code, _ = get("/health/live")
+	assert.Equal(t, http.StatusOK, code)
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/keystore"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/routes"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type introspectionFixture struct {
+	router      *gin.Engine
+	users       *fakeUserRepository
+	revocations *revocation.MemoryStore
+	login       *models.LoginResponse
+}
+
+func setupIntrospection(t *testing.T, ttl time.Duration) *introspectionFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+	keys := keystore.NewStaticStore(key)
+	revocations := revocation.NewMemoryStore()
+	users := newFakeUserRepository()
+	sessions := newFakeSessionRepository()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{
+		Email:    "payer@example.com",
+		Username: "payer",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}))
+
+	userService := services.NewUserService(users, sessions, nil, noMFA{}, keys, revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwordService := services.NewPasswordService(users, newFakePasswordRepository(users, sessions), revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), testAccessTTL)
+	validator := tokens.NewValidator(keys, revocations, passwordService)
+	auth := middleware.NewAuthMiddleware(validator, seededPermissions{})
+
+	login, _, err := userService.Login(&models.LoginRequest{Email: "payer@example.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	routes.SetupRoutes(router, routes.Handlers{
+		Introspection: handlers.NewIntrospectionHandler(services.NewIntrospectionService(validator, users, ttl)),
+	}, auth, middleware.NewServiceKeys([]string{testServiceKey}))
+
+	return &introspectionFixture{router: router, users: users, revocations: revocations, login: login}
+}
+
+func (f *introspectionFixture) introspect(t *testing.T, token string) models.IntrospectionResponse {
+	t.Helper()
+	w := f.post(url.Values{"token": {token}}.Encode(), "application/x-www-form-urlencoded", testServiceKey)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	var response models.IntrospectionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func (f *introspectionFixture) post(body, contentType, serviceKey string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/introspect", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if serviceKey != "" {
+		req.Header.Set(middleware.ServiceKeyHeader, serviceKey)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIntrospectionRequiresServiceKey(t *testing.T) {
+	f := setupIntrospection(t, 0)
+	form := url.Values{"token": {f.login.AccessToken}}.Encode()
+
+	assert.Equal(t, http.StatusUnauthorized, f.post(form, "application/x-www-form-urlencoded", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, f.post(form, "application/x-www-form-urlencoded", "wrong").Code)
+
+	// A user's own bearer token is not a service credential.
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/introspect", strings.NewReader(form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+f.login.AccessToken)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestIntrospectionActiveToken(t *testing.T) {
+	f := setupIntrospection(t, 0)
+
+	response := f.introspect(t, f.login.AccessToken)
+	assert.True(t, response.Active)
+	assert.Equal(t, f.login.User.ID, response.Sub)
+	assert.Equal(t, "user", response.Role)
+	assert.Equal(t, "access_token", response.TokenType)
+	assert.Equal(t, "unverified", response.AccountStatus)
+	assert.Greater(t, response.Exp, time.Now().Unix())
+
+	// JSON bodies are accepted too.
+	body, _ := json.Marshal(models.IntrospectionRequest{Token: f.login.AccessToken})
+	w := f.post(string(body), "application/json", testServiceKey)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"active":true`)
+}
+
+func TestIntrospectionInactiveTokens(t *testing.T) {
+	f := setupIntrospection(t, 0)
+
+	for name, token := range map[string]string{
+		"garbage": "not-a-jwt",
+		"refresh": f.login.RefreshToken,
+	} {
+		t.Run(name, func(t *testing.T) {
+			response := f.introspect(t, token)
+			assert.Equal(t, models.IntrospectionResponse{Active: false}, response)
+		})
+	}
+
+	t.Run("revoked", func(t *testing.T) {
+		require.NoError(t, f.revocations.RevokeUser(context.Background(), f.login.User.ID, time.Now().Add(time.Second), time.Hour))
+		w := f.post(url.Values{"token": {f.login.AccessToken}}.Encode(), "application/x-www-form-urlencoded", testServiceKey)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"active":false}`, w.Body.String())
+	})
+}
+
+func TestIntrospectionDeactivatedAccount(t *testing.T) {
+	f := setupIntrospection(t, 0)
+
+	require.NoError(t, f.users.Delete(f.login.User.ID))
+	assert.False(t, f.introspect(t, f.login.AccessToken).Active)
+}
+
+func TestIntrospectionMissingToken(t *testing.T) {
+	f := setupIntrospection(t, 0)
+
+	w := f.post("", "application/x-www-form-urlencoded", testServiceKey)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = f.post("{", "application/json", testServiceKey)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIntrospectionCachesWithinTTL(t *testing.T) {
+	f := setupIntrospection(t, time.Minute)
+
+	require.True(t, f.introspect(t, f.login.AccessToken).Active)
+	require.NoError(t, f.revocations.RevokeUser(context.Background(), f.login.User.ID, time.Now().Add(time.Second), time.Hour))
+	assert.True(t, f.introspect(t, f.login.AccessToken).Active, "answer should come from the cache")
+
+	uncached := setupIntrospection(t, 0)
+	require.True(t, uncached.introspect(t, uncached.login.AccessToken).Active)
+	require.NoError(t, uncached.revocations.RevokeUser(context.Background(), uncached.login.User.ID, time.Now().Add(time.Second), time.Hour))
+	assert.False(t, uncached.introspect(t, uncached.login.AccessToken).Active)
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/keystore"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	keyGrace = 48 * time.Hour
+	// keyPublishLead is how long a rotated-in key is published before it
+	// signs: one refresh interval plus the JWKS cache lifetime.
+	keyPublishLead = 5*time.Minute + keystore.JWKSMaxAge
+)
+
+type keystoreFixture struct {
+	repo   *fakeSigningKeyRepository
+	keys   *keystore.Store
+	tokens *tokens.Validator
+	user   services.UserService
+}
+
+// setupKeystore signs tokens with a database-backed key store over an
+// in-memory repository whose clock the tests control.
+func setupKeystore(t *testing.T) *keystoreFixture {
+	t.Helper()
+
+	repo := newFakeSigningKeyRepository()
+	keys := keystore.NewStore(repo, config.JWTConfig{
+		Algorithm:            keystore.AlgEdDSA,
+		KeyRotationInterval:  720,
+		KeyVerificationGrace: int(keyGrace / time.Hour),
+		KeyRefreshInterval:   5,
+	})
+	keys.SetClock(repo.clock)
+	require.NoError(t, keys.Init())
+
+	users := newFakeUserRepository()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{Email: "keys@example.com", Username: "keys", Password: string(hashed), Role: "user", IsActive: true}))
+
+	sessions := newFakeSessionRepository()
+	revocations := revocation.NewMemoryStore()
+	userService := services.NewUserService(users, sessions, nil, noMFA{}, keys, revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwordService := services.NewPasswordService(users, newFakePasswordRepository(users, sessions), revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), testAccessTTL)
+
+	return &keystoreFixture{
+		repo:   repo,
+		keys:   keys,
+		tokens: tokens.NewValidator(keys, revocations, passwordService),
+		user:   userService,
+	}
+}
+
+func (f *keystoreFixture) accessToken(t *testing.T) string {
+	t.Helper()
+
+	login, _, err := f.user.Login(&models.LoginRequest{Email: "keys@example.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+	return login.AccessToken
+}
+
+func (f *keystoreFixture) publishedKIDs() []string {
+	var kids []string
+	for _, jwk := range f.keys.JWKS().Keys {
+		kids = append(kids, jwk.Kid)
+	}
+	return kids
+}
+
+func (f *keystoreFixture) signingKID(t *testing.T) string {
+	t.Helper()
+
+	key, err := f.keys.SigningKey()
+	require.NoError(t, err)
+	return key.ID
+}
+
+func TestRetiredKeyVerifiesOnlyDuringGrace(t *testing.T) {
+	f := setupKeystore(t)
+
+	oldKID := f.signingKID(t)
+	token := f.accessToken(t)
+
+	require.NoError(t, f.keys.Rotate())
+	f.repo.advance(keyPublishLead)
+	newKID := f.signingKID(t)
+	require.NotEqual(t, oldKID, newKID)
+
+	_, err := f.tokens.Validate(context.Background(), token)
+	require.NoError(t, err, "tokens from the retired key verify during the grace period")
+
+	f.repo.advance(keyGrace + time.Minute)
+	require.NoError(t, f.keys.Reload())
+
+	_, err = f.tokens.Validate(context.Background(), token)
+	var tokenErr *tokens.Error
+	require.True(t, errors.As(err, &tokenErr), "tokens from an expired key are rejected")
+	assert.Equal(t, "bad_signature", tokenErr.Reason)
+
+	_, err = f.tokens.Validate(context.Background(), f.accessToken(t))
+	assert.NoError(t, err)
+}
+
+func TestJWKSListsExactlyTheVerifyingKeys(t *testing.T) {
+	f := setupKeystore(t)
+
+	first := f.signingKID(t)
+	assert.Equal(t, []string{first}, f.publishedKIDs())
+
+	require.NoError(t, f.keys.Rotate())
+	f.repo.advance(keyPublishLead)
+	second := f.signingKID(t)
+	assert.Equal(t, []string{second, first}, f.publishedKIDs(), "the retired key stays published during grace")
+
+	f.repo.advance(keyGrace + time.Minute)
+	require.NoError(t, f.keys.Reload())
+	assert.Equal(t, []string{second}, f.publishedKIDs())
+
+	_, err := f.keys.VerificationKey(first)
+	assert.ErrorIs(t, err, keystore.ErrUnknownKey)
+}
+
+func TestRotatedKeyIsPublishedBeforeItSigns(t *testing.T) {
+	f := setupKeystore(t)
+
+	first := f.signingKID(t)
+	require.NoError(t, f.keys.Rotate())
+
+	published := f.publishedKIDs()
+	require.Len(t, published, 2)
+	next := published[0]
+	assert.NotEqual(t, first, next)
+	assert.Equal(t, first, f.signingKID(t), "the new key only signs once cached key sets include it")
+
+	// A second rotation check while the key is pending doesn't stack another one.
+	require.NoError(t, f.keys.Init())
+	assert.Len(t, f.publishedKIDs(), 2)
+
+	f.repo.advance(keyPublishLead - time.Second)
+	assert.Equal(t, first, f.signingKID(t))
+
+	// The switch happens without waiting for a reload.
+	f.repo.advance(time.Second)
+	assert.Equal(t, next, f.signingKID(t))
+
+	_, err := f.tokens.Validate(context.Background(), f.accessToken(t))
+	assert.NoError(t, err)
+
+	// The previous key verifies for the full grace period after it stopped signing.
+	f.repo.advance(keyGrace - time.Minute)
+	require.NoError(t, f.keys.Reload())
+	assert.Equal(t, []string{next, first}, f.publishedKIDs())
+}
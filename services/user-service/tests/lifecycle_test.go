@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/lifecycle"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleDrainsRequestsThenClosesInReverseOrder(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})}
+
+	app := lifecycle.New(5*time.Second, 0)
+	app.AddServer("http", func() error { return srv.Serve(listener) }, srv.Shutdown)
+
+	workerStopped := make(chan struct{})
+	app.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		close(workerStopped)
+	})
+
+	var mu sync.Mutex
+	var closed []string
+	for _, name := range []string{"postgres", "redis"} {
+		name := name
+		app.OnShutdown(name, func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			closed = append(closed, name)
+			return nil
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- app.Run(ctx) }()
+	require.Eventually(t, app.Ready, time.Second, 5*time.Millisecond)
+
+	// A request is in flight when shutdown starts.
+	responses := make(chan int, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- 0
+			return
+		}
+		resp.Body.Close()
+		responses <- resp.StatusCode
+	}()
+	<-started
+
+	cancel()
+	require.Eventually(t, func() bool { return !app.Ready() }, time.Second, 5*time.Millisecond)
+
+	select {
+	case <-done:
+		t.Fatal("Run returned before the in-flight request finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusNoContent, <-responses)
+	require.NoError(t, <-done)
+
+	<-workerStopped
+	assert.Equal(t, []string{"redis", "postgres"}, closed)
+
+	// New connections are refused once shut down.
+	_, err = http.Get("http://" + listener.Addr().String())
+	assert.Error(t, err)
+}
+
+func TestLifecycleStopsWhenAServerFails(t *testing.T) {
+	app := lifecycle.New(time.Second, time.Hour)
+	app.AddServer("broken", func() error { return errors.New("address in use") }, func(context.Context) error { return nil })
+
+	closed := false
+	app.OnShutdown("postgres", func() error {
+		closed = true
+		return nil
+	})
+
+	err := app.Run(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "address in use")
+	assert.True(t, closed)
+	assert.False(t, app.Ready())
+}
@@ -0,0 +1,188 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/keystore"
+	"user-service/internal/lockout"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestLockoutPolicyDelays(t *testing.T) {
+	expected := []time.Duration{0, 0, time.Second, 2 * time.Second, 15 * time.Minute, 15 * time.Minute}
+	for i, want := range expected {
+		assert.Equal(t, want, testLockoutPolicy.DelayAfter(i+1), "failure %d", i+1)
+	}
+
+	capped := testLockoutPolicy
+	capped.Threshold = 0
+	assert.Equal(t, 30*time.Second, capped.DelayAfter(100))
+}
+
+type lockoutFixture struct {
+	service  services.UserService
+	users    *fakeUserRepository
+	accounts *lockout.MemoryTracker
+	router   *gin.Engine
+	clock    time.Time
+}
+
+func setupLockout(t *testing.T) *lockoutFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+
+	users := newFakeUserRepository()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{
+		Email:    "locked@example.com",
+		Username: "locked",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}))
+
+	f := &lockoutFixture{users: users, clock: time.Now()}
+	f.accounts = lockout.NewMemoryTracker(testLockoutPolicy)
+	f.accounts.SetClock(func() time.Time { return f.clock })
+
+	// A lenient IP policy keeps these tests about the account counter.
+	ipPolicy := testLockoutPolicy
+	ipPolicy.FreeAttempts, ipPolicy.Threshold = 100, 0
+	guard := lockout.NewGuard(f.accounts, lockout.NewMemoryTracker(ipPolicy))
+
+	f.service = services.NewUserService(users, newFakeSessionRepository(), nil, noMFA{}, keystore.NewStaticStore(key),
+		revocation.NewMemoryStore(), guard, newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+
+	f.router = gin.New()
+	f.router.POST("/login", handlers.NewUserHandler(f.service).Login)
+	return f
+}
+
+func (f *lockoutFixture) login(password string) error {
+	_, _, err := f.service.Login(&models.LoginRequest{Email: "locked@example.com", Password: password}, models.ClientInfo{IPAddress: "10.0.0.1"})
+	return err
+}
+
+// lockOut fails logins, waiting out each back-off, until the account is
+// locked for the full lockout duration.
+func (f *lockoutFixture) lockOut(t *testing.T) {
+	t.Helper()
+
+	for i := 0; i < 2*testLockoutPolicy.Threshold; i++ {
+		var locked *lockout.LockedError
+		if errors.As(f.login("wrong"), &locked) {
+			if locked.RetryAfter == testLockoutPolicy.LockoutDuration {
+				return
+			}
+			f.clock = f.clock.Add(locked.RetryAfter)
+		}
+	}
+	t.Fatal("account was never locked out")
+}
+
+func TestLoginBacksOffAndLocksOut(t *testing.T) {
+	f := setupLockout(t)
+
+	// Two free failures, then every failure imposes a growing wait.
+	for i := 0; i < 2; i++ {
+		require.EqualError(t, f.login("wrong"), "invalid credentials")
+	}
+	require.EqualError(t, f.login("wrong"), "invalid credentials")
+
+	var locked *lockout.LockedError
+	require.True(t, errors.As(f.login("password123"), &locked), "even the right password waits out the back-off")
+	assert.Equal(t, time.Second, locked.RetryAfter)
+
+	f.clock = f.clock.Add(time.Second)
+	require.EqualError(t, f.login("wrong"), "invalid credentials")
+	f.clock = f.clock.Add(2 * time.Second)
+	require.EqualError(t, f.login("wrong"), "invalid credentials")
+
+	require.True(t, errors.As(f.login("password123"), &locked))
+	assert.Equal(t, 15*time.Minute, locked.RetryAfter)
+
+	f.clock = f.clock.Add(15 * time.Minute)
+	require.NoError(t, f.login("password123"))
+
+	// Success clears the account's failures.
+	require.EqualError(t, f.login("wrong"), "invalid credentials")
+	require.EqualError(t, f.login("wrong"), "invalid credentials")
+	require.EqualError(t, f.login("wrong"), "invalid credentials")
+}
+
+func TestLoginHandlerReturnsRetryAfter(t *testing.T) {
+	f := setupLockout(t)
+
+	f.lockOut(t)
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email":"locked@example.com","password":"password123"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "900", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "TOO_MANY_ATTEMPTS")
+}
+
+func TestAdminUnlockLiftsLockout(t *testing.T) {
+	f := setupLockout(t)
+
+	f.lockOut(t)
+	var locked *lockout.LockedError
+	require.True(t, errors.As(f.login("password123"), &locked))
+
+	stored, err := f.users.GetByEmail("locked@example.com")
+	require.NoError(t, err)
+	require.NoError(t, f.service.UnlockLogin(stored.ID))
+
+	assert.NoError(t, f.login("password123"))
+}
+
+func TestRedisTrackerSharesFailures(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	// Two replicas see the same counter.
+	a := lockout.NewRedisTracker(client, testLockoutPolicy)
+	b := lockout.NewRedisTracker(client, testLockoutPolicy)
+
+	for i := 0; i < 4; i++ {
+		_, err := a.Fail(ctx, "account:x@example.com")
+		require.NoError(t, err)
+	}
+	wait, err := b.Fail(ctx, "account:x@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, wait)
+
+	remaining, err := b.Check(ctx, "account:x@example.com")
+	require.NoError(t, err)
+	assert.Greater(t, remaining, 14*time.Minute)
+
+	require.NoError(t, a.Reset(ctx, "account:x@example.com"))
+	remaining, err = b.Check(ctx, "account:x@example.com")
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+}
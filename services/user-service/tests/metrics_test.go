@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/lockout"
+	"user-service/internal/metrics"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/password"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubLoginService answers Login with a fixed outcome; every other method is
+// left to the nil embedded interface.
+type stubLoginService struct {
+	services.UserService
+	challenge *models.MFAChallenge
+	err       error
+}
+
+func (s stubLoginService) Login(*models.LoginRequest, models.ClientInfo) (*models.LoginResponse, *models.MFAChallenge, error) {
+	if s.err != nil || s.challenge != nil {
+		return nil, s.challenge, s.err
+	}
+	return &models.LoginResponse{}, nil, nil
+}
+
+func TestInstrumentedUserServiceCountsLoginOutcomes(t *testing.T) {
+	cases := []struct {
+		name   string
+		stub   stubLoginService
+		result string
+		reason string
+	}{
+		{"success", stubLoginService{}, metrics.ResultSuccess, ""},
+		{"mfa", stubLoginService{challenge: &models.MFAChallenge{}}, metrics.ResultMFARequired, ""},
+		{"bad password", stubLoginService{err: services.ErrInvalidCredentials}, metrics.ResultFailure, "invalid_credentials"},
+		{"locked", stubLoginService{err: &lockout.LockedError{RetryAfter: time.Minute}}, metrics.ResultFailure, "locked"},
+		{"deactivated", stubLoginService{err: services.ErrAccountDeactivated}, metrics.ResultFailure, "deactivated"},
+		{"database down", stubLoginService{err: errors.New("connection refused")}, metrics.ResultFailure, metrics.ReasonError},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			counter := metrics.Logins.WithLabelValues(tc.result, tc.reason)
+			before := testutil.ToFloat64(counter)
+
+			_, _, _ = services.NewInstrumentedUserService(tc.stub).Login(&models.LoginRequest{}, models.ClientInfo{})
+
+			assert.Equal(t, before+1, testutil.ToFloat64(counter))
+		})
+	}
+}
+
+func TestMetricsMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.MetricsMiddleware())
+	router.GET("/api/v1/users/:id", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
+	for _, path := range []string{"/api/v1/users/1", "/api/v1/users/2", "/wp-login.php"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	body, _ := io.ReadAll(w.Body)
+
+	assert.Contains(t, string(body), `user_service_http_request_duration_seconds_count{method="GET",route="/api/v1/users/:id",status="204"}`)
+	assert.Contains(t, string(body), `route="unmatched",status="404"`)
+	assert.NotContains(t, string(body), "/api/v1/users/1")
+	assert.NotContains(t, string(body), "wp-login")
+}
+
+func TestAuthMiddlewareCountsTokenValidationFailures(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	auth := middleware.NewAuthMiddleware(tokens.NewValidator(nil, revocation.NewMemoryStore(), nil), nil)
+	router := gin.New()
+	router.GET("/me", auth.RequireAuth(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	missing := metrics.TokenValidationFailures.WithLabelValues("missing")
+	malformed := metrics.TokenValidationFailures.WithLabelValues("malformed_header")
+	missingBefore, malformedBefore := testutil.ToFloat64(missing), testutil.ToFloat64(malformed)
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/me", nil))
+	req := httptest.NewRequest(http.MethodGet, "/me", nil)
+	req.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, missingBefore+1, testutil.ToFloat64(missing))
+	assert.Equal(t, malformedBefore+1, testutil.ToFloat64(malformed))
+}
+
+func TestInstrumentHasherObservesDurations(t *testing.T) {
+	inner, err := password.NewHasher(config.PasswordHashConfig{Algorithm: password.AlgorithmBcrypt, BcryptCost: 4})
+	require.NoError(t, err)
+	hasher := metrics.InstrumentHasher(inner, password.AlgorithmBcrypt)
+
+	encoded, err := hasher.Hash("s3cret-Passw0rd")
+	require.NoError(t, err)
+	ok, err := hasher.Verify("s3cret-Passw0rd", encoded)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Contains(t, w.Body.String(), `user_service_password_hash_duration_seconds_count{algorithm="bcrypt",operation="hash"}`)
+	assert.Contains(t, w.Body.String(), `user_service_password_hash_duration_seconds_count{algorithm="bcrypt",operation="verify"}`)
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/keystore"
+	"user-service/internal/lockout"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/secretbox"
+	"user-service/internal/services"
+	"user-service/internal/totp"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type mfaFixture struct {
+	service services.UserService
+	secret  string
+	router  *gin.Engine
+	clock   time.Time
+}
+
+// setupMFALogin signs in a user with an enabled TOTP factor. The account
+// tracker follows the fixture clock so back-off can be skipped.
+func setupMFALogin(t *testing.T) *mfaFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+
+	users := newFakeUserRepository()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{Email: "mfa@example.com", Username: "mfa", Password: string(hashed), Role: "user", IsActive: true}
+	require.NoError(t, users.Create(user))
+
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	factors := newFakeMFARepository()
+	require.NoError(t, factors.SavePending(user.ID, secret))
+	require.NoError(t, factors.Enable(user.ID, nil))
+
+	f := &mfaFixture{secret: secret, clock: time.Now()}
+	accounts := lockout.NewMemoryTracker(testLockoutPolicy)
+	accounts.SetClock(func() time.Time { return f.clock })
+	ipPolicy := testLockoutPolicy
+	ipPolicy.FreeAttempts, ipPolicy.Threshold = 100, 0
+	guard := lockout.NewGuard(accounts, lockout.NewMemoryTracker(ipPolicy))
+
+	mfa := services.NewMFAService(users, factors,
+		config.MFAConfig{Issuer: "Test", ChallengeTTL: 5, RecoveryCodeCount: 2, EncryptionKey: testEncryptionKey},
+		config.AuthConfig{TokenSigningSecret: "test-secret"})
+	f.service = services.NewUserService(users, newFakeSessionRepository(), nil, mfa, keystore.NewStaticStore(key),
+		revocation.NewMemoryStore(), guard, newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+
+	f.router = gin.New()
+	f.router.POST("/mfa/verify", handlers.NewUserHandler(f.service).VerifyMFA)
+	return f
+}
+
+func (f *mfaFixture) login(password string) (*models.MFAChallenge, error) {
+	_, challenge, err := f.service.Login(&models.LoginRequest{Email: "mfa@example.com", Password: password}, models.ClientInfo{IPAddress: "10.0.0.1"})
+	return challenge, err
+}
+
+func (f *mfaFixture) challenge(t *testing.T) string {
+	t.Helper()
+
+	challenge, err := f.login("password123")
+	require.NoError(t, err)
+	require.NotNil(t, challenge)
+	return challenge.ChallengeToken
+}
+
+func (f *mfaFixture) verify(challengeToken, code string) error {
+	_, err := f.service.VerifyMFA(&models.MFAVerifyRequest{ChallengeToken: challengeToken, Code: code}, models.ClientInfo{IPAddress: "10.0.0.1"})
+	return err
+}
+
+func (f *mfaFixture) code(t *testing.T) string {
+	t.Helper()
+
+	code, err := totp.GenerateCode(f.secret, time.Now())
+	require.NoError(t, err)
+	return code
+}
+
+func TestMFAChallengeAllowsOneAttempt(t *testing.T) {
+	f := setupMFALogin(t)
+
+	challenge := f.challenge(t)
+	require.ErrorIs(t, f.verify(challenge, "000000"), services.ErrInvalidMFACode)
+	assert.ErrorIs(t, f.verify(challenge, f.code(t)), services.ErrInvalidMFAChallenge, "a used challenge cannot be retried")
+
+	assert.NoError(t, f.verify(f.challenge(t), f.code(t)))
+}
+
+func TestMFAFailuresLockTheAccount(t *testing.T) {
+	f := setupMFALogin(t)
+
+	for i := 0; i < testLockoutPolicy.FreeAttempts; i++ {
+		require.ErrorIs(t, f.verify(f.challenge(t), "000000"), services.ErrInvalidMFACode)
+	}
+
+	var locked *lockout.LockedError
+	require.True(t, errors.As(f.verify(f.challenge(t), "000000"), &locked), "the third wrong code imposes a wait")
+	assert.Equal(t, time.Second, locked.RetryAfter)
+
+	_, err := f.login("password123")
+	require.True(t, errors.As(err, &locked), "the password alone does not lift the wait")
+
+	f.clock = f.clock.Add(time.Second)
+	w := postJSON(f.router, "/mfa/verify", models.MFAVerifyRequest{ChallengeToken: f.challenge(t), Code: "000000"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+}
+
+func TestMFALoginClearsFailuresOnlyAfterSecondFactor(t *testing.T) {
+	f := setupMFALogin(t)
+
+	for i := 0; i < testLockoutPolicy.FreeAttempts; i++ {
+		_, err := f.login("wrong")
+		require.ErrorIs(t, err, services.ErrInvalidCredentials)
+	}
+
+	// The right password alone leaves the failures in place.
+	challenge := f.challenge(t)
+	_, err := f.login("wrong")
+	require.ErrorIs(t, err, services.ErrInvalidCredentials)
+	var locked *lockout.LockedError
+	_, err = f.login("password123")
+	require.True(t, errors.As(err, &locked))
+
+	f.clock = f.clock.Add(locked.RetryAfter)
+	require.True(t, errors.As(f.verify(challenge, "000000"), &locked), "a wrong code is another failure")
+	f.clock = f.clock.Add(locked.RetryAfter)
+	require.NoError(t, f.verify(f.challenge(t), f.code(t)))
+
+	for i := 0; i < testLockoutPolicy.FreeAttempts; i++ {
+		_, err := f.login("wrong")
+		require.ErrorIs(t, err, services.ErrInvalidCredentials)
+	}
+	_, err = f.login("password123")
+	assert.NoError(t, err, "a completed second factor clears the account's failures")
+}
+
+func TestMFASecretsAreEncryptedAtRest(t *testing.T) {
+	users := newFakeUserRepository()
+	enrolling := &models.User{Email: "enroll@example.com", Username: "enroll", Role: "user", IsActive: true}
+	legacy := &models.User{Email: "legacy@example.com", Username: "legacy", Role: "user", IsActive: true}
+	require.NoError(t, users.Create(enrolling))
+	require.NoError(t, users.Create(legacy))
+
+	factors := newFakeMFARepository()
+	newService := func(key string) services.MFAService {
+		return services.NewMFAService(users, factors,
+			config.MFAConfig{Issuer: "Test", ChallengeTTL: 5, RecoveryCodeCount: 2, EncryptionKey: key},
+			config.AuthConfig{TokenSigningSecret: "test-secret"})
+	}
+	service := newService(testEncryptionKey)
+	codeFor := func(secret string) string {
+		code, err := totp.GenerateCode(secret, time.Now())
+		require.NoError(t, err)
+		return code
+	}
+
+	enrollment, err := service.Enroll(enrolling)
+	require.NoError(t, err)
+	stored, _ := factors.GetByUserID(enrolling.ID)
+	assert.True(t, secretbox.Sealed(stored.Secret))
+	assert.NotContains(t, stored.Secret, enrollment.Secret)
+
+	_, err = service.Confirm(enrolling.ID, codeFor(enrollment.Secret))
+	require.NoError(t, err)
+
+	// Without the key the stored value is useless.
+	_, err = newService("another-key-another-key-another-k").RegenerateRecoveryCodes(enrolling.ID, codeFor(enrollment.Secret))
+	assert.ErrorIs(t, err, secretbox.ErrCorrupt)
+
+	// Secrets stored in plaintext before keep working and get encrypted.
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	require.NoError(t, factors.SavePending(legacy.ID, secret))
+	require.NoError(t, factors.Enable(legacy.ID, nil))
+
+	encrypted, err := service.EncryptStoredSecrets()
+	require.NoError(t, err)
+	assert.Equal(t, 1, encrypted)
+	stored, _ = factors.GetByUserID(legacy.ID)
+	assert.True(t, secretbox.Sealed(stored.Secret))
+
+	_, err = service.RegenerateRecoveryCodes(legacy.ID, codeFor(secret))
+	assert.NoError(t, err)
+
+	encrypted, err = service.EncryptStoredSecrets()
+	require.NoError(t, err)
+	assert.Zero(t, encrypted)
+}
@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"testing/fstest"
+
+	"user-service/internal/database"
+	"user-service/migrations"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrationsOrdersAndPairsScripts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000010_add_index.up.sql":      {Data: []byte("CREATE INDEX a ON t(a);")},
+		"000002_create_table.up.sql":   {Data: []byte("CREATE TABLE t (a INT);")},
+		"000002_create_table.down.sql": {Data: []byte("DROP TABLE t;")},
+	}
+
+	loaded, err := database.LoadMigrations(fsys)
+	require.NoError(t, err)
+	require.Len(t, loaded, 2)
+
+	assert.Equal(t, int64(2), loaded[0].Version)
+	assert.Equal(t, "create_table", loaded[0].Name)
+	assert.Equal(t, "DROP TABLE t;", loaded[0].Down)
+	sum := sha256.Sum256([]byte("CREATE TABLE t (a INT);"))
+	assert.Equal(t, hex.EncodeToString(sum[:]), loaded[0].Checksum)
+
+	assert.Equal(t, int64(10), loaded[1].Version)
+	assert.Empty(t, loaded[1].Down)
+}
+
+func TestLoadMigrationsRejectsBadSets(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad filename": {"create_table.up.sql": {Data: []byte("SELECT 1;")}},
+		"no up script": {"000001_create_table.down.sql": {Data: []byte("SELECT 1;")}},
+		"version zero": {"000000_create_table.up.sql": {Data: []byte("SELECT 1;")}},
+		"two names": {
+			"000001_create_table.up.sql": {Data: []byte("SELECT 1;")},
+			"000001_other_name.up.sql":   {Data: []byte("SELECT 1;")},
+		},
+	}
+
+	for name, fsys := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := database.LoadMigrations(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEmbeddedMigrationsAreReversible(t *testing.T) {
+	loaded, err := database.LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for i, m := range loaded {
+		assert.Equal(t, int64(i+1), m.Version, "versions should be contiguous")
+		assert.NotEmpty(t, m.Down, "migration %d_%s has no down script", m.Version, m.Name)
+	}
+}
@@ -0,0 +1,256 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/events"
+	"user-service/internal/handlers"
+	"user-service/internal/models"
+	"user-service/internal/outbox"
+	"user-service/internal/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testOutboxConfig = config.OutboxConfig{
+	PollInterval:   10,
+	BatchSize:      2,
+	MaxAttempts:    3,
+	RetryBaseDelay: 5,
+	RetryMaxDelay:  60,
+	Retention:      1,
+}
+
+// flakyPublisher fails the first failures calls, then publishes to memory.
+type flakyPublisher struct {
+	*events.MemoryPublisher
+	mu       sync.Mutex
+	failures int
+}
+
+func (p *flakyPublisher) Publish(ctx context.Context, event *events.Envelope) error {
+	p.mu.Lock()
+	if p.failures > 0 {
+		p.failures--
+		p.mu.Unlock()
+		return errors.New("stream unavailable")
+	}
+	p.mu.Unlock()
+	return p.MemoryPublisher.Publish(ctx, event)
+}
+
+func newUserEvent(t *testing.T, eventType, userID string) *events.Envelope {
+	t.Helper()
+	event, err := events.NewUserEvent(eventType, &models.User{
+		ID:       userID,
+		Email:    userID + "@example.com",
+		Username: userID,
+		Role:     "user",
+		IsActive: eventType != events.UserDeleted,
+	})
+	require.NoError(t, err)
+	return event
+}
+
+func TestUserEventEnvelopeMatchesContract(t *testing.T) {
+	event := newUserEvent(t, events.UserCreated, "u1")
+
+	raw, err := json.Marshal(event)
+	require.NoError(t, err)
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+
+	assert.NotEmpty(t, decoded["eventId"])
+	assert.Equal(t, "user.created", decoded["eventType"])
+	assert.Equal(t, "1.0", decoded["version"])
+	assert.Equal(t, "user-service", decoded["source"])
+	_, err = time.Parse(time.RFC3339, decoded["timestamp"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"userId":     "u1",
+		"email":      "u1@example.com",
+		"username":   "u1",
+		"role":       "user",
+		"isActive":   true,
+		"isVerified": false,
+	}, decoded["data"])
+}
+
+func TestRelayPublishesPendingEventsInOrder(t *testing.T) {
+	repo := newFakeOutboxRepository()
+	first := newUserEvent(t, events.UserCreated, "u1")
+	first.Timestamp = time.Now().Add(-3 * time.Second)
+	second := newUserEvent(t, events.UserUpdated, "u1")
+	second.Timestamp = time.Now().Add(-2 * time.Second)
+	third := newUserEvent(t, events.UserDeleted, "u1")
+	third.Timestamp = time.Now().Add(-time.Second)
+	for _, e := range []*events.Envelope{third, first, second} {
+		repo.enqueue(t, "u1", e)
+	}
+
+	publisher := events.NewMemoryPublisher()
+	relay := outbox.NewRelay(repo, publisher, testOutboxConfig)
+
+	claimed, err := relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, claimed)
+	claimed, err = relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+
+	published := publisher.Published()
+	require.Len(t, published, 3)
+	assert.Equal(t, []string{first.EventID, second.EventID, third.EventID},
+		[]string{published[0].EventID, published[1].EventID, published[2].EventID})
+	assert.JSONEq(t, string(first.Data), string(published[0].Data))
+	assert.Equal(t, models.OutboxPublished, repo.get(first.EventID).Status)
+
+	// Nothing is published twice.
+	claimed, err = relay.RelayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+}
+
+func TestRelayRetriesWithBackoffThenDeadLetters(t *testing.T) {
+	repo := newFakeOutboxRepository()
+	event := newUserEvent(t, events.UserCreated, "u1")
+	repo.enqueue(t, "u1", event)
+
+	publisher := &flakyPublisher{MemoryPublisher: events.NewMemoryPublisher(), failures: 5}
+	relay := outbox.NewRelay(repo, publisher, testOutboxConfig)
+	ctx := context.Background()
+
+	_, err := relay.RelayBatch(ctx)
+	require.NoError(t, err)
+	stored := repo.get(event.EventID)
+	assert.Equal(t, models.OutboxPending, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+	require.NotNil(t, stored.LastError)
+	assert.Equal(t, "stream unavailable", *stored.LastError)
+	assert.WithinDuration(t, time.Now().Add(5*time.Second), stored.NextAttemptAt, time.Second)
+
+	// Not due yet.
+	claimed, err := relay.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+
+	repo.makeDue()
+	_, err = relay.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(10*time.Second), repo.get(event.EventID).NextAttemptAt, time.Second)
+
+	repo.makeDue()
+	_, err = relay.RelayBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.OutboxDead, repo.get(event.EventID).Status)
+	assert.Equal(t, 3, repo.get(event.EventID).Attempts)
+	assert.Empty(t, publisher.Published())
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	backoff := outbox.Backoff{Base: 5 * time.Second, Max: time.Minute}
+
+	assert.Equal(t, 5*time.Second, backoff.DelayAfter(1))
+	assert.Equal(t, 10*time.Second, backoff.DelayAfter(2))
+	assert.Equal(t, 40*time.Second, backoff.DelayAfter(4))
+	assert.Equal(t, time.Minute, backoff.DelayAfter(5))
+	assert.Equal(t, time.Minute, backoff.DelayAfter(1000))
+}
+
+func TestRelayRunStopsOnCancel(t *testing.T) {
+	repo := newFakeOutboxRepository()
+	repo.enqueue(t, "u1", newUserEvent(t, events.UserCreated, "u1"))
+	publisher := events.NewMemoryPublisher()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		outbox.NewRelay(repo, publisher, testOutboxConfig).Run(ctx)
+		close(done)
+	}()
+
+	assert.Eventually(t, func() bool { return len(publisher.Published()) == 1 }, time.Second, 5*time.Millisecond)
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("relay did not stop")
+	}
+}
+
+func TestRedisPublisherAppendsToTypedStream(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	publisher := events.NewRedisPublisher(client, "events:", 1000)
+	event := newUserEvent(t, events.UserCreated, "u1")
+
+	require.NoError(t, publisher.Publish(context.Background(), event))
+
+	entries, err := client.XRange(context.Background(), "events:user.created", "-", "+").Result()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, event.EventID, entries[0].Values["eventId"])
+	assert.Equal(t, "user.created", entries[0].Values["eventType"])
+
+	var decoded events.Envelope
+	require.NoError(t, json.Unmarshal([]byte(entries[0].Values["payload"].(string)), &decoded))
+	assert.Equal(t, event.EventID, decoded.EventID)
+	assert.Equal(t, "user-service", decoded.Source)
+}
+
+func TestDeadLetterEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newFakeOutboxRepository()
+	event := newUserEvent(t, events.UserDeleted, "u1")
+	repo.enqueue(t, "u1", event)
+	require.NoError(t, repo.MarkDead(event.EventID, "stream unavailable"))
+
+	outboxHandler := handlers.NewOutboxHandler(services.NewOutboxService(repo))
+	router := gin.New()
+	router.GET("/dead-letters", outboxHandler.ListDeadLetters)
+	router.POST("/dead-letters/:id/requeue", outboxHandler.RequeueDeadLetter)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dead-letters?limit=10", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list struct {
+		Data       []models.OutboxEvent `json:"data"`
+		Pagination models.Pagination    `json:"pagination"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, event.EventID, list.Data[0].ID)
+	assert.Equal(t, models.Pagination{Page: 1, Limit: 10, Total: 1, TotalPages: 1}, list.Pagination)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/dead-letters?limit=500", nil))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dead-letters/not-a-uuid/requeue", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/dead-letters/"+event.EventID+"/requeue", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, models.OutboxPending, repo.get(event.EventID).Status)
+
+	// Requeued events go out on the next poll.
+	publisher := events.NewMemoryPublisher()
+	_, err := outbox.NewRelay(repo, publisher, testOutboxConfig).RelayBatch(context.Background())
+	require.NoError(t, err)
+	require.Len(t, publisher.Published(), 1)
+	assert.Equal(t, event.EventID, publisher.Published()[0].EventID)
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"user-service/internal/config"
+	"user-service/internal/keystore"
+	"user-service/internal/models"
+	"user-service/internal/password"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHasherEncodesArgon2idInPHCFormat(t *testing.T) {
+	hasher := newTestHasher(t)
+
+	encoded, err := hasher.Hash("c0rrect-Horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encoded, "$argon2id$v=19$m=1024,t=1,p=1$"), encoded)
+	assert.Len(t, strings.Split(encoded, "$"), 6)
+
+	match, err := hasher.Verify("c0rrect-Horse", encoded)
+	require.NoError(t, err)
+	assert.True(t, match)
+
+	match, err = hasher.Verify("wrong-Horse", encoded)
+	require.NoError(t, err)
+	assert.False(t, match)
+
+	assert.False(t, hasher.NeedsRehash(encoded))
+}
+
+func TestHasherVerifiesBcryptAndAsksForUpgrade(t *testing.T) {
+	hasher := newTestHasher(t)
+	legacy, err := bcrypt.GenerateFromPassword([]byte("c0rrect-Horse"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	match, err := hasher.Verify("c0rrect-Horse", string(legacy))
+	require.NoError(t, err)
+	assert.True(t, match)
+	assert.True(t, hasher.NeedsRehash(string(legacy)))
+
+	_, err = hasher.Verify("c0rrect-Horse", "plaintext")
+	assert.ErrorIs(t, err, password.ErrUnknownHashFormat)
+}
+
+func TestHasherRehashesWeakerParameters(t *testing.T) {
+	weak := newTestHasher(t)
+	strong, err := password.NewHasher(config.PasswordHashConfig{
+		Algorithm:         password.AlgorithmArgon2id,
+		Argon2Memory:      2048,
+		Argon2Iterations:  2,
+		Argon2Parallelism: 1,
+	})
+	require.NoError(t, err)
+
+	encoded, err := weak.Hash("c0rrect-Horse")
+	require.NoError(t, err)
+	assert.True(t, strong.NeedsRehash(encoded))
+
+	// Stronger hashes than configured are left alone.
+	encoded, err = strong.Hash("c0rrect-Horse")
+	require.NoError(t, err)
+	assert.False(t, weak.NeedsRehash(encoded))
+
+	// Switching back to bcrypt upgrades Argon2id hashes as well.
+	bcryptHasher, err := password.NewHasher(config.PasswordHashConfig{Algorithm: password.AlgorithmBcrypt, BcryptCost: bcrypt.MinCost})
+	require.NoError(t, err)
+	assert.True(t, bcryptHasher.NeedsRehash(encoded))
+}
+
+func TestNewHasherRejectsBadConfig(t *testing.T) {
+	_, err := password.NewHasher(config.PasswordHashConfig{Algorithm: "md5"})
+	assert.ErrorIs(t, err, password.ErrUnknownAlgorithm)
+
+	_, err = password.NewHasher(config.PasswordHashConfig{Algorithm: password.AlgorithmBcrypt, BcryptCost: 64})
+	assert.Error(t, err)
+
+	_, err = password.NewHasher(config.PasswordHashConfig{Algorithm: password.AlgorithmArgon2id, Argon2Iterations: 1, Argon2Parallelism: 1})
+	assert.Error(t, err)
+}
+
+func TestLoginUpgradesLegacyHash(t *testing.T) {
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+
+	users := newFakeUserRepository()
+	legacy, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{
+		Email:    "legacy@example.com",
+		Username: "legacy",
+		Password: string(legacy),
+		Role:     "user",
+		IsActive: true,
+	}
+	require.NoError(t, users.Create(user))
+
+	service := services.NewUserService(users, newFakeSessionRepository(), nil, noMFA{}, keystore.NewStaticStore(key), revocation.NewMemoryStore(), newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	login := func() error {
+		_, _, err := service.Login(&models.LoginRequest{Email: "legacy@example.com", Password: "password123"}, models.ClientInfo{})
+		return err
+	}
+
+	require.NoError(t, login())
+
+	stored, _ := users.GetByID(user.ID)
+	assert.True(t, strings.HasPrefix(stored.Password, "$argon2id$"), stored.Password)
+	assertPasswordHash(t, stored.Password, "password123")
+	assert.NoError(t, login())
+}
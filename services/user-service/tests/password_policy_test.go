@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/keystore"
+	"user-service/internal/password"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rules(err *password.PolicyError) []string {
+	names := make([]string, len(err.Violations))
+	for i, v := range err.Violations {
+		names[i] = v.Rule
+	}
+	return names
+}
+
+func TestPasswordPolicyRules(t *testing.T) {
+	policy := &password.Policy{
+		MinLength:      10,
+		MaxLength:      72,
+		RequireUpper:   true,
+		RequireDigit:   true,
+		RequireSymbol:  true,
+		MinCharClasses: 3,
+	}
+	owner := password.Owner{Email: "minh.tran@example.com", Username: "minhtran"}
+
+	cases := []struct {
+		password string
+		want     []string
+	}{
+		{"Str0ng!Passphrase", nil},
+		{"short", []string{password.RuleMinLength, password.RuleUppercase, password.RuleDigit, password.RuleSymbol, password.RuleCharClasses}},
+		{"lowercaseonly!", []string{password.RuleUppercase, password.RuleDigit, password.RuleCharClasses}},
+		{"Xx1!minh.tran2024", []string{password.RuleContainsEmail}},
+		{"Xx1!MinhTran2024", []string{password.RuleContainsUsername}},
+		{strings.Repeat("Aa1!", 20), []string{password.RuleMaxLength}},
+	}
+
+	for _, tc := range cases {
+		err := policy.Validate(tc.password, owner)
+		if tc.want == nil {
+			assert.NoError(t, err, tc.password)
+			continue
+		}
+
+		var policyErr *password.PolicyError
+		require.ErrorAs(t, err, &policyErr, tc.password)
+		assert.Equal(t, tc.want, rules(policyErr), tc.password)
+	}
+}
+
+func TestBundledBreachedList(t *testing.T) {
+	index, err := password.LoadPrefixIndexFile("")
+	require.NoError(t, err)
+
+	for _, common := range []string{"password123", "qwerty123", "P@ssw0rd123", "123456789"} {
+		assert.True(t, index.Contains(common), common)
+	}
+	assert.False(t, index.Contains("correct-Horse-battery-9"))
+}
+
+func TestPrefixIndexReadsHIBPFormat(t *testing.T) {
+	// SHA-1("hunter2") with an occurrence count, as in the HIBP downloads.
+	index, err := password.LoadPrefixIndex(strings.NewReader("# comment\n\nf3bbbd66a63d4bf1747940578ec3d0103530e21d:17\n"))
+	require.NoError(t, err)
+
+	assert.True(t, index.Contains("hunter2"))
+	assert.False(t, index.Contains("hunter3"))
+
+	_, err = password.LoadPrefixIndex(strings.NewReader("not-a-hash\n"))
+	assert.Error(t, err)
+}
+
+func TestRegisterReportsPolicyViolations(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+
+	service := services.NewUserService(newFakeUserRepository(), newFakeSessionRepository(), nil, noMFA{}, keystore.NewStaticStore(key),
+		revocation.NewMemoryStore(), newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+
+	router := gin.New()
+	router.POST("/register", handlers.NewUserHandler(service).Register)
+
+	body := `{"email":"new@example.com","username":"newbie","password":"password123"}`
+	req := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	var resp struct {
+		Error struct {
+			Code    string               `json:"code"`
+			Details []password.Violation `json:"details"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "PASSWORD_POLICY_VIOLATION", resp.Error.Code)
+	require.Len(t, resp.Error.Details, 1)
+	assert.Equal(t, password.RuleBreached, resp.Error.Details[0].Rule)
+}
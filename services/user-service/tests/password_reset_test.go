@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/keystore"
+	"user-service/internal/mailer"
+	"user-service/internal/models"
+	"user-service/internal/password"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var resetLinkPattern = regexp.MustCompile(`https?://\S+/reset-password\?token=(\S+)`)
+
+type resetFixture struct {
+	service  services.PasswordResetService
+	users    *fakeUserRepository
+	sessions *fakeSessionRepository
+	resets   *fakePasswordResetRepository
+	mail     *mailer.MemoryMailer
+	user     *models.User
+}
+
+func setupPasswordReset(t *testing.T) *resetFixture {
+	t.Helper()
+
+	users := newFakeUserRepository()
+	sessions := newFakeSessionRepository()
+	passwords := newFakePasswordRepository(users, sessions)
+	resets := newFakePasswordResetRepository(passwords)
+	mail := mailer.NewMemoryMailer()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("oldpassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	user := &models.User{
+		Email:    "reset@example.com",
+		Username: "reset",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}
+	require.NoError(t, users.Create(user))
+
+	policy, hasher := newTestPasswordPolicy(t), newTestHasher(t)
+	service := services.NewPasswordResetService(
+		users,
+		resets,
+		passwords,
+		services.NewPasswordService(users, passwords, revocation.NewMemoryStore(), newTestGuard(), policy, hasher, testAccessTTL),
+		mail,
+		policy,
+		hasher,
+		config.AuthConfig{TokenSigningSecret: "test-secret", PasswordResetTokenTTL: 30, PasswordResetResendDelay: 60},
+		config.MailConfig{AppBaseURL: "http://shop.test"},
+	)
+	startResetMailer(t, service)
+
+	return &resetFixture{service: service, users: users, sessions: sessions, resets: resets, mail: mail, user: user}
+}
+
+func startResetMailer(t *testing.T, service services.PasswordResetService) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.SendResetEmails(ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+}
+
+// waitForResetMail waits until count emails were sent to address, the
+// mailer working in the background.
+func waitForResetMail(t *testing.T, mail *mailer.MemoryMailer, address string, count int) []mailer.Message {
+	t.Helper()
+
+	require.Eventually(t, func() bool { return len(mail.SentTo(address)) >= count }, time.Second, 5*time.Millisecond)
+	sent := mail.SentTo(address)
+	require.Len(t, sent, count)
+	return sent
+}
+
+func resetTokenFrom(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+
+	match := resetLinkPattern.FindStringSubmatch(msg.Body)
+	require.Len(t, match, 2, "reset link not found in email body")
+
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func TestPasswordResetUnknownEmailSendsNothing(t *testing.T) {
+	f := setupPasswordReset(t)
+
+	assert.NoError(t, f.service.RequestReset("nobody@example.com"))
+	// Requests are handled in order, so once the next one is mailed the
+	// unknown address has been dealt with.
+	require.NoError(t, f.service.RequestReset(f.user.Email))
+	waitForResetMail(t, f.mail, f.user.Email, 1)
+	assert.Len(t, f.mail.Sent(), 1)
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	f := setupPasswordReset(t)
+
+	require.NoError(t, f.sessions.CreateSession(
+		&models.UserSession{UserID: f.user.ID, ExpiresAt: time.Now().Add(time.Hour)},
+		&models.RefreshToken{UserID: f.user.ID, TokenHash: "refresh", ExpiresAt: time.Now().Add(time.Hour)},
+	))
+
+	require.NoError(t, f.service.RequestReset(f.user.Email))
+	token := resetTokenFrom(t, waitForResetMail(t, f.mail, f.user.Email, 1)[0])
+
+	require.NoError(t, f.service.ResetPassword(token, "n3w-Passphrase"))
+
+	stored, _ := f.users.GetByID(f.user.ID)
+	assertPasswordHash(t, stored.Password, "n3w-Passphrase")
+	assert.Zero(t, f.sessions.sessionCount(f.user.ID), "sessions must be revoked after a reset")
+
+	// Tokens are single use.
+	assert.ErrorIs(t, f.service.ResetPassword(token, "an0ther-Passphrase"), services.ErrInvalidResetToken)
+}
+
+func TestPasswordResetEnforcesPolicy(t *testing.T) {
+	f := setupPasswordReset(t)
+
+	require.NoError(t, f.service.RequestReset(f.user.Email))
+	token := resetTokenFrom(t, waitForResetMail(t, f.mail, f.user.Email, 1)[0])
+
+	err := f.service.ResetPassword(token, "reset")
+	var policyErr *password.PolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Contains(t, rules(policyErr), password.RuleContainsUsername)
+
+	// A rejected password leaves the token usable.
+	require.NoError(t, f.service.ResetPassword(token, "n3w-Passphrase"))
+	stored, _ := f.users.GetByID(f.user.ID)
+	assertPasswordHash(t, stored.Password, "n3w-Passphrase")
+}
+
+func TestPasswordResetRejectsTamperedToken(t *testing.T) {
+	f := setupPasswordReset(t)
+
+	require.NoError(t, f.service.RequestReset(f.user.Email))
+	token := resetTokenFrom(t, waitForResetMail(t, f.mail, f.user.Email, 1)[0])
+
+	assert.ErrorIs(t, f.service.ResetPassword(token+"x", "n3w-Passphrase"), services.ErrInvalidResetToken)
+	assert.ErrorIs(t, f.service.ResetPassword("not-a-token", "n3w-Passphrase"), services.ErrInvalidResetToken)
+}
+
+func TestPasswordResetIsThrottledAndOnlyLatestTokenIsValid(t *testing.T) {
+	f := setupPasswordReset(t)
+	other := &models.User{Email: "other@example.com", Username: "other", Role: "user", IsActive: true}
+	require.NoError(t, f.users.Create(other))
+
+	require.NoError(t, f.service.RequestReset(f.user.Email))
+	first := waitForResetMail(t, f.mail, f.user.Email, 1)[0]
+
+	// Inside the resend delay nothing is sent and the first link still works.
+	require.NoError(t, f.service.RequestReset(f.user.Email))
+	require.NoError(t, f.service.RequestReset(other.Email))
+	waitForResetMail(t, f.mail, other.Email, 1)
+	require.Len(t, f.mail.SentTo(f.user.Email), 1)
+
+	f.resets.backdate(f.user.ID, time.Minute)
+	require.NoError(t, f.service.RequestReset(f.user.Email))
+	sent := waitForResetMail(t, f.mail, f.user.Email, 2)
+
+	assert.ErrorIs(t, f.service.ResetPassword(resetTokenFrom(t, first), "n3w-Passphrase"), services.ErrInvalidResetToken)
+	assert.NoError(t, f.service.ResetPassword(resetTokenFrom(t, sent[1]), "n3w-Passphrase"))
+}
+
+func TestPasswordResetRevokesAccessTokens(t *testing.T) {
+	users := newFakeUserRepository()
+	sessions := newFakeSessionRepository()
+	passwords := newFakePasswordRepository(users, sessions)
+	mail := mailer.NewMemoryMailer()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("oldpassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+	user := &models.User{Email: "reset@example.com", Username: "reset", Password: string(hashed), Role: "user", IsActive: true}
+	require.NoError(t, users.Create(user))
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+	keys := keystore.NewStaticStore(key)
+	revocations := revocation.NewMemoryStore()
+	policy, hasher := newTestPasswordPolicy(t), newTestHasher(t)
+	auth := services.NewUserService(users, sessions, nil, noMFA{}, keys, revocations, newTestGuard(), policy, hasher, &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwordService := services.NewPasswordService(users, passwords, revocations, newTestGuard(), policy, hasher, testAccessTTL)
+	reset := services.NewPasswordResetService(users, newFakePasswordResetRepository(passwords), passwords, passwordService, mail, policy, hasher,
+		config.AuthConfig{TokenSigningSecret: "test-secret", PasswordResetTokenTTL: 30}, config.MailConfig{AppBaseURL: "http://shop.test"})
+	startResetMailer(t, reset)
+
+	// The attacker's token is valid, and the password change time is cached.
+	stolen, _, err := auth.Login(&models.LoginRequest{Email: user.Email, Password: "oldpassword"}, models.ClientInfo{})
+	require.NoError(t, err)
+	validator := tokens.NewValidator(keys, revocations, passwordService)
+	_, err = validator.Validate(context.Background(), stolen.AccessToken)
+	require.NoError(t, err)
+
+	require.NoError(t, reset.RequestReset(user.Email))
+	require.NoError(t, reset.ResetPassword(resetTokenFrom(t, waitForResetMail(t, mail, user.Email, 1)[0]), "n3w-Passphrase"))
+
+	var rejected *tokens.Error
+	_, err = validator.Validate(context.Background(), stolen.AccessToken)
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "revoked", rejected.Reason)
+
+	// Without the shared revocation, the refreshed change time still rejects it.
+	_, err = tokens.NewValidator(keys, revocation.NewMemoryStore(), passwordService).Validate(context.Background(), stolen.AccessToken)
+	require.ErrorAs(t, err, &rejected)
+	assert.Equal(t, "password_changed", rejected.Reason)
+}
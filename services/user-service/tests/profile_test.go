@@ -0,0 +1,226 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/keystore"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingVerification records the users verification links were sent to.
+type recordingVerification struct {
+	mu     sync.Mutex
+	issued []string
+}
+
+func (v *recordingVerification) IssueToken(user *models.User) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.issued = append(v.issued, user.Email)
+	return nil
+}
+
+func (v *recordingVerification) VerifyEmail(string) error        { return nil }
+func (v *recordingVerification) ResendVerification(string) error { return nil }
+
+type profileFixture struct {
+	users        *fakeUserRepository
+	verification *recordingVerification
+	user         *models.User
+	router       *gin.Engine
+}
+
+func setupProfile(t *testing.T) *profileFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+
+	users := newFakeUserRepository()
+	user := &models.User{Email: "profile@example.com", Username: "profile", Role: "user", IsActive: true, IsVerified: true}
+	require.NoError(t, users.Create(user))
+	require.NoError(t, users.Create(&models.User{Email: "taken@example.com", Username: "taken", Role: "user", IsActive: true}))
+
+	verification := &recordingVerification{}
+	service := services.NewUserService(users, newFakeSessionRepository(), verification, noMFA{}, keystore.NewStaticStore(key), revocation.NewMemoryStore(), newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	handler := handlers.NewUserHandler(service)
+
+	router := gin.New()
+	authenticated := router.Group("/", func(c *gin.Context) { c.Set("user_id", user.ID) })
+	authenticated.PUT("/profile", handler.UpdateProfile)
+	authenticated.PATCH("/profile", handler.PatchProfile)
+
+	return &profileFixture{users: users, verification: verification, user: user, router: router}
+}
+
+func (f *profileFixture) send(method, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/profile", strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func (f *profileFixture) stored(t *testing.T) *models.User {
+	t.Helper()
+
+	user, err := f.users.GetByID(f.user.ID)
+	require.NoError(t, err)
+	return user
+}
+
+func errorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	var body struct {
+		Error struct {
+			Code string `json:"code"`
+		} `json:"error"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body.Error.Code
+}
+
+func TestUpdateProfileChangesOnlyGivenFields(t *testing.T) {
+	f := setupProfile(t)
+
+	w := f.send(http.MethodPut, "application/json", `{"username":"renamed"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"username":"renamed"`)
+
+	stored := f.stored(t)
+	assert.Equal(t, "renamed", stored.Username)
+	assert.Equal(t, "profile@example.com", stored.Email)
+	assert.True(t, stored.IsVerified)
+	assert.Empty(t, f.verification.issued)
+}
+
+func TestUpdateProfileEmailChangeRequiresVerification(t *testing.T) {
+	f := setupProfile(t)
+
+	w := f.send(http.MethodPut, "application/json", `{"email":"moved@example.com"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	stored := f.stored(t)
+	assert.Equal(t, "moved@example.com", stored.Email)
+	assert.False(t, stored.IsVerified)
+	assert.Equal(t, []string{"moved@example.com"}, f.verification.issued)
+}
+
+func TestUpdateProfileConflicts(t *testing.T) {
+	f := setupProfile(t)
+
+	w := f.send(http.MethodPut, "application/json", `{"email":"taken@example.com"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "EMAIL_TAKEN", errorCode(t, w))
+
+	w = f.send(http.MethodPatch, "application/merge-patch+json", `{"username":"taken"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "USERNAME_TAKEN", errorCode(t, w))
+
+	assert.Equal(t, "profile", f.stored(t).Username)
+}
+
+func TestPatchProfileMergePatch(t *testing.T) {
+	f := setupProfile(t)
+
+	w := f.send(http.MethodPatch, "application/merge-patch+json", `{"username":"patched"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "patched", f.stored(t).Username)
+
+	// An empty patch changes nothing.
+	w = f.send(http.MethodPatch, "application/merge-patch+json", `{}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "patched", f.stored(t).Username)
+}
+
+func TestPatchProfileRejectsInvalidPatches(t *testing.T) {
+	f := setupProfile(t)
+
+	cases := []struct {
+		name, contentType, body string
+		status                  int
+	}{
+		{"null on required field", "application/merge-patch+json", `{"username":null}`, http.StatusUnprocessableEntity},
+		{"unknown field", "application/merge-patch+json", `{"role":"admin"}`, http.StatusUnprocessableEntity},
+		{"invalid value", "application/merge-patch+json", `{"email":"not-an-email"}`, http.StatusUnprocessableEntity},
+		{"not an object", "application/merge-patch+json", `["username"]`, http.StatusBadRequest},
+		{"wrong media type", "text/plain", `{"username":"patched"}`, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := f.send(http.MethodPatch, tc.contentType, tc.body)
+			assert.Equal(t, tc.status, w.Code, w.Body.String())
+		})
+	}
+
+	stored := f.stored(t)
+	assert.Equal(t, "profile", stored.Username)
+	assert.Equal(t, "user", stored.Role)
+}
+
+func TestUpdateProfileNameAndContactFields(t *testing.T) {
+	f := setupProfile(t)
+
+	w := f.send(http.MethodPut, "application/json",
+		`{"first_name":"Ada","last_name":"Lovelace","phone":"+442071234567","avatar_url":"https://cdn.example.com/ada.png"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"first_name":"Ada"`)
+
+	stored := f.stored(t)
+	assert.Equal(t, "Ada", stored.FirstName)
+	assert.Equal(t, "Lovelace", stored.LastName)
+	require.NotNil(t, stored.Phone)
+	assert.Equal(t, "+442071234567", *stored.Phone)
+	require.NotNil(t, stored.AvatarURL)
+	assert.Equal(t, "https://cdn.example.com/ada.png", *stored.AvatarURL)
+
+	// null clears optional fields and leaves the rest alone.
+	w = f.send(http.MethodPatch, "application/merge-patch+json", `{"phone":null,"avatar_url":null}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	stored = f.stored(t)
+	assert.Nil(t, stored.Phone)
+	assert.Nil(t, stored.AvatarURL)
+	assert.Equal(t, "Ada", stored.FirstName)
+
+	// An empty string is stored as NULL too, with PUT and with PATCH.
+	for _, tc := range []struct{ method, contentType string }{
+		{http.MethodPut, "application/json"},
+		{http.MethodPatch, "application/merge-patch+json"},
+	} {
+		w = f.send(http.MethodPut, "application/json", `{"phone":"+442071234567","avatar_url":"https://cdn.example.com/ada.png"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		w = f.send(tc.method, tc.contentType, `{"phone":"","avatar_url":""}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"phone":null`)
+
+		stored = f.stored(t)
+		assert.Nil(t, stored.Phone, tc.method)
+		assert.Nil(t, stored.AvatarURL, tc.method)
+	}
+
+	// Names are required columns, so they can be emptied but not nulled.
+	w = f.send(http.MethodPatch, "application/merge-patch+json", `{"first_name":null}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	w = f.send(http.MethodPatch, "application/merge-patch+json", `{"phone":"0123"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "Ada", f.stored(t).FirstName)
+}
@@ -0,0 +1,334 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/keystore"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/routes"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const rbacPassword = "Rbac-Passphrase-1"
+
+type rbacFixture struct {
+	router *gin.Engine
+	users  *fakeUserRepository
+	roles  *fakeRoleRepository
+	auth   services.UserService
+	key    *keystore.Key
+	ids    map[string]string
+}
+
+// setupRBAC mounts the real routes over one account per built-in role,
+// named after the role.
+func setupRBAC(t *testing.T) *rbacFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+	keys := keystore.NewStaticStore(key)
+	revocations := revocation.NewMemoryStore()
+
+	users := newFakeUserRepository()
+	hashed, err := bcrypt.GenerateFromPassword([]byte(rbacPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+	ids := make(map[string]string)
+	for _, role := range []string{models.RoleUser, models.RoleModerator, models.RoleAdmin} {
+		user := &models.User{Email: role + "@example.com", Username: role, Password: string(hashed), Role: role, IsActive: true}
+		require.NoError(t, users.Create(user))
+		ids[role] = user.ID
+	}
+
+	roles := newFakeRoleRepository(users)
+	roleService := services.NewRoleService(roles, users, revocations, testAccessTTL)
+	sessions := newFakeSessionRepository()
+	policy := newTestPasswordPolicy(t)
+	hasher := newTestHasher(t)
+	userService := services.NewUserService(users, sessions, &recordingVerification{}, noMFA{}, keys, revocations, newTestGuard(), policy, hasher, &recordingEmitter{}, roleService, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwordService := services.NewPasswordService(users, newFakePasswordRepository(users, sessions), revocations, newTestGuard(), policy, hasher, testAccessTTL)
+
+	router := gin.New()
+	routes.SetupRoutes(router, routes.Handlers{
+		User:  handlers.NewUserHandler(userService),
+		Admin: handlers.NewAdminHandler(userService, nil),
+		Roles: handlers.NewRoleHandler(roleService),
+	}, middleware.NewAuthMiddleware(tokens.NewValidator(keys, revocations, passwordService), roleService), nil)
+
+	return &rbacFixture{router: router, users: users, roles: roles, auth: userService, key: key, ids: ids}
+}
+
+func (f *rbacFixture) login(t *testing.T, role string) *models.LoginResponse {
+	t.Helper()
+
+	login, _, err := f.auth.Login(&models.LoginRequest{Email: role + "@example.com", Password: rbacPassword}, models.ClientInfo{})
+	require.NoError(t, err)
+	return login
+}
+
+func (f *rbacFixture) do(method, path, token string, payload interface{}) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	if payload != nil {
+		_ = json.NewEncoder(&body).Encode(payload)
+	}
+	req := httptest.NewRequest(method, path, &body)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	return w
+}
+
+func tokenScope(t *testing.T, token string) string {
+	t.Helper()
+
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(token, claims)
+	require.NoError(t, err)
+	scope, ok := claims["scope"].(string)
+	require.True(t, ok, "access token has no scope claim")
+	return scope
+}
+
+func TestRegistrationCannotChooseElevatedRole(t *testing.T) {
+	router, users := setupTestRouter(t)
+
+	for _, role := range []string{models.RoleAdmin, models.RoleModerator} {
+		w := postJSON(router, "/api/v1/auth/register", models.CreateUserRequest{
+			Email:    role + "@example.com",
+			Username: role + "-signup",
+			Password: testPassword,
+			Role:     role,
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+		stored, _ := users.GetByEmail(role + "@example.com")
+		assert.Nil(t, stored, "no account is created for role %s", role)
+	}
+
+	w := postJSON(router, "/api/v1/auth/register", models.CreateUserRequest{
+		Email:    "customer@example.com",
+		Username: "customer",
+		Password: testPassword,
+		Role:     models.RoleUser,
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	stored, _ := users.GetByEmail("customer@example.com")
+	require.NotNil(t, stored)
+	assert.Equal(t, models.RoleUser, stored.Role)
+}
+
+func TestAccessTokenCarriesRolePermissionsAsScope(t *testing.T) {
+	f := setupRBAC(t)
+
+	assert.Equal(t, "profile:read profile:write", tokenScope(t, f.login(t, models.RoleUser).AccessToken))
+	assert.Equal(t, "profile:read profile:write users:read", tokenScope(t, f.login(t, models.RoleModerator).AccessToken))
+	assert.Equal(t, strings.Join(seededRolePermissions[models.RoleAdmin], " "), tokenScope(t, f.login(t, models.RoleAdmin).AccessToken))
+}
+
+func TestRequirePermissionGatesRoutes(t *testing.T) {
+	f := setupRBAC(t)
+	customer := f.login(t, models.RoleUser).AccessToken
+	moderator := f.login(t, models.RoleModerator).AccessToken
+	admin := f.login(t, models.RoleAdmin).AccessToken
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"customer reads own profile", http.MethodGet, "/api/v1/user/profile", customer, http.StatusOK},
+		{"customer lists users", http.MethodGet, "/api/v1/admin/users", customer, http.StatusForbidden},
+		{"customer lists roles", http.MethodGet, "/api/v1/admin/roles", customer, http.StatusForbidden},
+		{"moderator lists users", http.MethodGet, "/api/v1/admin/users", moderator, http.StatusOK},
+		{"moderator unlocks account", http.MethodPost, "/api/v1/admin/users/" + f.ids[models.RoleUser] + "/unlock", moderator, http.StatusForbidden},
+		{"moderator lists roles", http.MethodGet, "/api/v1/admin/roles", moderator, http.StatusForbidden},
+		{"admin lists roles", http.MethodGet, "/api/v1/admin/roles", admin, http.StatusOK},
+		{"admin lists permissions", http.MethodGet, "/api/v1/admin/permissions", admin, http.StatusOK},
+		{"anonymous lists users", http.MethodGet, "/api/v1/admin/users", "", http.StatusUnauthorized},
+		{"anonymous looks up user", http.MethodGet, "/api/v1/users/" + f.ids[models.RoleUser], "", http.StatusUnauthorized},
+		{"customer looks up user", http.MethodGet, "/api/v1/users/" + f.ids[models.RoleModerator], customer, http.StatusForbidden},
+		{"moderator looks up user", http.MethodGet, "/api/v1/users/" + f.ids[models.RoleUser], moderator, http.StatusOK},
+		{"moderator looks up malformed id", http.MethodGet, "/api/v1/users/not-a-uuid", moderator, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := f.do(tc.method, tc.path, tc.token, nil)
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+			if tc.status == http.StatusForbidden {
+				assert.Equal(t, "FORBIDDEN", errorCode(t, w))
+			}
+		})
+	}
+}
+
+func TestTokensWithoutScopeUseCurrentRolePermissions(t *testing.T) {
+	f := setupRBAC(t)
+
+	// An access token issued before permissions were embedded
+	legacy := func(role string) string {
+		token := jwt.NewWithClaims(f.key.SigningMethod(), jwt.MapClaims{
+			"sub":   f.ids[role],
+			"email": role + "@example.com",
+			"role":  role,
+			"type":  "access",
+			"sid":   uuid.NewString(),
+			"jti":   uuid.NewString(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"iat":   time.Now().Unix(),
+		})
+		token.Header["kid"] = f.key.ID
+		signed, err := token.SignedString(f.key.Private)
+		require.NoError(t, err)
+		return signed
+	}
+
+	assert.Equal(t, http.StatusOK, f.do(http.MethodGet, "/api/v1/admin/users", legacy(models.RoleModerator), nil).Code)
+	assert.Equal(t, http.StatusForbidden, f.do(http.MethodGet, "/api/v1/admin/users", legacy(models.RoleUser), nil).Code)
+}
+
+func TestAdminManagesRoles(t *testing.T) {
+	f := setupRBAC(t)
+	admin := f.login(t, models.RoleAdmin).AccessToken
+
+	w := f.do(http.MethodPost, "/api/v1/admin/roles", admin, models.CreateRoleRequest{
+		Name:        "support",
+		Description: "Customer support",
+		Permissions: []string{"users:read", "profile:read", "users:read"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data models.Role `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, []string{"profile:read", "users:read"}, created.Data.Permissions)
+	assert.False(t, created.Data.IsSystem)
+
+	rejected := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		status int
+		code   string
+	}{
+		{"duplicate name", http.MethodPost, "/api/v1/admin/roles", models.CreateRoleRequest{Name: "support"}, http.StatusConflict, "ROLE_EXISTS"},
+		{"unknown permission", http.MethodPost, "/api/v1/admin/roles", models.CreateRoleRequest{Name: "auditor", Permissions: []string{"users:delete"}}, http.StatusUnprocessableEntity, "VALIDATION_ERROR"},
+		{"bad name", http.MethodPost, "/api/v1/admin/roles", models.CreateRoleRequest{Name: "Support Team"}, http.StatusUnprocessableEntity, "VALIDATION_ERROR"},
+		{"admin permissions", http.MethodPatch, "/api/v1/admin/roles/admin", models.UpdateRoleRequest{Permissions: []string{"profile:read"}}, http.StatusConflict, "ROLE_PROTECTED"},
+		{"delete built-in role", http.MethodDelete, "/api/v1/admin/roles/moderator", nil, http.StatusConflict, "ROLE_PROTECTED"},
+		{"unknown role", http.MethodGet, "/api/v1/admin/roles/nobody", nil, http.StatusNotFound, "ROLE_NOT_FOUND"},
+	}
+	for _, tc := range rejected {
+		t.Run(tc.name, func(t *testing.T) {
+			w := f.do(tc.method, tc.path, admin, tc.body)
+			require.Equal(t, tc.status, w.Code, w.Body.String())
+			assert.Equal(t, tc.code, errorCode(t, w))
+		})
+	}
+
+	description := "Tier 1 support"
+	w = f.do(http.MethodPatch, "/api/v1/admin/roles/support", admin, models.UpdateRoleRequest{
+		Description: &description,
+		Permissions: []string{"profile:read", "profile:write", "users:read"},
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	role, _ := f.roles.GetRole("support")
+	assert.Equal(t, "Tier 1 support", role.Description)
+	assert.Equal(t, []string{"profile:read", "profile:write", "users:read"}, role.Permissions)
+
+	// The moderator role can be narrowed; its holders lose the permission
+	w = f.do(http.MethodPatch, "/api/v1/admin/roles/moderator", admin, models.UpdateRoleRequest{Permissions: []string{"profile:read"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "profile:read", tokenScope(t, f.login(t, models.RoleModerator).AccessToken))
+
+	w = f.do(http.MethodDelete, "/api/v1/admin/roles/support", admin, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	role, _ = f.roles.GetRole("support")
+	assert.Nil(t, role)
+}
+
+func TestRemovingRolePermissionsRevokesItsTokens(t *testing.T) {
+	f := setupRBAC(t)
+	admin := f.login(t, models.RoleAdmin).AccessToken
+	moderator := f.login(t, models.RoleModerator).AccessToken
+	customer := f.login(t, models.RoleUser).AccessToken
+
+	// Adding a permission leaves existing tokens alone
+	w := f.do(http.MethodPatch, "/api/v1/admin/roles/moderator", admin, models.UpdateRoleRequest{Permissions: []string{"profile:read", "profile:write", "users:read", "users:write"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.Equal(t, http.StatusOK, f.do(http.MethodGet, "/api/v1/admin/users", moderator, nil).Code)
+
+	w = f.do(http.MethodPatch, "/api/v1/admin/roles/moderator", admin, models.UpdateRoleRequest{Permissions: []string{"profile:read"}})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = f.do(http.MethodGet, "/api/v1/admin/users", moderator, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "tokens issued with the old permissions stop working")
+	assert.Equal(t, "TOKEN_REVOKED", errorCode(t, w))
+	assert.Equal(t, http.StatusOK, f.do(http.MethodGet, "/api/v1/user/profile", customer, nil).Code, "other roles are unaffected")
+
+	fresh := f.login(t, models.RoleModerator).AccessToken
+	assert.Equal(t, http.StatusOK, f.do(http.MethodGet, "/api/v1/user/profile", fresh, nil).Code)
+	assert.Equal(t, http.StatusForbidden, f.do(http.MethodGet, "/api/v1/admin/users", fresh, nil).Code)
+}
+
+func TestAssignRoleAppliesOnNextRefresh(t *testing.T) {
+	f := setupRBAC(t)
+	admin := f.login(t, models.RoleAdmin).AccessToken
+	customer := f.login(t, models.RoleUser)
+	customerID := f.ids[models.RoleUser]
+
+	w := f.do(http.MethodPost, "/api/v1/admin/roles", admin, models.CreateRoleRequest{
+		Name:        "support",
+		Permissions: []string{"profile:read", "profile:write", "users:read"},
+	})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = f.do(http.MethodPut, "/api/v1/admin/users/"+customerID+"/role", admin, models.AssignRoleRequest{Role: "nobody"})
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = f.do(http.MethodPut, "/api/v1/admin/users/"+uuid.NewString()+"/role", admin, models.AssignRoleRequest{Role: "support"})
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = f.do(http.MethodPut, "/api/v1/admin/users/"+f.ids[models.RoleAdmin]+"/role", admin, models.AssignRoleRequest{Role: models.RoleUser})
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+
+	w = f.do(http.MethodPut, "/api/v1/admin/users/"+customerID+"/role", admin, models.AssignRoleRequest{Role: "support"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored, _ := f.users.GetByID(customerID)
+	assert.Equal(t, "support", stored.Role)
+
+	// The old token no longer works; a refreshed one carries the new scope
+	w = f.do(http.MethodGet, "/api/v1/user/profile", customer.AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	refreshed, err := f.auth.RefreshToken(customer.RefreshToken, models.ClientInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, "profile:read profile:write users:read", tokenScope(t, refreshed.AccessToken))
+	w = f.do(http.MethodGet, "/api/v1/admin/users", refreshed.AccessToken, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = f.do(http.MethodDelete, "/api/v1/admin/roles/support", admin, nil)
+	require.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+	assert.Equal(t, "ROLE_IN_USE", errorCode(t, w))
+}
@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/keystore"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// noMFA never challenges, so logins go straight to session creation.
+type noMFA struct {
+	services.MFAService
+}
+
+func (noMFA) Challenge(*models.User) (*models.MFAChallenge, error) {
+	return nil, nil
+}
+
+type recordingEmitter struct {
+	mu     sync.Mutex
+	events []models.SecurityEvent
+}
+
+func (e *recordingEmitter) Emit(event models.SecurityEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *recordingEmitter) recorded() []models.SecurityEvent {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]models.SecurityEvent(nil), e.events...)
+}
+
+func setupRefresh(t *testing.T) (services.UserService, *fakeSessionRepository, *recordingEmitter, *revocation.MemoryStore, *models.LoginResponse) {
+	t.Helper()
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+
+	users := newFakeUserRepository()
+	sessions := newFakeSessionRepository()
+	events := &recordingEmitter{}
+	revocations := revocation.NewMemoryStore()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{
+		Email:    "refresh@example.com",
+		Username: "refresh",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}))
+
+	service := services.NewUserService(users, sessions, nil, noMFA{}, keystore.NewStaticStore(key), revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), events, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+
+	login, challenge, err := service.Login(&models.LoginRequest{Email: "refresh@example.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+	require.Nil(t, challenge)
+
+	return service, sessions, events, revocations, login
+}
+
+func TestRefreshTokenIsStoredHashed(t *testing.T) {
+	_, sessions, _, _, login := setupRefresh(t)
+
+	stored, err := sessions.GetRefreshTokenByHash(login.RefreshToken)
+	require.NoError(t, err)
+	assert.Nil(t, stored, "raw refresh token must not be persisted")
+	assert.Len(t, sessions.tokens, 1)
+}
+
+func TestTokenLifetimesFollowConfig(t *testing.T) {
+	_, sessions, _, _, login := setupRefresh(t)
+
+	claims := jwt.MapClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(login.AccessToken, claims)
+	require.NoError(t, err)
+	expiresAt, err := claims.GetExpirationTime()
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(testAccessTTL), expiresAt.Time, 2*time.Second)
+
+	for _, token := range sessions.tokens {
+		assert.WithinDuration(t, time.Now().Add(testRefreshTTL), token.ExpiresAt, 2*time.Second)
+	}
+	for _, session := range sessions.sessions {
+		assert.WithinDuration(t, time.Now().Add(testRefreshTTL), session.ExpiresAt, 2*time.Second)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	service, sessions, events, _, login := setupRefresh(t)
+
+	first, err := service.RefreshToken(login.RefreshToken, models.ClientInfo{})
+	require.NoError(t, err)
+	assert.NotEqual(t, login.RefreshToken, first.RefreshToken)
+
+	second, err := service.RefreshToken(first.RefreshToken, models.ClientInfo{})
+	require.NoError(t, err)
+	assert.NotEmpty(t, second.AccessToken)
+
+	assert.Equal(t, 1, sessions.sessionCount(login.User.ID), "rotation stays in the same family")
+	assert.Empty(t, events.recorded())
+}
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	service, _, events, revocations, login := setupRefresh(t)
+
+	rotated, err := service.RefreshToken(login.RefreshToken, models.ClientInfo{})
+	require.NoError(t, err)
+
+	// Replaying the original token signals theft.
+	_, err = service.RefreshToken(login.RefreshToken, models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrRefreshTokenReused)
+
+	recorded := events.recorded()
+	require.Len(t, recorded, 1)
+	assert.Equal(t, services.SecurityEventRefreshTokenReuse, recorded[0].Type)
+	assert.Equal(t, login.User.ID, recorded[0].UserID)
+
+	// The legitimate chain is cut off too, along with its access tokens.
+	_, err = service.RefreshToken(rotated.RefreshToken, models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(rotated.AccessToken, claims)
+	require.NoError(t, err)
+	revoked, err := revocations.IsRevoked(context.Background(), revocation.Token{
+		ID:        claims["jti"].(string),
+		UserID:    login.User.ID,
+		SessionID: claims["sid"].(string),
+		IssuedAt:  time.Now(),
+	})
+	require.NoError(t, err)
+	assert.True(t, revoked, "access tokens of the reused family are revoked")
+}
+
+func TestRefreshTokenUnknown(t *testing.T) {
+	service, _, _, _, _ := setupRefresh(t)
+
+	_, err := service.RefreshToken("not-a-token", models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+}
@@ -0,0 +1,192 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/keystore"
+	"user-service/internal/middleware"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+	"user-service/internal/tokens"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRedisRevocationStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := revocation.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, store.Revoke(ctx, "jti-1", now.Add(time.Hour)))
+
+	revoked, err := store.IsRevoked(ctx, revocation.Token{ID: "jti-1", UserID: "user-1", IssuedAt: now})
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, revocation.Token{ID: "jti-2", UserID: "user-1", IssuedAt: now})
+	require.NoError(t, err)
+	assert.False(t, revoked)
+
+	require.NoError(t, store.RevokeUser(ctx, "user-1", now, time.Hour))
+
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-2", UserID: "user-1", IssuedAt: now.Add(-time.Minute)})
+	assert.True(t, revoked, "tokens issued before the cut-off are revoked")
+
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-3", UserID: "user-1", IssuedAt: now.Add(time.Minute)})
+	assert.False(t, revoked, "tokens issued after the cut-off stay valid")
+
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-3", UserID: "user-1", IssuedAt: now.Add(time.Millisecond)})
+	assert.False(t, revoked, "the cut-off is finer than a second")
+
+	require.NoError(t, store.RevokeSession(ctx, "session-1", time.Hour))
+
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-4", UserID: "user-2", SessionID: "session-1", IssuedAt: now})
+	assert.True(t, revoked, "tokens of a revoked session are revoked")
+
+	require.NoError(t, store.RevokeRole(ctx, "moderator", now, time.Hour))
+
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-5", UserID: "user-3", Role: "moderator", IssuedAt: now})
+	assert.True(t, revoked, "tokens of a role issued before its cut-off are revoked")
+
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-6", UserID: "user-3", Role: "user", IssuedAt: now})
+	assert.False(t, revoked, "other roles keep their tokens")
+
+	// Entries disappear with the tokens they cover.
+	mr.FastForward(2 * time.Hour)
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-1", UserID: "user-1", IssuedAt: now})
+	assert.False(t, revoked)
+}
+
+func TestRedisUserCutoffNeverMovesBack(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := revocation.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()}))
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, store.RevokeUser(ctx, "user-1", now, time.Hour))
+	// A slower request with an older cut-off lands second.
+	require.NoError(t, store.RevokeUser(ctx, "user-1", now.Add(-time.Hour), time.Minute))
+
+	revoked, err := store.IsRevoked(ctx, revocation.Token{ID: "jti-1", UserID: "user-1", IssuedAt: now.Add(-time.Minute)})
+	require.NoError(t, err)
+	assert.True(t, revoked, "the later cut-off still applies")
+
+	mr.FastForward(30 * time.Minute)
+	revoked, _ = store.IsRevoked(ctx, revocation.Token{ID: "jti-1", UserID: "user-1", IssuedAt: now.Add(-time.Minute)})
+	assert.True(t, revoked, "the shorter TTL does not expire the cut-off early")
+}
+
+func TestFallbackStoreSurvivesRedisOutage(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := revocation.NewFallbackStore(
+		revocation.NewRedisStore(redis.NewClient(&redis.Options{Addr: mr.Addr()})),
+		revocation.NewMemoryStore(),
+	)
+	ctx := context.Background()
+
+	mr.Close()
+
+	require.NoError(t, store.Revoke(ctx, "jti-1", time.Now().Add(time.Hour)))
+
+	revoked, err := store.IsRevoked(ctx, revocation.Token{ID: "jti-1", UserID: "user-1", IssuedAt: time.Now()})
+	require.NoError(t, err)
+	assert.True(t, revoked)
+
+	revoked, err = store.IsRevoked(ctx, revocation.Token{ID: "jti-2", UserID: "user-1", IssuedAt: time.Now()})
+	require.NoError(t, err)
+	assert.False(t, revoked)
+}
+
+func setupLogout(t *testing.T) (services.UserService, *gin.Engine, *models.LoginResponse) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+	keys := keystore.NewStaticStore(key)
+	revocations := revocation.NewMemoryStore()
+
+	users := newFakeUserRepository()
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{
+		Email:    "logout@example.com",
+		Username: "logout",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}))
+
+	sessions := newFakeSessionRepository()
+	service := services.NewUserService(users, sessions, nil, noMFA{}, keys, revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwords := services.NewPasswordService(users, newFakePasswordRepository(users, sessions), revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), testAccessTTL)
+
+	auth := middleware.NewAuthMiddleware(tokens.NewValidator(keys, revocations, passwords), seededPermissions{})
+	router := gin.New()
+	router.GET("/me", auth.RequireAuth(), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.POST("/logout", auth.RequireAuth(), func(c *gin.Context) {
+		err := service.Logout(c.GetString("user_id"), c.GetString("session_id"), c.GetString("token_id"), c.GetTime("token_expires_at"))
+		require.NoError(t, err)
+		c.Status(http.StatusNoContent)
+	})
+
+	login, _, err := service.Login(&models.LoginRequest{Email: "logout@example.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+
+	return service, router, login
+}
+
+func authorizedRequest(router *gin.Engine, method, path, token string) int {
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestLogoutRevokesAccessAndRefreshTokens(t *testing.T) {
+	service, router, login := setupLogout(t)
+
+	assert.Equal(t, http.StatusNoContent, authorizedRequest(router, http.MethodGet, "/me", login.AccessToken))
+	assert.Equal(t, http.StatusNoContent, authorizedRequest(router, http.MethodPost, "/logout", login.AccessToken))
+	assert.Equal(t, http.StatusUnauthorized, authorizedRequest(router, http.MethodGet, "/me", login.AccessToken))
+
+	_, err := service.RefreshToken(login.RefreshToken, models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+}
+
+func TestLogoutAllRevokesEverySession(t *testing.T) {
+	service, router, login := setupLogout(t)
+
+	second, _, err := service.Login(&models.LoginRequest{Email: "logout@example.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+
+	require.NoError(t, service.LogoutAll(login.User.ID))
+
+	for _, token := range []string{login.AccessToken, second.AccessToken} {
+		assert.Equal(t, http.StatusUnauthorized, authorizedRequest(router, http.MethodGet, "/me", token))
+	}
+	for _, token := range []string{login.RefreshToken, second.RefreshToken} {
+		_, err := service.RefreshToken(token, models.ClientInfo{})
+		assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+	}
+
+	// Signing in again right away, within the same second, works.
+	again, _, err := service.Login(&models.LoginRequest{Email: "logout@example.com", Password: "password123"}, models.ClientInfo{})
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, authorizedRequest(router, http.MethodGet, "/me", again.AccessToken))
+}
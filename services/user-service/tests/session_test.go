@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"testing"
+
+	"user-service/internal/config"
+	"user-service/internal/keystore"
+	"user-service/internal/models"
+	"user-service/internal/revocation"
+	"user-service/internal/services"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	desktopChrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	iPhoneSafari  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1"
+)
+
+func setupSessions(t *testing.T) (services.UserService, services.SessionService, *revocation.MemoryStore) {
+	t.Helper()
+
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+
+	users := newFakeUserRepository()
+	sessions := newFakeSessionRepository()
+	revocations := revocation.NewMemoryStore()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("password123"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{
+		Email:    "sessions@example.com",
+		Username: "sessions",
+		Password: string(hashed),
+		Role:     "user",
+		IsActive: true,
+	}))
+
+	userService := services.NewUserService(users, sessions, nil, noMFA{}, keystore.NewStaticStore(key), revocations, newTestGuard(), newTestPasswordPolicy(t), newTestHasher(t), &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	return userService, services.NewSessionService(sessions, revocations, testAccessTTL), revocations
+}
+
+func loginFrom(t *testing.T, service services.UserService, client models.ClientInfo) *models.LoginResponse {
+	t.Helper()
+
+	login, _, err := service.Login(&models.LoginRequest{Email: "sessions@example.com", Password: "password123"}, client)
+	require.NoError(t, err)
+	return login
+}
+
+func TestListSessionsDescribesDevices(t *testing.T) {
+	userService, sessionService, _ := setupSessions(t)
+
+	desktop := loginFrom(t, userService, models.ClientInfo{IPAddress: "10.0.0.1", UserAgent: desktopChrome})
+	phone := loginFrom(t, userService, models.ClientInfo{IPAddress: "10.0.0.2", UserAgent: iPhoneSafari})
+
+	// Refreshing from a new network records the new address and bumps last use.
+	_, err := userService.RefreshToken(desktop.RefreshToken, models.ClientInfo{IPAddress: "10.0.0.3", UserAgent: desktopChrome})
+	require.NoError(t, err)
+
+	sessions, err := sessionService.ListSessions(desktop.User.ID, "")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	latest := sessions[0]
+	assert.Equal(t, "10.0.0.3", latest.IPAddress)
+	assert.Equal(t, "desktop", latest.Device)
+	assert.Equal(t, "Chrome 120", latest.Browser)
+	assert.Contains(t, latest.OS, "Windows")
+
+	other := sessions[1]
+	assert.Equal(t, "10.0.0.2", other.IPAddress)
+	assert.Equal(t, "mobile", other.Device)
+	assert.Contains(t, other.Browser, "Safari")
+	assert.Contains(t, other.OS, "17.1")
+
+	assert.NotEmpty(t, phone.AccessToken)
+}
+
+func TestRevokeSessionSignsDeviceOut(t *testing.T) {
+	userService, sessionService, revocations := setupSessions(t)
+
+	kept := loginFrom(t, userService, models.ClientInfo{UserAgent: desktopChrome})
+	revoked := loginFrom(t, userService, models.ClientInfo{UserAgent: iPhoneSafari})
+
+	sessions, err := sessionService.ListSessions(kept.User.ID, "")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+
+	var target string
+	for _, s := range sessions {
+		if s.Device == "mobile" {
+			target = s.ID
+		}
+	}
+	require.NotEmpty(t, target)
+
+	require.NoError(t, sessionService.RevokeSession(kept.User.ID, target))
+
+	_, err = userService.RefreshToken(revoked.RefreshToken, models.ClientInfo{})
+	assert.ErrorIs(t, err, services.ErrInvalidRefreshToken)
+
+	_, err = userService.RefreshToken(kept.RefreshToken, models.ClientInfo{})
+	assert.NoError(t, err)
+
+	isRevoked, err := revocations.IsRevoked(context.Background(), revocation.Token{ID: "any", UserID: kept.User.ID, SessionID: target})
+	require.NoError(t, err)
+	assert.True(t, isRevoked, "access tokens of the revoked session must stop working")
+
+	sessions, err = sessionService.ListSessions(kept.User.ID, "")
+	require.NoError(t, err)
+	assert.Len(t, sessions, 1)
+
+	// Revoking twice, someone else's session or a malformed id looks like a
+	// missing session.
+	assert.ErrorIs(t, sessionService.RevokeSession(kept.User.ID, target), services.ErrSessionNotFound)
+	assert.ErrorIs(t, sessionService.RevokeSession("someone-else", sessions[0].ID), services.ErrSessionNotFound)
+	assert.ErrorIs(t, sessionService.RevokeSession(kept.User.ID, "not-a-uuid"), services.ErrSessionNotFound)
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"user-service/internal/totp"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Test vectors from RFC 6238 appendix B (SHA-1).
+func TestTOTPRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+
+	for _, v := range vectors {
+		step := totp.Step(time.Unix(v.unix, 0))
+		assert.Equal(t, v.code, totp.HOTP(key, uint64(step), 8), "t=%d", v.unix)
+	}
+}
+
+func TestTOTPValidateWithSkew(t *testing.T) {
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+	now := time.Unix(1111111111, 0)
+
+	code, err := totp.GenerateCode(secret, now)
+	require.NoError(t, err)
+	assert.Equal(t, "050471", code)
+
+	step, ok := totp.Validate(secret, code, now)
+	assert.True(t, ok)
+	assert.Equal(t, totp.Step(now), step)
+
+	_, ok = totp.Validate(secret, code, now.Add(totp.Period))
+	assert.True(t, ok, "one step of drift is tolerated")
+
+	_, ok = totp.Validate(secret, code, now.Add(3*totp.Period))
+	assert.False(t, ok)
+
+	_, ok = totp.Validate(secret, "000000", now)
+	assert.False(t, ok)
+}
+
+func TestTOTPKeyURI(t *testing.T) {
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+
+	uri := totp.KeyURI("Stationery", "admin@example.com", secret)
+	require.True(t, strings.HasPrefix(uri, "otpauth://totp/"))
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	assert.Equal(t, secret, parsed.Query().Get("secret"))
+	assert.Equal(t, "Stationery", parsed.Query().Get("issuer"))
+	assert.Equal(t, "6", parsed.Query().Get("digits"))
+}
@@ -6,275 +6,179 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
+
 	"user-service/internal/config"
 	"user-service/internal/handlers"
+	"user-service/internal/health"
+	"user-service/internal/keystore"
 	"user-service/internal/middleware"
 	"user-service/internal/models"
-	"user-service/internal/repository"
+	"user-service/internal/revocation"
 	"user-service/internal/routes"
 	"user-service/internal/services"
+	"user-service/internal/tokens"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
-	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
-// MockUserRepository is a mock implementation of UserRepository
-type MockUserRepository struct {
-	mock.Mock
-}
-
-func (m *MockUserRepository) CreateUser(user *models.User) error {
-	args := m.Called(user)
-	return args.Error(0)
-}
-
-func (m *MockUserRepository) GetUserByEmail(email string) (*models.User, error) {
-	args := m.Called(email)
-	return args.Get(0).(*models.User), args.Error(1)
-}
+const testPassword = "C0rrect-Horse-Battery"
 
-func (m *MockUserRepository) GetUserByID(id string) (*models.User, error) {
-	args := m.Called(id)
-	return args.Get(0).(*models.User), args.Error(1)
-}
+func setupTestRouter(t *testing.T) (*gin.Engine, *fakeUserRepository) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
 
-func (m *MockUserRepository) UpdateUser(user *models.User) error {
-	args := m.Called(user)
-	return args.Error(0)
-}
+	key, err := keystore.Generate(keystore.AlgEdDSA)
+	require.NoError(t, err)
+	keys := keystore.NewStaticStore(key)
+	revocations := revocation.NewMemoryStore()
 
-func (m *MockUserRepository) DeleteUser(id string) error {
-	args := m.Called(id)
-	return args.Error(0)
-}
+	users := newFakeUserRepository()
+	sessions := newFakeSessionRepository()
+	policy := newTestPasswordPolicy(t)
+	hasher := newTestHasher(t)
 
-func (m *MockUserRepository) SaveRefreshToken(userID, tokenHash string, expiresAt int64) error {
-	args := m.Called(userID, tokenHash, expiresAt)
-	return args.Error(0)
-}
+	userService := services.NewUserService(users, sessions, &recordingVerification{}, noMFA{}, keys, revocations, newTestGuard(), policy, hasher, &recordingEmitter{}, seededPermissions{}, config.AuthConfig{}, testAccessTTL, testRefreshTTL)
+	passwordService := services.NewPasswordService(users, newFakePasswordRepository(users, sessions), revocations, newTestGuard(), policy, hasher, testAccessTTL)
+	authMiddleware := middleware.NewAuthMiddleware(tokens.NewValidator(keys, revocations, passwordService), seededPermissions{})
 
-func (m *MockUserRepository) GetRefreshToken(tokenHash string) (*models.RefreshToken, error) {
-	args := m.Called(tokenHash)
-	return args.Get(0).(*models.RefreshToken), args.Error(1)
-}
+	router := gin.New()
+	routes.SetupRoutes(router, routes.Handlers{
+		Health:   handlers.NewHealthHandler(health.NewRegistry(time.Second, 0), func() bool { return true }),
+		User:     handlers.NewUserHandler(userService),
+		Password: handlers.NewPasswordHandler(passwordService),
+		Keys:     handlers.NewKeysHandler(keys),
+	}, authMiddleware, nil)
 
-func (m *MockUserRepository) DeleteRefreshToken(tokenHash string) error {
-	args := m.Called(tokenHash)
-	return args.Error(0)
+	return router, users
 }
 
-func setupTestRouter() (*gin.Engine, *MockUserRepository) {
-	gin.SetMode(gin.TestMode)
-	
-	// Create mock repository
-	mockRepo := &MockUserRepository{}
-	
-	// Create config
-	cfg := &config.Config{
-		JWT: config.JWTConfig{
-			SecretKey:            "test-secret-key",
-			AccessTokenDuration:  15,
-			RefreshTokenDuration: 24,
-		},
-	}
-	
-	// Create services
-	userService := services.NewUserService(mockRepo, cfg)
-	userHandler := handlers.NewUserHandler(userService)
-	authMiddleware := middleware.NewAuthMiddleware(cfg.JWT.SecretKey)
-	
-	// Setup router
-	router := gin.New()
-	routes.SetupRoutes(router, userHandler, authMiddleware)
-	
-	return router, mockRepo
+func postJSON(router *gin.Engine, path string, payload interface{}) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(payload)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
 }
 
 func TestUserRegistration(t *testing.T) {
-	router, mockRepo := setupTestRouter()
-	
-	// Test case: Successful registration
+	router, users := setupTestRouter(t)
+
 	t.Run("Successful Registration", func(t *testing.T) {
-		// Setup mock expectations
-		mockRepo.On("GetUserByEmail", "test@example.com").Return((*models.User)(nil), repository.ErrUserNotFound)
-		mockRepo.On("CreateUser", mock.AnythingOfType("*models.User")).Return(nil)
-		
-		// Create request payload
-		payload := models.CreateUserRequest{
+		w := postJSON(router, "/api/v1/auth/register", models.CreateUserRequest{
 			Email:     "test@example.com",
-			Password:  "password123",
+			Username:  "testuser",
+			Password:  testPassword,
 			FirstName: "Test",
 			LastName:  "User",
 			Phone:     "+1234567890",
+		})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var response struct {
+			Data models.User            `json:"data"`
+			Meta map[string]interface{} `json:"meta"`
 		}
-		
-		jsonPayload, _ := json.Marshal(payload)
-		
-		// Create request
-		req, _ := http.NewRequest("POST", "/api/v1/auth/register", bytes.NewBuffer(jsonPayload))
-		req.Header.Set("Content-Type", "application/json")
-		
-		// Perform request
-		w := httptest.NewRecorder()
-		router.ServeHTTP(w, req)
-		
-		// Assertions
-		assert.Equal(t, http.StatusCreated, w.Code)
-		
-		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		
-		assert.Contains(t, response, "data")
-		assert.Contains(t, response, "meta")
-		
-		mockRepo.AssertExpectations(t)
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "Test", response.Data.FirstName)
+		assert.Equal(t, "User", response.Data.LastName)
+		require.NotNil(t, response.Data.Phone)
+		assert.Equal(t, "+1234567890", *response.Data.Phone)
+		assert.Nil(t, response.Data.AvatarURL)
+		assert.NotContains(t, w.Body.String(), testPassword)
+
+		stored, err := users.GetByEmail("test@example.com")
+		require.NoError(t, err)
+		require.NotNil(t, stored)
+		assert.Equal(t, "testuser", stored.Username)
+		assert.Equal(t, "user", stored.Role)
+		assert.Equal(t, "Test", stored.FirstName)
+		assertPasswordHash(t, stored.Password, testPassword)
 	})
-	
-	// Test case: Email already exists
+
 	t.Run("Email Already Exists", func(t *testing.T) {
-		// Setup mock expectations
-		existingUser := &models.User{
-			ID:    "existing-user-id",
-			Email: "existing@example.com",
-		}
-		mockRepo.On("GetUserByEmail", "existing@example.com").Return(existingUser, nil)
-		
-		// Create request payload
-		payload := models.CreateUserRequest{
-			Email:     "existing@example.com",
-			Password:  "password123",
-			FirstName: "Test",
-			LastName:  "User",
-		}
-		
-		jsonPayload, _ := json.Marshal(payload)
-		
-		// Create request
-		req, _ := http.NewRequest("POST", "/api/v1/auth/register", bytes.NewBuffer(jsonPayload))
-		req.Header.Set("Content-Type", "application/json")
-		
-		// Perform request
-		w := httptest.NewRecorder()
-		router.ServeHTTP(w, req)
-		
-		// Assertions
+		w := postJSON(router, "/api/v1/auth/register", models.CreateUserRequest{
+			Email:    "test@example.com",
+			Username: "another",
+			Password: testPassword,
+		})
 		assert.Equal(t, http.StatusBadRequest, w.Code)
-		
-		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		
-		assert.Contains(t, response, "error")
-		
-		mockRepo.AssertExpectations(t)
+		assert.Equal(t, "REGISTRATION_FAILED", errorCode(t, w))
+	})
+
+	t.Run("Invalid Profile Fields", func(t *testing.T) {
+		w := postJSON(router, "/api/v1/auth/register", models.CreateUserRequest{
+			Email:     "fields@example.com",
+			Username:  "fields",
+			Password:  testPassword,
+			Phone:     "12-34",
+			AvatarURL: "not a url",
+		})
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, "VALIDATION_ERROR", errorCode(t, w))
 	})
 }
 
 func TestUserLogin(t *testing.T) {
-	router, mockRepo := setupTestRouter()
-	
-	// Test case: Successful login
+	router, users := setupTestRouter(t)
+
+	hashed, err := newTestHasher(t).Hash(testPassword)
+	require.NoError(t, err)
+	require.NoError(t, users.Create(&models.User{
+		Email:     "test@example.com",
+		Username:  "testuser",
+		Password:  hashed,
+		Role:      "user",
+		FirstName: "Test",
+		LastName:  "User",
+		IsActive:  true,
+	}))
+
 	t.Run("Successful Login", func(t *testing.T) {
-		// Setup mock user with hashed password
-		hashedPassword := "$2a$10$N9qo8uLOickgx2ZMRZoMye1Jrq/zAG6Q/DKOJcGdFGWBDJpE1Y2.2" // "password123"
-		user := &models.User{
-			ID:           "user-id",
-			Email:        "test@example.com",
-			PasswordHash: hashedPassword,
-			FirstName:    "Test",
-			LastName:     "User",
-			IsActive:     true,
-		}
-		
-		// Setup mock expectations
-		mockRepo.On("GetUserByEmail", "test@example.com").Return(user, nil)
-		mockRepo.On("SaveRefreshToken", "user-id", mock.AnythingOfType("string"), mock.AnythingOfType("int64")).Return(nil)
-		
-		// Create request payload
-		payload := models.LoginRequest{
+		w := postJSON(router, "/api/v1/auth/login", models.LoginRequest{
 			Email:    "test@example.com",
-			Password: "password123",
-		}
-		
-		jsonPayload, _ := json.Marshal(payload)
-		
-		// Create request
-		req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(jsonPayload))
-		req.Header.Set("Content-Type", "application/json")
-		
-		// Perform request
-		w := httptest.NewRecorder()
-		router.ServeHTTP(w, req)
-		
-		// Assertions
-		assert.Equal(t, http.StatusOK, w.Code)
-		
+			Password: testPassword,
+		})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
 		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		
-		assert.Contains(t, response, "data")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+		require.Contains(t, response, "data")
 		data := response["data"].(map[string]interface{})
 		assert.Contains(t, data, "access_token")
 		assert.Contains(t, data, "refresh_token")
 		assert.Contains(t, data, "user")
-		
-		mockRepo.AssertExpectations(t)
 	})
-	
-	// Test case: Invalid credentials
+
 	t.Run("Invalid Credentials", func(t *testing.T) {
-		// Setup mock expectations
-		mockRepo.On("GetUserByEmail", "wrong@example.com").Return((*models.User)(nil), repository.ErrUserNotFound)
-		
-		// Create request payload
-		payload := models.LoginRequest{
+		w := postJSON(router, "/api/v1/auth/login", models.LoginRequest{
 			Email:    "wrong@example.com",
 			Password: "wrongpassword",
-		}
-		
-		jsonPayload, _ := json.Marshal(payload)
-		
-		// Create request
-		req, _ := http.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(jsonPayload))
-		req.Header.Set("Content-Type", "application/json")
-		
-		// Perform request
-		w := httptest.NewRecorder()
-		router.ServeHTTP(w, req)
-		
-		// Assertions
+		})
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
-		
+
 		var response map[string]interface{}
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
 		assert.Contains(t, response, "error")
-		
-		mockRepo.AssertExpectations(t)
 	})
 }
 
 func TestHealthCheck(t *testing.T) {
-	router, _ := setupTestRouter()
-	
-	// Create request
-	req, _ := http.NewRequest("GET", "/health", nil)
-	
-	// Perform request
+	router, _ := setupTestRouter(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
-	
-	// Assertions
+
 	assert.Equal(t, http.StatusOK, w.Code)
-	
+
 	var response map[string]interface{}
-	err := json.Unmarshal(w.Body.Bytes(), &response)
-	assert.NoError(t, err)
-	
-	assert.Equal(t, "ok", response["status"])
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	assert.Equal(t, "healthy", response["status"])
 	assert.Equal(t, "user-service", response["service"])
 }
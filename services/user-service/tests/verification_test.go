@@ -0,0 +1,166 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/handlers"
+	"user-service/internal/mailer"
+	"user-service/internal/models"
+	"user-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var verifyLinkPattern = regexp.MustCompile(`https?://\S+/verify-email\?token=(\S+)`)
+
+func setupVerification(t *testing.T) (services.VerificationService, *fakeUserRepository, *fakeVerificationRepository, *mailer.MemoryMailer, *models.User) {
+	t.Helper()
+
+	users := newFakeUserRepository()
+	tokens := newFakeVerificationRepository(users)
+	mail := mailer.NewMemoryMailer()
+
+	user := &models.User{
+		Email:    "verify@example.com",
+		Username: "verify",
+		Role:     "user",
+		IsActive: true,
+	}
+	require.NoError(t, users.Create(user))
+
+	service := services.NewVerificationService(
+		users,
+		tokens,
+		mail,
+		config.AuthConfig{TokenSigningSecret: "test-secret", VerificationTokenTTL: 48, VerificationResendDelay: 60},
+		config.MailConfig{AppBaseURL: "http://shop.test"},
+	)
+
+	return service, users, tokens, mail, user
+}
+
+func verifyTokenFrom(t *testing.T, msg mailer.Message) string {
+	t.Helper()
+
+	match := verifyLinkPattern.FindStringSubmatch(msg.Body)
+	require.Len(t, match, 2, "verification link not found in email body")
+
+	token, err := url.QueryUnescape(match[1])
+	require.NoError(t, err)
+	return token
+}
+
+func TestVerifyEmailMarksUserVerified(t *testing.T) {
+	service, users, _, mail, user := setupVerification(t)
+
+	require.NoError(t, service.IssueToken(user))
+	sent := mail.SentTo(user.Email)
+	require.Len(t, sent, 1)
+
+	require.NoError(t, service.VerifyEmail(verifyTokenFrom(t, sent[0])))
+
+	stored, _ := users.GetByID(user.ID)
+	assert.True(t, stored.IsVerified)
+}
+
+func TestVerifyEmailTokenIsSingleUse(t *testing.T) {
+	service, _, _, mail, user := setupVerification(t)
+
+	require.NoError(t, service.IssueToken(user))
+	token := verifyTokenFrom(t, mail.SentTo(user.Email)[0])
+
+	require.NoError(t, service.VerifyEmail(token))
+	assert.ErrorIs(t, service.VerifyEmail(token), services.ErrInvalidVerificationToken)
+}
+
+func TestVerifyEmailRejectsExpiredToken(t *testing.T) {
+	service, users, tokens, mail, user := setupVerification(t)
+
+	require.NoError(t, service.IssueToken(user))
+	token := verifyTokenFrom(t, mail.SentTo(user.Email)[0])
+	tokens.backdate(user.ID, 49*time.Hour)
+
+	assert.ErrorIs(t, service.VerifyEmail(token), services.ErrInvalidVerificationToken)
+	stored, _ := users.GetByID(user.ID)
+	assert.False(t, stored.IsVerified)
+}
+
+func TestVerifyEmailRejectsTamperedToken(t *testing.T) {
+	service, _, _, mail, user := setupVerification(t)
+
+	require.NoError(t, service.IssueToken(user))
+	token := verifyTokenFrom(t, mail.SentTo(user.Email)[0])
+
+	assert.ErrorIs(t, service.VerifyEmail(token+"x"), services.ErrInvalidVerificationToken)
+	assert.ErrorIs(t, service.VerifyEmail("not-a-token"), services.ErrInvalidVerificationToken)
+}
+
+func TestResendVerificationIsThrottled(t *testing.T) {
+	service, _, tokens, mail, user := setupVerification(t)
+
+	require.NoError(t, service.IssueToken(user))
+	first := verifyTokenFrom(t, mail.SentTo(user.Email)[0])
+
+	// Inside the resend delay nothing is sent and the first link still works.
+	require.NoError(t, service.ResendVerification(user.Email))
+	require.Len(t, mail.SentTo(user.Email), 1)
+
+	tokens.backdate(user.ID, time.Minute)
+	require.NoError(t, service.ResendVerification(user.Email))
+	sent := mail.SentTo(user.Email)
+	require.Len(t, sent, 2)
+
+	// Only the newest link is valid.
+	assert.ErrorIs(t, service.VerifyEmail(first), services.ErrInvalidVerificationToken)
+	assert.NoError(t, service.VerifyEmail(verifyTokenFrom(t, sent[1])))
+}
+
+func TestResendVerificationIgnoresUnknownAndVerifiedAddresses(t *testing.T) {
+	service, users, _, mail, user := setupVerification(t)
+
+	require.NoError(t, service.ResendVerification("nobody@example.com"))
+	users.modify(user.ID, func(u *models.User) { u.IsVerified = true })
+	require.NoError(t, service.ResendVerification(user.Email))
+
+	assert.Empty(t, mail.Sent())
+}
+
+// failingMailer refuses every message, like an unreachable SMTP server.
+type failingMailer struct{}
+
+func (failingMailer) Send(mailer.Message) error { return errors.New("smtp: connection refused") }
+
+func TestResendVerificationAnswersAlikeWhenMailFails(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	users := newFakeUserRepository()
+	require.NoError(t, users.Create(&models.User{Email: "verify@example.com", Username: "verify", Role: "user", IsActive: true}))
+	service := services.NewVerificationService(users, newFakeVerificationRepository(users), failingMailer{},
+		config.AuthConfig{TokenSigningSecret: "test-secret", VerificationTokenTTL: 48, VerificationResendDelay: 60},
+		config.MailConfig{AppBaseURL: "http://shop.test"})
+
+	router := gin.New()
+	router.POST("/resend-verification", handlers.NewVerificationHandler(service).ResendVerification)
+	resend := func(email string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/resend-verification", bytes.NewBufferString(`{"email":"`+email+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	unknown := resend("nobody@example.com")
+	existing := resend("verify@example.com")
+	assert.Equal(t, http.StatusAccepted, unknown.Code)
+	assert.Equal(t, unknown.Code, existing.Code)
+	assert.Equal(t, unknown.Body.String(), existing.Body.String())
+}
@@ -0,0 +1,404 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"user-service/internal/config"
+	"user-service/internal/events"
+	"user-service/internal/handlers"
+	"user-service/internal/models"
+	"user-service/internal/services"
+	"user-service/internal/webhooks"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWebhooksConfig lets the dispatcher reach httptest servers on loopback.
+var testWebhooksConfig = config.WebhooksConfig{
+	PollInterval:        10,
+	BatchSize:           10,
+	Timeout:             2,
+	MaxAttempts:         3,
+	RetryBaseDelay:      30,
+	RetryMaxDelay:       3600,
+	DisableAfter:        10,
+	Retention:           1,
+	AllowPrivateTargets: true,
+}
+
+// webhookReceiver records the requests a partner endpoint receives and
+// answers with status.
+type webhookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func newWebhookReceiver(t *testing.T, status int) (*webhookReceiver, *httptest.Server) {
+	t.Helper()
+	receiver := &webhookReceiver{status: status}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		receiver.mu.Lock()
+		receiver.requests = append(receiver.requests, r)
+		receiver.bodies = append(receiver.bodies, body)
+		status := receiver.status
+		receiver.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+func createWebhook(t *testing.T, service services.WebhookService, url string, eventTypes ...string) *models.CreatedWebhookSubscription {
+	t.Helper()
+	sub, err := service.CreateSubscription(&models.CreateWebhookRequest{URL: url, Events: eventTypes})
+	require.NoError(t, err)
+	return sub
+}
+
+func TestWebhookSignature(t *testing.T) {
+	body := []byte(`{"eventType":"user.created"}`)
+	sentAt := time.Unix(1700000000, 0)
+
+	header := webhooks.Sign("whsec_test", sentAt, body)
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	assert.Equal(t, "t=1700000000,v1="+hex.EncodeToString(mac.Sum(nil)), header)
+
+	assert.NoError(t, webhooks.Verify("whsec_test", header, body, 5*time.Minute, sentAt.Add(time.Minute)))
+	assert.ErrorIs(t, webhooks.Verify("whsec_other", header, body, 5*time.Minute, sentAt), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify("whsec_test", header, []byte(`{}`), 5*time.Minute, sentAt), webhooks.ErrInvalidSignature)
+	// A captured request replayed later is rejected.
+	assert.ErrorIs(t, webhooks.Verify("whsec_test", header, body, 5*time.Minute, sentAt.Add(time.Hour)), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify("whsec_test", "v1=abc", body, 5*time.Minute, sentAt), webhooks.ErrInvalidSignature)
+}
+
+func TestWebhookDispatcherDeliversSignedEvents(t *testing.T) {
+	repo := newFakeWebhookRepository()
+	service := services.NewWebhookService(repo, testWebhooksConfig)
+	receiver, server := newWebhookReceiver(t, http.StatusNoContent)
+
+	sub := createWebhook(t, service, server.URL+"/hooks", events.UserCreated, events.UserVerified)
+	createWebhook(t, service, server.URL+"/other", events.UserDeleted)
+
+	created := newUserEvent(t, events.UserCreated, "u1")
+	ids := repo.enqueue(t, created)
+	require.Len(t, ids, 1, "only the subscription asking for user.created gets it")
+	assert.Empty(t, repo.enqueue(t, newUserEvent(t, events.UserUpdated, "u1")))
+
+	dispatcher := webhooks.NewDispatcher(repo, testWebhooksConfig)
+	claimed, err := dispatcher.DispatchBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+
+	require.Equal(t, 1, receiver.count())
+	req, body := receiver.requests[0], receiver.bodies[0]
+	assert.Equal(t, "/hooks", req.URL.Path)
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, events.UserCreated, req.Header.Get(webhooks.EventHeader))
+	assert.Equal(t, ids[0], req.Header.Get(webhooks.DeliveryHeader))
+	assert.NoError(t, webhooks.Verify(sub.Secret, req.Header.Get(webhooks.SignatureHeader), body, time.Minute, time.Now()))
+
+	var envelope events.Envelope
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	assert.Equal(t, created.EventID, envelope.EventID)
+	assert.Equal(t, events.UserCreated, envelope.EventType)
+
+	delivered := repo.delivery(ids[0])
+	assert.Equal(t, models.WebhookDeliveryDelivered, delivered.Status)
+	assert.Equal(t, 1, delivered.Attempts)
+	assert.NotNil(t, delivered.DeliveredAt)
+
+	withLog, err := service.GetDelivery(sub.ID, ids[0])
+	require.NoError(t, err)
+	require.Len(t, withLog.AttemptLog, 1)
+	require.NotNil(t, withLog.AttemptLog[0].StatusCode)
+	assert.Equal(t, http.StatusNoContent, *withLog.AttemptLog[0].StatusCode)
+	assert.Nil(t, withLog.AttemptLog[0].Error)
+}
+
+func TestWebhookDispatcherRetriesWithBackoffThenFails(t *testing.T) {
+	repo := newFakeWebhookRepository()
+	service := services.NewWebhookService(repo, testWebhooksConfig)
+	receiver, server := newWebhookReceiver(t, http.StatusInternalServerError)
+	sub := createWebhook(t, service, server.URL, events.UserDeleted)
+
+	id := repo.enqueue(t, newUserEvent(t, events.UserDeleted, "u1"))[0]
+	dispatcher := webhooks.NewDispatcher(repo, testWebhooksConfig)
+	ctx := context.Background()
+
+	_, err := dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	stored := repo.delivery(id)
+	assert.Equal(t, models.WebhookDeliveryPending, stored.Status)
+	assert.Equal(t, 1, stored.Attempts)
+	require.NotNil(t, stored.LastError)
+	assert.Contains(t, *stored.LastError, "500")
+	assert.WithinDuration(t, time.Now().Add(30*time.Second), stored.NextAttemptAt, time.Second)
+
+	// Not due yet.
+	claimed, err := dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+
+	repo.makeDue()
+	_, err = dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), repo.delivery(id).NextAttemptAt, time.Second)
+
+	repo.makeDue()
+	_, err = dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryFailed, repo.delivery(id).Status)
+	assert.Equal(t, 3, repo.delivery(id).Attempts)
+	assert.Equal(t, 3, receiver.count())
+
+	// Failed deliveries are not picked up again.
+	repo.makeDue()
+	claimed, err = dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+
+	withLog, err := service.GetDelivery(sub.ID, id)
+	require.NoError(t, err)
+	assert.Len(t, withLog.AttemptLog, 3)
+
+	// A redirect is a failure, not followed.
+	receiver.setStatus(http.StatusFound)
+	require.NoError(t, service.ReplayDelivery(sub.ID, id))
+	_, err = dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, models.WebhookDeliveryPending, repo.delivery(id).Status)
+	assert.Equal(t, 4, receiver.count())
+}
+
+func TestWebhookSubscriptionDisabledAfterPersistentFailures(t *testing.T) {
+	repo := newFakeWebhookRepository()
+	service := services.NewWebhookService(repo, testWebhooksConfig)
+	receiver, server := newWebhookReceiver(t, http.StatusServiceUnavailable)
+	sub := createWebhook(t, service, server.URL, events.UserCreated)
+
+	cfg := testWebhooksConfig
+	cfg.DisableAfter = 3
+	dispatcher := webhooks.NewDispatcher(repo, cfg)
+	ctx := context.Background()
+
+	repo.enqueue(t, newUserEvent(t, events.UserCreated, "u1"))
+	_, err := dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+
+	// A success in between resets the count.
+	receiver.setStatus(http.StatusOK)
+	repo.makeDue()
+	_, err = dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, repo.subscription(sub.ID).ConsecutiveFailures)
+
+	receiver.setStatus(http.StatusServiceUnavailable)
+	for _, userID := range []string{"u2", "u3", "u4"} {
+		repo.enqueue(t, newUserEvent(t, events.UserCreated, userID))
+	}
+	_, err = dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+
+	disabled := repo.subscription(sub.ID)
+	assert.False(t, disabled.IsActive)
+	assert.Equal(t, 3, disabled.ConsecutiveFailures)
+	require.NotNil(t, disabled.DisabledReason)
+	assert.Contains(t, *disabled.DisabledReason, "3 consecutive failed attempts")
+	assert.NotNil(t, disabled.DisabledAt)
+
+	// Nothing is sent or queued while disabled.
+	sent := receiver.count()
+	assert.Empty(t, repo.enqueue(t, newUserEvent(t, events.UserCreated, "u5")))
+	repo.makeDue()
+	claimed, err := dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, claimed)
+	assert.Equal(t, sent, receiver.count())
+
+	// Re-enabling clears the failure count and resumes pending deliveries.
+	receiver.setStatus(http.StatusOK)
+	active := true
+	enabled, err := service.UpdateSubscription(sub.ID, &models.UpdateWebhookRequest{IsActive: &active})
+	require.NoError(t, err)
+	assert.True(t, enabled.IsActive)
+	assert.Zero(t, enabled.ConsecutiveFailures)
+	assert.Nil(t, enabled.DisabledAt)
+
+	claimed, err = dispatcher.DispatchBatch(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, claimed)
+}
+
+func TestWebhookTargetsMustBePublic(t *testing.T) {
+	service := services.NewWebhookService(newFakeWebhookRepository(), config.WebhooksConfig{})
+
+	for _, url := range []string{
+		"http://partner.example.com/hooks",
+		"https://localhost/hooks",
+		"https://api.localhost/hooks",
+		"https://127.0.0.1:8443/hooks",
+		"https://10.1.2.3/hooks",
+		"https://192.168.0.10/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://100.64.0.1/hooks",
+		"https://[::1]/hooks",
+		"https://[fd00::1]/hooks",
+	} {
+		_, err := service.CreateSubscription(&models.CreateWebhookRequest{URL: url, Events: []string{events.UserCreated}})
+		assert.ErrorIs(t, err, services.ErrInvalidWebhookURL, url)
+	}
+	createWebhook(t, service, "https://partner.example.com/hooks", events.UserCreated)
+	createWebhook(t, service, "https://203.0.113.10/hooks", events.UserCreated)
+
+	// An endpoint that resolves to an internal address, say after DNS
+	// rebinding, is refused when connecting.
+	repo := newFakeWebhookRepository()
+	receiver, server := newWebhookReceiver(t, http.StatusOK)
+	createWebhook(t, services.NewWebhookService(repo, testWebhooksConfig), server.URL, events.UserCreated)
+	id := repo.enqueue(t, newUserEvent(t, events.UserCreated, "u1"))[0]
+
+	cfg := testWebhooksConfig
+	cfg.AllowPrivateTargets = false
+	_, err := webhooks.NewDispatcher(repo, cfg).DispatchBatch(context.Background())
+	require.NoError(t, err)
+
+	assert.Zero(t, receiver.count())
+	stored := repo.delivery(id)
+	assert.Equal(t, models.WebhookDeliveryPending, stored.Status)
+	require.NotNil(t, stored.LastError)
+	assert.Contains(t, *stored.LastError, webhooks.ErrForbiddenTarget.Error())
+}
+
+func TestWebhookAdminEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := newFakeWebhookRepository()
+	service := services.NewWebhookService(repo, config.WebhooksConfig{})
+	webhookHandler := handlers.NewWebhookHandler(service)
+
+	router := gin.New()
+	router.POST("/webhooks", webhookHandler.CreateSubscription)
+	router.GET("/webhooks", webhookHandler.ListSubscriptions)
+	router.GET("/webhooks/:id", webhookHandler.GetSubscription)
+	router.PATCH("/webhooks/:id", webhookHandler.UpdateSubscription)
+	router.DELETE("/webhooks/:id", webhookHandler.DeleteSubscription)
+	router.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+	router.GET("/webhooks/:id/deliveries/:deliveryId", webhookHandler.GetDelivery)
+	router.POST("/webhooks/:id/deliveries/:deliveryId/replay", webhookHandler.ReplayDelivery)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodPost, "/webhooks", `{"url":"https://partner.example.com/hooks","events":["user.created","user.verified","user.created"],"description":"Reseller"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	id := created.Data["id"].(string)
+	assert.True(t, strings.HasPrefix(created.Data["secret"].(string), "whsec_"))
+	assert.Equal(t, []interface{}{"user.created", "user.verified"}, created.Data["events"])
+	assert.Equal(t, true, created.Data["is_active"])
+
+	for _, body := range []string{
+		`{"url":"https://partner.example.com","events":["order.placed"]}`,
+		`{"url":"https://partner.example.com","events":[]}`,
+		`{"url":"ftp://partner.example.com","events":["user.created"]}`,
+		`{"url":"http://partner.example.com","events":["user.created"]}`,
+		`{"url":"https://169.254.169.254/latest/meta-data","events":["user.created"]}`,
+	} {
+		w = do(http.MethodPost, "/webhooks", body)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code, body)
+	}
+
+	// The secret is never shown again.
+	w = do(http.MethodGet, "/webhooks/"+id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "whsec_")
+
+	w = do(http.MethodGet, "/webhooks?limit=10", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data       []models.WebhookSubscription `json:"data"`
+		Pagination models.Pagination            `json:"pagination"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, models.Pagination{Page: 1, Limit: 10, Total: 1, TotalPages: 1}, list.Pagination)
+
+	w = do(http.MethodPatch, "/webhooks/"+id, `{"events":["user.deleted"],"is_active":false}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored := repo.subscription(id)
+	assert.Equal(t, []string{"user.deleted"}, stored.Events)
+	assert.False(t, stored.IsActive)
+	require.NotNil(t, stored.DisabledReason)
+
+	active := true
+	_, err := service.UpdateSubscription(id, &models.UpdateWebhookRequest{IsActive: &active})
+	require.NoError(t, err)
+
+	// A failed delivery can be replayed.
+	deliveryID := repo.enqueue(t, newUserEvent(t, events.UserDeleted, "u1"))[0]
+	_, err = repo.MarkFailed(deliveryID, &models.WebhookAttempt{AttemptedAt: time.Now()}, nil, 10)
+	require.NoError(t, err)
+	require.Equal(t, models.WebhookDeliveryFailed, repo.delivery(deliveryID).Status)
+
+	w = do(http.MethodGet, "/webhooks/"+id+"/deliveries", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), deliveryID)
+
+	w = do(http.MethodPost, "/webhooks/"+id+"/deliveries/"+deliveryID+"/replay", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Equal(t, models.WebhookDeliveryPending, repo.delivery(deliveryID).Status)
+	assert.Zero(t, repo.delivery(deliveryID).Attempts)
+
+	w = do(http.MethodGet, "/webhooks/"+id+"/deliveries/"+deliveryID, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"attempt_log"`)
+
+	w = do(http.MethodPost, "/webhooks/"+id+"/deliveries/not-a-uuid/replay", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "WEBHOOK_DELIVERY_NOT_FOUND")
+
+	w = do(http.MethodDelete, "/webhooks/"+id, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	w = do(http.MethodGet, "/webhooks/"+id, "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "WEBHOOK_NOT_FOUND")
+	w = do(http.MethodPost, "/webhooks/"+id+"/deliveries/"+deliveryID+"/replay", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}